@@ -72,6 +72,9 @@ module "basemachina_bridge" {
   fetch_timeout  = var.fetch_timeout
   port           = var.port
 
+  # Bridgeコンテナイメージのタグ
+  bridge_image_tag = var.bridge_image_tag
+
   # ========================================
   # リソース設定
   # ========================================
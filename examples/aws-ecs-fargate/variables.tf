@@ -72,6 +72,12 @@ variable "port" {
   default     = 8080
 }
 
+variable "bridge_image_tag" {
+  description = "Bridgeコンテナイメージのタグ（例: 'latest', 'v1.0.0'）"
+  type        = string
+  default     = "latest"
+}
+
 # ========================================
 # リソース設定
 # ========================================
@@ -37,6 +37,7 @@ resource "google_compute_global_address" "private_ip_address" {
   prefix_length = 16
   network       = google_compute_network.main.id
   project       = var.project_id
+  labels        = var.labels
 
   # ライフサイクル設定
   # VPC Peering接続が削除されるまで、このアドレスを削除しない
@@ -47,6 +47,12 @@ variable "port" {
   default     = 8080
 }
 
+variable "bridge_image_tag" {
+  description = "Bridge container image tag (e.g. 'latest', 'v1.0.0')"
+  type        = string
+  default     = "latest"
+}
+
 # ========================================
 # リソース設定
 # ========================================
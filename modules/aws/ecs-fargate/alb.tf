@@ -71,4 +71,6 @@ resource "aws_lb_listener" "https" {
     type             = "forward"
     target_group_arn = aws_lb_target_group.bridge.arn
   }
+
+  tags = var.tags
 }
@@ -139,6 +139,19 @@ variable "name_prefix" {
   description = "Prefix for resource names"
   type        = string
   default     = ""
+
+  # ALB名（"<name_prefix>basemachina-bridge"）は32文字まで
+  validation {
+    condition     = length(var.name_prefix) <= 14
+    error_message = "Name prefix must be at most 14 characters (the ALB name \"<name_prefix>basemachina-bridge\" is limited to 32)"
+  }
+
+  # ALB・ターゲットグループ名は英数字とハイフンのみで先頭にハイフン不可、
+  # ALB名は"internal-"、セキュリティグループ名は"sg-"で始まってはならない
+  validation {
+    condition     = can(regex("^([a-zA-Z0-9][a-zA-Z0-9-]*)?$", var.name_prefix)) && !can(regex("^(internal-|sg(-|$))", var.name_prefix))
+    error_message = "Name prefix may only contain letters, digits and hyphens, must not start with a hyphen, and must not start with \"internal-\" or \"sg-\" or be \"sg\""
+  }
 }
 
 # ========================================
@@ -29,6 +29,7 @@ resource "google_compute_global_address" "default" {
   count   = var.domain_name != null ? 1 : 0
   name    = "${var.service_name}-lb-ip"
   project = var.project_id
+  labels  = var.labels
 }
 
 # ========================================
@@ -170,6 +171,7 @@ resource "google_compute_global_forwarding_rule" "https" {
   port_range = "443"
   ip_address = google_compute_global_address.default[0].address
   project    = var.project_id
+  labels     = var.labels
 }
 
 # ========================================
@@ -203,4 +205,5 @@ resource "google_compute_global_forwarding_rule" "http" {
   port_range = "80"
   ip_address = google_compute_global_address.default[0].address
   project    = var.project_id
+  labels     = var.labels
 }
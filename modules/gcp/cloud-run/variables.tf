@@ -17,6 +17,18 @@ variable "service_name" {
   description = "Name of the Cloud Run service"
   type        = string
   default     = "basemachina-bridge"
+
+  # サービスアカウントID（"<service_name>-sa"）は6〜30文字
+  validation {
+    condition     = length(var.service_name) >= 3 && length(var.service_name) <= 27
+    error_message = "Service name must be 3 to 27 characters (the service account ID \"<service_name>-sa\" is limited to 30)"
+  }
+
+  # Cloud Run・Compute Engineのリソース名の形式（RFC 1035）
+  validation {
+    condition     = can(regex("^[a-z]([-a-z0-9]*[a-z0-9])?$", var.service_name))
+    error_message = "Service name must start with a lowercase letter, contain only lowercase letters, digits and hyphens, and not end with a hyphen"
+  }
 }
 
 # ========================================
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/ecsstate"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/probe"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTaskKill stops one running Bridge task and measures how long ECS
+// takes to start a replacement and the ALB takes to mark it healthy. The
+// recovery time is recorded as a metric of the task_kill_recovery phase.
+// With desired_count >= 2 the remaining tasks keep serving, so no probe
+// may fail; with a single task the outage is only recorded.
+func testTaskKill(t *testing.T, rep *report.Report, ecsClient *ecs.ECS, elbv2Client *elbv2.ELBV2, clusterName, serviceName, domainName string) {
+	ctx := context.Background()
+
+	before, err := ecsstate.Describe(ecsClient, clusterName, serviceName)
+	require.NoError(t, err)
+	require.True(t, before.Settled(), "service must be settled before the task kill: %s", before)
+	require.NotEmpty(t, before.TargetGroupArns, "service has no target group")
+	victim := before.Tasks[0]
+	highAvailability := before.Desired >= 2
+
+	phase := rep.Begin("task_kill_recovery")
+	phase.SetMetric("desired_count", float64(before.Desired))
+	stopProber := probe.StartPhase(ctx, phase, domainName, t.Logf)
+
+	t.Logf("Stopping task %s (%s, %s) of %d", victim.Arn, victim.PrivateIP, victim.AvailabilityZone, before.Desired)
+	killedAt := time.Now()
+	_, err = ecsClient.StopTask(&ecs.StopTaskInput{
+		Cluster: aws.String(clusterName),
+		Task:    aws.String(victim.Arn),
+		Reason:  aws.String("terratest chaos: task kill"),
+	})
+	if err != nil {
+		stopProber()
+		phase.Finish(err)
+		require.NoError(t, err, "failed to stop task %s", victim.Arn)
+	}
+
+	// The stopped task leaves the RUNNING list immediately, so the service
+	// is settled again once the replacement is RUNNING.
+	var after *ecsstate.Service
+	opts := poll.Options{
+		Timeout: 10 * time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second, Max: 15 * time.Second, Multiplier: 1.5},
+		Logf:    t.Logf,
+	}
+	err = poll.Until(ctx, opts, func(context.Context) error {
+		s, err := ecsstate.Describe(ecsClient, clusterName, serviceName)
+		if err != nil {
+			return err
+		}
+		after = s
+		for _, arn := range s.TaskArns() {
+			if arn == victim.Arn {
+				return fmt.Errorf("task %s is still running", victim.Arn)
+			}
+		}
+		if !s.Settled() {
+			return fmt.Errorf("replacement not running yet: %s", s)
+		}
+		return nil
+	})
+	if err == nil {
+		phase.SetMetric("replacement_running_seconds", time.Since(killedAt).Seconds())
+		// The stopped task's target is still draining; only the running
+		// tasks have to be healthy.
+		err = ecsstate.WaitIPsHealthy(ctx, elbv2Client, after.TargetGroupArns[0], taskIPs(after.Tasks), opts)
+	}
+	recovery := time.Since(killedAt)
+	res := stopProber()
+	if err != nil {
+		phase.Finish(err)
+		require.NoError(t, err, "service did not recover from the task kill")
+	}
+	phase.SetMetric("recovery_seconds", recovery.Seconds())
+	t.Logf("Recovered from the task kill in %v: %s, tasks %v", recovery.Round(time.Second), after, after.TaskArns())
+
+	if !highAvailability {
+		t.Logf("desired_count is %d; availability during the task kill is not asserted (%d of %d probes failed)", before.Desired, res.Failed(), res.Total)
+		phase.Finish(nil)
+		return
+	}
+	probe.FinishPhase(phase, res, "task kill")
+	assert.Empty(t, res.Failures, "the Bridge should stay available while a task is replaced (desired_count=%d)", before.Desired)
+}
@@ -0,0 +1,265 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/artifacts"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/fakeaws"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestECSFargateValidationOffline runs the validation and diagnosis flow of
+// TestECSFargateModule against the fake AWS API, one subtest per scenario
+// in testdata/scenarios. It needs neither credentials nor a deployment.
+func TestECSFargateValidationOffline(t *testing.T) {
+	tests := []struct {
+		scenario string
+		// phases are the expected outcomes; a missing phase never began
+		phases   map[string]report.Outcome
+		findings []string
+	}{
+		{
+			scenario: "healthy",
+			phases: map[string]report.Outcome{
+				"first_running_task": report.OutcomePassed,
+				"healthy_target":     report.OutcomePassed,
+			},
+		},
+		{
+			scenario: "stuck_pending",
+			phases: map[string]report.Outcome{
+				"first_running_task": report.OutcomeFailed,
+			},
+			findings: []string{
+				"Stopped Reason: CannotPullContainerError",
+				"=== TASK FAILURE DIAGNOSIS ===",
+				"Task Definition: test-fake-basemachina-bridge",
+				"Internet Route (0.0.0.0/0): NAT: nat-0fake000000000001",
+			},
+		},
+		{
+			scenario: "failing_health_checks",
+			phases: map[string]report.Outcome{
+				"first_running_task": report.OutcomePassed,
+				"healthy_target":     report.OutcomeFailed,
+			},
+			findings: []string{
+				"DIAGNOSIS: Health check is receiving unexpected HTTP response code",
+				"Health check path: /health",
+				"✓ ALB security group has access to Bridge",
+				"GET /health 404",
+				"✓ Private subnets HAVE internet access",
+				"internet:443 reachable via nat-0fake000000000001",
+			},
+		},
+		{
+			scenario: "no_nat_route",
+			phases: map[string]report.Outcome{
+				"first_running_task": report.OutcomePassed,
+				"healthy_target":     report.OutcomeFailed,
+			},
+			findings: []string{
+				"DIAGNOSIS: Health check is timing out",
+				"failed to fetch authentication keys",
+				"✗ Private subnets DO NOT have internet access",
+				"rtb-0fakeprivate00001 has no route to the internet (0.0.0.0/0)",
+				"ECR API:443 reachable via vpce-0fakeecrapi00001",
+				"S3:443 reachable via vpce-0fakes300000001",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			sc, err := fakeaws.LoadScenario(filepath.Join("testdata", "scenarios", tt.scenario+".json"))
+			require.NoError(t, err)
+			srv := fakeaws.New(sc)
+			defer srv.Close()
+			sess, err := session.NewSession(srv.Config())
+			require.NoError(t, err)
+
+			rep := report.New("aws-ecs-fargate-offline", sc.Name)
+			bundle := artifacts.New("aws-ecs-fargate-offline", sc.Name)
+			rec := testutil.NewRecorder(t)
+
+			rec.Run(func() {
+				validateBridgeService(rec, rep, bundle, bridgeDeployment{
+					Session:               sess,
+					ECS:                   ecs.New(sess),
+					ELBV2:                 elbv2.New(sess),
+					EC2:                   ec2.New(sess),
+					Region:                sc.Region,
+					ClusterName:           sc.Cluster,
+					ServiceName:           sc.Service,
+					ALBArn:                sc.LoadBalancerArn(),
+					ALBSecurityGroupID:    sc.ALBSecurityGroup,
+					BridgeSecurityGroupID: sc.BridgeSecurityGroup,
+					LogGroupName:          sc.LogGroup,
+					VPCID:                 sc.VPCID,
+					PrivateSubnetIDs:      sc.PrivateSubnets,
+					DesiredCount:          sc.DesiredCount,
+					MaxRetries:            12,
+				})
+			})
+			rep.Close("validation aborted")
+
+			failed := false
+			for name, want := range tt.phases {
+				phase := rep.Phase(name)
+				if assert.NotNil(t, phase, "phase %s", name) {
+					assert.Equal(t, want, phase.Outcome, "phase %s", name)
+				}
+				failed = failed || want == report.OutcomeFailed
+			}
+			for _, name := range []string{"first_running_task", "healthy_target"} {
+				if _, ok := tt.phases[name]; !ok {
+					assert.Nil(t, rep.Phase(name), "phase %s should not begin", name)
+				}
+			}
+			assert.Equal(t, failed, len(rec.Errors()) > 0, "assertion failures: %v", rec.Errors())
+
+			var messages []string
+			for _, f := range bundle.Findings() {
+				messages = append(messages, f.Message)
+			}
+			joined := strings.Join(messages, "\n")
+			for _, want := range tt.findings {
+				assert.Contains(t, joined, want)
+			}
+			if !failed {
+				assert.Empty(t, messages, "a healthy deployment should not produce findings")
+				return
+			}
+
+			// The failure artifacts collect from the same API without errors
+			dir, err := bundle.Collect(t.TempDir(), func(w *artifacts.Writer) {
+				artifacts.CollectECS(w, ecs.New(sess), artifacts.ECSTarget{Cluster: sc.Cluster, Service: sc.Service, LoadBalancerArn: sc.LoadBalancerArn()})
+				artifacts.CollectALB(w, elbv2.New(sess), sc.LoadBalancerArn())
+				artifacts.CollectCloudWatchLogs(w, cloudwatchlogs.New(sess), sc.LogGroup, artifacts.LogOptions{})
+			})
+			require.NoError(t, err)
+			for _, name := range []string{"ecs/service.json", "ecs/task-definition.json", "alb/target-health.json", "findings.json"} {
+				_, err := os.Stat(filepath.Join(dir, name))
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestVPCEndpointsOffline runs the VPC endpoint checks of
+// TestECSFargateModule against the endpoints of the no_nat_route scenario,
+// as deployed and with one thing broken at a time.
+func TestVPCEndpointsOffline(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(sc *fakeaws.Scenario)
+		// problems are expected in the error; none means no error
+		problems []string
+	}{
+		{
+			name:   "deployed",
+			modify: func(*fakeaws.Scenario) {},
+		},
+		{
+			name: "private DNS disabled",
+			modify: func(sc *fakeaws.Scenario) {
+				sc.VPCEndpoints[1].PrivateDNS = false
+			},
+			problems: []string{
+				"ECR Docker registry endpoint vpce-0fakeecrdkr00001 has private DNS disabled",
+				"ECR Docker registry:443 NOT reachable",
+			},
+		},
+		{
+			name: "one private subnet",
+			modify: func(sc *fakeaws.Scenario) {
+				sc.VPCEndpoints[0].SubnetIDs = sc.VPCEndpoints[0].SubnetIDs[:1]
+			},
+			problems: []string{
+				"ECR API endpoint vpce-0fakeecrapi00001 has no network interface in private subnets subnet-0fake00000000000c1",
+			},
+		},
+		{
+			name: "pending",
+			modify: func(sc *fakeaws.Scenario) {
+				sc.VPCEndpoints[2].State = "pending"
+			},
+			problems: []string{
+				"CloudWatch Logs endpoint vpce-0fakelogs000001 is pending, not available",
+				"interface endpoint vpce-0fakelogs000001 for logs is pending, not available",
+			},
+		},
+		{
+			name: "no HTTPS from Bridge",
+			modify: func(sc *fakeaws.Scenario) {
+				for i := range sc.SecurityGroups {
+					if sc.SecurityGroups[i].ID == "sg-0fakeendpoints001" {
+						sc.SecurityGroups[i].Ingress = nil
+					}
+				}
+			},
+			problems: []string{
+				"from subnet-0fake00000000000a1: ECR API:443 NOT reachable: no security group of endpoint vpce-0fakeecrapi00001",
+				"from subnet-0fake00000000000c1: CloudWatch Logs:443 NOT reachable",
+			},
+		},
+		{
+			name: "S3 not attached",
+			modify: func(sc *fakeaws.Scenario) {
+				sc.VPCEndpoints[3].RouteTableIDs = nil
+			},
+			problems: []string{
+				"S3 endpoint vpce-0fakes300000001 is not attached to private route tables rtb-0fakeprivate00001",
+				"S3:443 NOT reachable: rtb-0fakeprivate00001 has no route to the internet (0.0.0.0/0)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sc, err := fakeaws.LoadScenario(filepath.Join("testdata", "scenarios", "no_nat_route.json"))
+			require.NoError(t, err)
+			tt.modify(sc)
+			srv := fakeaws.New(sc)
+			defer srv.Close()
+			sess, err := session.NewSession(srv.Config())
+			require.NoError(t, err)
+
+			err = verifyVPCEndpoints(t, ec2.New(sess), vpcEndpointWiring{
+				VPCID:                 sc.VPCID,
+				PrivateSubnetIDs:      sc.PrivateSubnets,
+				BridgeSecurityGroupID: sc.BridgeSecurityGroup,
+				SecurityGroupID:       "sg-0fakeendpoints001",
+				ECRAPIID:              "vpce-0fakeecrapi00001",
+				ECRDockerID:           "vpce-0fakeecrdkr00001",
+				LogsID:                "vpce-0fakelogs000001",
+				S3ID:                  "vpce-0fakes300000001",
+			})
+			if len(tt.problems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.problems {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
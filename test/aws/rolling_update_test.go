@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/ecsstate"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/probe"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rollingUpdateVars returns the variables changed by the rolling update
+// test. Each of them produces a new task definition revision.
+// TEST_ROLLING_UPDATE_IMAGE_TAG additionally switches bridge_image_tag; it
+// is opt-in because only published tags can be pulled.
+func rollingUpdateVars() map[string]interface{} {
+	vars := map[string]interface{}{
+		"fetch_interval": "30m",
+		"cpu":            512,
+		"memory":         1024,
+	}
+	if tag := os.Getenv("TEST_ROLLING_UPDATE_IMAGE_TAG"); tag != "" {
+		vars["bridge_image_tag"] = tag
+	}
+	return vars
+}
+
+// testRollingUpdate re-applies the stack with changed variables while a
+// background prober requests https://<domain>/ok every second. It asserts
+// that no probe failed, that the service runs only tasks of the new task
+// definition revision and that the old tasks were stopped and deregistered
+// from the target group.
+//
+// terraformOptions.Vars is updated in place so that later phases and
+// destroy see the applied configuration.
+func testRollingUpdate(t *testing.T, rep *report.Report, terraformOptions *terraform.Options, ecsClient *ecs.ECS, elbv2Client *elbv2.ELBV2, clusterName, serviceName, domainName string) {
+	ctx := context.Background()
+
+	before, err := ecsstate.Describe(ecsClient, clusterName, serviceName)
+	require.NoError(t, err)
+	require.True(t, before.Settled(), "service must be settled before the rolling update: %s", before)
+	require.NotEmpty(t, before.TargetGroupArns, "service has no target group")
+	t.Logf("Before rolling update: %s, tasks %v", before, before.TaskArns())
+
+	phase := rep.Begin("rolling_update")
+	stopProber := probe.StartPhase(ctx, phase, domainName, t.Logf)
+
+	for name, value := range rollingUpdateVars() {
+		t.Logf("Rolling update: %s %v -> %v", name, terraformOptions.Vars[name], value)
+		terraformOptions.Vars[name] = value
+	}
+
+	applyPhase := rep.Begin("rolling_update_apply")
+	_, err = terraform.ApplyE(t, terraformOptions)
+	applyPhase.Finish(err)
+	if err != nil {
+		stopProber()
+		phase.Finish(err)
+		require.NoError(t, err, "terraform apply for the rolling update failed")
+	}
+
+	// ECS starts the new tasks before stopping the old ones (minimum healthy
+	// percent 100), so a settled service means the rollout completed.
+	after, err := ecsstate.WaitSettled(ctx, ecsClient, clusterName, serviceName, poll.Options{
+		Timeout: 15 * time.Minute,
+		Backoff: poll.Backoff{Initial: 10 * time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+		Logf:    t.Logf,
+	})
+	if err == nil {
+		// Old targets stay registered in draining state for the target
+		// group's deregistration delay.
+		err = ecsstate.WaitTargetsHealthy(ctx, elbv2Client, after.TargetGroupArns[0], taskIPs(after.Tasks), poll.Options{
+			Timeout: 10 * time.Minute,
+			Backoff: poll.Backoff{Initial: 10 * time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+			Logf:    t.Logf,
+		})
+	}
+	res := stopProber()
+	if err != nil {
+		phase.Finish(err)
+		require.NoError(t, err, "rolling update did not complete")
+	}
+	probe.FinishPhase(phase, res, "rolling update")
+	t.Logf("After rolling update: %s, tasks %v", after, after.TaskArns())
+
+	assert.NotEqual(t, before.TaskDefinition, after.TaskDefinition, "a new task definition revision should be deployed")
+	for _, task := range after.Tasks {
+		assert.Equal(t, after.TaskDefinition, task.TaskDefinitionArn, "task %s runs an old revision", task.Arn)
+	}
+
+	old, err := ecsstate.DescribeTasks(ecsClient, clusterName, before.TaskArns())
+	require.NoError(t, err)
+	for _, task := range old {
+		assert.Equal(t, ecs.DesiredStatusStopped, task.LastStatus, "old task %s should be stopped", task.Arn)
+	}
+
+	assert.Empty(t, res.Failures, "the Bridge should stay available during the rolling update")
+}
+
+func taskIPs(tasks []ecsstate.Task) []string {
+	ips := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ips = append(ips, task.PrivateIP)
+	}
+	return ips
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/ecsstate"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/probe"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scaleOutCount returns the desired_count used for scale-out:
+// TEST_SCALE_OUT_COUNT if set, otherwise one task per private subnet, and
+// always more tasks than currently desired.
+func scaleOutCount(t *testing.T, current, subnets int) int {
+	if val := os.Getenv("TEST_SCALE_OUT_COUNT"); val != "" {
+		n, err := strconv.Atoi(val)
+		require.NoError(t, err, "TEST_SCALE_OUT_COUNT must be a number")
+		require.Greater(t, n, current, "TEST_SCALE_OUT_COUNT must be greater than desired_count")
+		return n
+	}
+	return max(subnets, current+1, 2)
+}
+
+// testScaling changes desired_count from its current value to N and back
+// while a background prober requests https://<domain>/ok. After scale-out
+// it asserts that the tasks are balanced across the AZs of the private
+// subnets and that all of them are healthy targets; after scale-in it
+// asserts that the removed tasks were deregistered. No probe may fail in
+// either direction. whileScaledOut, if not nil, runs between the two.
+func testScaling(t *testing.T, rep *report.Report, terraformOptions *terraform.Options, ecsClient *ecs.ECS, elbv2Client *elbv2.ELBV2, ec2Client *ec2.EC2, clusterName, serviceName, domainName string, privateSubnetIDs []string, whileScaledOut func(t *testing.T)) {
+	subnetAZs, err := ecsstate.SubnetAZs(ec2Client, privateSubnetIDs)
+	require.NoError(t, err)
+	t.Logf("Private subnet AZs: %v", subnetAZs)
+
+	initial := terraformOptions.Vars["desired_count"].(int)
+	target := scaleOutCount(t, initial, len(privateSubnetIDs))
+
+	t.Run("ScaleOut", func(t *testing.T) {
+		tasks := scaleTo(t, rep.Begin("scale_out"), terraformOptions, ecsClient, elbv2Client, clusterName, serviceName, domainName, target)
+		t.Logf("Tasks per AZ after scale-out: %v", ecsstate.AZCounts(tasks))
+		assert.NoError(t, ecsstate.CheckSpread(tasks, subnetAZs), "tasks should be spread across the private subnets' AZs")
+	})
+	if whileScaledOut != nil {
+		whileScaledOut(t)
+	}
+	t.Run("ScaleIn", func(t *testing.T) {
+		tasks := scaleTo(t, rep.Begin("scale_in"), terraformOptions, ecsClient, elbv2Client, clusterName, serviceName, domainName, initial)
+		assert.Len(t, tasks, initial)
+	})
+}
+
+// scaleTo applies desired_count=count, waits until exactly count tasks run
+// and are the only healthy targets, and returns the running tasks.
+func scaleTo(t *testing.T, phase *report.Phase, terraformOptions *terraform.Options, ecsClient *ecs.ECS, elbv2Client *elbv2.ELBV2, clusterName, serviceName, domainName string, count int) []ecsstate.Task {
+	ctx := context.Background()
+	phase.SetMetric("desired_count", float64(count))
+	stopProber := probe.StartPhase(ctx, phase, domainName, t.Logf)
+
+	t.Logf("Scaling %s: desired_count %v -> %d", serviceName, terraformOptions.Vars["desired_count"], count)
+	terraformOptions.Vars["desired_count"] = count
+
+	_, err := terraform.ApplyE(t, terraformOptions)
+	var state *ecsstate.Service
+	if err == nil {
+		state, err = ecsstate.WaitSettled(ctx, ecsClient, clusterName, serviceName, poll.Options{
+			Timeout: 10 * time.Minute,
+			Backoff: poll.Backoff{Initial: 10 * time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+			Logf:    t.Logf,
+		})
+	}
+	if err == nil && len(state.TargetGroupArns) == 0 {
+		err = fmt.Errorf("service %s has no target group", serviceName)
+	}
+	if err == nil {
+		// On scale-in the removed tasks' targets drain for the target
+		// group's deregistration delay before they disappear.
+		err = ecsstate.WaitTargetsHealthy(ctx, elbv2Client, state.TargetGroupArns[0], taskIPs(state.Tasks), poll.Options{
+			Timeout: 10 * time.Minute,
+			Backoff: poll.Backoff{Initial: 10 * time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+			Logf:    t.Logf,
+		})
+	}
+	res := stopProber()
+	if err != nil {
+		phase.Finish(err)
+		require.NoError(t, err, "scaling to %d task(s) did not complete", count)
+	}
+	probe.FinishPhase(phase, res, fmt.Sprintf("scaling to %d task(s)", count))
+
+	t.Logf("Scaled to %s, tasks %v", state, state.TaskArns())
+	assert.Empty(t, res.Failures, "the Bridge should stay available while scaling to %d task(s)", count)
+	return state.Tasks
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// TestTagPropagationECSFargateModule plans examples/aws-ecs-fargate with a
+// sentinel tag set and checks that every resource whose schema has tags
+// receives it. Nothing is applied.
+func TestTagPropagationECSFargateModule(t *testing.T) {
+	t.Parallel()
+
+	awsRegion := os.Getenv("AWS_DEFAULT_REGION")
+	if awsRegion == "" {
+		awsRegion = "ap-northeast-1"
+	}
+
+	uniqueID := strings.ToLower(random.UniqueId())
+	sentinel := map[string]string{
+		"TagPropagationTest": uniqueID,
+		"CostCenter":         "tag-propagation",
+	}
+
+	rep := report.ForTest(t, "aws-ecs-fargate-tags", uniqueID)
+	rep.SetLabel("region", awsRegion)
+
+	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "../../examples/aws-ecs-fargate",
+		Vars: map[string]interface{}{
+			"name_prefix":        fmt.Sprintf("test-%s", uniqueID),
+			"vpc_id":             mustGetenv(t, "TEST_VPC_ID"),
+			"private_subnet_ids": getenvSlice(t, "TEST_PRIVATE_SUBNET_IDS"),
+			"public_subnet_ids":  getenvSlice(t, "TEST_PUBLIC_SUBNET_IDS"),
+			"tenant_id":          mustGetenv(t, "TEST_TENANT_ID"),
+			"bridge_domain_name": mustGetenv(t, "TEST_BRIDGE_DOMAIN_NAME"),
+			"route53_zone_id":    mustGetenv(t, "TEST_ROUTE53_ZONE_ID"),
+			"tags":               sentinel,
+		},
+		EnvVars: map[string]string{
+			"AWS_ACCESS_KEY_ID":        mustGetenv(t, "AWS_ACCESS_KEY_ID"),
+			"AWS_SECRET_ACCESS_KEY":    mustGetenv(t, "AWS_SECRET_ACCESS_KEY"),
+			"AWS_DEFAULT_REGION":       awsRegion,
+			"AWS_DISABLE_EC2_METADATA": "true",
+		},
+	})
+
+	tfplan.CheckPropagation(t, rep, terraformOptions, "tags", sentinel)
+}
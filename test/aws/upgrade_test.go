@@ -0,0 +1,137 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/preflight"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/upgrade"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protectedResources must survive a module upgrade: replacing the ALB or the
+// certificate changes what the customer's DNS and clients point at, and
+// replacing the NAT EIP changes the source IP allow-listed by BaseMachina.
+var protectedResources = []string{
+	"module.basemachina_bridge.aws_lb.main",
+	"module.basemachina_bridge.aws_eip.nat",
+	"aws_acm_certificate.bridge",
+}
+
+// TestUpgradeECSFargateModule applies examples/aws-ecs-fargate with the
+// modules of the previous release, then plans the working tree against the
+// resulting state and fails if any protected resource would be destroyed
+// or replaced. It shares the VPC, the pull-through cache rule and the DNS
+// record with TestECSFargateModule, so it only runs with TEST_UPGRADE=true
+// and never in parallel.
+func TestUpgradeECSFargateModule(t *testing.T) {
+	repoDir, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	fromRef := upgrade.FromRefForTest(t, repoDir, "modules/aws/ecs-fargate")
+
+	awsRegion := os.Getenv("AWS_DEFAULT_REGION")
+	if awsRegion == "" {
+		awsRegion = "ap-northeast-1"
+	}
+
+	uniqueID := strings.ToLower(random.UniqueId())
+	namePrefix := fmt.Sprintf("test-%s", uniqueID)
+
+	rep := report.ForTest(t, "aws-ecs-fargate-upgrade", uniqueID)
+	rep.SetLabel("region", awsRegion)
+	rep.SetLabel("from_ref", fromRef)
+
+	vpcID := mustGetenv(t, "TEST_VPC_ID")
+	bridgeDomainName := mustGetenv(t, "TEST_BRIDGE_DOMAIN_NAME")
+	route53ZoneID := mustGetenv(t, "TEST_ROUTE53_ZONE_ID")
+	tfVars := map[string]interface{}{
+		"name_prefix":                  namePrefix,
+		"vpc_id":                       vpcID,
+		"private_subnet_ids":           getenvSlice(t, "TEST_PRIVATE_SUBNET_IDS"),
+		"public_subnet_ids":            getenvSlice(t, "TEST_PUBLIC_SUBNET_IDS"),
+		"tenant_id":                    mustGetenv(t, "TEST_TENANT_ID"),
+		"desired_count":                1,
+		"bridge_domain_name":           bridgeDomainName,
+		"route53_zone_id":              route53ZoneID,
+		"additional_alb_ingress_cidrs": []string{"0.0.0.0/0"},
+	}
+	envVars := map[string]string{
+		"AWS_ACCESS_KEY_ID":        mustGetenv(t, "AWS_ACCESS_KEY_ID"),
+		"AWS_SECRET_ACCESS_KEY":    mustGetenv(t, "AWS_SECRET_ACCESS_KEY"),
+		"AWS_DEFAULT_REGION":       awsRegion,
+		"AWS_DISABLE_EC2_METADATA": "true",
+	}
+
+	ws, err := upgrade.NewWorkspace(repoDir, fromRef, t.TempDir(), "examples/aws-ecs-fargate", "modules/aws/ecs-fargate")
+	require.NoError(t, err)
+	t.Logf("Upgrading from %s: previous tree %s, working tree copy %s", fromRef, ws.Previous, ws.Current)
+
+	previousVars, dropped, err := upgrade.FilterVars(tfVars, ws.Previous)
+	require.NoError(t, err)
+	if len(dropped) > 0 {
+		t.Logf("Variables not declared at %s, not passed to its apply: %v", fromRef, dropped)
+	}
+
+	previousOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: ws.Previous,
+		Vars:         previousVars,
+		EnvVars:      envVars,
+	})
+	currentOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: ws.Current,
+		Vars:         tfVars,
+		EnvVars:      envVars,
+	})
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(awsRegion)})
+	require.NoError(t, err)
+	verifyRoute53Zone(t, route53ZoneID, bridgeDomainName)
+	checkNetwork(t, rep, sess, preflight.Input{
+		VPCID:            vpcID,
+		PrivateSubnetIDs: getenvSlice(t, "TEST_PRIVATE_SUBNET_IDS"),
+		PublicSubnetIDs:  getenvSlice(t, "TEST_PUBLIC_SUBNET_IDS"),
+	})
+
+	// The state moves to the working tree copy before the upgrade plan;
+	// destroy from wherever it is at the end.
+	stateOptions := previousOptions
+	defer func() { destroyAWS(t, rep, stateOptions) }()
+
+	applyPhase := rep.Begin("previous_release_apply")
+	_, err = terraform.InitAndApplyE(t, previousOptions)
+	applyPhase.Finish(err)
+	require.NoError(t, err, "terraform apply of %s failed", fromRef)
+
+	require.NoError(t, ws.MoveState())
+	stateOptions = currentOptions
+
+	planPhase := rep.Begin("upgrade_plan")
+	_, err = terraform.InitE(t, currentOptions)
+	var result *tfplan.Result
+	if err == nil {
+		result, err = tfplan.Run(t, currentOptions)
+	}
+	if err != nil {
+		planPhase.Finish(err)
+		require.NoError(t, err, "terraform plan of the working tree failed")
+	}
+	changes := result.Changes()
+	if len(changes) > 0 {
+		t.Logf("Upgrading from %s changes %d resource(s):\n%s", fromRef, len(changes), tfplan.Describe(changes))
+	}
+	err = upgrade.Check(fromRef, changes, protectedResources)
+	planPhase.SetMetric("changes", float64(len(changes)))
+	planPhase.SetMetric("protected_destroys", float64(len(upgrade.Protected(changes, protectedResources))))
+	planPhase.Finish(err)
+	assert.NoError(t, err, "the upgrade must not destroy protected resources")
+}
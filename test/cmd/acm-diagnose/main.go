@@ -0,0 +1,98 @@
+// Command acm-diagnose explains why the ACM certificate for the Bridge
+// domain is not issued: it checks that the hosted zone contains the
+// domain, that the validation CNAME is in the zone and is answered by the
+// authoritative nameservers the public delegation points to, and that the
+// CAA records of the domain allow Amazon to issue.
+//
+//	go run ./cmd/acm-diagnose -domain bridge.example.com -zone-id Z1234567890ABC
+//	go run ./cmd/acm-diagnose -domain bridge.example.com -zone-id Z1234567890ABC -wait 15m
+//
+// It exits with status 1 when the certificate is not issued.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/acmcert"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+func main() {
+	var (
+		domain = flag.String("domain", os.Getenv("TEST_BRIDGE_DOMAIN_NAME"), "domain name of the certificate (default: $TEST_BRIDGE_DOMAIN_NAME)")
+		zoneID = flag.String("zone-id", os.Getenv("TEST_ROUTE53_ZONE_ID"), "Route 53 hosted zone ID the certificate is validated through (default: $TEST_ROUTE53_ZONE_ID)")
+		region = flag.String("region", "", "region of the certificate (default: $AWS_DEFAULT_REGION or ap-northeast-1)")
+		arn    = flag.String("arn", "", "certificate ARN (default: the newest certificate for -domain)")
+		wait   = flag.Duration("wait", 0, "keep checking until the certificate is issued or this much time has passed")
+	)
+	flag.Parse()
+
+	if *domain == "" || *zoneID == "" {
+		log.Fatal("-domain and -zone-id are required")
+	}
+	if *region == "" {
+		*region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if *region == "" {
+		*region = "ap-northeast-1"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(*region)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracker := &acmcert.Tracker{
+		ACM:        acm.New(sess),
+		Route53:    route53.New(sess),
+		ZoneID:     *zoneID,
+		DomainName: *domain,
+	}
+
+	if *arn == "" {
+		*arn, err = tracker.Find(ctx, time.Time{})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var status *acmcert.Status
+	if *wait > 0 {
+		status, err = tracker.WaitCertificate(ctx, *arn, poll.Options{
+			Timeout: *wait,
+			Backoff: poll.Backoff{Initial: 15 * time.Second, Max: time.Minute, Multiplier: 1.5},
+			Logf: func(format string, args ...any) {
+				fmt.Fprintf(os.Stderr, format+"\n", args...)
+			},
+		})
+		if status == nil {
+			log.Fatal(err)
+		}
+	} else {
+		status, err = tracker.Diagnose(ctx, *arn)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("Certificate: %s\n", status.CertificateArn)
+	fmt.Printf("Status:      %s\n", status.Status)
+	fmt.Printf("Created:     %s\n", status.CreatedAt.Format(time.RFC3339))
+	if !status.Issued() {
+		fmt.Printf("Reason:      %s\n", status.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("Issued:      %s (%v after creation)\n", status.IssuedAt.Format(time.RFC3339), status.IssuedAt.Sub(status.CreatedAt))
+}
@@ -0,0 +1,88 @@
+// Command aws-preflight checks that an existing VPC can take the AWS ECS
+// Fargate module before it is applied: that vpc_id exists, that the
+// private and public subnets are in it across two availability zones, that
+// the public subnets route to an internet gateway, that no S3 gateway
+// endpoint or pull-through cache rule the module would conflict with
+// exists, and that the Elastic IP and NAT gateway quotas allow one more.
+//
+//	go run ./cmd/aws-preflight -vpc-id vpc-0123 -private-subnet-ids subnet-a,subnet-c -public-subnet-ids subnet-d,subnet-e
+//
+// The flags default to the TEST_* environment variables of
+// TestECSFargateModule. It exits with status 1 when a check fails.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/preflight"
+)
+
+func main() {
+	var (
+		vpcID          = flag.String("vpc-id", os.Getenv("TEST_VPC_ID"), "vpc_id of the module (default: $TEST_VPC_ID)")
+		privateSubnets = flag.String("private-subnet-ids", os.Getenv("TEST_PRIVATE_SUBNET_IDS"), "comma-separated private_subnet_ids (default: $TEST_PRIVATE_SUBNET_IDS)")
+		publicSubnets  = flag.String("public-subnet-ids", os.Getenv("TEST_PUBLIC_SUBNET_IDS"), "comma-separated public_subnet_ids (default: $TEST_PUBLIC_SUBNET_IDS)")
+		natGatewayID   = flag.String("nat-gateway-id", "", "nat_gateway_id of the module, if an existing NAT gateway is used")
+		region         = flag.String("region", "", "region (default: $AWS_DEFAULT_REGION or ap-northeast-1)")
+	)
+	flag.Parse()
+
+	if *vpcID == "" || *privateSubnets == "" || *publicSubnets == "" {
+		log.Fatal("-vpc-id, -private-subnet-ids and -public-subnet-ids are required")
+	}
+	if *region == "" {
+		*region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if *region == "" {
+		*region = "ap-northeast-1"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(*region)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	checker := &preflight.Checker{
+		EC2:           ec2.New(sess),
+		ECR:           ecr.New(sess),
+		ServiceQuotas: servicequotas.New(sess),
+	}
+	result, err := checker.Run(ctx, preflight.Input{
+		VPCID:            *vpcID,
+		PrivateSubnetIDs: splitList(*privateSubnets),
+		PublicSubnetIDs:  splitList(*publicSubnets),
+		NATGatewayID:     *natGatewayID,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range result.Checks {
+		fmt.Println(c)
+	}
+	if result.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+// Command bridge-parity compares the Bridge container configuration of the
+// AWS and GCP modules, read from the JSON of a plan of each example, and
+// reports the settings in which the two deployments differ: image, port,
+// environment and the defaults and sensitivity of the Bridge variables.
+//
+//	(cd examples/aws-ecs-fargate && terraform plan -out tfplan && terraform show -json tfplan > /tmp/aws-plan.json)
+//	(cd examples/gcp-cloud-run && terraform plan -out tfplan && terraform show -json tfplan > /tmp/gcp-plan.json)
+//	go run ./cmd/bridge-parity -aws /tmp/aws-plan.json -gcp /tmp/gcp-plan.json -accept image.registry
+//
+// It exits with status 1 if there are gaps that are not accepted with
+// -accept. Values of environment variables set from sensitive variables are
+// never printed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/parity"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+)
+
+type gap struct {
+	parity.Gap
+	Accepted bool `json:"accepted"`
+}
+
+type report struct {
+	AWS  *parity.Bridge `json:"aws"`
+	GCP  *parity.Bridge `json:"gcp"`
+	Gaps []gap          `json:"gaps"`
+}
+
+func main() {
+	var (
+		awsPlan = flag.String("aws", "", "JSON plan of examples/aws-ecs-fargate (terraform show -json)")
+		gcpPlan = flag.String("gcp", "", "JSON plan of examples/gcp-cloud-run (terraform show -json)")
+		accept  = flag.String("accept", "", "comma separated gap keys that are intentional (e.g. image.registry)")
+		jsonOut = flag.Bool("json", false, "write the report as JSON to stdout")
+	)
+	flag.Parse()
+
+	if *awsPlan == "" || *gcpPlan == "" {
+		log.Fatal("-aws and -gcp are required")
+	}
+
+	aws, err := parity.FromECS(readPlan(*awsPlan))
+	if err != nil {
+		log.Fatalf("%s: %v", *awsPlan, err)
+	}
+	gcp, err := parity.FromCloudRun(readPlan(*gcpPlan))
+	if err != nil {
+		log.Fatalf("%s: %v", *gcpPlan, err)
+	}
+
+	accepted := splitList(*accept)
+	gaps := parity.Compare(aws, gcp)
+	unaccepted := map[string]bool{}
+	for _, g := range parity.Unaccepted(gaps, accepted) {
+		unaccepted[g.Key] = true
+	}
+	rep := report{AWS: parity.Redacted(aws, gcp), GCP: parity.Redacted(gcp, aws), Gaps: []gap{}}
+	for _, g := range gaps {
+		rep.Gaps = append(rep.Gaps, gap{Gap: g, Accepted: !unaccepted[g.Key]})
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rep)
+	} else {
+		err = writeText(rep)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(unaccepted) > 0 {
+		fmt.Fprintf(os.Stderr, "%d parity gap(s) between the AWS and GCP modules\n", len(unaccepted))
+		os.Exit(1)
+	}
+}
+
+func readPlan(path string) *tfplan.Plan {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plan, err := tfplan.Parse(data)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return plan
+}
+
+func writeText(rep report) error {
+	fmt.Printf("aws: %s (%s)\n", rep.AWS.Image, rep.AWS.Address)
+	fmt.Printf("gcp: %s (%s)\n\n", rep.GCP.Image, rep.GCP.Address)
+	if len(rep.Gaps) == 0 {
+		fmt.Println("No parity gaps.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tAWS\tGCP\t")
+	for _, g := range rep.Gaps {
+		mark := ""
+		if g.Accepted {
+			mark = "(accepted)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", g.Key, g.AWS, g.GCP, mark)
+	}
+	return w.Flush()
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+// Command gcp-cleanup force-deletes the GCP resources left behind by
+// examples/gcp-cloud-run when terraform destroy fails:
+//
+//	go run ./cmd/gcp-cleanup -project my-gcp-project -prefix bridge-test-abc123 -dry-run
+//	go run ./cmd/gcp-cleanup -project my-gcp-project -prefix bridge-test-abc123 -yes
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/gcpcleanup"
+)
+
+func main() {
+	var (
+		project  = flag.String("project", os.Getenv("TEST_GCP_PROJECT_ID"), "GCP project ID (default: $TEST_GCP_PROJECT_ID)")
+		prefix   = flag.String("prefix", "basemachina-bridge-example", "service name prefix of the resources to delete")
+		regions  = flag.String("regions", strings.Join(gcpcleanup.DefaultRegions, ","), "comma separated regions to search")
+		quick    = flag.Bool("quick", false, "only delete the VPC network and what blocks its deletion, like the former quick-cleanup.sh (default: everything, like the former cleanup.sh)")
+		dryRun   = flag.Bool("dry-run", false, "list what would be deleted without deleting anything")
+		jsonOut  = flag.Bool("json", false, "write the report as JSON to stdout")
+		yes      = flag.Bool("yes", false, "do not ask for confirmation")
+		ipv4Wait = flag.Duration("serverless-ipv4-timeout", 15*time.Minute, "how long to wait for Cloud Run to release serverless-ipv4 addresses")
+	)
+	flag.Parse()
+
+	if *project == "" {
+		log.Fatal("-project is required")
+	}
+	if *prefix == "" {
+		log.Fatal("-prefix must not be empty (it would match every resource in the project)")
+	}
+
+	if !*dryRun && !*yes && !confirm(*project, *prefix) {
+		fmt.Fprintln(os.Stderr, "Cancelled.")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := gcpcleanup.NewGCPClient(ctx, *project)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	opts := gcpcleanup.Options{
+		Project:               *project,
+		Prefix:                *prefix,
+		Regions:               splitList(*regions),
+		DryRun:                *dryRun,
+		ServerlessIPv4Timeout: *ipv4Wait,
+		// Progress goes to stderr so that -json output stays parseable.
+		Logf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		},
+	}
+	if *quick {
+		opts.Order = gcpcleanup.QuickOrder
+	}
+
+	report := gcpcleanup.Run(ctx, client, opts)
+
+	if *jsonOut {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func confirm(project, prefix string) bool {
+	fmt.Fprintf(os.Stderr, "Delete all resources with prefix %q in project %q? (yes/no): ", prefix, project)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+// Command module-compat compares the variables and outputs of the modules
+// between two git refs, recommends a semantic version bump and fails when a
+// breaking change is not declared.
+//
+// By default each module is compared from its previous release tag to the
+// working tree:
+//
+//	go run ./cmd/module-compat
+//	go run ./cmd/module-compat -from v1.2.0 -to HEAD -json
+//
+// Breaking changes are declared in BREAKING_CHANGES at the repository root,
+// one "<module path> <subject>" per line (e.g.
+// "modules/aws/ecs-fargate variable.certificate_arn", or "*" for all
+// changes of a module).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/modcompat"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/upgrade"
+)
+
+// declarationsFile is the default declarations file, relative to the
+// repository root.
+const declarationsFile = "BREAKING_CHANGES"
+
+type change struct {
+	modcompat.Change
+	Declared bool `json:"declared"`
+}
+
+type moduleReport struct {
+	Path    string         `json:"path"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Bump    modcompat.Bump `json:"bump"`
+	Changes []change       `json:"changes"`
+}
+
+type report struct {
+	Bump       modcompat.Bump `json:"bump"`
+	Undeclared int            `json:"undeclared_breaking_changes"`
+	Modules    []moduleReport `json:"modules"`
+}
+
+func main() {
+	var (
+		repo     = flag.String("repo", "", "repository root (default: the enclosing git repository)")
+		from     = flag.String("from", "", "ref to compare from (default: each module's previous release tag)")
+		to       = flag.String("to", "", "ref to compare to (default: the working tree)")
+		modules  = flag.String("modules", "modules/aws/ecs-fargate,modules/gcp/cloud-run", "comma separated module paths relative to the repository root")
+		declared = flag.String("declared", "", "breaking change declarations file (default: "+declarationsFile+" in the repository root, if present)")
+		jsonOut  = flag.Bool("json", false, "write the report as JSON to stdout")
+	)
+	flag.Parse()
+
+	if *repo == "" {
+		root, err := gitRoot()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*repo = root
+	}
+
+	decls, err := loadDeclarations(*repo, *declared)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rep report
+	for _, module := range splitList(*modules) {
+		fromRef := *from
+		if fromRef == "" {
+			fromRef, err = upgrade.PreviousTag(*repo, upgrade.TagPatterns(module)...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if fromRef == "" {
+				log.Fatalf("no release tag of %s found; pass -from", module)
+			}
+		}
+		old, err := modcompat.Load(*repo, fromRef, module)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cur, err := modcompat.Load(*repo, *to, module)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		changes := modcompat.Compare(old, cur)
+		mr := moduleReport{Path: module, From: fromRef, To: *to, Bump: modcompat.Recommend(changes), Changes: []change{}}
+		if mr.To == "" {
+			mr.To = "working tree"
+		}
+		for _, c := range changes {
+			mr.Changes = append(mr.Changes, change{Change: c, Declared: c.Breaking() && decls.Declared(module, c)})
+		}
+		rep.Undeclared += len(decls.Undeclared(module, changes))
+		rep.Bump = max(rep.Bump, mr.Bump)
+		rep.Modules = append(rep.Modules, mr)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rep)
+	} else {
+		err = writeText(os.Stdout, rep)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rep.Undeclared > 0 {
+		fmt.Fprintf(os.Stderr, "%d undeclared breaking change(s): keep the interface compatible or declare them in %s\n", rep.Undeclared, declarationsFile)
+		os.Exit(1)
+	}
+}
+
+func writeText(w io.Writer, rep report) error {
+	var b strings.Builder
+	for _, m := range rep.Modules {
+		fmt.Fprintf(&b, "%s (%s -> %s): %s\n", m.Path, m.From, m.To, m.Bump)
+		if len(m.Changes) == 0 {
+			b.WriteString("  no interface changes\n")
+		}
+		for _, c := range m.Changes {
+			mark := ""
+			switch {
+			case c.Declared:
+				mark = " [declared]"
+			case c.Breaking():
+				mark = " [UNDECLARED]"
+			}
+			fmt.Fprintf(&b, "  %-5s %s: %s%s\n    %s\n", c.Bump, c.Subject, c.Detail, mark, c.Pos)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Recommended version bump: %s\n", rep.Bump)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// loadDeclarations reads path, or the default declarations file if path is
+// empty. Only the default file may be missing.
+func loadDeclarations(repo, path string) (modcompat.Declarations, error) {
+	optional := path == ""
+	if optional {
+		path = filepath.Join(repo, declarationsFile)
+	}
+	f, err := os.Open(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return modcompat.Declarations{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := modcompat.ParseDeclarations(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+func gitRoot() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("find repository root: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(p), "/")); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/probe"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/runstate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chaosLabel is the revision template label changed to force new
+// instances. The next terraform apply removes it again.
+const chaosLabel = "terratest-chaos"
+
+// testForcedRevision replaces every running Bridge instance by deploying a
+// new revision outside of Terraform (only a template label changes) and
+// measures the time until the new revision serves all traffic. The
+// recovery time is recorded as a metric of the instance_replace_recovery
+// phase. Cloud Run keeps the old revision serving until the new one is
+// ready, so no probe may fail.
+func testForcedRevision(ctx context.Context, t *testing.T, rep *report.Report, servicePath, domainName string) {
+	services, err := run.NewServicesClient(ctx)
+	require.NoError(t, err)
+	defer services.Close()
+
+	svc, err := services.GetService(ctx, &runpb.GetServiceRequest{Name: servicePath})
+	require.NoError(t, err)
+	before := runstate.FromProto(svc)
+	require.True(t, before.ServesOnly(before.LatestReadyRevision), "service must be settled before forcing a new revision: %s", before)
+
+	phase := rep.Begin("instance_replace_recovery")
+	stopProber := probe.StartPhase(ctx, phase, domainName, t.Logf)
+
+	if svc.Template.Labels == nil {
+		svc.Template.Labels = map[string]string{}
+	}
+	svc.Template.Labels[chaosLabel] = strconv.FormatInt(time.Now().Unix(), 10)
+	// Let Cloud Run generate the revision name.
+	svc.Template.Revision = ""
+
+	t.Logf("Forcing a new revision of %s (current: %s)", servicePath, before.LatestReadyRevision)
+	forcedAt := time.Now()
+	op, err := services.UpdateService(ctx, &runpb.UpdateServiceRequest{Service: svc})
+	if err == nil {
+		_, err = op.Wait(ctx)
+	}
+	var after *runstate.Service
+	if err == nil {
+		after, err = runstate.WaitRollout(ctx, services, servicePath, before.LatestCreatedRevision, poll.Options{
+			Timeout: 10 * time.Minute,
+			Backoff: poll.Backoff{Initial: 5 * time.Second, Max: 15 * time.Second, Multiplier: 1.5},
+			Logf:    t.Logf,
+		})
+	}
+	recovery := time.Since(forcedAt)
+	res := stopProber()
+	if err != nil {
+		phase.Finish(err)
+		require.NoError(t, err, "service did not recover from the forced revision")
+	}
+	phase.SetMetric("recovery_seconds", recovery.Seconds())
+	t.Logf("Revision %s took over from %s in %v", after.LatestReadyRevision, before.LatestReadyRevision, recovery.Round(time.Second))
+
+	probe.FinishPhase(phase, res, "instance replacement")
+	assert.Empty(t, res.Failures, "the Bridge should stay available while instances are replaced")
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -14,10 +13,19 @@ import (
 	run "cloud.google.com/go/run/apiv2"
 	runpb "cloud.google.com/go/run/apiv2/runpb"
 
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/artifacts"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/dnscheck"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/managedcert"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/teardown"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	compute "google.golang.org/api/compute/v1"
+	logging "google.golang.org/api/logging/v2"
 )
 
 // ========================================
@@ -33,23 +41,38 @@ func mustGetenv(t *testing.T, key string) string {
 	return val
 }
 
-// retryWithTimeout retries a function with exponential backoff
-func retryWithTimeout(t *testing.T, timeout time.Duration, interval time.Duration, fn func() error) error {
-	deadline := time.Now().Add(timeout)
-	attempt := 0
-
-	for time.Now().Before(deadline) {
-		attempt++
-		err := fn()
-		if err == nil {
-			return nil
+// collectGCPArtifacts returns the collector for the failure artifact bundle:
+// redacted Terraform plan/state/outputs, the Cloud Run service and revisions
+// and the service's recent log entries.
+func collectGCPArtifacts(ctx context.Context, t *testing.T, terraformOptions *terraform.Options, projectID, region, serviceName, tenantID string) func(w *artifacts.Writer) {
+	return func(w *artifacts.Writer) {
+		// CollectTerraform only learns the sensitive values from a plan,
+		// which may well fail when the test did
+		w.Redact(tenantID)
+		artifacts.CollectTerraform(t, w, terraformOptions)
+
+		services, err := run.NewServicesClient(ctx)
+		if err != nil {
+			w.Error("cloud-run", err)
+		} else {
+			defer services.Close()
+			revisions, err := run.NewRevisionsClient(ctx)
+			if err != nil {
+				w.Error("cloud-run", err)
+			} else {
+				defer revisions.Close()
+				name := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, serviceName)
+				artifacts.CollectCloudRun(ctx, w, services, revisions, name)
+			}
 		}
 
-		t.Logf("Attempt %d failed: %v. Retrying in %v...", attempt, err, interval)
-		time.Sleep(interval)
+		logs, err := logging.NewService(ctx)
+		if err != nil {
+			w.Error("logs", err)
+			return
+		}
+		artifacts.CollectCloudRunLogs(ctx, w, logs, projectID, region, serviceName, artifacts.LogOptions{})
 	}
-
-	return fmt.Errorf("operation timed out after %v", timeout)
 }
 
 // ========================================
@@ -79,6 +102,15 @@ func TestCloudRunModule(t *testing.T) {
 	uniqueID := strings.ToLower(random.UniqueId())
 	serviceName := fmt.Sprintf("bridge-test-%s", uniqueID)
 
+	// Phase timings are written to TEST_REPORT_DIR (default: test/reports)
+	// when the test finishes.
+	rep := report.ForTest(t, "gcp-cloud-run", uniqueID)
+	rep.SetLabel("region", region)
+
+	// Evidence for post-mortems is written to TEST_ARTIFACT_DIR (default:
+	// test/artifacts) before destroy when the test fails.
+	bundle := artifacts.New("gcp-cloud-run", uniqueID)
+
 	// Construct Terraform options
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../../examples/gcp-cloud-run",
@@ -118,93 +150,88 @@ func TestCloudRunModule(t *testing.T) {
 	// Ensure cleanup
 	// Note: VPC/subnet deletion may fail due to serverless-ipv4 circular dependency.
 	// This is a known GCP Direct VPC Egress limitation and is expected.
-	// Resources will be cleaned up when running the cleanup script or via GCP Console.
+	// Leftover resources can be removed with cmd/gcp-cleanup or via GCP Console.
 	defer func() {
 		t.Log("Starting terraform destroy...")
 
-		// Wait for Cloud Run to fully release the serverless-ipv4 address
+		// Wait for Cloud Run to fully release the serverless-ipv4 address and
+		// retry while the destroy fails with a known transient error
 		// GCP needs time (5-10 minutes) to clean up after Cloud Run service deletion
-		t.Log("Waiting 30 seconds for GCP to release serverless-ipv4 addresses...")
-		time.Sleep(30 * time.Second)
-
-		// Retry destroy up to 3 times with increasing wait times
-		maxRetries := 3
-		var err error
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			t.Logf("Destroy attempt %d/%d...", attempt, maxRetries)
-
-			_, err = terraform.DestroyE(t, terraformOptions)
-
-			if err == nil {
-				t.Log("✅ terraform destroy completed successfully")
-				return
-			}
-
-			// Check if it's the known serverless-ipv4 issue
-			isKnownIssue := strings.Contains(err.Error(), "serverless-ipv4") ||
-				strings.Contains(err.Error(), "already being used") ||
-				strings.Contains(err.Error(), "servicenetworking")
+		policy := teardown.DefaultGCPPolicy()
+		policy.Logf = t.Logf
+		destroyPhase := rep.Begin("destroy")
+		result := teardown.Destroy(t, terraformOptions, policy)
+		destroyPhase.SetMetric("attempts", float64(result.Attempts))
+		destroyPhase.SetMetric("remaining_resources", float64(len(result.Remaining)))
+		destroyPhase.Finish(result.Err)
+
+		if result.Err == nil {
+			t.Log("✅ terraform destroy completed successfully")
+			return
+		}
 
-			if isKnownIssue && attempt < maxRetries {
-				waitTime := time.Duration(attempt*60) * time.Second
-				t.Logf("VPC deletion failed (serverless-ipv4 still in use). Waiting %v before retry...", waitTime)
-				time.Sleep(waitTime)
-				continue
-			}
+		t.Logf("Destroy result:\n%s", result.Summary())
 
-			// If max retries reached or unknown error, log and continue
-			break
-		}
+		// VPC削除エラーは既知の問題（serverless-ipv4 circular dependency）
+		if result.Known() {
+			t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			t.Logf("⚠️  VPC deletion failed after %d attempts (known GCP Direct VPC Egress limitation)", result.Attempts)
+			t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			t.Logf("")
+			t.Logf("This is expected behavior:")
+			t.Logf("  - serverless-ipv4 addresses are auto-created by Cloud Run")
+			t.Logf("  - They cannot be deleted independently")
+			t.Logf("  - GCP needs 5-10 minutes to release them after Cloud Run service deletion")
+			t.Logf("  - This creates circular dependency: VPC ← subnet ← serverless-ipv4")
+			t.Logf("")
+			t.Logf("To clean up remaining resources:")
+			t.Logf("")
+			t.Logf("Option 1: Use gcp-cleanup command (recommended)")
+			t.Logf("  cd test")
+			t.Logf("  go run ./cmd/gcp-cleanup -project %s -prefix %s -dry-run", projectID, serviceName)
+			t.Logf("  go run ./cmd/gcp-cleanup -project %s -prefix %s -yes", projectID, serviceName)
+			t.Logf("")
+			t.Logf("Option 2: Wait and retry")
+			t.Logf("  cd examples/gcp-cloud-run")
+			t.Logf("  # Wait 5-10 minutes, then:")
+			t.Logf("  terraform destroy -auto-approve")
+			t.Logf("")
+			t.Logf("Option 3: Delete via GCP Console")
+			t.Logf("  https://console.cloud.google.com/networking/networks?project=%s", projectID)
+			t.Logf("  - Delete VPC: %s-vpc", serviceName)
+			t.Logf("")
+			t.Logf("Option 4: Leave resources (no cost impact)")
+			t.Logf("  - VPC, subnet, serverless-ipv4 are all free")
+			t.Logf("  - New tests use unique IDs and won't conflict")
+			t.Logf("")
+			t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			t.Logf("")
 
-		// Handle final error after all retries
-		if err != nil {
-			// VPC削除エラーは既知の問題（serverless-ipv4 circular dependency）
-			if strings.Contains(err.Error(), "serverless-ipv4") ||
-				strings.Contains(err.Error(), "already being used") ||
-				strings.Contains(err.Error(), "servicenetworking") {
-				t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-				t.Logf("⚠️  VPC deletion failed after %d retries (known GCP Direct VPC Egress limitation)", maxRetries)
-				t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-				t.Logf("")
-				t.Logf("This is expected behavior:")
-				t.Logf("  - serverless-ipv4 addresses are auto-created by Cloud Run")
-				t.Logf("  - They cannot be deleted independently")
-				t.Logf("  - GCP needs 5-10 minutes to release them after Cloud Run service deletion")
-				t.Logf("  - This creates circular dependency: VPC ← subnet ← serverless-ipv4")
-				t.Logf("")
-				t.Logf("To clean up remaining resources:")
-				t.Logf("")
-				t.Logf("Option 1: Use cleanup script (recommended)")
-				t.Logf("  cd examples/gcp-cloud-run")
-				t.Logf("  ./scripts/cleanup.sh %s %s", projectID, serviceName)
-				t.Logf("")
-				t.Logf("Option 2: Wait and retry")
-				t.Logf("  cd examples/gcp-cloud-run")
-				t.Logf("  # Wait 5-10 minutes, then:")
-				t.Logf("  terraform destroy -auto-approve")
-				t.Logf("")
-				t.Logf("Option 3: Delete via GCP Console")
-				t.Logf("  https://console.cloud.google.com/networking/networks?project=%s", projectID)
-				t.Logf("  - Delete VPC: %s-vpc", serviceName)
-				t.Logf("")
-				t.Logf("Option 4: Leave resources (no cost impact)")
-				t.Logf("  - VPC, subnet, serverless-ipv4 are all free")
-				t.Logf("  - New tests use unique IDs and won't conflict")
-				t.Logf("")
-				t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-				t.Logf("")
-
-				// テストは失敗させない（VPC削除は既知の問題のため）
-			} else {
-				// その他のエラーはログに出力するが、テストは失敗させない
-				t.Logf("⚠️  terraform destroy encountered an error: %v", err)
-				t.Logf("This may require manual cleanup via GCP Console or cleanup script")
-			}
+			// テストは失敗させない（VPC削除は既知の問題のため）
+		} else {
+			// その他のエラーはログに出力するが、テストは失敗させない
+			t.Logf("⚠️  terraform destroy encountered an error: %v", result.Err)
+			t.Logf("This may require manual cleanup via GCP Console or cmd/gcp-cleanup")
 		}
 	}()
 
 	// Run terraform init and apply
-	terraform.InitAndApply(t, terraformOptions)
+	defer bundle.CollectOnFailure(t, collectGCPArtifacts(ctx, t, terraformOptions, projectID, region, serviceName, tenantID))
+
+	applyPhase := rep.Begin("init_and_apply")
+	_, err := terraform.InitAndApplyE(t, terraformOptions)
+	applyPhase.Finish(err)
+	require.NoError(t, err, "terraform init and apply failed")
+
+	// A second plan right after apply must be empty; perpetual diffs are
+	// reported with the attributes that would change. This runs before the
+	// chaos test, which deliberately changes the service outside Terraform.
+	t.Run("Idempotency", func(t *testing.T) {
+		phase := rep.Begin("idempotency")
+		err := tfplan.CheckIdempotent(t, terraformOptions)
+		phase.Finish(err)
+		assert.NoError(t, err, "terraform plan after apply should have no changes")
+	})
 
 	// ========================================
 	// Task 7.3: Cloud Run Service Validation
@@ -217,50 +244,7 @@ func TestCloudRunModule(t *testing.T) {
 		defer client.Close()
 
 		servicePath := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, serviceName)
-		service, err := client.GetService(ctx, &runpb.GetServiceRequest{
-			Name: servicePath,
-		})
-		require.NoError(t, err)
-		assert.NotNil(t, service)
-
-		t.Logf("Cloud Run service found: %s", service.Name)
-
-		// Verify service configuration
-		template := service.GetTemplate()
-		require.NotNil(t, template)
-
-		containers := template.GetContainers()
-		require.NotEmpty(t, containers)
-
-		container := containers[0]
-
-		// Verify environment variables
-		envVars := container.GetEnv()
-		envMap := make(map[string]string)
-		for _, env := range envVars {
-			envMap[env.GetName()] = env.GetValue()
-		}
-
-		assert.Equal(t, "1h", envMap["FETCH_INTERVAL"])
-		assert.Equal(t, "10s", envMap["FETCH_TIMEOUT"])
-		assert.Equal(t, tenantID, envMap["TENANT_ID"])
-		// Note: PORT environment variable is automatically set by Cloud Run from container_port
-
-		// Verify container port
-		ports := container.GetPorts()
-		require.NotEmpty(t, ports)
-		assert.Equal(t, int32(8080), ports[0].GetContainerPort())
-
-		// Verify resource limits
-		resources := container.GetResources()
-		require.NotNil(t, resources)
-		assert.Equal(t, "1", resources.Limits["cpu"])
-		assert.Equal(t, "512Mi", resources.Limits["memory"])
-
-		// Verify ingress setting (internal load balancer only)
-		assert.Equal(t, runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER, service.GetIngress())
-
-		t.Logf("Cloud Run service configuration verified")
+		checkCloudRunService(ctx, t, rep, client, servicePath, tenantID)
 	})
 
 	// ========================================
@@ -281,48 +265,85 @@ func TestCloudRunModule(t *testing.T) {
 			t.Logf("  Load Balancer:  %s", lbIP)
 			t.Logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
-			// Check DNS resolution first
+			// Check DNS resolution first, on the zone's authoritative
+			// nameservers so that no cached negative answer is involved
 			t.Logf("Step 1: Checking DNS resolution...")
-			ips, err := net.LookupIP(domainName)
-			if err != nil {
+			var dns dnscheck.Checker
+			result, err := dns.Check(ctx, domainName, []string{lbIP})
+			if result == nil {
 				t.Logf("  ❌ DNS lookup failed: %v", err)
 			} else {
-				t.Logf("  ✅ DNS resolved to: %v", ips)
-				for _, ip := range ips {
-					if ip.String() == lbIP {
-						t.Logf("  ✅ Load Balancer IP matched: %s", lbIP)
-					}
+				for _, answer := range result.Answers {
+					t.Logf("  %s", answer)
+				}
+				if err != nil {
+					t.Logf("  ❌ %v", err)
+				} else {
+					t.Logf("  ✅ Load Balancer IP matched on every nameserver of %s: %s", result.Zone, lbIP)
 				}
 			}
 
-			// Wait for SSL certificate to be provisioned and DNS to propagate
-			// This can take up to 15 minutes
-			t.Logf("\nStep 2: Waiting for SSL certificate provisioning and health check...")
-			t.Logf("  Timeout: 5 minutes")
-			t.Logf("  Interval: 30 seconds")
-
-			err = retryWithTimeout(t, 5*time.Minute, 30*time.Second, func() error {
+			// Provisioning usually takes 15 minutes but can take up to an
+			// hour, so the certificate status decides how long to wait:
+			// the poller gives up as soon as it cannot become ACTIVE
+			t.Logf("\nStep 2: Waiting for the managed SSL certificate to become ACTIVE...")
+			t.Logf("  Timeout: 40 minutes, or until provisioning cannot progress")
+			computeService, err := compute.NewService(ctx)
+			require.NoError(t, err)
+			certPoller := &managedcert.Poller{
+				Client:         managedcert.ComputeClient{Service: computeService},
+				Project:        projectID,
+				Name:           serviceName + "-cert",
+				LoadBalancerIP: lbIP,
+			}
+			certPhase := rep.Begin("managed_certificate_issued")
+			status, err := certPoller.Wait(ctx, poll.Options{
+				Timeout: 40 * time.Minute,
+				Backoff: poll.Backoff{Initial: 15 * time.Second, Max: time.Minute, Multiplier: 1.5, Jitter: 0.2},
+				Logf:    t.Logf,
+			})
+			certPhase.Finish(err)
+			require.NoError(t, err, "managed SSL certificate was not provisioned")
+			t.Logf("  ✅ Certificate is %s", status)
+
+			// The load balancer serves the new certificate a few minutes
+			// after it becomes ACTIVE
+			t.Logf("\nStep 3: Waiting for the HTTPS health check...")
+			t.Logf("  Timeout: 10 minutes")
+			t.Logf("  Interval: 10-60 seconds (exponential backoff)")
+
+			httpsPhase := rep.Begin("https_health_check")
+			diag := bundle.Logger(t, "httpsHealthCheck")
+			err = poll.Until(ctx, poll.Options{
+				Timeout: 10 * time.Minute,
+				Backoff: poll.Backoff{Initial: 10 * time.Second, Max: 60 * time.Second, Multiplier: 1.5, Jitter: 0.2},
+				Logf:    diag.Logf,
+			}, func(ctx context.Context) error {
 				// Log detailed attempt information
-				t.Logf("\n  → Attempting HTTPS request to %s/ok", domainURL)
+				diag.Logf("\n  → Attempting HTTPS request to %s/ok", domainURL)
 
 				// Create custom HTTP client with timeout
 				client := &http.Client{
 					Timeout: 10 * time.Second,
 				}
 
-				resp, err := client.Get(domainURL + "/ok")
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, domainURL+"/ok", nil)
+				if err != nil {
+					return poll.Permanent(err)
+				}
+				resp, err := client.Do(req)
 				if err != nil {
-					t.Logf("     ❌ Request error: %v", err)
+					diag.Logf("     ❌ Request error: %v", err)
 					return fmt.Errorf("HTTP request failed: %w", err)
 				}
 				defer resp.Body.Close()
 
-				t.Logf("     Status: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
-				t.Logf("     TLS: %v", resp.TLS != nil)
+				diag.Logf("     Status: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+				diag.Logf("     TLS: %v", resp.TLS != nil)
 				if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
 					cert := resp.TLS.PeerCertificates[0]
-					t.Logf("     Certificate: CN=%s, Issuer=%s", cert.Subject.CommonName, cert.Issuer.CommonName)
-					t.Logf("     Valid: %v - %v", cert.NotBefore, cert.NotAfter)
+					diag.Logf("     Certificate: CN=%s, Issuer=%s", cert.Subject.CommonName, cert.Issuer.CommonName)
+					diag.Logf("     Valid: %v - %v", cert.NotBefore, cert.NotAfter)
 				}
 
 				if resp.StatusCode != http.StatusOK {
@@ -332,28 +353,29 @@ func TestCloudRunModule(t *testing.T) {
 					if len(bodyPreview) > 500 {
 						bodyPreview = bodyPreview[:500] + "..."
 					}
-					t.Logf("     Response body: %s", bodyPreview)
+					diag.Logf("     Response body: %s", bodyPreview)
 					return fmt.Errorf("expected status 200, got %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 				}
 
 				body, err := io.ReadAll(resp.Body)
 				if err != nil {
-					t.Logf("     ❌ Failed to read body: %v", err)
+					diag.Logf("     ❌ Failed to read body: %v", err)
 					return fmt.Errorf("failed to read response body: %w", err)
 				}
 
 				bodyStr := strings.ToLower(strings.TrimSpace(string(body)))
-				t.Logf("     Response body: '%s'", bodyStr)
+				diag.Logf("     Response body: '%s'", bodyStr)
 
 				if bodyStr != "bridge is ready" {
-					t.Logf("     ❌ Unexpected body content")
+					diag.Logf("     ❌ Unexpected body content")
 					return fmt.Errorf("expected response body 'ok', got '%s'", bodyStr)
 				}
 
-				t.Logf("     ✅ Health check succeeded!")
+				diag.Logf("     ✅ Health check succeeded!")
 				return nil
 			})
 
+			httpsPhase.Finish(err)
 			require.NoError(t, err, "HTTPS health check failed")
 			t.Logf("\n✅ HTTPS health check passed: %s/ok", domainURL)
 
@@ -391,45 +413,43 @@ func TestCloudRunModule(t *testing.T) {
 			// Wait for DNS propagation
 			t.Logf("\nWaiting for DNS propagation...")
 			t.Logf("  Timeout: 5 minutes")
-			t.Logf("  Interval: 10 seconds")
-
-			err := retryWithTimeout(t, 5*time.Minute, 10*time.Second, func() error {
-				t.Logf("\n  → Performing DNS lookup for %s", domainName)
-
-				ips, err := net.LookupIP(domainName)
-				if err != nil {
-					t.Logf("     ❌ DNS lookup error: %v", err)
+			t.Logf("  Interval: 5-30 seconds (exponential backoff)")
+
+			// The system resolver caches the negative answers from before
+			// the record existed, so the zone's nameservers are asked
+			// directly
+			var dns dnscheck.Checker
+			dnsPhase := rep.Begin("dns_propagation")
+			diag := bundle.Logger(t, "dnsResolution")
+			err := poll.Until(ctx, poll.Options{
+				Timeout: 5 * time.Minute,
+				Backoff: poll.Backoff{Initial: 5 * time.Second, Max: 30 * time.Second, Multiplier: 1.5, Jitter: 0.2},
+				Logf:    diag.Logf,
+			}, func(ctx context.Context) error {
+				diag.Logf("\n  → Asking the authoritative nameservers for %s", domainName)
+
+				result, err := dns.Check(ctx, domainName, []string{lbIP})
+				if result == nil {
+					diag.Logf("     ❌ DNS lookup error: %v", err)
 					return fmt.Errorf("DNS lookup failed: %w", err)
 				}
 
-				if len(ips) == 0 {
-					t.Logf("     ❌ No IP addresses found")
-					return fmt.Errorf("no IP addresses found for domain %s", domainName)
-				}
-
-				t.Logf("     DNS resolved to %d IP(s):", len(ips))
-				for _, ip := range ips {
-					t.Logf("       - %s", ip.String())
-				}
-
-				// Check if Load Balancer IP is in the resolved IPs
-				found := false
-				for _, ip := range ips {
-					if ip.String() == lbIP {
-						found = true
-						t.Logf("     ✅ Load Balancer IP matched: %s", lbIP)
-						break
-					}
+				diag.Logf("     Nameservers of %s answered:", result.Zone)
+				for _, answer := range result.Answers {
+					diag.Logf("       - %s", answer)
 				}
 
-				if !found {
-					t.Logf("     ❌ Expected IP %s not found in DNS resolution", lbIP)
-					return fmt.Errorf("expected IP %s not found in DNS resolution", lbIP)
+				// Every nameserver must answer with the Load Balancer IP only
+				if err != nil {
+					diag.Logf("     ❌ Expected IP %s not answered by every nameserver", lbIP)
+					return err
 				}
 
+				diag.Logf("     ✅ Load Balancer IP matched: %s", lbIP)
 				return nil
 			})
 
+			dnsPhase.Finish(err)
 			require.NoError(t, err, "DNS resolution test failed")
 			t.Logf("\n✅ DNS resolution verified: %s -> %s", domainName, lbIP)
 
@@ -448,6 +468,39 @@ func TestCloudRunModule(t *testing.T) {
 		})
 	}
 
+	// ========================================
+	// Zero-downtime revision rollout
+	// ========================================
+
+	// The prober goes through the load balancer, so a domain is required
+	// (set TEST_SKIP_REVISION_ROLLOUT=true to skip)
+	if domainName != "" {
+		t.Run("RevisionRollout", func(t *testing.T) {
+			if os.Getenv("TEST_SKIP_REVISION_ROLLOUT") == "true" {
+				rep.Begin("revision_rollout").Skip("TEST_SKIP_REVISION_ROLLOUT=true")
+				t.Skip("TEST_SKIP_REVISION_ROLLOUT=true")
+			}
+			servicePath := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, serviceName)
+			testRevisionRollout(ctx, t, rep, terraformOptions, servicePath, domainName)
+		})
+	}
+
+	// ========================================
+	// Chaos: forced instance replacement
+	// ========================================
+
+	// Set TEST_SKIP_CHAOS=true to skip
+	if domainName != "" {
+		t.Run("ForcedRevision", func(t *testing.T) {
+			if os.Getenv("TEST_SKIP_CHAOS") == "true" {
+				rep.Begin("instance_replace_recovery").Skip("TEST_SKIP_CHAOS=true")
+				t.Skip("TEST_SKIP_CHAOS=true")
+			}
+			servicePath := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, serviceName)
+			testForcedRevision(ctx, t, rep, servicePath, domainName)
+		})
+	}
+
 	// Log all outputs for debugging
 	t.Run("LogOutputs", func(t *testing.T) {
 		outputs := []string{
@@ -466,3 +519,74 @@ func TestCloudRunModule(t *testing.T) {
 		}
 	})
 }
+
+// validationT is the part of *testing.T that checkCloudRunService uses, so
+// that the offline test can run it against a recorder
+type validationT interface {
+	require.TestingT
+	Logf(format string, args ...any)
+}
+
+// checkCloudRunService verifies that the service at servicePath is
+// configured as the module intends, and records the service_ready phase
+// when its first revision became Ready.
+func checkCloudRunService(ctx context.Context, t validationT, rep *report.Report, client *run.ServicesClient, servicePath, tenantID string) {
+	service, err := client.GetService(ctx, &runpb.GetServiceRequest{
+		Name: servicePath,
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, service)
+
+	t.Logf("Cloud Run service found: %s", service.Name)
+
+	// The service must be Ready, not just exist: a failed latest revision
+	// leaves the service serving an older one
+	cond := service.GetTerminalCondition()
+	assert.Equal(t, runpb.Condition_CONDITION_SUCCEEDED, cond.GetState(),
+		"Cloud Run service is not ready: %s", cond.GetMessage())
+
+	// Cloud Run waits for the first revision during apply; record the
+	// interval from service creation until it became Ready
+	if cond.GetState() == runpb.Condition_CONDITION_SUCCEEDED &&
+		service.GetCreateTime() != nil && cond.GetLastTransitionTime() != nil {
+		phase := rep.Add("service_ready", service.GetCreateTime().AsTime(), cond.GetLastTransitionTime().AsTime(), nil)
+		t.Logf("Cloud Run service became ready in %v", phase.Duration())
+	}
+
+	// Verify service configuration
+	template := service.GetTemplate()
+	require.NotNil(t, template)
+
+	containers := template.GetContainers()
+	require.NotEmpty(t, containers)
+
+	container := containers[0]
+
+	// Verify environment variables
+	envVars := container.GetEnv()
+	envMap := make(map[string]string)
+	for _, env := range envVars {
+		envMap[env.GetName()] = env.GetValue()
+	}
+
+	assert.Equal(t, "1h", envMap["FETCH_INTERVAL"])
+	assert.Equal(t, "10s", envMap["FETCH_TIMEOUT"])
+	assert.Equal(t, tenantID, envMap["TENANT_ID"])
+	// Note: PORT environment variable is automatically set by Cloud Run from container_port
+
+	// Verify container port
+	ports := container.GetPorts()
+	require.NotEmpty(t, ports)
+	assert.Equal(t, int32(8080), ports[0].GetContainerPort())
+
+	// Verify resource limits
+	resources := container.GetResources()
+	require.NotNil(t, resources)
+	assert.Equal(t, "1", resources.Limits["cpu"])
+	assert.Equal(t, "512Mi", resources.Limits["memory"])
+
+	// Verify ingress setting (internal load balancer only)
+	assert.Equal(t, runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER, service.GetIngress())
+
+	t.Logf("Cloud Run service configuration verified")
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// TestLabelPropagationCloudRunModule plans examples/gcp-cloud-run with a
+// sentinel label set and checks that every resource whose schema has labels
+// receives it. The load balancer resources only exist with a domain, so
+// TEST_DOMAIN_NAME is required. Nothing is applied.
+func TestLabelPropagationCloudRunModule(t *testing.T) {
+	t.Parallel()
+
+	projectID := mustGetenv(t, "TEST_GCP_PROJECT_ID")
+	region := os.Getenv("TEST_GCP_REGION")
+	if region == "" {
+		region = "asia-northeast1"
+	}
+
+	uniqueID := strings.ToLower(random.UniqueId())
+	// Label keys and values must be lowercase.
+	sentinel := map[string]string{
+		"label-propagation-test": uniqueID,
+		"cost-center":            "label-propagation",
+	}
+
+	rep := report.ForTest(t, "gcp-cloud-run-labels", uniqueID)
+	rep.SetLabel("region", region)
+
+	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "../../examples/gcp-cloud-run",
+		Vars: map[string]any{
+			"project_id":        projectID,
+			"region":            region,
+			"service_name":      fmt.Sprintf("bridge-test-%s", uniqueID),
+			"tenant_id":         mustGetenv(t, "TEST_TENANT_ID"),
+			"domain_name":       mustGetenv(t, "TEST_DOMAIN_NAME"),
+			"dns_zone_name":     mustGetenv(t, "TEST_DNS_ZONE_NAME"),
+			"allowed_ip_ranges": []string{"*"},
+			"database_name":     "testdb",
+			"database_user":     "testuser",
+			"labels":            sentinel,
+		},
+	})
+
+	tfplan.CheckPropagation(t, rep, terraformOptions, "labels", sentinel)
+}
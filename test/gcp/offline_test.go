@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/artifacts"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/fakerun"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCloudRunServiceOffline runs the CloudRunServiceExists checks of
+// TestCloudRunModule against the fake Cloud Run API, one subtest per
+// scenario in testdata/scenarios. It needs neither credentials nor a
+// deployment.
+func TestCloudRunServiceOffline(t *testing.T) {
+	tests := []struct {
+		scenario string
+		// service is the service to check; empty means the scenario's
+		service string
+		// failures are substrings of the expected assertion failures
+		failures []string
+		// ready is the expected duration of the service_ready phase; zero
+		// means the phase is not recorded
+		ready time.Duration
+	}{
+		{scenario: "ready", ready: 45 * time.Second},
+		{
+			scenario: "revision_failed",
+			failures: []string{"is not ready and cannot serve traffic"},
+		},
+		{
+			scenario: "wrong_ingress",
+			failures: []string{"actual  : 1"}, // INGRESS_TRAFFIC_ALL
+			ready:    45 * time.Second,
+		},
+		{
+			scenario: "ready",
+			service:  "projects/fake-project/locations/asia-northeast1/services/other",
+			failures: []string{"NotFound"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		name := tt.scenario
+		if tt.service != "" {
+			name += "/" + filepath.Base(tt.service)
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			sc, err := fakerun.LoadScenario(filepath.Join("testdata", "scenarios", tt.scenario+".json"))
+			require.NoError(t, err)
+			srv, err := fakerun.Start(sc)
+			require.NoError(t, err)
+			defer srv.Close()
+
+			services, err := run.NewServicesClient(ctx, srv.ClientOptions()...)
+			require.NoError(t, err)
+			defer services.Close()
+
+			servicePath := tt.service
+			if servicePath == "" {
+				servicePath = sc.Service.GetName()
+			}
+			rep := report.New("gcp-cloud-run-offline", sc.Name)
+			rec := testutil.NewRecorder(t)
+
+			rec.Run(func() {
+				checkCloudRunService(ctx, rec, rep, services, servicePath, "fake-tenant")
+			})
+
+			if assert.Len(t, rec.Errors(), len(tt.failures), "assertion failures: %v", rec.Errors()) {
+				for i, want := range tt.failures {
+					assert.Contains(t, rec.Errors()[i], want)
+				}
+			}
+			phase := rep.Phase("service_ready")
+			if tt.ready == 0 {
+				assert.Nil(t, phase)
+			} else if assert.NotNil(t, phase) {
+				assert.Equal(t, tt.ready, phase.Duration())
+			}
+			if len(tt.failures) == 0 {
+				return
+			}
+
+			// The failure artifacts collect from the same API without errors
+			revisions, err := run.NewRevisionsClient(ctx, srv.ClientOptions()...)
+			require.NoError(t, err)
+			defer revisions.Close()
+			bundle := artifacts.New("gcp-cloud-run-offline", sc.Name)
+			dir, err := bundle.Collect(t.TempDir(), func(w *artifacts.Writer) {
+				artifacts.CollectCloudRun(ctx, w, services, revisions, sc.Service.GetName())
+			})
+			require.NoError(t, err)
+			for _, name := range []string{"cloud-run/service.json", "cloud-run/revisions.json"} {
+				_, err := os.Stat(filepath.Join(dir, name))
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/probe"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/runstate"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revisionRolloutVars returns the variables changed by the revision
+// rollout test. Each of them changes the revision template.
+// TEST_ROLLOUT_IMAGE_TAG additionally switches bridge_image_tag; it is
+// opt-in because only published tags can be pulled.
+func revisionRolloutVars() map[string]interface{} {
+	vars := map[string]interface{}{
+		"fetch_interval": "30m",
+	}
+	if tag := os.Getenv("TEST_ROLLOUT_IMAGE_TAG"); tag != "" {
+		vars["bridge_image_tag"] = tag
+	}
+	return vars
+}
+
+// testRevisionRollout re-applies the stack with changed variables while a
+// background prober requests https://<domain>/ok through the load balancer
+// every second. It asserts that a new revision became ready with 100% of
+// the traffic and that no probe failed, and records the cutover time from
+// the new revision's creation until the service finished rolling out.
+//
+// terraformOptions.Vars is updated in place so that destroy sees the
+// applied configuration.
+func testRevisionRollout(ctx context.Context, t *testing.T, rep *report.Report, terraformOptions *terraform.Options, servicePath, domainName string) {
+	services, err := run.NewServicesClient(ctx)
+	require.NoError(t, err)
+	defer services.Close()
+
+	before, err := runstate.Describe(ctx, services, servicePath)
+	require.NoError(t, err)
+	require.True(t, before.ServesOnly(before.LatestReadyRevision), "service must be settled before the rollout: %s", before)
+	t.Logf("Before rollout: %s", before)
+
+	phase := rep.Begin("revision_rollout")
+	stopProber := probe.StartPhase(ctx, phase, domainName, t.Logf)
+
+	for name, value := range revisionRolloutVars() {
+		t.Logf("Revision rollout: %s %v -> %v", name, terraformOptions.Vars[name], value)
+		terraformOptions.Vars[name] = value
+	}
+
+	_, err = terraform.ApplyE(t, terraformOptions)
+	var after *runstate.Service
+	if err == nil {
+		// apply waits for the new revision, but traffic may still be
+		// migrating when it returns.
+		after, err = runstate.WaitRollout(ctx, services, servicePath, before.LatestCreatedRevision, poll.Options{
+			Timeout: 10 * time.Minute,
+			Backoff: poll.Backoff{Initial: 5 * time.Second, Max: 30 * time.Second, Multiplier: 1.5},
+			Logf:    t.Logf,
+		})
+	}
+	res := stopProber()
+	if err != nil {
+		phase.Finish(err)
+		require.NoError(t, err, "revision rollout did not complete")
+	}
+	probe.FinishPhase(phase, res, "revision rollout")
+	t.Logf("After rollout: %s", after)
+
+	assert.NotEqual(t, before.LatestReadyRevision, after.LatestReadyRevision, "a new revision should be deployed")
+	assert.Equal(t, map[string]int32{after.LatestReadyRevision: 100}, after.Traffic, "the new revision should serve all traffic")
+
+	recordCutover(ctx, t, rep, phase, servicePath+"/revisions/"+after.LatestReadyRevision, after.ReadyTime)
+
+	assert.Empty(t, res.Failures, "the Bridge should stay available during the revision rollout")
+}
+
+// recordCutover adds the interval from the new revision's creation until
+// the service's Ready condition last changed, i.e. until the revision took
+// over all traffic.
+func recordCutover(ctx context.Context, t *testing.T, rep *report.Report, phase *report.Phase, revisionPath string, readyTime time.Time) {
+	revisions, err := run.NewRevisionsClient(ctx)
+	if err != nil {
+		t.Logf("Could not create Cloud Run revisions client: %v", err)
+		return
+	}
+	defer revisions.Close()
+
+	revision, err := revisions.GetRevision(ctx, &runpb.GetRevisionRequest{Name: revisionPath})
+	if err != nil {
+		t.Logf("Could not get revision %s: %v", revisionPath, err)
+		return
+	}
+	if revision.GetCreateTime() == nil || readyTime.IsZero() {
+		t.Logf("Revision %s has no create or ready time; cutover not recorded", revisionPath)
+		return
+	}
+	cutover := rep.Add("revision_cutover", revision.GetCreateTime().AsTime(), readyTime, nil)
+	phase.SetMetric("cutover_seconds", cutover.Duration().Seconds())
+	t.Logf("Revision %s took over all traffic in %v", revision.GetName(), cutover.Duration())
+}
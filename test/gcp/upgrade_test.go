@@ -0,0 +1,127 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/teardown"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/upgrade"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protectedResources must survive a module upgrade: replacing the global
+// address changes the IP the domain resolves to, and a new managed
+// certificate leaves HTTPS down until it is provisioned again.
+var protectedResources = []string{
+	"module.basemachina_bridge.google_compute_global_address.default",
+	"module.basemachina_bridge.google_compute_managed_ssl_certificate.default",
+	"module.basemachina_bridge.google_compute_global_forwarding_rule.https",
+}
+
+// TestUpgradeCloudRunModule applies examples/gcp-cloud-run with the modules
+// of the previous release, then plans the working tree against the
+// resulting state and fails if any protected resource would be destroyed
+// or replaced. The load balancer resources only exist with a domain, so
+// TEST_DOMAIN_NAME is required. It shares the DNS record with
+// TestCloudRunModule, so it only runs with TEST_UPGRADE=true and never in
+// parallel.
+func TestUpgradeCloudRunModule(t *testing.T) {
+	repoDir, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	fromRef := upgrade.FromRefForTest(t, repoDir, "modules/gcp/cloud-run")
+
+	projectID := mustGetenv(t, "TEST_GCP_PROJECT_ID")
+	region := os.Getenv("TEST_GCP_REGION")
+	if region == "" {
+		region = "asia-northeast1"
+	}
+
+	uniqueID := strings.ToLower(random.UniqueId())
+	serviceName := fmt.Sprintf("bridge-test-%s", uniqueID)
+
+	rep := report.ForTest(t, "gcp-cloud-run-upgrade", uniqueID)
+	rep.SetLabel("region", region)
+	rep.SetLabel("from_ref", fromRef)
+
+	tfVars := map[string]any{
+		"project_id":        projectID,
+		"region":            region,
+		"service_name":      serviceName,
+		"tenant_id":         mustGetenv(t, "TEST_TENANT_ID"),
+		"domain_name":       mustGetenv(t, "TEST_DOMAIN_NAME"),
+		"dns_zone_name":     mustGetenv(t, "TEST_DNS_ZONE_NAME"),
+		"allowed_ip_ranges": []string{"*"},
+		"database_name":     "testdb",
+		"database_user":     "testuser",
+	}
+
+	ws, err := upgrade.NewWorkspace(repoDir, fromRef, t.TempDir(), "examples/gcp-cloud-run", "modules/gcp/cloud-run")
+	require.NoError(t, err)
+	t.Logf("Upgrading from %s: previous tree %s, working tree copy %s", fromRef, ws.Previous, ws.Current)
+
+	previousVars, dropped, err := upgrade.FilterVars(tfVars, ws.Previous)
+	require.NoError(t, err)
+	if len(dropped) > 0 {
+		t.Logf("Variables not declared at %s, not passed to its apply: %v", fromRef, dropped)
+	}
+
+	previousOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: ws.Previous,
+		Vars:         previousVars,
+	})
+	currentOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: ws.Current,
+		Vars:         tfVars,
+	})
+
+	// The state moves to the working tree copy before the upgrade plan;
+	// destroy from wherever it is at the end.
+	stateOptions := previousOptions
+	defer func() {
+		policy := teardown.DefaultGCPPolicy()
+		policy.Logf = t.Logf
+		destroyPhase := rep.Begin("destroy")
+		result := teardown.Destroy(t, stateOptions, policy)
+		destroyPhase.SetMetric("attempts", float64(result.Attempts))
+		destroyPhase.Finish(result.Err)
+		if result.Err != nil && !result.Known() {
+			t.Errorf("terraform destroy failed:\n%s", result.Summary())
+		}
+	}()
+
+	applyPhase := rep.Begin("previous_release_apply")
+	_, err = terraform.InitAndApplyE(t, previousOptions)
+	applyPhase.Finish(err)
+	require.NoError(t, err, "terraform apply of %s failed", fromRef)
+
+	require.NoError(t, ws.MoveState())
+	stateOptions = currentOptions
+
+	planPhase := rep.Begin("upgrade_plan")
+	_, err = terraform.InitE(t, currentOptions)
+	var result *tfplan.Result
+	if err == nil {
+		result, err = tfplan.Run(t, currentOptions)
+	}
+	if err != nil {
+		planPhase.Finish(err)
+		require.NoError(t, err, "terraform plan of the working tree failed")
+	}
+	changes := result.Changes()
+	if len(changes) > 0 {
+		t.Logf("Upgrading from %s changes %d resource(s):\n%s", fromRef, len(changes), tfplan.Describe(changes))
+	}
+	err = upgrade.Check(fromRef, changes, protectedResources)
+	planPhase.SetMetric("changes", float64(len(changes)))
+	planPhase.SetMetric("protected_destroys", float64(len(upgrade.Protected(changes, protectedResources))))
+	planPhase.Finish(err)
+	assert.NoError(t, err, "the upgrade must not destroy protected resources")
+}
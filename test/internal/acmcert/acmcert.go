@@ -0,0 +1,337 @@
+// Package acmcert follows an ACM certificate validated through Route 53 DNS
+// from PENDING_VALIDATION to ISSUED and, while it is not issued, works out
+// why: the hosted zone does not contain the domain, the validation CNAME is
+// missing from the zone or does not resolve publicly, or a CAA record does
+// not allow Amazon to issue for the domain.
+package acmcert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/dnscheck"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+// AmazonCAADomains are the CAA issuer domains that allow ACM to issue
+// (https://docs.aws.amazon.com/acm/latest/userguide/setup-caa.html).
+var AmazonCAADomains = []string{"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"}
+
+// Tracker follows the certificate for DomainName, which is validated
+// through the hosted zone ZoneID.
+type Tracker struct {
+	ACM        acmiface.ACMAPI
+	Route53    route53iface.Route53API
+	ZoneID     string
+	DomainName string
+
+	// LookupCNAME returns a name's CNAME as the zone's authoritative
+	// nameservers, found through the public delegation, answer it, which
+	// is how ACM sees the validation record. Unlike a recursive resolver
+	// they do not cache an earlier NXDOMAIN (nil means a dnscheck.Checker).
+	LookupCNAME func(ctx context.Context, name string) (string, error)
+	// LookupCAA returns the CAA records that apply to a name and where
+	// they were found (nil means a dnscheck.Checker).
+	LookupCAA func(ctx context.Context, name string) (string, []dnscheck.CAA, error)
+}
+
+// Status is the certificate's state at one point in time.
+type Status struct {
+	CertificateArn string
+	// Status is the ACM status, e.g. PENDING_VALIDATION or ISSUED.
+	Status    string
+	CreatedAt time.Time
+	IssuedAt  time.Time
+	// Reason says why the certificate is not issued yet, most specific
+	// cause first; empty once it is issued.
+	Reason string
+}
+
+// Issued reports whether the certificate is issued.
+func (s *Status) Issued() bool {
+	return s.Status == acm.CertificateStatusIssued
+}
+
+// Failed reports whether ACM gave up on the certificate, so waiting longer
+// cannot help.
+func (s *Status) Failed() bool {
+	return s.Status != acm.CertificateStatusIssued && s.Status != acm.CertificateStatusPendingValidation
+}
+
+func (s *Status) String() string {
+	if s.Reason == "" {
+		return fmt.Sprintf("%s is %s", s.CertificateArn, s.Status)
+	}
+	return fmt.Sprintf("%s is %s: %s", s.CertificateArn, s.Status, s.Reason)
+}
+
+// Find returns the ARN of the newest certificate for DomainName that was
+// created at or after since.
+func (t *Tracker) Find(ctx context.Context, since time.Time) (string, error) {
+	var arns []string
+	err := t.ACM.ListCertificatesPagesWithContext(ctx, &acm.ListCertificatesInput{}, func(page *acm.ListCertificatesOutput, _ bool) bool {
+		for _, c := range page.CertificateSummaryList {
+			if strings.EqualFold(aws.StringValue(c.DomainName), t.DomainName) {
+				arns = append(arns, aws.StringValue(c.CertificateArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("list certificates: %w", err)
+	}
+
+	var newest *acm.CertificateDetail
+	for _, arn := range arns {
+		cert, err := t.describe(ctx, arn)
+		if err != nil {
+			return "", err
+		}
+		created := aws.TimeValue(cert.CreatedAt)
+		if created.Before(since) {
+			continue
+		}
+		if newest == nil || created.After(aws.TimeValue(newest.CreatedAt)) {
+			newest = cert
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no certificate for %s created since %s", t.DomainName, since.Format(time.RFC3339))
+	}
+	return aws.StringValue(newest.CertificateArn), nil
+}
+
+// Wait finds the certificate for DomainName created at or after since and
+// polls it until it is issued. Every failed attempt is logged with its
+// Reason through opts.Logf. It stops early when ACM gives up on the
+// certificate. The last Status is returned with the error, if there was
+// one.
+func (t *Tracker) Wait(ctx context.Context, since time.Time, opts poll.Options) (*Status, error) {
+	return t.wait(ctx, "", since, opts)
+}
+
+// WaitCertificate is Wait for the certificate arn.
+func (t *Tracker) WaitCertificate(ctx context.Context, arn string, opts poll.Options) (*Status, error) {
+	return t.wait(ctx, arn, time.Time{}, opts)
+}
+
+func (t *Tracker) wait(ctx context.Context, arn string, since time.Time, opts poll.Options) (*Status, error) {
+	var last *Status
+	err := poll.Until(ctx, opts, func(ctx context.Context) error {
+		if arn == "" {
+			found, err := t.Find(ctx, since)
+			if err != nil {
+				return err
+			}
+			arn = found
+		}
+		s, err := t.Diagnose(ctx, arn)
+		if err != nil {
+			return err
+		}
+		last = s
+		switch {
+		case s.Issued():
+			return nil
+		case s.Failed():
+			return poll.Permanent(errors.New(s.String()))
+		default:
+			return errors.New(s.String())
+		}
+	})
+	return last, err
+}
+
+// Diagnose describes the certificate and, unless it is issued, determines
+// the Reason.
+func (t *Tracker) Diagnose(ctx context.Context, arn string) (*Status, error) {
+	cert, err := t.describe(ctx, arn)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{
+		CertificateArn: arn,
+		Status:         aws.StringValue(cert.Status),
+		CreatedAt:      aws.TimeValue(cert.CreatedAt),
+		IssuedAt:       aws.TimeValue(cert.IssuedAt),
+	}
+	if s.Issued() {
+		return s, nil
+	}
+
+	// notes do not explain the stall by themselves
+	var reasons, notes []string
+	if s.Failed() {
+		reason := "ACM stopped validating"
+		if cert.FailureReason != nil {
+			reason += " (" + aws.StringValue(cert.FailureReason) + ")"
+		}
+		reasons = append(reasons, reason)
+	}
+
+	// A CAA record that excludes Amazon fails the request however the
+	// validation record looks
+	owner, records, err := t.lookupCAA(ctx, t.DomainName)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("could not check CAA records: %v", err))
+	} else if !AllowsAmazon(records) {
+		reasons = append(reasons, fmt.Sprintf("CAA records at %s (%s) do not allow Amazon to issue; add an issue record for amazon.com",
+			owner, joinCAA(records)))
+	}
+
+	zone, err := t.Route53.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: aws.String(t.ZoneID)})
+	if err != nil {
+		return nil, fmt.Errorf("get hosted zone %s: %w", t.ZoneID, err)
+	}
+	zoneName := strings.TrimSuffix(aws.StringValue(zone.HostedZone.Name), ".")
+	if !inZone(t.DomainName, zoneName) {
+		reasons = append(reasons, fmt.Sprintf("%s is not in hosted zone %s (%s), so the validation record cannot be created there",
+			t.DomainName, t.ZoneID, zoneName))
+	} else {
+		var nameServers []string
+		if zone.DelegationSet != nil {
+			nameServers = aws.StringValueSlice(zone.DelegationSet.NameServers)
+		}
+		for _, o := range cert.DomainValidationOptions {
+			if aws.StringValue(o.ValidationStatus) == acm.DomainStatusSuccess {
+				continue
+			}
+			reason, err := t.checkRecord(ctx, o, zoneName, nameServers)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	if len(reasons) == 0 {
+		reasons = append(reasons, "the validation record is in place and resolves publicly; waiting for ACM to validate it")
+	}
+	s.Reason = strings.Join(append(reasons, notes...), "; ")
+	return s, nil
+}
+
+// checkRecord returns why the validation record of o is not usable yet, or
+// "" when it is in the hosted zone and resolves publicly.
+func (t *Tracker) checkRecord(ctx context.Context, o *acm.DomainValidation, zoneName string, nameServers []string) (string, error) {
+	rr := o.ResourceRecord
+	if rr == nil {
+		return fmt.Sprintf("ACM has not published the validation record for %s yet", aws.StringValue(o.DomainName)), nil
+	}
+	name := strings.TrimSuffix(aws.StringValue(rr.Name), ".")
+	want := strings.TrimSuffix(aws.StringValue(rr.Value), ".")
+
+	out, err := t.Route53.ListResourceRecordSetsWithContext(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(t.ZoneID),
+		StartRecordName: aws.String(name),
+		StartRecordType: aws.String(route53.RRTypeCname),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("list records of hosted zone %s: %w", t.ZoneID, err)
+	}
+	var inZoneValue string
+	for _, set := range out.ResourceRecordSets {
+		if sameName(aws.StringValue(set.Name), name) && aws.StringValue(set.Type) == route53.RRTypeCname && len(set.ResourceRecords) > 0 {
+			inZoneValue = strings.TrimSuffix(aws.StringValue(set.ResourceRecords[0].Value), ".")
+		}
+	}
+	switch {
+	case inZoneValue == "":
+		return fmt.Sprintf("validation record %s CNAME %s is not in hosted zone %s", name, want, t.ZoneID), nil
+	case !sameName(inZoneValue, want):
+		return fmt.Sprintf("validation record %s in hosted zone %s points to %s, not %s", name, t.ZoneID, inZoneValue, want), nil
+	}
+
+	got, err := t.lookupCNAME(ctx, name)
+	if err != nil {
+		return fmt.Sprintf("validation record %s is in hosted zone %s but does not resolve publicly (%v); check that %s is delegated to %s",
+			name, t.ZoneID, err, zoneName, strings.Join(nameServers, ", ")), nil
+	}
+	if !sameName(got, want) {
+		return fmt.Sprintf("validation record %s resolves publicly to %s, not %s; another zone may be answering for %s",
+			name, strings.TrimSuffix(got, "."), want, zoneName), nil
+	}
+	return "", nil
+}
+
+func (t *Tracker) describe(ctx context.Context, arn string) (*acm.CertificateDetail, error) {
+	out, err := t.ACM.DescribeCertificateWithContext(ctx, &acm.DescribeCertificateInput{CertificateArn: aws.String(arn)})
+	if err != nil {
+		return nil, fmt.Errorf("describe certificate %s: %w", arn, err)
+	}
+	return out.Certificate, nil
+}
+
+func (t *Tracker) lookupCNAME(ctx context.Context, name string) (string, error) {
+	if t.LookupCNAME != nil {
+		return t.LookupCNAME(ctx, name)
+	}
+	var c dnscheck.Checker
+	return c.CNAME(ctx, name)
+}
+
+func (t *Tracker) lookupCAA(ctx context.Context, name string) (string, []dnscheck.CAA, error) {
+	if t.LookupCAA != nil {
+		return t.LookupCAA(ctx, name)
+	}
+	var c dnscheck.Checker
+	return c.CAA(ctx, name)
+}
+
+// AllowsAmazon reports whether a set of CAA records lets Amazon issue a
+// non-wildcard certificate: there are no issue records, or one of them
+// names an Amazon domain, and no critical property is unknown to CAs.
+func AllowsAmazon(records []dnscheck.CAA) bool {
+	hasIssue, amazon := false, false
+	for _, r := range records {
+		switch r.Tag {
+		case "issue":
+			hasIssue = true
+			// The issuer domain is followed by optional "; key=value"
+			// parameters; an empty one forbids every CA
+			issuer := strings.TrimSpace(strings.SplitN(r.Value, ";", 2)[0])
+			for _, d := range AmazonCAADomains {
+				if strings.EqualFold(issuer, d) {
+					amazon = true
+				}
+			}
+		case "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone":
+		default:
+			if r.Critical {
+				return false
+			}
+		}
+	}
+	return !hasIssue || amazon
+}
+
+func joinCAA(records []dnscheck.CAA) string {
+	s := make([]string, len(records))
+	for i, r := range records {
+		s[i] = r.String()
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func inZone(name, zone string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(zone)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func sameName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
@@ -0,0 +1,337 @@
+package acmcert
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/dnscheck"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	domain      = "bridge.example.com"
+	zoneID      = "Z0123456789"
+	certArn     = "arn:aws:acm:ap-northeast-1:123456789012:certificate/new"
+	oldCertArn  = "arn:aws:acm:ap-northeast-1:123456789012:certificate/old"
+	recordName  = "_abc123.bridge.example.com."
+	recordValue = "_def456.xlfgrmvvlj.acm-validations.aws."
+)
+
+var created = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeACM has an old certificate for the domain and a new one whose status
+// follows statuses, one per DescribeCertificate call (including the one
+// Find makes), holding the last.
+type fakeACM struct {
+	acmiface.ACMAPI
+	statuses []string
+	calls    int
+	// noRecord leaves the validation record unpublished
+	noRecord bool
+}
+
+func (f *fakeACM) ListCertificatesPagesWithContext(_ aws.Context, _ *acm.ListCertificatesInput, fn func(*acm.ListCertificatesOutput, bool) bool, _ ...request.Option) error {
+	fn(&acm.ListCertificatesOutput{CertificateSummaryList: []*acm.CertificateSummary{
+		{CertificateArn: aws.String(oldCertArn), DomainName: aws.String(domain)},
+		{CertificateArn: aws.String("arn:aws:acm:ap-northeast-1:123456789012:certificate/other"), DomainName: aws.String("other.example.com")},
+		{CertificateArn: aws.String(certArn), DomainName: aws.String(domain)},
+	}}, true)
+	return nil
+}
+
+func (f *fakeACM) DescribeCertificateWithContext(_ aws.Context, in *acm.DescribeCertificateInput, _ ...request.Option) (*acm.DescribeCertificateOutput, error) {
+	if aws.StringValue(in.CertificateArn) == oldCertArn {
+		return &acm.DescribeCertificateOutput{Certificate: &acm.CertificateDetail{
+			CertificateArn: in.CertificateArn,
+			Status:         aws.String(acm.CertificateStatusIssued),
+			CreatedAt:      aws.Time(created.Add(-24 * time.Hour)),
+		}}, nil
+	}
+
+	status := f.statuses[len(f.statuses)-1]
+	if f.calls < len(f.statuses) {
+		status = f.statuses[f.calls]
+	}
+	f.calls++
+	cert := &acm.CertificateDetail{
+		CertificateArn: in.CertificateArn,
+		Status:         aws.String(status),
+		CreatedAt:      aws.Time(created),
+	}
+	validation := &acm.DomainValidation{
+		DomainName:       aws.String(domain),
+		ValidationStatus: aws.String(acm.DomainStatusPendingValidation),
+	}
+	if !f.noRecord {
+		validation.ResourceRecord = &acm.ResourceRecord{Name: aws.String(recordName), Type: aws.String("CNAME"), Value: aws.String(recordValue)}
+	}
+	switch status {
+	case acm.CertificateStatusIssued:
+		cert.IssuedAt = aws.Time(created.Add(3 * time.Minute))
+		validation.ValidationStatus = aws.String(acm.DomainStatusSuccess)
+	case acm.CertificateStatusFailed:
+		cert.FailureReason = aws.String(acm.FailureReasonCaaError)
+		validation.ValidationStatus = aws.String(acm.DomainStatusFailed)
+	}
+	cert.DomainValidationOptions = []*acm.DomainValidation{validation}
+	return &acm.DescribeCertificateOutput{Certificate: cert}, nil
+}
+
+// fakeRoute53 is a hosted zone with records, a map of CNAME name to value.
+type fakeRoute53 struct {
+	route53iface.Route53API
+	zoneName string
+	records  map[string]string
+}
+
+func (f *fakeRoute53) GetHostedZoneWithContext(_ aws.Context, in *route53.GetHostedZoneInput, _ ...request.Option) (*route53.GetHostedZoneOutput, error) {
+	return &route53.GetHostedZoneOutput{
+		HostedZone:    &route53.HostedZone{Id: in.Id, Name: aws.String(f.zoneName + ".")},
+		DelegationSet: &route53.DelegationSet{NameServers: aws.StringSlice([]string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"})},
+	}, nil
+}
+
+func (f *fakeRoute53) ListResourceRecordSetsWithContext(_ aws.Context, in *route53.ListResourceRecordSetsInput, _ ...request.Option) (*route53.ListResourceRecordSetsOutput, error) {
+	// Like Route 53, start at the requested name and return the next
+	// record set even when it is a different name
+	out := &route53.ListResourceRecordSetsOutput{}
+	name := aws.StringValue(in.StartRecordName)
+	if value, ok := f.records[name]; ok {
+		out.ResourceRecordSets = append(out.ResourceRecordSets, &route53.ResourceRecordSet{
+			Name:            aws.String(name + "."),
+			Type:            aws.String(route53.RRTypeCname),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(value)}},
+		})
+	} else {
+		out.ResourceRecordSets = append(out.ResourceRecordSets, &route53.ResourceRecordSet{
+			Name: aws.String("bridge.example.com."),
+			Type: aws.String(route53.RRTypeA),
+		})
+	}
+	return out, nil
+}
+
+func TestDiagnose(t *testing.T) {
+	inZone := map[string]string{"_abc123.bridge.example.com": recordValue}
+	public := func(context.Context, string) (string, error) { return recordValue, nil }
+	nxdomain := func(context.Context, string) (string, error) {
+		return "", &net.DNSError{Err: "no such host", Name: "_abc123.bridge.example.com", IsNotFound: true}
+	}
+	noCAA := func(context.Context, string) (string, []dnscheck.CAA, error) { return "", nil, nil }
+
+	tests := []struct {
+		name     string
+		status   string
+		noRecord bool
+		zoneName string
+		records  map[string]string
+		cname    func(context.Context, string) (string, error)
+		caa      func(context.Context, string) (string, []dnscheck.CAA, error)
+		// reason is a substring of the expected Reason
+		reason string
+	}{
+		{
+			name:   "issued",
+			status: acm.CertificateStatusIssued,
+		},
+		{
+			name:   "waiting for ACM",
+			status: acm.CertificateStatusPendingValidation,
+			reason: "the validation record is in place and resolves publicly; waiting for ACM",
+		},
+		{
+			name:     "record not published",
+			status:   acm.CertificateStatusPendingValidation,
+			noRecord: true,
+			reason:   "ACM has not published the validation record for bridge.example.com yet",
+		},
+		{
+			name:     "wrong hosted zone",
+			status:   acm.CertificateStatusPendingValidation,
+			zoneName: "example.org",
+			reason:   "bridge.example.com is not in hosted zone Z0123456789 (example.org)",
+		},
+		{
+			name:    "record missing from zone",
+			status:  acm.CertificateStatusPendingValidation,
+			records: map[string]string{},
+			reason:  "validation record _abc123.bridge.example.com CNAME _def456.xlfgrmvvlj.acm-validations.aws is not in hosted zone Z0123456789",
+		},
+		{
+			name:    "record with wrong value",
+			status:  acm.CertificateStatusPendingValidation,
+			records: map[string]string{"_abc123.bridge.example.com": "_stale.acm-validations.aws."},
+			reason:  "points to _stale.acm-validations.aws, not _def456",
+		},
+		{
+			name:   "not delegated",
+			status: acm.CertificateStatusPendingValidation,
+			cname:  nxdomain,
+			reason: "does not resolve publicly (lookup _abc123.bridge.example.com: no such host); check that example.com is delegated to ns-1.awsdns-01.org, ns-2.awsdns-02.com",
+		},
+		{
+			name:   "other zone answers",
+			status: acm.CertificateStatusPendingValidation,
+			cname:  func(context.Context, string) (string, error) { return "_old.acm-validations.aws.", nil },
+			reason: "resolves publicly to _old.acm-validations.aws, not _def456",
+		},
+		{
+			name:   "CAA blocks Amazon",
+			status: acm.CertificateStatusPendingValidation,
+			caa: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "example.com", []dnscheck.CAA{{Tag: "issue", Value: "letsencrypt.org"}}, nil
+			},
+			reason: `CAA records at example.com (0 issue "letsencrypt.org") do not allow Amazon to issue`,
+		},
+		{
+			name:   "failed",
+			status: acm.CertificateStatusFailed,
+			caa: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "example.com", []dnscheck.CAA{{Tag: "issue", Value: ";"}}, nil
+			},
+			reason: "ACM stopped validating (CAA_ERROR); CAA records at example.com",
+		},
+		{
+			name:   "CAA lookup fails",
+			status: acm.CertificateStatusPendingValidation,
+			caa: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "", nil, errors.New("i/o timeout")
+			},
+			reason: "waiting for ACM to validate it; could not check CAA records: i/o timeout",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			zoneName, records, cname, caa := "example.com", inZone, public, noCAA
+			if tt.zoneName != "" {
+				zoneName = tt.zoneName
+			}
+			if tt.records != nil {
+				records = tt.records
+			}
+			if tt.cname != nil {
+				cname = tt.cname
+			}
+			if tt.caa != nil {
+				caa = tt.caa
+			}
+			tracker := &Tracker{
+				ACM:         &fakeACM{statuses: []string{tt.status}, noRecord: tt.noRecord},
+				Route53:     &fakeRoute53{zoneName: zoneName, records: records},
+				ZoneID:      zoneID,
+				DomainName:  domain,
+				LookupCNAME: cname,
+				LookupCAA:   caa,
+			}
+
+			s, err := tracker.Diagnose(context.Background(), certArn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, s.Status)
+			assert.Equal(t, created, s.CreatedAt)
+			if tt.reason == "" {
+				assert.Empty(t, s.Reason)
+			} else {
+				assert.Contains(t, s.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	tracker := &Tracker{ACM: &fakeACM{statuses: []string{acm.CertificateStatusPendingValidation}}, DomainName: domain}
+
+	arn, err := tracker.Find(context.Background(), created.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, certArn, arn)
+
+	arn, err = tracker.Find(context.Background(), created.Add(-48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, certArn, arn, "the newest certificate wins")
+
+	_, err = tracker.Find(context.Background(), created.Add(time.Minute))
+	assert.Error(t, err)
+}
+
+func TestWait(t *testing.T) {
+	opts := poll.Options{MaxAttempts: 5, Clock: poll.NewFakeClock(created)}
+	noCAA := func(context.Context, string) (string, []dnscheck.CAA, error) { return "", nil, nil }
+	newTracker := func(statuses ...string) *Tracker {
+		return &Tracker{
+			ACM:         &fakeACM{statuses: statuses},
+			Route53:     &fakeRoute53{zoneName: "example.com", records: map[string]string{"_abc123.bridge.example.com": recordValue}},
+			ZoneID:      zoneID,
+			DomainName:  domain,
+			LookupCNAME: func(context.Context, string) (string, error) { return recordValue, nil },
+			LookupCAA:   noCAA,
+		}
+	}
+
+	t.Run("issued", func(t *testing.T) {
+		var logs []string
+		opts := opts
+		opts.Logf = func(format string, args ...any) { logs = append(logs, format) }
+		s, err := newTracker(acm.CertificateStatusPendingValidation, acm.CertificateStatusPendingValidation, acm.CertificateStatusPendingValidation, acm.CertificateStatusIssued).
+			Wait(context.Background(), created.Add(-time.Minute), opts)
+		require.NoError(t, err)
+		assert.True(t, s.Issued())
+		assert.Equal(t, 3*time.Minute, s.IssuedAt.Sub(s.CreatedAt))
+		assert.Len(t, logs, 2)
+	})
+
+	t.Run("known certificate", func(t *testing.T) {
+		tracker := newTracker(acm.CertificateStatusPendingValidation, acm.CertificateStatusIssued)
+		s, err := tracker.WaitCertificate(context.Background(), certArn, opts)
+		require.NoError(t, err)
+		assert.True(t, s.Issued())
+		assert.Equal(t, 2, tracker.ACM.(*fakeACM).calls)
+	})
+
+	t.Run("failed stops early", func(t *testing.T) {
+		tracker := newTracker(acm.CertificateStatusPendingValidation, acm.CertificateStatusPendingValidation, acm.CertificateStatusFailed)
+		s, err := tracker.Wait(context.Background(), created.Add(-time.Minute), opts)
+		require.Error(t, err)
+		assert.True(t, s.Failed())
+		assert.Equal(t, 3, tracker.ACM.(*fakeACM).calls)
+		assert.Contains(t, err.Error(), "ACM stopped validating (CAA_ERROR)")
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		s, err := newTracker(acm.CertificateStatusPendingValidation).Wait(context.Background(), created.Add(-time.Minute), opts)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, poll.ErrMaxAttempts)
+		assert.Contains(t, err.Error(), "waiting for ACM to validate it")
+		assert.False(t, s.Issued())
+	})
+}
+
+func TestAllowsAmazon(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []dnscheck.CAA
+		want    bool
+	}{
+		{name: "no records", want: true},
+		{name: "amazon", records: []dnscheck.CAA{{Tag: "issue", Value: "letsencrypt.org"}, {Tag: "issue", Value: "amazon.com"}}, want: true},
+		{name: "amazontrust with parameters", records: []dnscheck.CAA{{Tag: "issue", Value: "amazontrust.com; validationmethods=dns-01"}}, want: true},
+		{name: "other CA only", records: []dnscheck.CAA{{Tag: "issue", Value: "letsencrypt.org"}}},
+		{name: "no CA", records: []dnscheck.CAA{{Tag: "issue", Value: ";"}}},
+		{name: "issuewild only", records: []dnscheck.CAA{{Tag: "issuewild", Value: "letsencrypt.org"}, {Tag: "iodef", Value: "mailto:a@example.com"}}, want: true},
+		{name: "unknown critical", records: []dnscheck.CAA{{Tag: "issue", Value: "amazon.com"}, {Critical: true, Tag: "future", Value: "x"}}},
+		{name: "unknown non-critical", records: []dnscheck.CAA{{Tag: "issue", Value: "amazon.com"}, {Tag: "future", Value: "x"}}, want: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, AllowsAmazon(tt.records), tt.name)
+	}
+}
@@ -0,0 +1,385 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Trimmed `terraform output -json` of examples/aws-ecs-fargate.
+const outputsJSON = `{
+  "alb_dns_name": {"sensitive": false, "type": "string", "value": "test-abc-alb-123.ap-northeast-1.elb.amazonaws.com"},
+  "rds_password": {"sensitive": true, "type": "string", "value": "hunter2"},
+  "rds_credentials_secret_arn": {"sensitive": false, "type": "string", "value": "arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:db"},
+  "bastion": {"sensitive": false, "type": ["object", {}], "value": {"ssh_private_key": "-----BEGIN", "public_ip": "203.0.113.10"}}
+}`
+
+// Trimmed `terraform show -json` of a state.
+const stateJSON = `{
+  "format_version": "1.0",
+  "values": {
+    "outputs": {"rds_endpoint": {"sensitive": false, "value": "db.example:5432"}},
+    "root_module": {
+      "resources": [
+        {
+          "address": "random_password.db",
+          "values": {"length": 32, "result": "s3cr3t-result", "special": true},
+          "sensitive_values": {"result": true}
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.basemachina_bridge",
+          "resources": [
+            {
+              "address": "module.basemachina_bridge.aws_ecs_task_definition.bridge",
+              "values": {"family": "bridge", "container_definitions": "[...]", "tags": {"Name": "bridge"}},
+              "sensitive_values": {"tags": {}}
+            },
+            {
+              "address": "module.basemachina_bridge.aws_db_instance.main",
+              "values": {"identifier": "db", "password": "plain-but-secret"},
+              "sensitive_values": {}
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+// Trimmed `terraform show -json` of a plan.
+const planJSON = `{
+  "variables": {"tenant_id": {"value": "tenant-1"}, "database_password": {"value": "pw"}, "region": {"value": "ap-northeast-1"}},
+  "configuration": {
+    "root_module": {
+      "variables": {"tenant_id": {}, "database_password": {"sensitive": true}, "region": {}},
+      "module_calls": {
+        "basemachina_bridge": {
+          "expressions": {"tenant_id": {"references": ["var.tenant_id"]}, "region": {"references": ["var.region"]}},
+          "module": {"variables": {"tenant_id": {"sensitive": true}, "region": {}}}
+        }
+      }
+    }
+  },
+  "planned_values": {"root_module": {"resources": [{"values": {"result": "x"}, "sensitive_values": {"result": true}}]}},
+  "resource_changes": [
+    {
+      "address": "aws_ssm_parameter.token",
+      "change": {
+        "actions": ["update"],
+        "before": {"value": "old", "tags": {"a": "b"}, "list": ["keep", "hide"]},
+        "after": {"value": "new", "tags": {"a": "c"}, "list": ["keep", "hide2"]},
+        "before_sensitive": {"value": true, "list": [false, true]},
+        "after_sensitive": {"value": true, "list": [false, true]}
+      }
+    }
+  ],
+  "output_changes": {
+    "db_password": {"before": "a", "after": "b", "before_sensitive": false, "after_sensitive": false},
+    "endpoint": {"before": null, "after": "https://x", "before_sensitive": false, "after_sensitive": false}
+  }
+}`
+
+func TestRedactOutputs(t *testing.T) {
+	out, err := RedactOutputs([]byte(outputsJSON))
+	require.NoError(t, err)
+	s := string(out)
+
+	assert.NotContains(t, s, "hunter2")
+	assert.NotContains(t, s, "-----BEGIN")
+	assert.Contains(t, s, "test-abc-alb-123.ap-northeast-1.elb.amazonaws.com")
+	assert.Contains(t, s, "203.0.113.10")
+	assert.NotContains(t, s, "arn:aws:secretsmanager", "outputs named like secrets are redacted")
+}
+
+func TestRedactState(t *testing.T) {
+	out, err := RedactState([]byte(stateJSON))
+	require.NoError(t, err)
+	s := string(out)
+
+	assert.NotContains(t, s, "s3cr3t-result", "sensitive_values mask")
+	assert.NotContains(t, s, "plain-but-secret", "secret-looking attribute names")
+	assert.Contains(t, s, `"length": 32`, "numbers are preserved")
+	assert.Contains(t, s, "db.example:5432")
+	assert.Contains(t, s, `"family": "bridge"`)
+}
+
+func TestRedactPlan(t *testing.T) {
+	out, err := RedactPlan([]byte(planJSON))
+	require.NoError(t, err)
+
+	var plan struct {
+		Variables map[string]struct{ Value any }
+		Changes   []struct {
+			Change struct{ Before, After map[string]any } `json:"change"`
+		} `json:"resource_changes"`
+		Outputs map[string]struct{ Before, After any } `json:"output_changes"`
+	}
+	require.NoError(t, json.Unmarshal(out, &plan))
+
+	assert.Equal(t, Redacted, plan.Variables["tenant_id"].Value, "passed to a sensitive module variable")
+	assert.Equal(t, Redacted, plan.Variables["database_password"].Value)
+	assert.Equal(t, "ap-northeast-1", plan.Variables["region"].Value)
+	assert.NotContains(t, string(out), "tenant-1")
+
+	after := plan.Changes[0].Change.After
+	assert.Equal(t, Redacted, after["value"])
+	assert.Equal(t, []any{"keep", Redacted}, after["list"])
+	assert.Equal(t, map[string]any{"a": "c"}, after["tags"])
+	assert.Equal(t, Redacted, plan.Changes[0].Change.Before["value"])
+
+	assert.Equal(t, Redacted, plan.Outputs["db_password"].After)
+	assert.Equal(t, "https://x", plan.Outputs["endpoint"].After)
+	assert.NotContains(t, string(out), `"result": "x"`)
+}
+
+func TestSensitiveValues(t *testing.T) {
+	values, err := SensitiveValues([]byte(planJSON))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pw", "tenant-1"}, values)
+}
+
+func TestRedactRejectsInvalidJSON(t *testing.T) {
+	_, err := RedactState([]byte("Error: no state"))
+	assert.Error(t, err)
+}
+
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Log(args ...any) { l.lines = append(l.lines, fmt.Sprintln(args...)) }
+func (l *recordingLogger) Logf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+type fakeTB struct {
+	recordingLogger
+	failed bool
+}
+
+func (f *fakeTB) Failed() bool { return f.failed }
+
+func TestBundleCollect(t *testing.T) {
+	root := t.TempDir()
+	b := New("aws-ecs-fargate", "abc123")
+
+	tb := &recordingLogger{}
+	diag := b.Logger(tb, "diagnoseSecurityGroups")
+	diag.Logf("ALB SG %s allows %d rules", "sg-1", 2)
+	diag.Log("DIAGNOSIS:", "Target.Timeout")
+	assert.Len(t, tb.lines, 2, "lines are still written to the test log")
+
+	dir, err := b.Collect(root,
+		func(w *Writer) { w.JSON("ecs/service.json", map[string]string{"status": "ACTIVE"}) },
+		func(w *Writer) { w.Error("logs", errors.New("AccessDenied")) },
+		func(w *Writer) { w.Text("notes.txt", "ok\n") },
+	)
+	assert.ErrorContains(t, err, "logs: AccessDenied")
+	assert.Equal(t, filepath.Join(root, "aws-ecs-fargate-abc123"), dir)
+
+	var findings []Finding
+	data, readErr := os.ReadFile(filepath.Join(dir, "findings.json"))
+	require.NoError(t, readErr)
+	require.NoError(t, json.Unmarshal(data, &findings))
+	require.Len(t, findings, 2)
+	assert.Equal(t, "diagnoseSecurityGroups", findings[0].Source)
+	assert.Equal(t, "ALB SG sg-1 allows 2 rules", findings[0].Message)
+	assert.Equal(t, "DIAGNOSIS: Target.Timeout", findings[1].Message)
+
+	assert.FileExists(t, filepath.Join(dir, "ecs", "service.json"))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+	errs, readErr := os.ReadFile(filepath.Join(dir, "errors.txt"))
+	require.NoError(t, readErr)
+	assert.Contains(t, string(errs), "AccessDenied")
+}
+
+func TestCollectOnFailure(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(DirEnv, root)
+	t.Setenv(AlwaysEnv, "")
+	b := New("gcp-cloud-run", "xyz")
+	called := 0
+	collect := func(*Writer) { called++ }
+
+	b.CollectOnFailure(&fakeTB{}, collect)
+	assert.Zero(t, called, "passing tests collect nothing")
+	assert.NoDirExists(t, filepath.Join(root, "gcp-cloud-run-xyz"))
+
+	b.CollectOnFailure(&fakeTB{failed: true}, collect)
+	assert.Equal(t, 1, called)
+	assert.DirExists(t, filepath.Join(root, "gcp-cloud-run-xyz"))
+
+	t.Setenv(AlwaysEnv, "true")
+	b.CollectOnFailure(&fakeTB{}, collect)
+	assert.Equal(t, 2, called)
+}
+
+type fakeECS struct {
+	ecsiface.ECSAPI
+	tasks map[string][]*ecs.Task
+}
+
+func (f *fakeECS) DescribeServices(*ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	return &ecs.DescribeServicesOutput{Services: []*ecs.Service{{
+		ServiceName:    aws.String("bridge"),
+		TaskDefinition: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:3"),
+	}}}, nil
+}
+
+func (f *fakeECS) ListTasks(in *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	var arns []*string
+	for _, task := range f.tasks[aws.StringValue(in.DesiredStatus)] {
+		arns = append(arns, task.TaskArn)
+	}
+	return &ecs.ListTasksOutput{TaskArns: arns}, nil
+}
+
+func (f *fakeECS) DescribeTasks(in *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	var out []*ecs.Task
+	for _, tasks := range f.tasks {
+		for _, task := range tasks {
+			for _, arn := range in.Tasks {
+				if aws.StringValue(arn) == aws.StringValue(task.TaskArn) {
+					out = append(out, task)
+				}
+			}
+		}
+	}
+	return &ecs.DescribeTasksOutput{Tasks: out}, nil
+}
+
+func (f *fakeECS) DescribeTaskDefinition(*ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{
+		Family: aws.String("bridge"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name: aws.String("bridge"),
+			Environment: []*ecs.KeyValuePair{
+				{Name: aws.String("TENANT_ID"), Value: aws.String("tenant-1")},
+				{Name: aws.String("API_TOKEN"), Value: aws.String("tok")},
+			},
+		}},
+	}}, nil
+}
+
+func TestCollectECS(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{dir: dir}
+	// As CollectTerraform does for the sensitive tenant_id
+	w.Redact("tenant-1")
+	CollectECS(w, &fakeECS{tasks: map[string][]*ecs.Task{
+		ecs.DesiredStatusStopped: {{TaskArn: aws.String("arn:task/1"), StoppedReason: aws.String("Essential container in task exited")}},
+	}}, ECSTarget{Cluster: "c", Service: "bridge"})
+	assert.Empty(t, w.errs)
+
+	stopped, err := os.ReadFile(filepath.Join(dir, "ecs", "tasks-stopped.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(stopped), "Essential container in task exited")
+
+	running, err := os.ReadFile(filepath.Join(dir, "ecs", "tasks-running.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", string(running))
+
+	def, err := os.ReadFile(filepath.Join(dir, "ecs", "task-definition.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(def), "tenant-1")
+	assert.NotContains(t, string(def), `"tok"`)
+	assert.Contains(t, string(def), Redacted)
+}
+
+func TestCollectECSWhenPlanFails(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{dir: dir}
+	// The tenant ID is known to the test; the plan that would reveal the
+	// sensitive values cannot be made
+	w.Redact("tenant-1")
+	CollectTerraform(t, w, &terraform.Options{TerraformDir: t.TempDir(), TerraformBinary: "false"})
+	CollectECS(w, &fakeECS{}, ECSTarget{Cluster: "c", Service: "bridge"})
+
+	var failed []string
+	for _, err := range w.errs {
+		failed = append(failed, err.Error())
+	}
+	assert.Contains(t, strings.Join(failed, "\n"), "plan.json")
+	def, err := os.ReadFile(filepath.Join(dir, "ecs", "task-definition.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(def), "tenant-1")
+	assert.Contains(t, string(def), Redacted)
+}
+
+type fakeELB struct {
+	elbv2iface.ELBV2API
+}
+
+func (fakeELB) DescribeLoadBalancers(*elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	return &elbv2.DescribeLoadBalancersOutput{LoadBalancers: []*elbv2.LoadBalancer{{LoadBalancerName: aws.String("alb")}}}, nil
+}
+
+func (fakeELB) DescribeListeners(*elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	return nil, errors.New("AccessDenied: elasticloadbalancing:DescribeListeners")
+}
+
+func (fakeELB) DescribeTargetGroups(*elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	return &elbv2.DescribeTargetGroupsOutput{TargetGroups: []*elbv2.TargetGroup{
+		{TargetGroupName: aws.String("bridge-tg"), TargetGroupArn: aws.String("arn:tg")},
+	}}, nil
+}
+
+func (fakeELB) DescribeTargetHealth(*elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []*elbv2.TargetHealthDescription{{
+		TargetHealth: &elbv2.TargetHealth{State: aws.String("unhealthy"), Reason: aws.String("Target.Timeout")},
+	}}}, nil
+}
+
+func TestCollectALBContinuesAfterErrors(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{dir: dir}
+	CollectALB(w, fakeELB{}, "arn:alb")
+
+	require.Len(t, w.errs, 1)
+	assert.ErrorContains(t, w.errs[0], "alb/listeners.json: AccessDenied")
+	health, err := os.ReadFile(filepath.Join(dir, "alb", "target-health.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(health), "Target.Timeout")
+	assert.Contains(t, string(health), "bridge-tg")
+}
+
+type fakeLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (fakeLogs) DescribeLogStreams(in *cloudwatchlogs.DescribeLogStreamsInput) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	return &cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: []*cloudwatchlogs.LogStream{
+		{LogStreamName: aws.String("bridge/bridge/0123abcd")},
+	}}, nil
+}
+
+func (fakeLogs) GetLogEvents(*cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	return &cloudwatchlogs.GetLogEventsOutput{Events: []*cloudwatchlogs.OutputLogEvent{
+		{Timestamp: aws.Int64(1704067200000), Message: aws.String("failed to fetch public keys\n")},
+	}}, nil
+}
+
+func TestCollectCloudWatchLogs(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{dir: dir}
+	CollectCloudWatchLogs(w, fakeLogs{}, "/ecs/bridge", LogOptions{})
+
+	data, err := os.ReadFile(filepath.Join(dir, "logs", "bridge_bridge_0123abcd.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-01T00:00:00Z failed to fetch public keys\n", string(data))
+}
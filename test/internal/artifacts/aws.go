@@ -0,0 +1,196 @@
+package artifacts
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// ECSTarget identifies the ECS service and load balancer of a deployment.
+type ECSTarget struct {
+	Cluster         string
+	Service         string
+	LoadBalancerArn string
+}
+
+// CollectECS writes the ECS service, its running and stopped tasks and
+// task definition to ecs/*.json.
+func CollectECS(w *Writer, client ecsiface.ECSAPI, target ECSTarget) {
+	services, svcErr := client.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(target.Cluster),
+		Services: []*string{aws.String(target.Service)},
+	})
+	if svcErr != nil {
+		w.Error("ecs/service.json", svcErr)
+	} else {
+		w.JSON("ecs/service.json", services)
+	}
+
+	for _, status := range []string{ecs.DesiredStatusRunning, ecs.DesiredStatusStopped} {
+		name := fmt.Sprintf("ecs/tasks-%s.json", strings.ToLower(status))
+		list, err := client.ListTasks(&ecs.ListTasksInput{
+			Cluster:       aws.String(target.Cluster),
+			ServiceName:   aws.String(target.Service),
+			DesiredStatus: aws.String(status),
+		})
+		if err != nil {
+			w.Error(name, err)
+			continue
+		}
+		if len(list.TaskArns) == 0 {
+			w.JSON(name, []any{})
+			continue
+		}
+		tasks, err := client.DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: aws.String(target.Cluster),
+			Tasks:   list.TaskArns,
+		})
+		if err != nil {
+			w.Error(name, err)
+			continue
+		}
+		w.JSON(name, tasks.Tasks)
+	}
+
+	if svcErr == nil && len(services.Services) > 0 {
+		def, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: services.Services[0].TaskDefinition,
+		})
+		if err != nil {
+			w.Error("ecs/task-definition.json", err)
+		} else {
+			w.JSON("ecs/task-definition.json", redactTaskDefinition(w, def.TaskDefinition))
+		}
+	}
+}
+
+// redactTaskDefinition hides plain environment values with secret-looking
+// names or sensitive values. Values from Secrets Manager are only ARNs and
+// are kept.
+func redactTaskDefinition(w *Writer, def *ecs.TaskDefinition) *ecs.TaskDefinition {
+	if def == nil {
+		return nil
+	}
+	for _, c := range def.ContainerDefinitions {
+		for _, env := range c.Environment {
+			if secretKey.MatchString(aws.StringValue(env.Name)) || w.isSensitive(aws.StringValue(env.Value)) {
+				env.Value = aws.String(Redacted)
+			}
+		}
+	}
+	return def
+}
+
+// CollectALB writes the load balancer, listeners, target groups and target
+// health to alb/*.json.
+func CollectALB(w *Writer, client elbv2iface.ELBV2API, loadBalancerArn string) {
+	lbs, err := client.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: []*string{aws.String(loadBalancerArn)},
+	})
+	if err != nil {
+		w.Error("alb/load-balancer.json", err)
+		return
+	}
+	w.JSON("alb/load-balancer.json", lbs.LoadBalancers)
+
+	listeners, err := client.DescribeListeners(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: aws.String(loadBalancerArn),
+	})
+	if err != nil {
+		w.Error("alb/listeners.json", err)
+	} else {
+		w.JSON("alb/listeners.json", listeners.Listeners)
+	}
+
+	tgs, err := client.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		LoadBalancerArn: aws.String(loadBalancerArn),
+	})
+	if err != nil {
+		w.Error("alb/target-groups.json", err)
+		return
+	}
+	w.JSON("alb/target-groups.json", tgs.TargetGroups)
+
+	health := map[string][]*elbv2.TargetHealthDescription{}
+	for _, tg := range tgs.TargetGroups {
+		out, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: tg.TargetGroupArn,
+		})
+		if err != nil {
+			w.Error("alb/target-health.json", err)
+			continue
+		}
+		health[aws.StringValue(tg.TargetGroupName)] = out.TargetHealthDescriptions
+	}
+	w.JSON("alb/target-health.json", health)
+}
+
+// LogOptions limits how many container log lines are collected.
+type LogOptions struct {
+	// Streams is the number of most recent log streams (default 5).
+	Streams int
+	// Lines is the number of most recent events per stream (default 500).
+	Lines int
+}
+
+func (o LogOptions) withDefaults() LogOptions {
+	if o.Streams <= 0 {
+		o.Streams = 5
+	}
+	if o.Lines <= 0 {
+		o.Lines = 500
+	}
+	return o
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CollectCloudWatchLogs writes the most recent events of the most recent
+// streams of logGroup to logs/<stream>.log.
+func CollectCloudWatchLogs(w *Writer, client cloudwatchlogsiface.CloudWatchLogsAPI, logGroup string, opts LogOptions) {
+	opts = opts.withDefaults()
+	streams, err := client.DescribeLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName: aws.String(logGroup),
+		OrderBy:      aws.String(cloudwatchlogs.OrderByLastEventTime),
+		Descending:   aws.Bool(true),
+		Limit:        aws.Int64(int64(opts.Streams)),
+	})
+	if err != nil {
+		w.Error("logs", err)
+		return
+	}
+	if len(streams.LogStreams) == 0 {
+		w.Text("logs/README.txt", fmt.Sprintf("no log streams in %s\n", logGroup))
+		return
+	}
+
+	for _, stream := range streams.LogStreams {
+		streamName := aws.StringValue(stream.LogStreamName)
+		name := "logs/" + unsafeFileChars.ReplaceAllString(streamName, "_") + ".log"
+		events, err := client.GetLogEvents(&cloudwatchlogs.GetLogEventsInput{
+			LogGroupName:  aws.String(logGroup),
+			LogStreamName: aws.String(streamName),
+			Limit:         aws.Int64(int64(opts.Lines)),
+			StartFromHead: aws.Bool(false),
+		})
+		if err != nil {
+			w.Error(name, err)
+			continue
+		}
+		var b strings.Builder
+		for _, e := range events.Events {
+			ts := time.UnixMilli(aws.Int64Value(e.Timestamp)).UTC().Format(time.RFC3339Nano)
+			fmt.Fprintf(&b, "%s %s\n", ts, strings.TrimRight(aws.StringValue(e.Message), "\n"))
+		}
+		w.Text(name, b.String())
+	}
+}
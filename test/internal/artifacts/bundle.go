@@ -0,0 +1,223 @@
+// Package artifacts writes a per-run directory of evidence for post-mortems
+// of failed integration tests: redacted Terraform plan, state and outputs,
+// diagnostic findings, container logs and cloud resource descriptions.
+//
+// Everything is collected before terraform destroy runs, so the bundle
+// survives the stack:
+//
+//	bundle := artifacts.New("aws-ecs-fargate", uniqueID)
+//	defer terraform.Destroy(t, options)
+//	defer bundle.CollectOnFailure(t, func(w *artifacts.Writer) {
+//		artifacts.CollectTerraform(t, w, options)
+//	})
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirEnv names the environment variable overriding the artifact root.
+const DirEnv = "TEST_ARTIFACT_DIR"
+
+// DefaultDir is used when DirEnv is unset. Tests run from test/aws and
+// test/gcp, so this resolves to test/artifacts.
+const DefaultDir = "../artifacts"
+
+// AlwaysEnv names the environment variable that, when set to "true",
+// collects the bundle for passing runs too.
+const AlwaysEnv = "TEST_ARTIFACTS_ALWAYS"
+
+// Dir returns the root directory bundles are written to.
+func Dir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// Logger is the logging subset of testing.T used by the diagnostics.
+type Logger interface {
+	Log(args ...any)
+	Logf(format string, args ...any)
+}
+
+// Finding is one line logged by a diagnostic.
+type Finding struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// Bundle accumulates diagnostic findings during a run and writes the
+// artifact directory on demand. It is safe for concurrent use.
+type Bundle struct {
+	Suite string
+	RunID string
+
+	mu       sync.Mutex
+	findings []Finding
+	now      func() time.Time
+}
+
+// New returns an empty bundle for one run of suite.
+func New(suite, runID string) *Bundle {
+	return &Bundle{Suite: suite, RunID: runID, now: time.Now}
+}
+
+// Findings returns the findings recorded so far.
+func (b *Bundle) Findings() []Finding {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Finding(nil), b.findings...)
+}
+
+// Logger returns a Logger that writes to l and records every line as a
+// finding from source.
+func (b *Bundle) Logger(l Logger, source string) Logger {
+	return &teeLogger{l: l, b: b, source: source}
+}
+
+func (b *Bundle) record(source, msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.findings = append(b.findings, Finding{Time: b.now(), Source: source, Message: msg})
+}
+
+type teeLogger struct {
+	l      Logger
+	b      *Bundle
+	source string
+}
+
+func (t *teeLogger) Log(args ...any) {
+	t.l.Log(args...)
+	t.b.record(t.source, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (t *teeLogger) Logf(format string, args ...any) {
+	t.l.Logf(format, args...)
+	t.b.record(t.source, fmt.Sprintf(format, args...))
+}
+
+// Writer writes files into a bundle directory. Write errors are collected
+// rather than returned so that one failing collector does not prevent the
+// others from running.
+type Writer struct {
+	dir       string
+	mu        sync.Mutex
+	errs      []error
+	sensitive map[string]bool
+}
+
+// Dir returns the bundle directory.
+func (w *Writer) Dir() string { return w.dir }
+
+// Bytes writes data to name, which may contain subdirectories.
+func (w *Writer) Bytes(name string, data []byte) {
+	path := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		w.Error(name, err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		w.Error(name, err)
+	}
+}
+
+// Text writes s to name.
+func (w *Writer) Text(name, s string) {
+	w.Bytes(name, []byte(s))
+}
+
+// JSON writes v as indented JSON to name.
+func (w *Writer) JSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		w.Error(name, err)
+		return
+	}
+	w.Bytes(name, append(data, '\n'))
+}
+
+// Redact marks values as sensitive. Collectors that run later replace
+// container environment values equal to one of them. CollectTerraform
+// marks the values of sensitive variables.
+func (w *Writer) Redact(values ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sensitive == nil {
+		w.sensitive = map[string]bool{}
+	}
+	for _, v := range values {
+		if v != "" {
+			w.sensitive[v] = true
+		}
+	}
+}
+
+// isSensitive reports whether v was marked by Redact.
+func (w *Writer) isSensitive(v string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sensitive[v]
+}
+
+// Error records a collection failure for name. All errors are written to
+// errors.txt when the bundle is finished.
+func (w *Writer) Error(name string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errs = append(w.errs, fmt.Errorf("%s: %w", name, err))
+}
+
+// Collect creates <root>/<suite>-<run id>, runs the collectors and writes
+// findings.json and, if anything failed, errors.txt. It returns the
+// directory and the joined collection errors.
+func (b *Bundle) Collect(root string, collectors ...func(w *Writer)) (string, error) {
+	dir := filepath.Join(root, fmt.Sprintf("%s-%s", b.Suite, b.RunID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	w := &Writer{dir: dir}
+	for _, collect := range collectors {
+		collect(w)
+	}
+	w.JSON("findings.json", b.Findings())
+
+	err := errors.Join(w.errs...)
+	if err != nil {
+		w.Text("errors.txt", err.Error()+"\n")
+	}
+	return dir, err
+}
+
+// TB is the subset of testing.TB used by CollectOnFailure.
+type TB interface {
+	Logger
+	Failed() bool
+}
+
+// CollectOnFailure collects the bundle into Dir() when the test has failed
+// (or AlwaysEnv is "true"). Defer it after the destroy so that it runs
+// first.
+func (b *Bundle) CollectOnFailure(t TB, collectors ...func(w *Writer)) {
+	if !t.Failed() && os.Getenv(AlwaysEnv) != "true" {
+		return
+	}
+	t.Log("Collecting failure artifacts...")
+	dir, err := b.Collect(Dir(), collectors...)
+	if abs, absErr := filepath.Abs(dir); absErr == nil {
+		dir = abs
+	}
+	if err != nil {
+		t.Logf("Some artifacts could not be collected (see errors.txt): %v", err)
+	}
+	t.Logf("Failure artifacts written: %s", dir)
+}
@@ -0,0 +1,109 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/api/iterator"
+	logging "google.golang.org/api/logging/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// CollectCloudRun writes the Cloud Run service and its revisions to
+// cloud-run/*.json. serviceName is the full resource name
+// (projects/*/locations/*/services/*).
+func CollectCloudRun(ctx context.Context, w *Writer, services *run.ServicesClient, revisions *run.RevisionsClient, serviceName string) {
+	svc, err := services.GetService(ctx, &runpb.GetServiceRequest{Name: serviceName})
+	if err != nil {
+		w.Error("cloud-run/service.json", err)
+	} else {
+		writeProto(w, "cloud-run/service.json", redactService(w, svc))
+	}
+
+	it := revisions.ListRevisions(ctx, &runpb.ListRevisionsRequest{Parent: serviceName})
+	var revs []string
+	for {
+		rev, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			w.Error("cloud-run/revisions.json", err)
+			return
+		}
+		for _, c := range rev.GetContainers() {
+			redactEnv(w, c)
+		}
+		data, err := protojson.Marshal(rev)
+		if err != nil {
+			w.Error("cloud-run/revisions.json", err)
+			return
+		}
+		revs = append(revs, string(data))
+	}
+	w.Bytes("cloud-run/revisions.json", []byte("["+strings.Join(revs, ",")+"]\n"))
+}
+
+func redactService(w *Writer, svc *runpb.Service) *runpb.Service {
+	for _, c := range svc.GetTemplate().GetContainers() {
+		redactEnv(w, c)
+	}
+	return svc
+}
+
+// redactEnv hides plain environment values with secret-looking names or
+// sensitive values. Values from Secret Manager are only references and are
+// kept.
+func redactEnv(w *Writer, c *runpb.Container) {
+	for _, env := range c.GetEnv() {
+		if env.GetValue() != "" && (secretKey.MatchString(env.GetName()) || w.isSensitive(env.GetValue())) {
+			env.Values = &runpb.EnvVar_Value{Value: Redacted}
+		}
+	}
+}
+
+func writeProto(w *Writer, name string, m proto.Message) {
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m)
+	if err != nil {
+		w.Error(name, err)
+		return
+	}
+	w.Bytes(name, append(data, '\n'))
+}
+
+// CollectCloudRunLogs writes the most recent log entries of a Cloud Run
+// service to logs/cloud-run.log.
+func CollectCloudRunLogs(ctx context.Context, w *Writer, client *logging.Service, project, region, service string, opts LogOptions) {
+	opts = opts.withDefaults()
+	filter := fmt.Sprintf(`resource.type="cloud_run_revision" AND resource.labels.service_name=%q AND resource.labels.location=%q`, service, region)
+	resp, err := client.Entries.List(&logging.ListLogEntriesRequest{
+		ResourceNames: []string{"projects/" + project},
+		Filter:        filter,
+		OrderBy:       "timestamp desc",
+		PageSize:      int64(opts.Lines),
+	}).Context(ctx).Do()
+	if err != nil {
+		w.Error("logs/cloud-run.log", err)
+		return
+	}
+
+	var b strings.Builder
+	// Entries are newest first; write them in chronological order.
+	for i := len(resp.Entries) - 1; i >= 0; i-- {
+		e := resp.Entries[i]
+		msg := e.TextPayload
+		if msg == "" && len(e.JsonPayload) > 0 {
+			msg = string(e.JsonPayload)
+		}
+		if msg == "" && e.HttpRequest != nil {
+			msg = fmt.Sprintf("%s %s %d", e.HttpRequest.RequestMethod, e.HttpRequest.RequestUrl, e.HttpRequest.Status)
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", e.Timestamp, e.Severity, msg)
+	}
+	w.Text("logs/cloud-run.log", b.String())
+}
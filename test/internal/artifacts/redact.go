@@ -0,0 +1,269 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces every sensitive value written to a bundle.
+const Redacted = "(redacted)"
+
+// secretKey matches attribute, output and variable names whose values are
+// redacted even when Terraform does not mark them sensitive.
+var secretKey = regexp.MustCompile(`(?i)(password|secret|token|private_key|credential)`)
+
+// RedactOutputs redacts `terraform output -json`.
+func RedactOutputs(raw []byte) ([]byte, error) {
+	var outputs map[string]any
+	if err := decode(raw, &outputs); err != nil {
+		return nil, err
+	}
+	redactOutputMap(outputs)
+	return encode(outputs)
+}
+
+// RedactState redacts `terraform show -json` of a state.
+func RedactState(raw []byte) ([]byte, error) {
+	var state map[string]any
+	if err := decode(raw, &state); err != nil {
+		return nil, err
+	}
+	redactState(state)
+	return encode(state)
+}
+
+// RedactPlan redacts `terraform show -json` of a saved plan.
+func RedactPlan(raw []byte) ([]byte, error) {
+	var plan map[string]any
+	if err := decode(raw, &plan); err != nil {
+		return nil, err
+	}
+
+	sensitive := sensitiveVariables(plan)
+	if vars, ok := plan["variables"].(map[string]any); ok {
+		for name, v := range vars {
+			if secretKey.MatchString(name) || sensitive[name] {
+				if m, ok := v.(map[string]any); ok {
+					m["value"] = Redacted
+				}
+			}
+		}
+	}
+	if values, ok := plan["planned_values"].(map[string]any); ok {
+		redactValues(values)
+	}
+	if prior, ok := plan["prior_state"].(map[string]any); ok {
+		redactState(prior)
+	}
+	if changes, ok := plan["resource_changes"].([]any); ok {
+		for _, c := range changes {
+			rc, _ := c.(map[string]any)
+			if change, ok := rc["change"].(map[string]any); ok {
+				redactChange(change)
+			}
+		}
+	}
+	if changes, ok := plan["output_changes"].(map[string]any); ok {
+		for name, c := range changes {
+			change, ok := c.(map[string]any)
+			if !ok {
+				continue
+			}
+			if secretKey.MatchString(name) {
+				change["before"], change["after"] = Redacted, Redacted
+				continue
+			}
+			redactChange(change)
+		}
+	}
+	return encode(plan)
+}
+
+// SensitiveValues returns the string values of the root variables that
+// RedactPlan redacts because they are, or feed, sensitive variables. Other
+// collectors hide these values where they reappear, such as in container
+// environments.
+func SensitiveValues(raw []byte) ([]string, error) {
+	var plan map[string]any
+	if err := decode(raw, &plan); err != nil {
+		return nil, err
+	}
+	vars, _ := plan["variables"].(map[string]any)
+	var values []string
+	for name := range sensitiveVariables(plan) {
+		v, _ := vars[name].(map[string]any)
+		if s, ok := v["value"].(string); ok && s != "" {
+			values = append(values, s)
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// sensitiveVariables returns the root variables of a plan that are
+// declared sensitive or are passed, directly or through other modules, to
+// a module variable declared sensitive.
+func sensitiveVariables(plan map[string]any) map[string]bool {
+	config, _ := plan["configuration"].(map[string]any)
+	root, _ := config["root_module"].(map[string]any)
+	return sensitiveModuleVariables(root)
+}
+
+func sensitiveModuleVariables(module map[string]any) map[string]bool {
+	out := map[string]bool{}
+	vars, _ := module["variables"].(map[string]any)
+	for name, v := range vars {
+		if m, ok := v.(map[string]any); ok {
+			if sensitive, _ := m["sensitive"].(bool); sensitive {
+				out[name] = true
+			}
+		}
+	}
+	calls, _ := module["module_calls"].(map[string]any)
+	for _, c := range calls {
+		call, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		child, _ := call["module"].(map[string]any)
+		expressions, _ := call["expressions"].(map[string]any)
+		for arg := range sensitiveModuleVariables(child) {
+			expr, _ := expressions[arg].(map[string]any)
+			refs, _ := expr["references"].([]any)
+			for _, ref := range refs {
+				if s, _ := ref.(string); strings.HasPrefix(s, "var.") {
+					out[strings.TrimPrefix(s, "var.")] = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+func redactState(state map[string]any) {
+	if values, ok := state["values"].(map[string]any); ok {
+		redactValues(values)
+	}
+}
+
+// redactValues redacts a state or planned "values" object: outputs and
+// every resource of the root and child modules.
+func redactValues(values map[string]any) {
+	if outputs, ok := values["outputs"].(map[string]any); ok {
+		redactOutputMap(outputs)
+	}
+	if root, ok := values["root_module"].(map[string]any); ok {
+		redactModule(root)
+	}
+}
+
+func redactModule(module map[string]any) {
+	if resources, ok := module["resources"].([]any); ok {
+		for _, r := range resources {
+			res, ok := r.(map[string]any)
+			if !ok {
+				continue
+			}
+			res["values"] = applyMask(res["values"], res["sensitive_values"])
+			res["values"] = redactByName(res["values"])
+		}
+	}
+	if children, ok := module["child_modules"].([]any); ok {
+		for _, c := range children {
+			if child, ok := c.(map[string]any); ok {
+				redactModule(child)
+			}
+		}
+	}
+}
+
+func redactOutputMap(outputs map[string]any) {
+	for name, o := range outputs {
+		out, ok := o.(map[string]any)
+		if !ok {
+			continue
+		}
+		if sensitive, _ := out["sensitive"].(bool); sensitive || secretKey.MatchString(name) {
+			out["value"] = Redacted
+			continue
+		}
+		out["value"] = redactByName(out["value"])
+	}
+}
+
+// redactChange redacts a plan change using before_sensitive/after_sensitive.
+func redactChange(change map[string]any) {
+	change["before"] = redactByName(applyMask(change["before"], change["before_sensitive"]))
+	change["after"] = redactByName(applyMask(change["after"], change["after_sensitive"]))
+}
+
+// applyMask replaces the parts of v marked true in mask, which has the
+// shape of Terraform's sensitive_values (true, or objects/arrays of them).
+func applyMask(v, mask any) any {
+	switch m := mask.(type) {
+	case bool:
+		if m && v != nil {
+			return Redacted
+		}
+	case map[string]any:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		for k, sub := range m {
+			if val, ok := obj[k]; ok {
+				obj[k] = applyMask(val, sub)
+			}
+		}
+	case []any:
+		list, ok := v.([]any)
+		if !ok {
+			return v
+		}
+		for i := range m {
+			if i < len(list) {
+				list[i] = applyMask(list[i], m[i])
+			}
+		}
+	}
+	return v
+}
+
+// redactByName replaces non-empty strings stored under secret-looking keys.
+func redactByName(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, sub := range val {
+			if s, ok := sub.(string); ok && s != "" && secretKey.MatchString(k) {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = redactByName(sub)
+		}
+	case []any:
+		for i := range val {
+			val[i] = redactByName(val[i])
+		}
+	}
+	return v
+}
+
+func decode(raw []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,65 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// CollectTerraform writes redacted plan.json, state.json and outputs.json
+// for the working directory of options. The binary plan file is not kept
+// because it contains sensitive values in clear text.
+func CollectTerraform(t testing.TestingT, w *Writer, options *terraform.Options) {
+	// Keep the JSON (and its secrets) out of the test log.
+	quiet := *options
+	quiet.Logger = logger.Discard
+
+	if raw, err := terraform.RunTerraformCommandAndGetStdoutE(t, &quiet, "show", "-json"); err != nil {
+		w.Error("state.json", err)
+	} else {
+		writeRedacted(w, "state.json", raw, RedactState)
+	}
+
+	if raw, err := terraform.RunTerraformCommandAndGetStdoutE(t, &quiet, "output", "-json"); err != nil {
+		w.Error("outputs.json", err)
+	} else {
+		writeRedacted(w, "outputs.json", raw, RedactOutputs)
+	}
+
+	tmp, err := os.MkdirTemp("", "tfplan")
+	if err != nil {
+		w.Error("plan.json", err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+	planFile := filepath.Join(tmp, "tfplan")
+
+	planOptions := quiet
+	planOptions.PlanFilePath = planFile
+	args := terraform.FormatArgs(&planOptions, "plan", "-input=false")
+	if _, err := terraform.RunTerraformCommandAndGetStdoutE(t, &quiet, args...); err != nil {
+		w.Error("plan.json", err)
+		return
+	}
+	if raw, err := terraform.RunTerraformCommandAndGetStdoutE(t, &quiet, "show", "-json", planFile); err != nil {
+		w.Error("plan.json", err)
+	} else {
+		writeRedacted(w, "plan.json", raw, RedactPlan)
+		if values, err := SensitiveValues([]byte(raw)); err == nil {
+			w.Redact(values...)
+		}
+	}
+}
+
+func writeRedacted(w *Writer, name, raw string, redact func([]byte) ([]byte, error)) {
+	data, err := redact([]byte(raw))
+	if err != nil {
+		// Never fall back to the unredacted document.
+		w.Error(name, err)
+		return
+	}
+	w.Bytes(name, data)
+}
@@ -0,0 +1,212 @@
+// Package conformance checks Terraform modules against the house rules of
+// this repository without running Terraform: variables and outputs are
+// documented and typed, secrets are sensitive, and AWS tags and GCP labels
+// are passed down from var.tags and var.labels.
+package conformance
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Rules.
+const (
+	RuleVariableDescription = "variable-description"
+	RuleVariableType        = "variable-type"
+	RuleSensitiveSecret     = "sensitive-secret"
+	RuleOutputDescription   = "output-description"
+	RuleAWSTags             = "aws-tags"
+	RuleGCPLabels           = "gcp-labels"
+)
+
+// Violation is a broken rule at a position in a .tf file.
+type Violation struct {
+	// Pos is "<file>:<line>".
+	Pos     string
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: [%s] %s", v.Pos, v.Rule, v.Message)
+}
+
+// secretName matches variable names that hold credentials.
+var secretName = regexp.MustCompile(`(^|_)(tenant_id|password|secret|token|api_key|private_key|credentials?)($|_)`)
+
+// TaggableAWS lists AWS resource types that accept tags. Resources of other
+// types are only checked when they set tags. CheckSchema keeps the list in
+// line with the provider schema.
+var TaggableAWS = setOf(
+	"aws_acm_certificate", "aws_cloudwatch_log_group", "aws_cloudwatch_metric_alarm",
+	"aws_db_instance", "aws_db_subnet_group", "aws_ecr_repository",
+	"aws_ecs_cluster", "aws_ecs_service", "aws_ecs_task_definition",
+	"aws_eip", "aws_iam_instance_profile", "aws_iam_policy", "aws_iam_role",
+	"aws_instance", "aws_internet_gateway", "aws_key_pair", "aws_kms_key",
+	"aws_lambda_function", "aws_lb", "aws_lb_listener", "aws_lb_listener_rule",
+	"aws_lb_target_group", "aws_nat_gateway", "aws_route53_zone", "aws_route_table",
+	"aws_s3_bucket", "aws_secretsmanager_secret", "aws_security_group",
+	"aws_sns_topic", "aws_sqs_queue", "aws_ssm_parameter", "aws_subnet", "aws_vpc",
+	"aws_vpc_endpoint", "aws_vpc_security_group_egress_rule",
+	"aws_vpc_security_group_ingress_rule",
+)
+
+// LabelableGCP lists Google resource types that accept labels. Resources of
+// other types are only checked when they set labels. CheckSchema keeps
+// the list in line with the provider schema.
+var LabelableGCP = setOf(
+	"google_bigquery_dataset", "google_cloud_run_v2_job", "google_cloud_run_v2_service",
+	"google_cloudfunctions2_function", "google_compute_address", "google_compute_disk",
+	"google_compute_forwarding_rule", "google_compute_global_address",
+	"google_compute_global_forwarding_rule", "google_compute_image",
+	"google_compute_instance", "google_compute_instance_template",
+	"google_compute_snapshot", "google_dns_managed_zone", "google_kms_crypto_key",
+	"google_pubsub_subscription", "google_pubsub_topic", "google_secret_manager_secret",
+	"google_storage_bucket",
+)
+
+func setOf(items ...string) map[string]bool {
+	m := make(map[string]bool, len(items))
+	for _, i := range items {
+		m[i] = true
+	}
+	return m
+}
+
+// CheckFile checks one .tf file. filename is used for positions.
+func CheckFile(filename string, src []byte) ([]Violation, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parse %s: %s", filename, diags.Error())
+	}
+	body := file.Body.(*hclsyntax.Body)
+
+	var out []Violation
+	add := func(r hcl.Range, rule, format string, args ...any) {
+		out = append(out, Violation{Pos: fmt.Sprintf("%s:%d", r.Filename, r.Start.Line), Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, block := range body.Blocks {
+		attrs := block.Body.Attributes
+		switch block.Type {
+		case "variable":
+			name := block.Labels[0]
+			if !hasDescription(attrs) {
+				add(block.DefRange(), RuleVariableDescription, "variable %q has no description", name)
+			}
+			if _, ok := attrs["type"]; !ok {
+				add(block.DefRange(), RuleVariableType, "variable %q has no type", name)
+			}
+			if secretName.MatchString(name) && !isTrue(attrs["sensitive"]) {
+				add(block.DefRange(), RuleSensitiveSecret, "variable %q holds a secret and must be sensitive = true", name)
+			}
+		case "output":
+			if !hasDescription(attrs) {
+				add(block.DefRange(), RuleOutputDescription, "output %q has no description", block.Labels[0])
+			}
+		case "resource":
+			typ, name := block.Labels[0], block.Labels[1]
+			switch {
+			case strings.HasPrefix(typ, "aws_"):
+				checkPassedDown(block, "tags", TaggableAWS[typ], func(r hcl.Range, format string, args ...any) {
+					add(r, RuleAWSTags, "%s.%s: %s", typ, name, fmt.Sprintf(format, args...))
+				})
+			case strings.HasPrefix(typ, "google_"):
+				checkPassedDown(block, "labels", LabelableGCP[typ], func(r hcl.Range, format string, args ...any) {
+					add(r, RuleGCPLabels, "%s.%s: %s", typ, name, fmt.Sprintf(format, args...))
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+// checkPassedDown requires the attribute (tags or labels) to reference
+// var.<attribute>; a missing attribute is reported only if required.
+func checkPassedDown(block *hclsyntax.Block, attribute string, required bool, report func(hcl.Range, string, ...any)) {
+	attr, ok := block.Body.Attributes[attribute]
+	if !ok {
+		if required {
+			report(block.DefRange(), "does not set %s; set %s = var.%s or merge(var.%s, {...})", attribute, attribute, attribute, attribute)
+		}
+		return
+	}
+	for _, traversal := range attr.Expr.Variables() {
+		if traversal.RootName() != "var" || len(traversal) < 2 {
+			continue
+		}
+		if step, ok := traversal[1].(hcl.TraverseAttr); ok && step.Name == attribute {
+			return
+		}
+	}
+	report(attr.SrcRange, "%s does not include var.%s", attribute, attribute)
+}
+
+func hasDescription(attrs hclsyntax.Attributes) bool {
+	attr, ok := attrs["description"]
+	if !ok {
+		return false
+	}
+	val, diags := attr.Expr.Value(nil)
+	return !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type().FriendlyName() == "string" && strings.TrimSpace(val.AsString()) != ""
+}
+
+func isTrue(attr *hclsyntax.Attribute) bool {
+	if attr == nil {
+		return false
+	}
+	val, diags := attr.Expr.Value(nil)
+	return !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type().FriendlyName() == "bool" && val.True()
+}
+
+// CheckDir checks every .tf file under root. Positions are relative to
+// base, e.g. "modules/aws/ecs-fargate/alb.tf:10" for base = repository root.
+// Provider caches (.terraform) are skipped.
+func CheckDir(base, root string) ([]Violation, error) {
+	var out []Violation
+	err := walkTF(root, func(path string, src []byte) error {
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		violations, err := CheckFile(filepath.ToSlash(rel), src)
+		if err != nil {
+			return err
+		}
+		out = append(out, violations...)
+		return nil
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Pos < out[j].Pos })
+	return out, err
+}
+
+// walkTF calls fn with the contents of every .tf file under root, skipping
+// provider caches (.terraform).
+func walkTF(root string, fn func(path string, src []byte) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".tf" {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return fn(path, src)
+	})
+}
@@ -0,0 +1,192 @@
+package conformance
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixture = `variable "tenant_id" {
+  description = "Tenant ID"
+  type        = string
+}
+
+variable "undocumented" {
+  type = string
+}
+
+variable "untyped" {
+  description = "No type"
+}
+
+variable "api_key" {
+  description = "API key"
+  type        = string
+  sensitive   = true
+}
+
+variable "tags" {
+  description = "Tags"
+  type        = map(string)
+}
+
+resource "aws_lb" "main" {
+  name = "lb"
+  tags = merge(var.tags, { Name = "lb" })
+}
+
+resource "aws_lb_listener" "https" {
+  port = 443
+}
+
+resource "aws_security_group" "bridge" {
+  tags = { Name = "bridge" }
+}
+
+resource "aws_route" "nat" {
+  route_table_id = "rtb"
+}
+
+resource "google_compute_global_address" "default" {
+  name = "ip"
+}
+
+resource "google_cloud_run_v2_service" "bridge" {
+  labels = var.labels
+}
+
+resource "google_compute_url_map" "default" {
+  name = "map"
+}
+
+output "url" {
+  value = "https://example.com"
+}
+
+output "documented" {
+  description = "Documented"
+  value       = 1
+}
+`
+
+func TestCheckFile(t *testing.T) {
+	got, err := CheckFile("main.tf", []byte(fixture))
+	require.NoError(t, err)
+
+	var strs []string
+	for _, v := range got {
+		strs = append(strs, v.String())
+	}
+	assert.Equal(t, []string{
+		`main.tf:1: [sensitive-secret] variable "tenant_id" holds a secret and must be sensitive = true`,
+		`main.tf:6: [variable-description] variable "undocumented" has no description`,
+		`main.tf:10: [variable-type] variable "untyped" has no type`,
+		`main.tf:30: [aws-tags] aws_lb_listener.https: does not set tags; set tags = var.tags or merge(var.tags, {...})`,
+		`main.tf:35: [aws-tags] aws_security_group.bridge: tags does not include var.tags`,
+		`main.tf:42: [gcp-labels] google_compute_global_address.default: does not set labels; set labels = var.labels or merge(var.labels, {...})`,
+		`main.tf:54: [output-description] output "url" has no description`,
+	}, strs)
+}
+
+func TestCheckFileParseError(t *testing.T) {
+	_, err := CheckFile("broken.tf", []byte(`variable "x" {`))
+	assert.ErrorContains(t, err, "parse broken.tf")
+}
+
+func TestCheckDir(t *testing.T) {
+	base := t.TempDir()
+	module := filepath.Join(base, "modules", "aws", "m")
+	require.NoError(t, os.MkdirAll(filepath.Join(module, ".terraform"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(module, "outputs.tf"), []byte("output \"x\" {\n  value = 1\n}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(module, "README.md"), []byte("output \"ignored\" {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(module, ".terraform", "cached.tf"), []byte("output \"ignored\" {}\n"), 0o644))
+
+	got, err := CheckDir(base, filepath.Join(base, "modules"))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "modules/aws/m/outputs.tf:1", got[0].Pos)
+	assert.Equal(t, RuleOutputDescription, got[0].Rule)
+}
+
+// schemaFixture returns a provider schema in which every listed type has its
+// attribute, plus the given extra resource types and attributes.
+func schemaFixture(t *testing.T, extra map[string][]string) *Schema {
+	t.Helper()
+	providers := map[string]map[string]any{
+		"registry.terraform.io/hashicorp/aws":    {},
+		"registry.terraform.io/hashicorp/google": {},
+	}
+	add := func(typ string, attributes ...string) {
+		provider := "registry.terraform.io/hashicorp/aws"
+		if prefix(typ) == "google" {
+			provider = "registry.terraform.io/hashicorp/google"
+		}
+		attrs := map[string]any{"id": map[string]any{"type": "string", "computed": true}}
+		for _, a := range attributes {
+			attrs[a] = map[string]any{"type": []any{"map", "string"}, "optional": true}
+		}
+		providers[provider][typ] = map[string]any{"block": map[string]any{"attributes": attrs}}
+	}
+	for typ := range TaggableAWS {
+		add(typ, "tags")
+	}
+	for typ := range LabelableGCP {
+		add(typ, "labels")
+	}
+	for typ, attributes := range extra {
+		add(typ, attributes...)
+	}
+	schemas := map[string]any{}
+	for name, resources := range providers {
+		schemas[name] = map[string]any{"resource_schemas": resources}
+	}
+	data, err := json.Marshal(map[string]any{"format_version": "1.0", "provider_schemas": schemas})
+	require.NoError(t, err)
+	s, err := ParseSchema(data)
+	require.NoError(t, err)
+	return s
+}
+
+func TestCheckSchema(t *testing.T) {
+	assert.Empty(t, CheckSchema(schemaFixture(t, map[string][]string{
+		"aws_route":               nil,
+		"google_compute_url_map":  nil,
+		"google_compute_firewall": {"tags"},
+	}), []string{"aws_lb", "aws_route", "google_compute_url_map", "google_compute_firewall"}))
+
+	assert.Equal(t, []string{
+		"LabelableGCP does not list google_compute_network_endpoint_group, which has a labels attribute",
+		"TaggableAWS does not list aws_flow_log, which has a tags attribute",
+		"TaggableAWS lists aws_route_table, which has no tags attribute",
+	}, CheckSchema(schemaFixture(t, map[string][]string{
+		"aws_flow_log":                          {"tags"},
+		"aws_route_table":                       nil,
+		"aws_unused_but_taggable":               {"tags"},
+		"google_compute_network_endpoint_group": {"labels"},
+	}), []string{"aws_flow_log", "aws_route_table", "google_compute_network_endpoint_group"}))
+}
+
+func TestCheckSchemaMissingType(t *testing.T) {
+	s := schemaFixture(t, nil)
+	delete(s.ProviderSchemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas, "aws_lb")
+	delete(s.ProviderSchemas, "registry.terraform.io/hashicorp/google")
+	assert.Equal(t, []string{"TaggableAWS lists aws_lb, which the provider schema does not have"}, CheckSchema(s, nil))
+}
+
+func TestResourceTypes(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".terraform"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.tf"), []byte(fixture), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".terraform", "cached.tf"), []byte("resource \"aws_ignored\" \"x\" {}\n"), 0o644))
+
+	got, err := ResourceTypes(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"aws_lb", "aws_lb_listener", "aws_route", "aws_security_group",
+		"google_cloud_run_v2_service", "google_compute_global_address", "google_compute_url_map",
+	}, got)
+}
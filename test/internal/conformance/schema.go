@@ -0,0 +1,114 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Schema is the part of `terraform providers schema -json` that
+// CheckSchema reads.
+type Schema struct {
+	ProviderSchemas map[string]struct {
+		ResourceSchemas map[string]struct {
+			Block struct {
+				Attributes map[string]json.RawMessage `json:"attributes"`
+			} `json:"block"`
+		} `json:"resource_schemas"`
+	} `json:"provider_schemas"`
+}
+
+// ParseSchema parses the output of `terraform providers schema -json`.
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse provider schema: %w", err)
+	}
+	return &s, nil
+}
+
+// CheckSchema compares TaggableAWS and LabelableGCP with schema and returns
+// one problem per mismatch:
+//
+//   - a listed type whose schema has no tags (labels) attribute, or that is
+//     missing from a provider with the same prefix;
+//   - a type of used whose schema has the attribute but that is not listed,
+//     so that the aws-tags or gcp-labels rule would not require it.
+//
+// Types of providers the schema does not include are skipped.
+func CheckSchema(schema *Schema, used []string) []string {
+	attrs := map[string]map[string]bool{}
+	prefixes := map[string]bool{}
+	for _, p := range schema.ProviderSchemas {
+		for typ, r := range p.ResourceSchemas {
+			attrs[typ] = map[string]bool{}
+			for name := range r.Block.Attributes {
+				attrs[typ][name] = true
+			}
+			prefixes[prefix(typ)] = true
+		}
+	}
+
+	var out []string
+	for _, list := range []struct {
+		name      string
+		prefix    string
+		attribute string
+		types     map[string]bool
+	}{
+		{"TaggableAWS", "aws", "tags", TaggableAWS},
+		{"LabelableGCP", "google", "labels", LabelableGCP},
+	} {
+		for typ := range list.types {
+			a, known := attrs[typ]
+			switch {
+			case !known && prefixes[prefix(typ)]:
+				out = append(out, fmt.Sprintf("%s lists %s, which the provider schema does not have", list.name, typ))
+			case known && !a[list.attribute]:
+				out = append(out, fmt.Sprintf("%s lists %s, which has no %s attribute", list.name, typ, list.attribute))
+			}
+		}
+		for _, typ := range used {
+			if prefix(typ) == list.prefix && attrs[typ][list.attribute] && !list.types[typ] {
+				out = append(out, fmt.Sprintf("%s does not list %s, which has a %s attribute", list.name, typ, list.attribute))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// prefix returns the provider prefix of a resource type, e.g. "aws" for
+// aws_lb.
+func prefix(typ string) string {
+	p, _, _ := strings.Cut(typ, "_")
+	return p
+}
+
+// ResourceTypes returns the sorted resource types declared in the .tf files
+// under root, skipping provider caches (.terraform).
+func ResourceTypes(root string) ([]string, error) {
+	seen := map[string]bool{}
+	err := walkTF(root, func(path string, src []byte) error {
+		file, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+		if diags.HasErrors() {
+			return fmt.Errorf("parse %s: %s", path, diags.Error())
+		}
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == "resource" {
+				seen[block.Labels[0]] = true
+			}
+		}
+		return nil
+	})
+	types := make([]string, 0, len(seen))
+	for typ := range seen {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types, err
+}
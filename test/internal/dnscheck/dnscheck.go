@@ -0,0 +1,429 @@
+// Package dnscheck checks what a name resolves to by asking the
+// authoritative nameservers of its zone directly.
+//
+// The system resolver caches negative answers: a lookup made before the
+// Bridge record exists keeps failing for the SOA minimum TTL after the
+// record is created, which makes propagation waits flaky. The authoritative
+// nameservers answer from the zone itself, and asking every one of them
+// also shows whether the record has reached all of them. CAA records, which
+// decide whether a CA may issue for a name, and CNAME records, such as the
+// ACM validation records, are looked up the same way.
+package dnscheck
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Checker queries a zone's authoritative nameservers. The zero value uses
+// the system resolver to find them and queries them on port 53.
+type Checker struct {
+	// Resolver finds the zone of a name and the addresses of its
+	// nameservers (nil means net.DefaultResolver). These lookups are for
+	// names that already exist, so caching does not affect them.
+	Resolver *net.Resolver
+	// Port is the port the nameservers are queried on ("" means 53).
+	Port string
+	// Timeout bounds each query (0 means 5 seconds).
+	Timeout time.Duration
+}
+
+// Answer is one authoritative nameserver's answer for a name.
+type Answer struct {
+	// NameServer is the nameserver's host name and Server the address
+	// it was queried at.
+	NameServer string
+	Server     string
+	// Addrs are the IPv4 addresses answered, sorted. Route 53 alias
+	// records are answered as A records too.
+	Addrs []string
+	// Err is set when the query failed or the nameserver answered with
+	// an error code.
+	Err error
+}
+
+func (a Answer) String() string {
+	if a.Err != nil {
+		return fmt.Sprintf("%s: %v", a.NameServer, a.Err)
+	}
+	if len(a.Addrs) == 0 {
+		return fmt.Sprintf("%s: no A records", a.NameServer)
+	}
+	return fmt.Sprintf("%s: %s", a.NameServer, strings.Join(a.Addrs, ", "))
+}
+
+// Result is every authoritative nameserver's answer for Name.
+type Result struct {
+	Name    string
+	Zone    string
+	Answers []Answer
+}
+
+// Addrs returns the union of the answered addresses, sorted.
+func (r *Result) Addrs() []string {
+	seen := map[string]bool{}
+	var addrs []string
+	for _, a := range r.Answers {
+		for _, addr := range a.Addrs {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Match returns nil when every nameserver answered with at least one
+// address and only with addresses in want. Otherwise the error names the
+// nameservers that do not.
+func (r *Result) Match(want []string) error {
+	allowed := map[string]bool{}
+	for _, w := range want {
+		allowed[w] = true
+	}
+	var problems []string
+	for _, a := range r.Answers {
+		if a.Err != nil || len(a.Addrs) == 0 {
+			problems = append(problems, a.String())
+			continue
+		}
+		for _, addr := range a.Addrs {
+			if !allowed[addr] {
+				problems = append(problems, a.String())
+				break
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s (zone %s) does not resolve to %v on every nameserver: %s",
+			r.Name, r.Zone, want, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Check looks name up and matches the answers against want; see
+// Result.Match. The result is returned with the error for logging.
+func (c *Checker) Check(ctx context.Context, name string, want []string) (*Result, error) {
+	result, err := c.Lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return result, result.Match(want)
+}
+
+// Lookup asks every authoritative nameserver of name's zone for its A
+// records. Failures of individual nameservers are reported in their
+// Answer; the error is only for failing to find the zone.
+func (c *Checker) Lookup(ctx context.Context, name string) (*Result, error) {
+	zone, nameServers, err := c.Zone(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Name: name, Zone: zone}
+	for _, ns := range nameServers {
+		answer := Answer{NameServer: ns}
+		answer.Server, answer.Err = c.serverAddr(ctx, ns)
+		if answer.Err == nil {
+			answer.Addrs, answer.Err = c.queryA(ctx, answer.Server, name)
+		}
+		result.Answers = append(result.Answers, answer)
+	}
+	return result, nil
+}
+
+// Zone returns the zone name is in and the zone's nameservers, sorted. The
+// zone is the closest enclosing name (name itself included) that has NS
+// records.
+func (c *Checker) Zone(ctx context.Context, name string) (string, []string, error) {
+	name = strings.TrimSuffix(name, ".")
+	for candidate := name; strings.Contains(candidate, "."); candidate = candidate[strings.Index(candidate, ".")+1:] {
+		records, err := c.resolver().LookupNS(ctx, candidate+".")
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("look up NS records of %s: %w", candidate, err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		var nameServers []string
+		for _, ns := range records {
+			nameServers = append(nameServers, strings.TrimSuffix(ns.Host, "."))
+		}
+		sort.Strings(nameServers)
+		return candidate, nameServers, nil
+	}
+	return "", nil, fmt.Errorf("no zone with NS records found for %s", name)
+}
+
+func (c *Checker) resolver() *net.Resolver {
+	if c.Resolver != nil {
+		return c.Resolver
+	}
+	return net.DefaultResolver
+}
+
+func (c *Checker) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+// serverAddr returns the address to query nameserver ns at, preferring
+// IPv4 since test runners often lack IPv6 connectivity.
+func (c *Checker) serverAddr(ctx context.Context, ns string) (string, error) {
+	ips, err := c.resolver().LookupIPAddr(ctx, ns)
+	if err != nil {
+		return "", fmt.Errorf("look up nameserver address: %w", err)
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("nameserver has no addresses")
+	}
+	ip := ips[0].IP
+	for _, addr := range ips {
+		if addr.IP.To4() != nil {
+			ip = addr.IP
+			break
+		}
+	}
+	port := c.Port
+	if port == "" {
+		port = "53"
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// CAA is a CAA record (RFC 8659).
+type CAA struct {
+	// Critical is the issuer critical flag: a CA that does not understand
+	// Tag must not issue.
+	Critical bool
+	// Tag is the property, e.g. "issue", "issuewild" or "iodef".
+	Tag   string
+	Value string
+}
+
+func (r CAA) String() string {
+	flag := 0
+	if r.Critical {
+		flag = 128
+	}
+	return fmt.Sprintf("%d %s %q", flag, r.Tag, r.Value)
+}
+
+// typeCAA is the CAA record type, which dnsmessage has no constant for.
+const typeCAA = dnsmessage.Type(257)
+
+// CAA returns the CAA records that apply to name: those of name itself or
+// of its closest ancestor that has any (RFC 8659 section 3), asked from the
+// authoritative nameservers. owner is the name they were found at. No
+// records means that any CA may issue.
+func (c *Checker) CAA(ctx context.Context, name string) (owner string, records []CAA, err error) {
+	name = strings.TrimSuffix(name, ".")
+	for candidate := name; strings.Contains(candidate, "."); candidate = candidate[strings.Index(candidate, ".")+1:] {
+		_, nameServers, err := c.Zone(ctx, candidate)
+		if err != nil {
+			return "", nil, err
+		}
+		records, err := c.queryCAA(ctx, nameServers, candidate)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(records) > 0 {
+			return candidate, records, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// queryCAA asks the first nameserver that answers for name's CAA records.
+// A name that does not exist has none.
+func (c *Checker) queryCAA(ctx context.Context, nameServers []string, name string) ([]CAA, error) {
+	var errs []error
+	for _, ns := range nameServers {
+		server, err := c.serverAddr(ctx, ns)
+		if err == nil {
+			var resp *dnsmessage.Message
+			resp, err = c.query(ctx, server, name, typeCAA)
+			if err == nil && resp.RCode == dnsmessage.RCodeNameError {
+				return nil, nil
+			}
+			if err == nil && resp.RCode != dnsmessage.RCodeSuccess {
+				err = fmt.Errorf("answered %s", resp.RCode)
+			}
+			if err == nil {
+				return parseCAA(resp)
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ns, err))
+	}
+	return nil, fmt.Errorf("query CAA records of %s: %w", name, errors.Join(errs...))
+}
+
+func parseCAA(resp *dnsmessage.Message) ([]CAA, error) {
+	var records []CAA
+	for _, rr := range resp.Answers {
+		u, ok := rr.Body.(*dnsmessage.UnknownResource)
+		if !ok || u.Type != typeCAA {
+			continue
+		}
+		// flags (1 byte), tag length (1 byte), tag, value
+		if len(u.Data) < 2 || len(u.Data) < 2+int(u.Data[1]) {
+			return nil, fmt.Errorf("malformed CAA record for %s", rr.Header.Name)
+		}
+		tagEnd := 2 + int(u.Data[1])
+		records = append(records, CAA{
+			Critical: u.Data[0]&128 != 0,
+			Tag:      strings.ToLower(string(u.Data[2:tagEnd])),
+			Value:    string(u.Data[tagEnd:]),
+		})
+	}
+	return records, nil
+}
+
+// CNAME returns the target of name's CNAME record, asked from the first
+// authoritative nameserver of its zone that answers. The zone is found
+// through the public delegation, so a record in a zone that is not
+// delegated is not found.
+func (c *Checker) CNAME(ctx context.Context, name string) (string, error) {
+	_, nameServers, err := c.Zone(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	var errs []error
+	for _, ns := range nameServers {
+		server, err := c.serverAddr(ctx, ns)
+		if err == nil {
+			var resp *dnsmessage.Message
+			resp, err = c.query(ctx, server, name, dnsmessage.TypeCNAME)
+			if err == nil && resp.RCode != dnsmessage.RCodeSuccess {
+				err = fmt.Errorf("answered %s", resp.RCode)
+			}
+			if err == nil {
+				for _, rr := range resp.Answers {
+					if r, ok := rr.Body.(*dnsmessage.CNAMEResource); ok {
+						return r.CNAME.String(), nil
+					}
+				}
+				err = fmt.Errorf("no CNAME record")
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ns, err))
+	}
+	return "", fmt.Errorf("query CNAME record of %s: %w", name, errors.Join(errs...))
+}
+
+// queryA asks server for name's A records.
+func (c *Checker) queryA(ctx context.Context, server, name string) ([]string, error) {
+	resp, err := c.query(ctx, server, name, dnsmessage.TypeA)
+	if err != nil {
+		return nil, err
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("answered %s", resp.RCode)
+	}
+
+	var addrs []string
+	for _, rr := range resp.Answers {
+		if a, ok := rr.Body.(*dnsmessage.AResource); ok {
+			addrs = append(addrs, net.IP(a.A[:]).String())
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// query sends a non-recursive query to server over UDP, retrying over TCP
+// when the answer is truncated. Answers that are not authoritative are
+// errors.
+func (c *Checker) query(ctx context.Context, server, name string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.exchange(ctx, "udp", server, query)
+	if err == nil && resp.Truncated {
+		resp, err = c.exchange(ctx, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != id {
+		return nil, fmt.Errorf("answer ID %d does not match query ID %d", resp.ID, id)
+	}
+	if !resp.Authoritative {
+		return nil, fmt.Errorf("answer is not authoritative")
+	}
+	return resp, nil
+}
+
+func (c *Checker) exchange(ctx context.Context, network, server string, query []byte) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		// DNS over TCP prefixes each message with its length
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(msg, query...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 1232)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("unpack answer: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,284 @@
+package dnscheck
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// nameserver is an in-process authoritative nameserver for example.test
+// whose nameservers are ns1 (127.0.0.1) and ns2 (127.0.0.2).
+type nameserver struct {
+	// a maps names to their A records; names that are not in it get
+	// NXDOMAIN
+	a map[string][]string
+	// caa maps names to their CAA records
+	caa map[string][]CAA
+	// cname maps names to their CNAME targets
+	cname map[string]string
+	// truncate answers every UDP query with an empty truncated answer
+	truncate bool
+}
+
+const zone = "example.test."
+
+// answer returns the answer to req, or nil when req cannot be parsed.
+func (s *nameserver) answer(req []byte, udp bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true},
+		Questions: []dnsmessage.Question{q},
+	}
+	name := strings.ToLower(q.Name.String())
+	rrHeader := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60}
+	switch {
+	case !strings.HasSuffix(name, zone):
+		resp.RCode = dnsmessage.RCodeRefused
+	case udp && s.truncate:
+		resp.Truncated = true
+	case q.Type == typeCAA && s.caa[name] != nil:
+		for _, r := range s.caa[name] {
+			data := []byte{0, byte(len(r.Tag))}
+			if r.Critical {
+				data[0] = 128
+			}
+			data = append(append(data, r.Tag...), r.Value...)
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.UnknownResource{Type: typeCAA, Data: data}})
+		}
+	case s.cname[name] != "":
+		if q.Type == dnsmessage.TypeCNAME {
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(s.cname[name])}})
+		}
+	case name == zone:
+		if q.Type == dnsmessage.TypeNS {
+			for _, ns := range []string{"ns1.example.test.", "ns2.example.test."} {
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.NSResource{NS: dnsmessage.MustNewName(ns)}})
+			}
+		}
+	case s.a[name] == nil:
+		resp.RCode = dnsmessage.RCodeNameError
+	case q.Type == dnsmessage.TypeA:
+		for _, addr := range s.a[name] {
+			var a [4]byte
+			copy(a[:], net.ParseIP(addr).To4())
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.AResource{A: a}})
+		}
+	}
+	msg, _ := resp.Pack()
+	return msg
+}
+
+// serve starts s on ip:port over UDP and TCP; port "0" picks a free one.
+// It returns the port.
+func (s *nameserver) serve(t *testing.T, ip, port string) string {
+	pc, err := net.ListenPacket("udp", net.JoinHostPort(ip, port))
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	_, port, _ = net.SplitHostPort(pc.LocalAddr().String())
+	l, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		buf := make([]byte, 1232)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.answer(buf[:n], true); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err == nil {
+				req := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, req); err == nil {
+					resp := s.answer(req, false)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return port
+}
+
+// newChecker starts ns1 and ns2 and returns a Checker that finds them
+// through ns1.
+func newChecker(t *testing.T, ns1, ns2 *nameserver) *Checker {
+	glue := map[string][]string{"ns1.example.test.": {"127.0.0.1"}, "ns2.example.test.": {"127.0.0.2"}}
+	for _, s := range []*nameserver{ns1, ns2} {
+		if s.a == nil {
+			s.a = map[string][]string{}
+		}
+		for name, addrs := range glue {
+			s.a[name] = addrs
+		}
+	}
+	port := ns1.serve(t, "127.0.0.1", "0")
+	ns2.serve(t, "127.0.0.2", port)
+
+	return &Checker{
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, net.JoinHostPort("127.0.0.1", port))
+			},
+		},
+		Port:    port,
+		Timeout: 2 * time.Second,
+	}
+}
+
+func TestZone(t *testing.T) {
+	c := newChecker(t, &nameserver{}, &nameserver{})
+	ctx := context.Background()
+
+	zone, nameServers, err := c.Zone(ctx, "bridge.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "example.test", zone)
+	assert.Equal(t, []string{"ns1.example.test", "ns2.example.test"}, nameServers)
+
+	_, _, err = c.Zone(ctx, "bridge.other.test")
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	const name = "bridge.example.test."
+	tests := []struct {
+		name     string
+		ns1, ns2 []string
+		truncate bool
+		// want is the Check error substring; empty means no error
+		want string
+	}{
+		{name: "propagated", ns1: []string{"192.0.2.10"}, ns2: []string{"192.0.2.10"}},
+		{name: "subset of want", ns1: []string{"192.0.2.10"}, ns2: []string{"192.0.2.11"}},
+		{name: "over tcp", ns1: []string{"192.0.2.10"}, ns2: []string{"192.0.2.10"}, truncate: true},
+		{name: "not on every nameserver", ns1: []string{"192.0.2.10"}, want: "ns2.example.test: answered RCodeNameError"},
+		{name: "wrong address", ns1: []string{"192.0.2.10"}, ns2: []string{"198.51.100.1"}, want: "ns2.example.test: 198.51.100.1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ns1 := &nameserver{a: map[string][]string{}, truncate: tt.truncate}
+			ns2 := &nameserver{a: map[string][]string{}, truncate: tt.truncate}
+			if tt.ns1 != nil {
+				ns1.a[name] = tt.ns1
+			}
+			if tt.ns2 != nil {
+				ns2.a[name] = tt.ns2
+			}
+			c := newChecker(t, ns1, ns2)
+
+			result, err := c.Check(context.Background(), "bridge.example.test", []string{"192.0.2.10", "192.0.2.11"})
+			require.NotNil(t, result)
+			assert.Equal(t, "example.test", result.Zone)
+			require.Len(t, result.Answers, 2)
+			assert.Equal(t, "127.0.0.1:"+c.Port, result.Answers[0].Server)
+			assert.Equal(t, "127.0.0.2:"+c.Port, result.Answers[1].Server)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	r := &Result{Name: "bridge.example.test", Zone: "example.test", Answers: []Answer{
+		{NameServer: "ns1", Addrs: []string{"192.0.2.10"}},
+		{NameServer: "ns2", Addrs: []string{"192.0.2.10", "192.0.2.11"}},
+	}}
+	assert.NoError(t, r.Match([]string{"192.0.2.10", "192.0.2.11"}))
+	assert.Error(t, r.Match([]string{"192.0.2.10"}))
+	assert.Equal(t, []string{"192.0.2.10", "192.0.2.11"}, r.Addrs())
+
+	r.Answers = append(r.Answers, Answer{NameServer: "ns3"})
+	err := r.Match([]string{"192.0.2.10", "192.0.2.11"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ns3: no A records")
+	}
+}
+
+func TestCAA(t *testing.T) {
+	apex := []CAA{{Tag: "issue", Value: "amazon.com"}, {Tag: "iodef", Value: "mailto:security@example.test"}}
+	own := []CAA{{Critical: true, Tag: "issue", Value: "letsencrypt.org"}}
+	ns := func() *nameserver {
+		return &nameserver{
+			a:   map[string][]string{"bridge.example.test.": {"192.0.2.10"}, "api.example.test.": {"192.0.2.11"}},
+			caa: map[string][]CAA{zone: apex, "api.example.test.": own},
+		}
+	}
+	c := newChecker(t, ns(), ns())
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		owner string
+		want  []CAA
+	}{
+		{name: "bridge.example.test", owner: "example.test", want: apex},
+		{name: "new.bridge.example.test", owner: "example.test", want: apex}, // NXDOMAIN climbs too
+		{name: "api.example.test", owner: "api.example.test", want: own},
+	}
+	for _, tt := range tests {
+		owner, records, err := c.CAA(ctx, tt.name)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.owner, owner, tt.name)
+		assert.Equal(t, tt.want, records, tt.name)
+	}
+	assert.Equal(t, `128 issue "letsencrypt.org"`, own[0].String())
+
+	// No CAA records anywhere: any CA may issue
+	c = newChecker(t, &nameserver{a: map[string][]string{"bridge.example.test.": {"192.0.2.10"}}}, &nameserver{})
+	owner, records, err := c.CAA(ctx, "bridge.example.test")
+	require.NoError(t, err)
+	assert.Empty(t, owner)
+	assert.Empty(t, records)
+}
+
+func TestCNAME(t *testing.T) {
+	const validation = "_0123abcd.bridge.example.test."
+	const target = "_4567efgh.acm-validations.aws."
+	c := newChecker(t, &nameserver{cname: map[string]string{validation: target}}, &nameserver{})
+	ctx := context.Background()
+
+	// ns2 does not have the record yet; ns1 answers first
+	got, err := c.CNAME(ctx, validation)
+	require.NoError(t, err)
+	assert.Equal(t, target, got)
+
+	_, err = c.CNAME(ctx, "_missing.bridge.example.test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NameError")
+}
@@ -0,0 +1,263 @@
+// Package ecsstate reads the live state of an ECS service (deployments,
+// tasks and load balancer targets) so that tests can assert on rollouts,
+// scaling and task replacement.
+package ecsstate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+// Task is the part of an ECS task the tests care about.
+type Task struct {
+	Arn               string
+	TaskDefinitionArn string
+	LastStatus        string
+	HealthStatus      string
+	PrivateIP         string
+	SubnetID          string
+	AvailabilityZone  string
+	StoppedReason     string
+}
+
+// Deployment is one ECS service deployment.
+type Deployment struct {
+	ID             string
+	Status         string // PRIMARY, ACTIVE or INACTIVE
+	TaskDefinition string
+	RolloutState   string // IN_PROGRESS, COMPLETED or FAILED
+	Desired        int64
+	Running        int64
+}
+
+// Service is a snapshot of an ECS service and its running tasks.
+type Service struct {
+	Cluster         string
+	Name            string
+	TaskDefinition  string
+	Desired         int64
+	Running         int64
+	Deployments     []Deployment
+	TargetGroupArns []string
+	Tasks           []Task
+}
+
+// Settled reports whether the service has finished deploying: a single
+// completed deployment, the desired number of tasks running and every
+// running task on the service's task definition.
+func (s *Service) Settled() bool {
+	if len(s.Deployments) != 1 {
+		return false
+	}
+	d := s.Deployments[0]
+	if d.RolloutState != "" && d.RolloutState != ecs.DeploymentRolloutStateCompleted {
+		return false
+	}
+	if s.Running != s.Desired || int64(len(s.Tasks)) != s.Desired {
+		return false
+	}
+	for _, task := range s.Tasks {
+		if task.TaskDefinitionArn != s.TaskDefinition || task.LastStatus != ecs.DesiredStatusRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a one-line summary for logs.
+func (s *Service) String() string {
+	return fmt.Sprintf("%s: desired=%d running=%d deployments=%d taskDefinition=%s",
+		s.Name, s.Desired, s.Running, len(s.Deployments), s.TaskDefinition)
+}
+
+// TaskArns returns the ARNs of the running tasks.
+func (s *Service) TaskArns() []string {
+	arns := make([]string, 0, len(s.Tasks))
+	for _, task := range s.Tasks {
+		arns = append(arns, task.Arn)
+	}
+	return arns
+}
+
+// Describe returns the current state of service and its RUNNING tasks.
+func Describe(client ecsiface.ECSAPI, cluster, service string) (*Service, error) {
+	out, err := client.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: []*string{aws.String(service)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe service %s: %w", service, err)
+	}
+	if len(out.Services) == 0 {
+		return nil, fmt.Errorf("service %s not found in cluster %s", service, cluster)
+	}
+	svc := out.Services[0]
+
+	state := &Service{
+		Cluster:        cluster,
+		Name:           service,
+		TaskDefinition: aws.StringValue(svc.TaskDefinition),
+		Desired:        aws.Int64Value(svc.DesiredCount),
+		Running:        aws.Int64Value(svc.RunningCount),
+	}
+	for _, d := range svc.Deployments {
+		state.Deployments = append(state.Deployments, Deployment{
+			ID:             aws.StringValue(d.Id),
+			Status:         aws.StringValue(d.Status),
+			TaskDefinition: aws.StringValue(d.TaskDefinition),
+			RolloutState:   aws.StringValue(d.RolloutState),
+			Desired:        aws.Int64Value(d.DesiredCount),
+			Running:        aws.Int64Value(d.RunningCount),
+		})
+	}
+	for _, lb := range svc.LoadBalancers {
+		if arn := aws.StringValue(lb.TargetGroupArn); arn != "" {
+			state.TargetGroupArns = append(state.TargetGroupArns, arn)
+		}
+	}
+
+	list, err := client.ListTasks(&ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		ServiceName:   aws.String(service),
+		DesiredStatus: aws.String(ecs.DesiredStatusRunning),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list tasks of %s: %w", service, err)
+	}
+	state.Tasks, err = DescribeTasks(client, cluster, aws.StringValueSlice(list.TaskArns))
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// DescribeTasks returns the tasks with the given ARNs, sorted by ARN. Tasks
+// ECS no longer knows about are omitted.
+func DescribeTasks(client ecsiface.ECSAPI, cluster string, arns []string) ([]Task, error) {
+	if len(arns) == 0 {
+		return nil, nil
+	}
+	out, err := client.DescribeTasks(&ecs.DescribeTasksInput{
+		Cluster: aws.String(cluster),
+		Tasks:   aws.StringSlice(arns),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe tasks: %w", err)
+	}
+	tasks := make([]Task, 0, len(out.Tasks))
+	for _, t := range out.Tasks {
+		tasks = append(tasks, convertTask(t))
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Arn < tasks[j].Arn })
+	return tasks, nil
+}
+
+func convertTask(t *ecs.Task) Task {
+	task := Task{
+		Arn:               aws.StringValue(t.TaskArn),
+		TaskDefinitionArn: aws.StringValue(t.TaskDefinitionArn),
+		LastStatus:        aws.StringValue(t.LastStatus),
+		HealthStatus:      aws.StringValue(t.HealthStatus),
+		AvailabilityZone:  aws.StringValue(t.AvailabilityZone),
+		StoppedReason:     aws.StringValue(t.StoppedReason),
+	}
+	for _, att := range t.Attachments {
+		if aws.StringValue(att.Type) != "ElasticNetworkInterface" {
+			continue
+		}
+		for _, kv := range att.Details {
+			switch aws.StringValue(kv.Name) {
+			case "privateIPv4Address":
+				task.PrivateIP = aws.StringValue(kv.Value)
+			case "subnetId":
+				task.SubnetID = aws.StringValue(kv.Value)
+			}
+		}
+	}
+	return task
+}
+
+// Targets returns the registered targets of a target group keyed by IP
+// address, with their health state (initial, healthy, draining, ...).
+func Targets(client elbv2iface.ELBV2API, targetGroupArn string) (map[string]string, error) {
+	out, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe target health: %w", err)
+	}
+	targets := make(map[string]string, len(out.TargetHealthDescriptions))
+	for _, d := range out.TargetHealthDescriptions {
+		if d.Target == nil || d.TargetHealth == nil {
+			continue
+		}
+		targets[aws.StringValue(d.Target.Id)] = aws.StringValue(d.TargetHealth.State)
+	}
+	return targets, nil
+}
+
+// WaitSettled polls the service until Settled reports true and returns the
+// settled state.
+func WaitSettled(ctx context.Context, client ecsiface.ECSAPI, cluster, service string, opts poll.Options) (*Service, error) {
+	var state *Service
+	err := poll.Until(ctx, opts, func(context.Context) error {
+		s, err := Describe(client, cluster, service)
+		if err != nil {
+			return err
+		}
+		state = s
+		if !s.Settled() {
+			return fmt.Errorf("not settled: %s", s)
+		}
+		return nil
+	})
+	return state, err
+}
+
+// WaitTargetsHealthy polls the target group until exactly the given IPs are
+// registered and all of them are healthy. Targets of replaced tasks must
+// have finished draining.
+func WaitTargetsHealthy(ctx context.Context, client elbv2iface.ELBV2API, targetGroupArn string, ips []string, opts poll.Options) error {
+	return waitTargets(ctx, client, targetGroupArn, ips, true, opts)
+}
+
+// WaitIPsHealthy polls the target group until all of the given IPs are
+// healthy. Other targets, such as those of a stopped task that is still
+// draining, are ignored.
+func WaitIPsHealthy(ctx context.Context, client elbv2iface.ELBV2API, targetGroupArn string, ips []string, opts poll.Options) error {
+	return waitTargets(ctx, client, targetGroupArn, ips, false, opts)
+}
+
+func waitTargets(ctx context.Context, client elbv2iface.ELBV2API, targetGroupArn string, ips []string, exclusive bool, opts poll.Options) error {
+	return poll.Until(ctx, opts, func(context.Context) error {
+		targets, err := Targets(client, targetGroupArn)
+		if err != nil {
+			return err
+		}
+		want := make(map[string]bool, len(ips))
+		for _, ip := range ips {
+			want[ip] = true
+			if state := targets[ip]; state != elbv2.TargetHealthStateEnumHealthy {
+				return fmt.Errorf("target %s is %q", ip, state)
+			}
+		}
+		if !exclusive {
+			return nil
+		}
+		for ip, state := range targets {
+			if !want[ip] {
+				return fmt.Errorf("unexpected target %s is still registered (%s)", ip, state)
+			}
+		}
+		return nil
+	})
+}
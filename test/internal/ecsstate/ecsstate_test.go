@@ -0,0 +1,316 @@
+package ecsstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+const (
+	oldDef = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:1"
+	newDef = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:2"
+	tgArn  = "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/bridge/abc"
+)
+
+func ecsTask(arn, def, ip, az string) *ecs.Task {
+	return &ecs.Task{
+		TaskArn:           aws.String(arn),
+		TaskDefinitionArn: aws.String(def),
+		LastStatus:        aws.String(ecs.DesiredStatusRunning),
+		AvailabilityZone:  aws.String(az),
+		Attachments: []*ecs.Attachment{{
+			Type: aws.String("ElasticNetworkInterface"),
+			Details: []*ecs.KeyValuePair{
+				{Name: aws.String("subnetId"), Value: aws.String("subnet-" + az)},
+				{Name: aws.String("privateIPv4Address"), Value: aws.String(ip)},
+			},
+		}},
+	}
+}
+
+// fakeECS replays one service state per DescribeServices call, holding the
+// last one, to simulate a rollout in progress.
+type fakeECS struct {
+	ecsiface.ECSAPI
+	states [][]*ecs.Task
+	defs   []string
+	calls  int
+}
+
+func (f *fakeECS) current() int {
+	if f.calls >= len(f.states) {
+		return len(f.states) - 1
+	}
+	return f.calls
+}
+
+func (f *fakeECS) DescribeServices(*ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	i := f.current()
+	svc := &ecs.Service{
+		TaskDefinition: aws.String(newDef),
+		DesiredCount:   aws.Int64(2),
+		RunningCount:   aws.Int64(int64(len(f.states[i]))),
+		LoadBalancers:  []*ecs.LoadBalancer{{TargetGroupArn: aws.String(tgArn)}},
+	}
+	for j, def := range f.defs[:len(f.defs)-i] {
+		status := "ACTIVE"
+		if j == 0 {
+			status = "PRIMARY"
+		}
+		svc.Deployments = append(svc.Deployments, &ecs.Deployment{
+			Id:             aws.String(def),
+			Status:         aws.String(status),
+			TaskDefinition: aws.String(def),
+			RolloutState:   aws.String(ecs.DeploymentRolloutStateCompleted),
+		})
+	}
+	return &ecs.DescribeServicesOutput{Services: []*ecs.Service{svc}}, nil
+}
+
+func (f *fakeECS) ListTasks(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	var arns []*string
+	for _, task := range f.states[f.current()] {
+		arns = append(arns, task.TaskArn)
+	}
+	return &ecs.ListTasksOutput{TaskArns: arns}, nil
+}
+
+func (f *fakeECS) DescribeTasks(in *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	defer func() { f.calls++ }()
+	var out []*ecs.Task
+	for _, task := range f.states[f.current()] {
+		for _, arn := range in.Tasks {
+			if aws.StringValue(arn) == aws.StringValue(task.TaskArn) {
+				out = append(out, task)
+			}
+		}
+	}
+	return &ecs.DescribeTasksOutput{Tasks: out}, nil
+}
+
+func TestDescribe(t *testing.T) {
+	client := &fakeECS{
+		states: [][]*ecs.Task{{
+			ecsTask("task/b", newDef, "10.0.2.10", "ap-northeast-1c"),
+			ecsTask("task/a", newDef, "10.0.1.10", "ap-northeast-1a"),
+		}},
+		defs: []string{newDef},
+	}
+
+	state, err := Describe(client, "cluster", "bridge")
+	require.NoError(t, err)
+
+	assert.Equal(t, newDef, state.TaskDefinition)
+	assert.Equal(t, []string{tgArn}, state.TargetGroupArns)
+	assert.Equal(t, []string{"task/a", "task/b"}, state.TaskArns())
+	assert.Equal(t, Task{
+		Arn:               "task/a",
+		TaskDefinitionArn: newDef,
+		LastStatus:        ecs.DesiredStatusRunning,
+		PrivateIP:         "10.0.1.10",
+		SubnetID:          "subnet-ap-northeast-1a",
+		AvailabilityZone:  "ap-northeast-1a",
+	}, state.Tasks[0])
+	assert.True(t, state.Settled())
+}
+
+func TestSettled(t *testing.T) {
+	settled := func() *Service {
+		return &Service{
+			TaskDefinition: newDef,
+			Desired:        1,
+			Running:        1,
+			Deployments:    []Deployment{{Status: "PRIMARY", TaskDefinition: newDef, RolloutState: ecs.DeploymentRolloutStateCompleted}},
+			Tasks:          []Task{{Arn: "task/a", TaskDefinitionArn: newDef, LastStatus: ecs.DesiredStatusRunning}},
+		}
+	}
+	assert.True(t, settled().Settled())
+
+	cases := map[string]func(s *Service){
+		"old deployment still active": func(s *Service) {
+			s.Deployments = append(s.Deployments, Deployment{Status: "ACTIVE", TaskDefinition: oldDef})
+		},
+		"rollout in progress": func(s *Service) { s.Deployments[0].RolloutState = ecs.DeploymentRolloutStateInProgress },
+		"below desired":       func(s *Service) { s.Desired = 2 },
+		"old task running": func(s *Service) {
+			s.Tasks[0].TaskDefinitionArn = oldDef
+		},
+		"task still pending": func(s *Service) { s.Tasks[0].LastStatus = "PENDING" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := settled()
+			mutate(s)
+			assert.False(t, s.Settled())
+		})
+	}
+}
+
+func TestWaitSettled(t *testing.T) {
+	// Rolling update: old tasks, then old and new side by side, then only
+	// new tasks.
+	client := &fakeECS{
+		states: [][]*ecs.Task{
+			{ecsTask("task/old", oldDef, "10.0.1.10", "a")},
+			{ecsTask("task/old", oldDef, "10.0.1.10", "a"), ecsTask("task/new", newDef, "10.0.1.11", "a")},
+			{ecsTask("task/new", newDef, "10.0.1.11", "a"), ecsTask("task/new2", newDef, "10.0.2.11", "c")},
+		},
+		defs: []string{newDef, oldDef, oldDef},
+	}
+
+	clock := poll.NewFakeClock(time.Unix(0, 0))
+	state, err := WaitSettled(context.Background(), client, "cluster", "bridge", poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 10 * time.Second},
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task/new", "task/new2"}, state.TaskArns())
+	assert.Len(t, clock.Waits(), 2)
+}
+
+func TestWaitSettledTimeout(t *testing.T) {
+	client := &fakeECS{
+		states: [][]*ecs.Task{{ecsTask("task/old", oldDef, "10.0.1.10", "a")}},
+		defs:   []string{newDef, oldDef},
+	}
+	state, err := WaitSettled(context.Background(), client, "cluster", "bridge", poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 10 * time.Second},
+		Clock:   poll.NewFakeClock(time.Unix(0, 0)),
+	})
+	require.ErrorIs(t, err, poll.ErrTimeout)
+	assert.Contains(t, err.Error(), "not settled")
+	require.NotNil(t, state, "the last observed state is returned for diagnostics")
+	assert.Len(t, state.Deployments, 2)
+}
+
+type fakeELB struct {
+	elbv2iface.ELBV2API
+	states []map[string]string
+	calls  int
+}
+
+func (f *fakeELB) DescribeTargetHealth(*elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	i := f.calls
+	if i >= len(f.states) {
+		i = len(f.states) - 1
+	}
+	f.calls++
+	out := &elbv2.DescribeTargetHealthOutput{}
+	for ip, state := range f.states[i] {
+		out.TargetHealthDescriptions = append(out.TargetHealthDescriptions, &elbv2.TargetHealthDescription{
+			Target:       &elbv2.TargetDescription{Id: aws.String(ip), Port: aws.Int64(8080)},
+			TargetHealth: &elbv2.TargetHealth{State: aws.String(state)},
+		})
+	}
+	return out, nil
+}
+
+func TestWaitTargetsHealthy(t *testing.T) {
+	client := &fakeELB{states: []map[string]string{
+		{"10.0.1.10": "healthy", "10.0.1.11": "initial"},
+		{"10.0.1.10": "draining", "10.0.1.11": "healthy"},
+		{"10.0.1.11": "healthy"},
+	}}
+
+	clock := poll.NewFakeClock(time.Unix(0, 0))
+	err := WaitTargetsHealthy(context.Background(), client, tgArn, []string{"10.0.1.11"}, poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second},
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, client.calls)
+
+	targets, err := Targets(client, tgArn)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"10.0.1.11": "healthy"}, targets)
+}
+
+func TestWaitTargetsHealthyDrainingTimesOut(t *testing.T) {
+	client := &fakeELB{states: []map[string]string{
+		{"10.0.1.10": "draining", "10.0.1.11": "healthy"},
+	}}
+	err := WaitTargetsHealthy(context.Background(), client, tgArn, []string{"10.0.1.11"}, poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second},
+		Clock:   poll.NewFakeClock(time.Unix(0, 0)),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "10.0.1.10 is still registered (draining)")
+}
+
+type fakeEC2 struct {
+	ec2iface.EC2API
+}
+
+func (fakeEC2) DescribeSubnets(in *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, id := range in.SubnetIds {
+		out.Subnets = append(out.Subnets, &ec2.Subnet{
+			SubnetId:         id,
+			AvailabilityZone: aws.String("ap-northeast-1" + aws.StringValue(id)[len("subnet-"):]),
+		})
+	}
+	return out, nil
+}
+
+func TestSubnetAZs(t *testing.T) {
+	azs, err := SubnetAZs(fakeEC2{}, []string{"subnet-a", "subnet-c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"subnet-a": "ap-northeast-1a", "subnet-c": "ap-northeast-1c"}, azs)
+}
+
+func TestCheckSpread(t *testing.T) {
+	subnets := map[string]string{
+		"subnet-a": "ap-northeast-1a",
+		"subnet-c": "ap-northeast-1c",
+		"subnet-d": "ap-northeast-1d",
+	}
+	task := func(subnet string) Task {
+		return Task{Arn: "task/" + subnet, SubnetID: subnet, AvailabilityZone: subnets[subnet]}
+	}
+
+	assert.NoError(t, CheckSpread(nil, subnets))
+	assert.NoError(t, CheckSpread([]Task{task("subnet-a")}, subnets))
+	assert.NoError(t, CheckSpread([]Task{task("subnet-a"), task("subnet-c")}, subnets))
+	assert.NoError(t, CheckSpread([]Task{task("subnet-a"), task("subnet-c"), task("subnet-d"), task("subnet-a")}, subnets))
+
+	err := CheckSpread([]Task{task("subnet-a"), task("subnet-a")}, subnets)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ap-northeast-1a=2")
+
+	err = CheckSpread([]Task{{Arn: "task/x", SubnetID: "subnet-public"}}, subnets)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `subnet "subnet-public"`)
+
+	assert.Equal(t, map[string]int{"ap-northeast-1a": 2, "ap-northeast-1c": 1},
+		AZCounts([]Task{task("subnet-a"), task("subnet-c"), task("subnet-a")}))
+}
+
+func TestWaitIPsHealthyIgnoresDrainingTargets(t *testing.T) {
+	client := &fakeELB{states: []map[string]string{
+		{"10.0.1.10": "draining", "10.0.1.11": "healthy", "10.0.1.12": "initial"},
+		{"10.0.1.10": "draining", "10.0.1.11": "healthy", "10.0.1.12": "healthy"},
+	}}
+	err := WaitIPsHealthy(context.Background(), client, tgArn, []string{"10.0.1.11", "10.0.1.12"}, poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second},
+		Clock:   poll.NewFakeClock(time.Unix(0, 0)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, client.calls)
+}
@@ -0,0 +1,71 @@
+package ecsstate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// SubnetAZs returns the availability zone of each subnet.
+func SubnetAZs(client ec2iface.EC2API, subnetIDs []string) (map[string]string, error) {
+	out, err := client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(subnetIDs),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe subnets: %w", err)
+	}
+	azs := make(map[string]string, len(out.Subnets))
+	for _, subnet := range out.Subnets {
+		azs[aws.StringValue(subnet.SubnetId)] = aws.StringValue(subnet.AvailabilityZone)
+	}
+	return azs, nil
+}
+
+// AZCounts returns the number of tasks per availability zone.
+func AZCounts(tasks []Task) map[string]int {
+	counts := map[string]int{}
+	for _, task := range tasks {
+		counts[task.AvailabilityZone]++
+	}
+	return counts
+}
+
+// CheckSpread verifies that every task runs in one of the given subnets
+// (subnet ID to AZ) and that the tasks are balanced across the subnets'
+// AZs: the task counts of any two AZs differ by at most one, so N tasks
+// use min(N, AZs) zones.
+func CheckSpread(tasks []Task, subnetAZs map[string]string) error {
+	counts := map[string]int{}
+	for _, az := range subnetAZs {
+		counts[az] = 0
+	}
+	for _, task := range tasks {
+		az, ok := subnetAZs[task.SubnetID]
+		if !ok {
+			return fmt.Errorf("task %s runs in subnet %q, which is not one of the configured subnets", task.Arn, task.SubnetID)
+		}
+		counts[az]++
+	}
+
+	zones := make([]string, 0, len(counts))
+	for az := range counts {
+		zones = append(zones, az)
+	}
+	sort.Strings(zones)
+	lo, hi := len(tasks), 0
+	for _, az := range zones {
+		lo = min(lo, counts[az])
+		hi = max(hi, counts[az])
+	}
+	if hi-lo > 1 {
+		spread := make([]string, 0, len(zones))
+		for _, az := range zones {
+			spread = append(spread, fmt.Sprintf("%s=%d", az, counts[az]))
+		}
+		return fmt.Errorf("%d task(s) are not balanced across availability zones: %v", len(tasks), spread)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package fakeaws
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (s *Server) ec2(op string, form url.Values) (any, error) {
+	switch op {
+	case "DescribeSubnets":
+		return s.describeSubnets(form)
+	case "DescribeRouteTables":
+		return s.describeRouteTables(form)
+	case "DescribeNatGateways":
+		return s.describeNatGateways(form)
+	case "DescribeSecurityGroups":
+		return s.describeSecurityGroups(form)
+	case "DescribeVpcs":
+		return s.describeVpcs()
+	case "DescribeNetworkAcls":
+		return s.describeNetworkAcls()
+	case "DescribeVpcEndpoints":
+		return s.describeVpcEndpoints()
+	}
+	return nil, errorf("InvalidAction", "ec2 %s is not implemented by the fake", op)
+}
+
+func (s *Server) describeSubnets(form url.Values) (*ec2.DescribeSubnetsOutput, error) {
+	ids := queryList(form, "SubnetId")
+	if len(ids) == 0 {
+		for _, sn := range s.sc.Subnets {
+			ids = append(ids, sn.ID)
+		}
+	}
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, id := range ids {
+		sn, ok := s.sc.subnet(id)
+		if !ok {
+			return nil, errorf("InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", id)
+		}
+		out.Subnets = append(out.Subnets, &ec2.Subnet{
+			SubnetId:                aws.String(sn.ID),
+			VpcId:                   aws.String(s.sc.VPCID),
+			AvailabilityZone:        aws.String(sn.AvailabilityZone),
+			CidrBlock:               aws.String(sn.CIDR),
+			AvailableIpAddressCount: aws.Int64(sn.AvailableIPs),
+			MapPublicIpOnLaunch:     aws.Bool(false),
+			State:                   aws.String("available"),
+		})
+	}
+	return out, nil
+}
+
+// describeRouteTables supports the association.subnet-id filter the
+// diagnostics use; without filters it returns every route table.
+func (s *Server) describeRouteTables(form url.Values) (*ec2.DescribeRouteTablesOutput, error) {
+	filters := ec2Filters(form)
+	out := &ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{}}
+	for _, rt := range s.sc.RouteTables {
+		var assoc []string
+		for _, sn := range s.sc.Subnets {
+			if sn.RouteTable == rt.ID {
+				assoc = append(assoc, sn.ID)
+			}
+		}
+		if want, ok := filters["association.subnet-id"]; ok && !overlaps(want, assoc) {
+			continue
+		}
+		table := &ec2.RouteTable{RouteTableId: aws.String(rt.ID), VpcId: aws.String(s.sc.VPCID)}
+		for _, id := range assoc {
+			table.Associations = append(table.Associations, &ec2.RouteTableAssociation{
+				RouteTableId: aws.String(rt.ID),
+				SubnetId:     aws.String(id),
+			})
+		}
+		for _, r := range rt.Routes {
+			route := &ec2.Route{DestinationCidrBlock: aws.String(r.Destination), State: aws.String("active")}
+			if r.GatewayID != "" {
+				route.GatewayId = aws.String(r.GatewayID)
+			}
+			if r.NatGatewayID != "" {
+				route.NatGatewayId = aws.String(r.NatGatewayID)
+			}
+			table.Routes = append(table.Routes, route)
+		}
+		out.RouteTables = append(out.RouteTables, table)
+	}
+	return out, nil
+}
+
+func overlaps(a, b []string) bool {
+	for _, v := range a {
+		if contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) describeNatGateways(form url.Values) (*ec2.DescribeNatGatewaysOutput, error) {
+	ids := queryList(form, "NatGatewayId")
+	out := &ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{}}
+	for _, nat := range s.sc.NatGateways {
+		if len(ids) > 0 && !contains(ids, nat.ID) {
+			continue
+		}
+		out.NatGateways = append(out.NatGateways, &ec2.NatGateway{
+			NatGatewayId: aws.String(nat.ID),
+			SubnetId:     aws.String(nat.SubnetID),
+			VpcId:        aws.String(s.sc.VPCID),
+			State:        aws.String(nat.State),
+			NatGatewayAddresses: []*ec2.NatGatewayAddress{{
+				PublicIp: aws.String(nat.PublicIP),
+			}},
+		})
+	}
+	if len(ids) > 0 && len(out.NatGateways) == 0 {
+		return nil, errorf("NatGatewayNotFound", "The NAT gateway ID '%s' does not exist", strings.Join(ids, ", "))
+	}
+	return out, nil
+}
+
+func (s *Server) describeSecurityGroups(form url.Values) (*ec2.DescribeSecurityGroupsOutput, error) {
+	ids := queryList(form, "GroupId")
+	if len(ids) == 0 {
+		for _, sg := range s.sc.SecurityGroups {
+			ids = append(ids, sg.ID)
+		}
+	}
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range ids {
+		sg, ok := s.sc.securityGroup(id)
+		if !ok {
+			return nil, errorf("InvalidGroup.NotFound", "The security group '%s' does not exist", id)
+		}
+		group := &ec2.SecurityGroup{
+			GroupId:   aws.String(sg.ID),
+			GroupName: aws.String(sg.Name),
+			VpcId:     aws.String(s.sc.VPCID),
+			OwnerId:   aws.String(AccountID),
+		}
+		for _, r := range sg.Ingress {
+			perm := &ec2.IpPermission{
+				IpProtocol: aws.String(r.Protocol),
+				FromPort:   aws.Int64(r.FromPort),
+				ToPort:     aws.Int64(r.ToPort),
+			}
+			if r.CIDR != "" {
+				perm.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(r.CIDR), Description: aws.String(r.Description)}}
+			}
+			if r.SourceGroup != "" {
+				perm.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: aws.String(r.SourceGroup), Description: aws.String(r.Description)}}
+			}
+			group.IpPermissions = append(group.IpPermissions, perm)
+		}
+		if sg.EgressAll {
+			group.IpPermissionsEgress = []*ec2.IpPermission{{
+				IpProtocol: aws.String("-1"),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			}}
+		}
+		out.SecurityGroups = append(out.SecurityGroups, group)
+	}
+	return out, nil
+}
+
+func (s *Server) describeVpcs() (*ec2.DescribeVpcsOutput, error) {
+	return &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{
+		VpcId:     aws.String(s.sc.VPCID),
+		CidrBlock: aws.String(s.sc.VPCCIDR),
+		State:     aws.String("available"),
+	}}}, nil
+}
+
+// describeNetworkAcls returns the VPC's default network ACL, which allows
+// all traffic and is associated with every subnet.
+func (s *Server) describeNetworkAcls() (*ec2.DescribeNetworkAclsOutput, error) {
+	acl := &ec2.NetworkAcl{
+		NetworkAclId: aws.String("acl-" + strings.TrimPrefix(s.sc.VPCID, "vpc-")),
+		VpcId:        aws.String(s.sc.VPCID),
+		IsDefault:    aws.Bool(true),
+	}
+	for _, egress := range []bool{false, true} {
+		acl.Entries = append(acl.Entries,
+			&ec2.NetworkAclEntry{RuleNumber: aws.Int64(100), Egress: aws.Bool(egress), RuleAction: aws.String("allow"), Protocol: aws.String("-1"), CidrBlock: aws.String("0.0.0.0/0")},
+			&ec2.NetworkAclEntry{RuleNumber: aws.Int64(32767), Egress: aws.Bool(egress), RuleAction: aws.String("deny"), Protocol: aws.String("-1"), CidrBlock: aws.String("0.0.0.0/0")},
+		)
+	}
+	for _, sn := range s.sc.Subnets {
+		acl.Associations = append(acl.Associations, &ec2.NetworkAclAssociation{NetworkAclId: acl.NetworkAclId, SubnetId: aws.String(sn.ID)})
+	}
+	return &ec2.DescribeNetworkAclsOutput{NetworkAcls: []*ec2.NetworkAcl{acl}}, nil
+}
+
+func (s *Server) describeVpcEndpoints() (*ec2.DescribeVpcEndpointsOutput, error) {
+	out := &ec2.DescribeVpcEndpointsOutput{VpcEndpoints: []*ec2.VpcEndpoint{}}
+	for _, e := range s.sc.VPCEndpoints {
+		state := e.State
+		if state == "" {
+			state = "available"
+		}
+		ep := &ec2.VpcEndpoint{
+			VpcEndpointId:     aws.String(e.ID),
+			VpcId:             aws.String(s.sc.VPCID),
+			ServiceName:       aws.String(fmt.Sprintf("com.amazonaws.%s.%s", s.sc.Region, e.Service)),
+			VpcEndpointType:   aws.String(e.Type),
+			State:             aws.String(state),
+			PrivateDnsEnabled: aws.Bool(e.PrivateDNS),
+			SubnetIds:         aws.StringSlice(e.SubnetIDs),
+			RouteTableIds:     aws.StringSlice(e.RouteTableIDs),
+		}
+		for _, id := range e.SecurityGroups {
+			ep.Groups = append(ep.Groups, &ec2.SecurityGroupIdentifier{GroupId: aws.String(id)})
+		}
+		out.VpcEndpoints = append(out.VpcEndpoints, ep)
+	}
+	return out, nil
+}
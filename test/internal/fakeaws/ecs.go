@@ -0,0 +1,273 @@
+package fakeaws
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// task is a task of the service as the fake reports it.
+type task struct {
+	ID      string
+	Subnet  Subnet
+	IP      string
+	Status  string
+	Stopped *StoppedTask
+}
+
+// started reports whether the tasks have left PENDING: StartsAfter
+// DescribeServices calls have been answered before this one.
+func (s *Server) started() bool {
+	return s.Calls("ecs.DescribeServices") > s.sc.StartsAfter
+}
+
+// taskStatus is the current last status of the service's tasks.
+func (s *Server) taskStatus() string {
+	if !s.started() || s.sc.TaskStatus == "" {
+		return "PENDING"
+	}
+	return s.sc.TaskStatus
+}
+
+// tasks returns the current tasks of the service, spread over the private
+// subnets like the ECS scheduler does.
+func (s *Server) tasks() []task {
+	status := s.taskStatus()
+	var out []task
+	for i := 0; i < int(s.sc.DesiredCount); i++ {
+		subnet, _ := s.sc.subnet(s.sc.PrivateSubnets[i%len(s.sc.PrivateSubnets)])
+		out = append(out, task{
+			ID:     fmt.Sprintf("%032x", i+1),
+			Subnet: subnet,
+			IP:     hostIP(subnet.CIDR, 10+i),
+			Status: status,
+		})
+	}
+	return out
+}
+
+// stoppedTasks returns the tasks the service has already stopped.
+func (s *Server) stoppedTasks() []task {
+	var out []task
+	for i := range s.sc.StoppedTasks {
+		subnet, _ := s.sc.subnet(s.sc.PrivateSubnets[i%len(s.sc.PrivateSubnets)])
+		out = append(out, task{
+			ID:      fmt.Sprintf("%032x", 0x1000+i),
+			Subnet:  subnet,
+			IP:      hostIP(subnet.CIDR, 100+i),
+			Status:  "STOPPED",
+			Stopped: &s.sc.StoppedTasks[i],
+		})
+	}
+	return out
+}
+
+func (s *Server) taskArn(id string) string {
+	return s.sc.arn("ecs", "task/"+s.sc.Cluster+"/"+id)
+}
+
+// hostIP returns the n-th address of a CIDR block.
+func hostIP(cidr string, n int) string {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ""
+	}
+	ip := ipnet.IP.To4()
+	if ip == nil {
+		return ""
+	}
+	v := uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	v += uint32(n)
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).String()
+}
+
+func (s *Server) checkCluster(cluster *string) error {
+	c := aws.StringValue(cluster)
+	if c != s.sc.Cluster && c != s.sc.ClusterArn() {
+		return errorf("ClusterNotFoundException", "Cluster not found.")
+	}
+	return nil
+}
+
+func (s *Server) ecs(op string, body []byte) (any, error) {
+	switch op {
+	case "DescribeServices":
+		var in ecs.DescribeServicesInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.describeServices(&in)
+	case "ListTasks":
+		var in ecs.ListTasksInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.listTasks(&in)
+	case "DescribeTasks":
+		var in ecs.DescribeTasksInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.describeTasks(&in)
+	case "DescribeTaskDefinition":
+		var in ecs.DescribeTaskDefinitionInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.describeTaskDefinition(&in)
+	}
+	return nil, errorf("UnknownOperationException", "ecs %s is not implemented by the fake", op)
+}
+
+func (s *Server) describeServices(in *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	if err := s.checkCluster(in.Cluster); err != nil {
+		return nil, err
+	}
+	out := &ecs.DescribeServicesOutput{}
+	for _, name := range aws.StringValueSlice(in.Services) {
+		arn := s.sc.arn("ecs", "service/"+s.sc.Cluster+"/"+s.sc.Service)
+		if name != s.sc.Service && name != arn {
+			out.Failures = append(out.Failures, &ecs.Failure{Arn: aws.String(name), Reason: aws.String("MISSING")})
+			continue
+		}
+		var running, pending int64
+		if s.taskStatus() == "RUNNING" {
+			running = s.sc.DesiredCount
+		} else {
+			pending = s.sc.DesiredCount
+		}
+		svc := &ecs.Service{
+			ServiceName:    aws.String(s.sc.Service),
+			ServiceArn:     aws.String(arn),
+			ClusterArn:     aws.String(s.sc.ClusterArn()),
+			Status:         aws.String("ACTIVE"),
+			LaunchType:     aws.String("FARGATE"),
+			DesiredCount:   aws.Int64(s.sc.DesiredCount),
+			RunningCount:   aws.Int64(running),
+			PendingCount:   aws.Int64(pending),
+			TaskDefinition: aws.String(s.sc.TaskDefinitionArn()),
+		}
+		for i, msg := range s.sc.Events {
+			svc.Events = append(svc.Events, &ecs.ServiceEvent{
+				Id:        aws.String(fmt.Sprintf("event-%d", i)),
+				CreatedAt: aws.Time(s.start.Add(-time.Duration(i) * time.Minute)),
+				Message:   aws.String(msg),
+			})
+		}
+		out.Services = append(out.Services, svc)
+	}
+	return out, nil
+}
+
+func (s *Server) listTasks(in *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	if err := s.checkCluster(in.Cluster); err != nil {
+		return nil, err
+	}
+	if name := aws.StringValue(in.ServiceName); name != "" && name != s.sc.Service {
+		return nil, errorf("ServiceNotFoundException", "Service not found.")
+	}
+	tasks := s.tasks()
+	if aws.StringValue(in.DesiredStatus) == "STOPPED" {
+		tasks = s.stoppedTasks()
+	}
+	out := &ecs.ListTasksOutput{TaskArns: []*string{}}
+	for _, t := range tasks {
+		out.TaskArns = append(out.TaskArns, aws.String(s.taskArn(t.ID)))
+	}
+	return out, nil
+}
+
+func (s *Server) describeTasks(in *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	if err := s.checkCluster(in.Cluster); err != nil {
+		return nil, err
+	}
+	byID := map[string]task{}
+	for _, t := range append(s.tasks(), s.stoppedTasks()...) {
+		byID[t.ID] = t
+	}
+	out := &ecs.DescribeTasksOutput{}
+	for _, ref := range aws.StringValueSlice(in.Tasks) {
+		t, ok := byID[ref[strings.LastIndex(ref, "/")+1:]]
+		if !ok {
+			out.Failures = append(out.Failures, &ecs.Failure{Arn: aws.String(ref), Reason: aws.String("MISSING")})
+			continue
+		}
+		out.Tasks = append(out.Tasks, s.describeTask(t))
+	}
+	return out, nil
+}
+
+func (s *Server) describeTask(t task) *ecs.Task {
+	container := &ecs.Container{
+		Name:       aws.String("bridge"),
+		Image:      aws.String(s.sc.TaskDefinition.Image),
+		LastStatus: aws.String(t.Status),
+	}
+	desired := "RUNNING"
+	eniStatus := "ATTACHED"
+	if t.Status == "PENDING" {
+		eniStatus = "PRECREATED"
+	}
+	out := &ecs.Task{
+		TaskArn:           aws.String(s.taskArn(t.ID)),
+		ClusterArn:        aws.String(s.sc.ClusterArn()),
+		TaskDefinitionArn: aws.String(s.sc.TaskDefinitionArn()),
+		Group:             aws.String("service:" + s.sc.Service),
+		LaunchType:        aws.String("FARGATE"),
+		AvailabilityZone:  aws.String(t.Subnet.AvailabilityZone),
+		LastStatus:        aws.String(t.Status),
+		CreatedAt:         aws.Time(s.start),
+		Containers:        []*ecs.Container{container},
+	}
+	if t.Stopped != nil {
+		desired = "STOPPED"
+		eniStatus = "DELETED"
+		out.StoppedReason = aws.String(t.Stopped.StoppedReason)
+		out.StoppedAt = aws.Time(s.start)
+		container.Reason = aws.String(t.Stopped.ContainerReason)
+		container.ExitCode = t.Stopped.ExitCode
+	}
+	out.DesiredStatus = aws.String(desired)
+	out.Attachments = []*ecs.Attachment{{
+		Id:     aws.String("attachment-" + t.ID[len(t.ID)-8:]),
+		Type:   aws.String("ElasticNetworkInterface"),
+		Status: aws.String(eniStatus),
+		Details: []*ecs.KeyValuePair{
+			{Name: aws.String("subnetId"), Value: aws.String(t.Subnet.ID)},
+			{Name: aws.String("networkInterfaceId"), Value: aws.String("eni-" + t.ID[len(t.ID)-12:])},
+			{Name: aws.String("privateIPv4Address"), Value: aws.String(t.IP)},
+		},
+	}}
+	return out
+}
+
+func (s *Server) describeTaskDefinition(in *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	def := s.sc.TaskDefinition
+	ref := aws.StringValue(in.TaskDefinition)
+	if ref != s.sc.TaskDefinitionArn() && ref != def.Family && ref != def.Family+":1" {
+		return nil, errorf("ClientException", "Unable to describe task definition.")
+	}
+	return &ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn:       aws.String(s.sc.TaskDefinitionArn()),
+			Family:                  aws.String(def.Family),
+			Revision:                aws.Int64(1),
+			Cpu:                     aws.String(def.CPU),
+			Memory:                  aws.String(def.Memory),
+			NetworkMode:             aws.String("awsvpc"),
+			RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+			ContainerDefinitions: []*ecs.ContainerDefinition{{
+				Name:  aws.String("bridge"),
+				Image: aws.String(def.Image),
+				PortMappings: []*ecs.PortMapping{{
+					ContainerPort: aws.Int64(s.sc.TargetGroup.Port),
+					Protocol:      aws.String("tcp"),
+				}},
+			}},
+		},
+	}, nil
+}
@@ -0,0 +1,117 @@
+package fakeaws
+
+import (
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func (s *Server) elbv2(op string, form url.Values) (any, error) {
+	switch op {
+	case "DescribeLoadBalancers":
+		return s.describeLoadBalancers(form)
+	case "DescribeListeners":
+		return s.describeListeners(form)
+	case "DescribeTargetGroups":
+		return s.describeTargetGroups(form)
+	case "DescribeTargetHealth":
+		return s.describeTargetHealth(form)
+	}
+	return nil, errorf("InvalidAction", "elbv2 %s is not implemented by the fake", op)
+}
+
+func (s *Server) describeLoadBalancers(form url.Values) (*elbv2.DescribeLoadBalancersOutput, error) {
+	arns := queryList(form, "LoadBalancerArns.member")
+	names := queryList(form, "Names.member")
+	if (len(arns) > 0 && !contains(arns, s.sc.LoadBalancerArn())) || (len(names) > 0 && !contains(names, s.sc.LoadBalancer.Name)) {
+		return nil, errorf("LoadBalancerNotFound", "One or more load balancers not found")
+	}
+	return &elbv2.DescribeLoadBalancersOutput{
+		LoadBalancers: []*elbv2.LoadBalancer{{
+			LoadBalancerArn:  aws.String(s.sc.LoadBalancerArn()),
+			LoadBalancerName: aws.String(s.sc.LoadBalancer.Name),
+			DNSName:          aws.String(s.sc.LoadBalancer.DNSName),
+			Scheme:           aws.String("internet-facing"),
+			Type:             aws.String("application"),
+			VpcId:            aws.String(s.sc.VPCID),
+			State:            &elbv2.LoadBalancerState{Code: aws.String("active")},
+			SecurityGroups:   aws.StringSlice([]string{s.sc.ALBSecurityGroup}),
+		}},
+	}, nil
+}
+
+func (s *Server) describeListeners(form url.Values) (*elbv2.DescribeListenersOutput, error) {
+	if form.Get("LoadBalancerArn") != s.sc.LoadBalancerArn() {
+		return nil, errorf("LoadBalancerNotFound", "One or more load balancers not found")
+	}
+	return &elbv2.DescribeListenersOutput{
+		Listeners: []*elbv2.Listener{{
+			ListenerArn:     aws.String(s.sc.arn("elasticloadbalancing", "listener/app/"+s.sc.LoadBalancer.Name+"/0123456789abcdef/0123456789abcdef")),
+			LoadBalancerArn: aws.String(s.sc.LoadBalancerArn()),
+			Port:            aws.Int64(443),
+			Protocol:        aws.String("HTTPS"),
+			DefaultActions: []*elbv2.Action{{
+				Type:           aws.String("forward"),
+				TargetGroupArn: aws.String(s.sc.TargetGroupArn()),
+			}},
+		}},
+	}, nil
+}
+
+func (s *Server) describeTargetGroups(form url.Values) (*elbv2.DescribeTargetGroupsOutput, error) {
+	if lb := form.Get("LoadBalancerArn"); lb != "" && lb != s.sc.LoadBalancerArn() {
+		return nil, errorf("LoadBalancerNotFound", "One or more load balancers not found")
+	}
+	if arns := queryList(form, "TargetGroupArns.member"); len(arns) > 0 && !contains(arns, s.sc.TargetGroupArn()) {
+		return nil, errorf("TargetGroupNotFound", "One or more target groups not found")
+	}
+	return &elbv2.DescribeTargetGroupsOutput{
+		TargetGroups: []*elbv2.TargetGroup{{
+			TargetGroupArn:             aws.String(s.sc.TargetGroupArn()),
+			TargetGroupName:            aws.String(s.sc.TargetGroup.Name),
+			Protocol:                   aws.String("HTTP"),
+			Port:                       aws.Int64(s.sc.TargetGroup.Port),
+			VpcId:                      aws.String(s.sc.VPCID),
+			TargetType:                 aws.String("ip"),
+			HealthCheckProtocol:        aws.String("HTTP"),
+			HealthCheckPort:            aws.String("traffic-port"),
+			HealthCheckPath:            aws.String(s.sc.TargetGroup.HealthCheckPath),
+			HealthCheckIntervalSeconds: aws.Int64(30),
+			HealthCheckTimeoutSeconds:  aws.Int64(5),
+			HealthyThresholdCount:      aws.Int64(2),
+			UnhealthyThresholdCount:    aws.Int64(3),
+			LoadBalancerArns:           aws.StringSlice([]string{s.sc.LoadBalancerArn()}),
+		}},
+	}, nil
+}
+
+// describeTargetHealth registers the running tasks. They stay "initial"
+// for HealthyAfter calls and then report the scenario's target health.
+func (s *Server) describeTargetHealth(form url.Values) (*elbv2.DescribeTargetHealthOutput, error) {
+	if form.Get("TargetGroupArn") != s.sc.TargetGroupArn() {
+		return nil, errorf("TargetGroupNotFound", "One or more target groups not found")
+	}
+	out := &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []*elbv2.TargetHealthDescription{}}
+	if s.taskStatus() != "RUNNING" {
+		return out, nil
+	}
+	health := &elbv2.TargetHealth{State: aws.String("initial"), Reason: aws.String("Elb.RegistrationInProgress"), Description: aws.String("Target registration is in progress")}
+	if s.Calls("elbv2.DescribeTargetHealth") > s.sc.Targets.HealthyAfter {
+		health = &elbv2.TargetHealth{State: aws.String(s.sc.Targets.State)}
+		if s.sc.Targets.Reason != "" {
+			health.Reason = aws.String(s.sc.Targets.Reason)
+		}
+		if s.sc.Targets.Description != "" {
+			health.Description = aws.String(s.sc.Targets.Description)
+		}
+	}
+	for _, t := range s.tasks() {
+		out.TargetHealthDescriptions = append(out.TargetHealthDescriptions, &elbv2.TargetHealthDescription{
+			Target:          &elbv2.TargetDescription{Id: aws.String(t.IP), Port: aws.Int64(s.sc.TargetGroup.Port), AvailabilityZone: aws.String(t.Subnet.AvailabilityZone)},
+			HealthCheckPort: aws.String("traffic-port"),
+			TargetHealth:    health,
+		})
+	}
+	return out, nil
+}
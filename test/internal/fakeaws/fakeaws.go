@@ -0,0 +1,263 @@
+// Package fakeaws is an in-process fake of the ECS, ELBv2, EC2 and
+// CloudWatch Logs operations that the ECS Fargate validation, its
+// diagnostics and the failure artifact collectors call. It serves a
+// Scenario over HTTP in each API's wire protocol (JSON for ECS and Logs,
+// Query for ELBv2 and EC2), so unmodified aws-sdk-go clients are pointed at
+// it with an endpoint override:
+//
+//	srv := fakeaws.New(scenario)
+//	defer srv.Close()
+//	sess := session.Must(session.NewSession(srv.Config()))
+package fakeaws
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// API versions that identify the Query protocol services.
+const (
+	elbv2Version = "2015-12-01"
+	ec2Version   = "2016-11-15"
+)
+
+// Server serves a Scenario. It is safe for concurrent use.
+type Server struct {
+	sc    *Scenario
+	srv   *httptest.Server
+	start time.Time
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// New starts a server for sc.
+func New(sc *Scenario) *Server {
+	s := &Server{sc: sc, start: time.Now().Add(-10 * time.Minute), calls: map[string]int{}}
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// URL is the endpoint of the server.
+func (s *Server) URL() string { return s.srv.URL }
+
+// Close stops the server.
+func (s *Server) Close() { s.srv.Close() }
+
+// Config returns an SDK configuration that sends every client to the
+// server with static credentials and without retries.
+func (s *Server) Config() *aws.Config {
+	return &aws.Config{
+		Endpoint:    aws.String(s.srv.URL),
+		Region:      aws.String(s.sc.Region),
+		Credentials: credentials.NewStaticCredentials("AKIAFAKE", "fake", ""),
+		MaxRetries:  aws.Int(0),
+	}
+}
+
+// Calls returns how many times an operation was called, such as
+// "ecs.DescribeServices" or "ec2.DescribeRouteTables".
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operation]
+}
+
+// call records a call and returns its 1-based count.
+func (s *Server) call(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[operation]++
+	return s.calls[operation]
+}
+
+// apiError is an error response of the API.
+type apiError struct {
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+func errorf(code, format string, args ...any) *apiError {
+	return &apiError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		prefix, op, _ := strings.Cut(target, ".")
+		switch prefix {
+		case "AmazonEC2ContainerServiceV20141113":
+			s.serveJSON(w, "ecs", op, body, s.ecs)
+		case "Logs_20140328":
+			s.serveJSON(w, "logs", op, body, s.logs)
+		default:
+			writeJSONError(w, errorf("UnknownOperationException", "unsupported target %s", target))
+		}
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	op := form.Get("Action")
+	switch form.Get("Version") {
+	case elbv2Version:
+		s.serveQuery(w, "elbv2", op, form, s.elbv2)
+	case ec2Version:
+		s.serveEC2(w, op, form)
+	default:
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+	}
+}
+
+func (s *Server) serveJSON(w http.ResponseWriter, service, op string, body []byte, handle func(op string, body []byte) (any, error)) {
+	s.call(service + "." + op)
+	out, err := handle(op, body)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	data, err := marshalJSON(out)
+	if err != nil {
+		writeJSONError(w, errorf("InternalFailure", "%v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(data)
+}
+
+func writeJSONError(w http.ResponseWriter, err error) {
+	e := asAPIError(err)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `{"__type":%s,"message":%s}`, strconv.Quote(e.Code), strconv.Quote(e.Message))
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, service, op string, form url.Values, handle func(op string, form url.Values) (any, error)) {
+	s.call(service + "." + op)
+	out, err := handle(op, form)
+	if err != nil {
+		e := asAPIError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>fake</RequestId></ErrorResponse>", escape(e.Code), escape(e.Message))
+		return
+	}
+	writeXML(w, op+"Response", op+"Result", out)
+}
+
+func (s *Server) serveEC2(w http.ResponseWriter, op string, form url.Values) {
+	s.call("ec2." + op)
+	out, err := s.ec2(op, form)
+	if err != nil {
+		e := asAPIError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>fake</RequestID></Response>", escape(e.Code), escape(e.Message))
+		return
+	}
+	writeXML(w, op+"Response", "", out)
+}
+
+// writeXML encodes out inside <response> and, for the Query protocol,
+// <result>.
+func writeXML(w http.ResponseWriter, response, result string, out any) {
+	var buf bytes.Buffer
+	buf.WriteString("<" + response + ">")
+	if result != "" {
+		buf.WriteString("<" + result + ">")
+	}
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLMembers(enc, reflect.ValueOf(out)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := enc.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result != "" {
+		buf.WriteString("</" + result + ">")
+	}
+	buf.WriteString("</" + response + ">")
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(buf.Bytes())
+}
+
+func asAPIError(err error) *apiError {
+	if e, ok := err.(*apiError); ok {
+		return e
+	}
+	return &apiError{Code: "InternalFailure", Message: err.Error()}
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// decodeJSON decodes a JSON protocol request body into an SDK input. The
+// SDK names members in lower camel case, which encoding/json matches to
+// the input fields case-insensitively.
+func decodeJSON(body []byte, in any) error {
+	if err := json.Unmarshal(body, in); err != nil {
+		return errorf("SerializationException", "%v", err)
+	}
+	return nil
+}
+
+// queryList returns the members of a Query protocol list parameter:
+// "<name>.1", "<name>.2", ...
+func queryList(form url.Values, name string) []string {
+	var out []string
+	for i := 1; ; i++ {
+		v, ok := form[name+"."+strconv.Itoa(i)]
+		if !ok || len(v) == 0 {
+			return out
+		}
+		out = append(out, v[0])
+	}
+}
+
+// ec2Filters returns the Filter.N.Name / Filter.N.Value.M parameters.
+func ec2Filters(form url.Values) map[string][]string {
+	out := map[string][]string{}
+	for i := 1; ; i++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", i))
+		if name == "" {
+			return out
+		}
+		out[name] = append(out[name], queryList(form, fmt.Sprintf("Filter.%d.Value", i))...)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package fakeaws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scenario() *Scenario {
+	return &Scenario{
+		Region:         "ap-northeast-1",
+		Cluster:        "bridge",
+		Service:        "bridge",
+		DesiredCount:   2,
+		TaskStatus:     "RUNNING",
+		StartsAfter:    1,
+		Events:         []string{"has started 2 tasks"},
+		StoppedTasks:   []StoppedTask{{StoppedReason: "Essential container in task exited", ContainerReason: "exit", ExitCode: aws.Int64(1)}},
+		TaskDefinition: TaskDefinition{Family: "bridge", CPU: "256", Memory: "512", Image: "bridge:latest"},
+		LoadBalancer:   LoadBalancer{Name: "bridge", DNSName: "bridge.elb.amazonaws.com"},
+		TargetGroup:    TargetGroup{Name: "bridge-tg", Port: 8080, HealthCheckPath: "/ok"},
+		Targets:        TargetHealth{State: "unhealthy", Reason: "Target.Timeout", HealthyAfter: 1},
+		VPCID:          "vpc-1",
+		VPCCIDR:        "10.0.0.0/16",
+		PrivateSubnets: []string{"subnet-a", "subnet-c"},
+		Subnets: []Subnet{
+			{ID: "subnet-a", AvailabilityZone: "ap-northeast-1a", CIDR: "10.0.10.0/24", RouteTable: "rtb-private"},
+			{ID: "subnet-c", AvailabilityZone: "ap-northeast-1c", CIDR: "10.0.11.0/24", RouteTable: "rtb-private"},
+			{ID: "subnet-public", AvailabilityZone: "ap-northeast-1a", CIDR: "10.0.0.0/24"},
+		},
+		RouteTables: []RouteTable{{ID: "rtb-private", Routes: []Route{{Destination: "0.0.0.0/0", NatGatewayID: "nat-1"}}}},
+		NatGateways: []NatGateway{{ID: "nat-1", SubnetID: "subnet-public", State: "available", PublicIP: "203.0.113.1"}},
+		SecurityGroups: []SecurityGroup{
+			{ID: "sg-alb", Name: "alb", EgressAll: true, Ingress: []Rule{{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "0.0.0.0/0"}}},
+			{ID: "sg-bridge", Name: "bridge", Ingress: []Rule{{Protocol: "tcp", FromPort: 8080, ToPort: 8080, SourceGroup: "sg-alb"}}},
+		},
+		VPCEndpoints: []VPCEndpoint{
+			{ID: "vpce-ecr", Service: "ecr.api", Type: "Interface", PrivateDNS: true, SubnetIDs: []string{"subnet-a"}, SecurityGroups: []string{"sg-bridge"}},
+			{ID: "vpce-s3", Service: "s3", Type: "Gateway", RouteTableIDs: []string{"rtb-private"}, State: "pending"},
+		},
+		ALBSecurityGroup:    "sg-alb",
+		BridgeSecurityGroup: "sg-bridge",
+		LogGroup:            "/ecs/bridge",
+		Logs:                []string{"starting", "ready"},
+	}
+}
+
+func start(t *testing.T, sc *Scenario) (*Server, *session.Session) {
+	t.Helper()
+	require.NoError(t, sc.validate())
+	srv := New(sc)
+	t.Cleanup(srv.Close)
+	return srv, session.Must(session.NewSession(srv.Config()))
+}
+
+func TestECS(t *testing.T) {
+	sc := scenario()
+	srv, sess := start(t, sc)
+	client := ecs.New(sess)
+
+	describe := func() *ecs.Service {
+		out, err := client.DescribeServices(&ecs.DescribeServicesInput{Cluster: aws.String("bridge"), Services: aws.StringSlice([]string{"bridge"})})
+		require.NoError(t, err)
+		require.Len(t, out.Services, 1)
+		return out.Services[0]
+	}
+	svc := describe()
+	assert.Equal(t, int64(0), aws.Int64Value(svc.RunningCount))
+	assert.Equal(t, int64(2), aws.Int64Value(svc.PendingCount))
+	assert.Equal(t, "has started 2 tasks", aws.StringValue(svc.Events[0].Message))
+	svc = describe()
+	assert.Equal(t, int64(2), aws.Int64Value(svc.RunningCount))
+	assert.Equal(t, 2, srv.Calls("ecs.DescribeServices"))
+
+	running, err := client.ListTasks(&ecs.ListTasksInput{Cluster: aws.String(sc.ClusterArn()), ServiceName: aws.String("bridge"), DesiredStatus: aws.String("RUNNING")})
+	require.NoError(t, err)
+	require.Len(t, running.TaskArns, 2)
+	assert.Equal(t, "arn:aws:ecs:ap-northeast-1:123456789012:task/bridge/00000000000000000000000000000001", aws.StringValue(running.TaskArns[0]))
+
+	tasks, err := client.DescribeTasks(&ecs.DescribeTasksInput{Cluster: aws.String("bridge"), Tasks: running.TaskArns})
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 2)
+	second := tasks.Tasks[1]
+	assert.Equal(t, "RUNNING", aws.StringValue(second.LastStatus))
+	assert.Equal(t, "ap-northeast-1c", aws.StringValue(second.AvailabilityZone))
+	details := map[string]string{}
+	for _, d := range second.Attachments[0].Details {
+		details[aws.StringValue(d.Name)] = aws.StringValue(d.Value)
+	}
+	assert.Equal(t, "subnet-c", details["subnetId"])
+	assert.Equal(t, "10.0.11.11", details["privateIPv4Address"])
+
+	stopped, err := client.ListTasks(&ecs.ListTasksInput{Cluster: aws.String("bridge"), DesiredStatus: aws.String("STOPPED")})
+	require.NoError(t, err)
+	tasks, err = client.DescribeTasks(&ecs.DescribeTasksInput{Cluster: aws.String("bridge"), Tasks: stopped.TaskArns})
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 1)
+	assert.Equal(t, "Essential container in task exited", aws.StringValue(tasks.Tasks[0].StoppedReason))
+	assert.Equal(t, int64(1), aws.Int64Value(tasks.Tasks[0].Containers[0].ExitCode))
+
+	def, err := client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: svc.TaskDefinition})
+	require.NoError(t, err)
+	assert.Equal(t, "bridge:latest", aws.StringValue(def.TaskDefinition.ContainerDefinitions[0].Image))
+
+	_, err = client.DescribeServices(&ecs.DescribeServicesInput{Cluster: aws.String("other"), Services: aws.StringSlice([]string{"bridge"})})
+	assertCode(t, err, "ClusterNotFoundException")
+}
+
+func TestELBV2(t *testing.T) {
+	sc := scenario()
+	sc.StartsAfter = 0
+	_, sess := start(t, sc)
+	client := elbv2.New(sess)
+
+	lbs, err := client.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: aws.StringSlice([]string{sc.LoadBalancerArn()})})
+	require.NoError(t, err)
+	assert.Equal(t, "bridge.elb.amazonaws.com", aws.StringValue(lbs.LoadBalancers[0].DNSName))
+
+	_, err = client.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: aws.StringSlice([]string{"arn:other"})})
+	assertCode(t, err, "LoadBalancerNotFound")
+
+	tgs, err := client.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(sc.LoadBalancerArn())})
+	require.NoError(t, err)
+	assert.Equal(t, "/ok", aws.StringValue(tgs.TargetGroups[0].HealthCheckPath))
+
+	listeners, err := client.DescribeListeners(&elbv2.DescribeListenersInput{LoadBalancerArn: aws.String(sc.LoadBalancerArn())})
+	require.NoError(t, err)
+	assert.Equal(t, sc.TargetGroupArn(), aws.StringValue(listeners.Listeners[0].DefaultActions[0].TargetGroupArn))
+
+	health := func() []*elbv2.TargetHealthDescription {
+		out, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(sc.TargetGroupArn())})
+		require.NoError(t, err)
+		return out.TargetHealthDescriptions
+	}
+	// Tasks are still PENDING until DescribeServices has been called.
+	assert.Empty(t, health())
+
+	_, err = ecs.New(sess).DescribeServices(&ecs.DescribeServicesInput{Cluster: aws.String("bridge"), Services: aws.StringSlice([]string{"bridge"})})
+	require.NoError(t, err)
+	targets := health()
+	require.Len(t, targets, 2)
+	assert.Equal(t, "unhealthy", aws.StringValue(targets[0].TargetHealth.State))
+	assert.Equal(t, "Target.Timeout", aws.StringValue(targets[0].TargetHealth.Reason))
+	assert.Equal(t, "10.0.10.10", aws.StringValue(targets[0].Target.Id))
+}
+
+func TestTargetsStartInitial(t *testing.T) {
+	sc := scenario()
+	sc.StartsAfter = 0
+	sc.Targets.HealthyAfter = 2
+	_, sess := start(t, sc)
+	_, err := ecs.New(sess).DescribeServices(&ecs.DescribeServicesInput{Cluster: aws.String("bridge"), Services: aws.StringSlice([]string{"bridge"})})
+	require.NoError(t, err)
+
+	client := elbv2.New(sess)
+	var states []string
+	for i := 0; i < 3; i++ {
+		out, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(sc.TargetGroupArn())})
+		require.NoError(t, err)
+		states = append(states, aws.StringValue(out.TargetHealthDescriptions[0].TargetHealth.State))
+	}
+	assert.Equal(t, []string{"initial", "initial", "unhealthy"}, states)
+}
+
+func TestEC2(t *testing.T) {
+	sc := scenario()
+	_, sess := start(t, sc)
+	client := ec2.New(sess)
+
+	subnets, err := client.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice([]string{"subnet-c"})})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.11.0/24", aws.StringValue(subnets.Subnets[0].CidrBlock))
+
+	_, err = client.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice([]string{"subnet-missing"})})
+	assertCode(t, err, "InvalidSubnetID.NotFound")
+
+	rts, err := client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: []*ec2.Filter{{
+		Name:   aws.String("association.subnet-id"),
+		Values: aws.StringSlice([]string{"subnet-a"}),
+	}}})
+	require.NoError(t, err)
+	require.Len(t, rts.RouteTables, 1)
+	assert.Equal(t, "nat-1", aws.StringValue(rts.RouteTables[0].Routes[0].NatGatewayId))
+	assert.Nil(t, rts.RouteTables[0].Routes[0].GatewayId)
+
+	rts, err = client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: []*ec2.Filter{{
+		Name:   aws.String("association.subnet-id"),
+		Values: aws.StringSlice([]string{"subnet-public"}),
+	}}})
+	require.NoError(t, err)
+	assert.Empty(t, rts.RouteTables)
+
+	nats, err := client.DescribeNatGateways(&ec2.DescribeNatGatewaysInput{NatGatewayIds: aws.StringSlice([]string{"nat-1"})})
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", aws.StringValue(nats.NatGateways[0].NatGatewayAddresses[0].PublicIp))
+
+	sgs, err := client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: aws.StringSlice([]string{"sg-bridge"})})
+	require.NoError(t, err)
+	sg := sgs.SecurityGroups[0]
+	assert.Equal(t, "sg-alb", aws.StringValue(sg.IpPermissions[0].UserIdGroupPairs[0].GroupId))
+	assert.Empty(t, sg.IpPermissionsEgress)
+
+	_, err = client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: aws.StringSlice([]string{"sg-missing"})})
+	assertCode(t, err, "InvalidGroup.NotFound")
+
+	vpcs, err := client.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{"vpc-1"})})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/16", aws.StringValue(vpcs.Vpcs[0].CidrBlock))
+
+	acls, err := client.DescribeNetworkAcls(&ec2.DescribeNetworkAclsInput{})
+	require.NoError(t, err)
+	require.Len(t, acls.NetworkAcls, 1)
+	assert.Len(t, acls.NetworkAcls[0].Associations, 3, "the default ACL covers every subnet")
+
+	endpoints, err := client.DescribeVpcEndpoints(&ec2.DescribeVpcEndpointsInput{})
+	require.NoError(t, err)
+	require.Len(t, endpoints.VpcEndpoints, 2)
+	assert.Equal(t, "com.amazonaws.ap-northeast-1.ecr.api", aws.StringValue(endpoints.VpcEndpoints[0].ServiceName))
+	assert.Equal(t, "sg-bridge", aws.StringValue(endpoints.VpcEndpoints[0].Groups[0].GroupId))
+	assert.Equal(t, "available", aws.StringValue(endpoints.VpcEndpoints[0].State))
+	assert.Equal(t, "pending", aws.StringValue(endpoints.VpcEndpoints[1].State))
+	assert.Equal(t, []string{"rtb-private"}, aws.StringValueSlice(endpoints.VpcEndpoints[1].RouteTableIds))
+}
+
+func TestLogs(t *testing.T) {
+	sc := scenario()
+	sc.StartsAfter = 0
+	_, sess := start(t, sc)
+	client := cloudwatchlogs.New(sess)
+
+	// Only the stopped task has a stream until the tasks start.
+	streams, err := client.DescribeLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{LogGroupName: aws.String("/ecs/bridge")})
+	require.NoError(t, err)
+	assert.Len(t, streams.LogStreams, 1)
+
+	_, err = ecs.New(sess).DescribeServices(&ecs.DescribeServicesInput{Cluster: aws.String("bridge"), Services: aws.StringSlice([]string{"bridge"})})
+	require.NoError(t, err)
+
+	in := &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  aws.String("/ecs/bridge"),
+		LogStreamName: aws.String("bridge/bridge/00000000000000000000000000000001"),
+		StartFromHead: aws.Bool(true),
+	}
+	page, err := client.GetLogEvents(in)
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	assert.Equal(t, "starting", aws.StringValue(page.Events[0].Message))
+
+	in.NextToken = page.NextForwardToken
+	page, err = client.GetLogEvents(in)
+	require.NoError(t, err)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, aws.StringValue(in.NextToken), aws.StringValue(page.NextForwardToken))
+
+	in.LogStreamName = aws.String("bridge/bridge/missing")
+	_, err = client.GetLogEvents(in)
+	assertCode(t, err, "ResourceNotFoundException")
+}
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenario.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"cluster": "bridge", "service": "bridge", "desired_count": 1,
+		"private_subnets": ["subnet-a"],
+		"subnets": [{"id": "subnet-a", "cidr": "10.0.0.0/24", "route_table": "rtb-missing"}]
+	}`), 0o644))
+	_, err := LoadScenario(path)
+	assert.EqualError(t, err, path+": subnet subnet-a: route table rtb-missing is not in route_tables")
+
+	matches, err := filepath.Glob("../../aws/testdata/scenarios/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	for _, path := range matches {
+		_, err := LoadScenario(path)
+		assert.NoError(t, err)
+	}
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var aerr awserr.Error
+	if assert.ErrorAs(t, err, &aerr) {
+		assert.Equal(t, code, aerr.Code())
+	}
+}
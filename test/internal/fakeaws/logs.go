@@ -0,0 +1,96 @@
+package fakeaws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// endToken is the forward token of the last page. GetLogEvents returns it
+// again when called with it, which is how clients detect the end.
+const endToken = "f/end"
+
+func (s *Server) logs(op string, body []byte) (any, error) {
+	switch op {
+	case "DescribeLogStreams":
+		var in cloudwatchlogs.DescribeLogStreamsInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.describeLogStreams(&in)
+	case "GetLogEvents":
+		var in cloudwatchlogs.GetLogEventsInput
+		if err := decodeJSON(body, &in); err != nil {
+			return nil, err
+		}
+		return s.getLogEvents(&in)
+	}
+	return nil, errorf("UnknownOperationException", "logs %s is not implemented by the fake", op)
+}
+
+// logStreams returns the stream of every task that has started, named
+// like the awslogs driver does: <prefix>/<container>/<task ID>.
+func (s *Server) logStreams() []string {
+	var out []string
+	if s.taskStatus() != "PENDING" {
+		for _, t := range s.tasks() {
+			out = append(out, "bridge/bridge/"+t.ID)
+		}
+	}
+	for _, t := range s.stoppedTasks() {
+		out = append(out, "bridge/bridge/"+t.ID)
+	}
+	return out
+}
+
+func (s *Server) checkLogGroup(name *string) error {
+	if aws.StringValue(name) != s.sc.LogGroup {
+		return errorf("ResourceNotFoundException", "The specified log group does not exist.")
+	}
+	return nil
+}
+
+func (s *Server) describeLogStreams(in *cloudwatchlogs.DescribeLogStreamsInput) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	if err := s.checkLogGroup(in.LogGroupName); err != nil {
+		return nil, err
+	}
+	out := &cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: []*cloudwatchlogs.LogStream{}}
+	last := s.start.Add(time.Duration(len(s.sc.Logs)) * time.Second).UnixMilli()
+	for _, name := range s.logStreams() {
+		out.LogStreams = append(out.LogStreams, &cloudwatchlogs.LogStream{
+			LogStreamName:      aws.String(name),
+			CreationTime:       aws.Int64(s.start.UnixMilli()),
+			LastEventTimestamp: aws.Int64(last),
+		})
+	}
+	return out, nil
+}
+
+// getLogEvents returns all of the scenario's logs on the first page and
+// an empty page for endToken.
+func (s *Server) getLogEvents(in *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	if err := s.checkLogGroup(in.LogGroupName); err != nil {
+		return nil, err
+	}
+	if !contains(s.logStreams(), aws.StringValue(in.LogStreamName)) {
+		return nil, errorf("ResourceNotFoundException", "The specified log stream does not exist.")
+	}
+	out := &cloudwatchlogs.GetLogEventsOutput{
+		Events:            []*cloudwatchlogs.OutputLogEvent{},
+		NextForwardToken:  aws.String(endToken),
+		NextBackwardToken: aws.String("b/start"),
+	}
+	if aws.StringValue(in.NextToken) == endToken {
+		return out, nil
+	}
+	for i, msg := range s.sc.Logs {
+		ts := s.start.Add(time.Duration(i) * time.Second).UnixMilli()
+		out.Events = append(out.Events, &cloudwatchlogs.OutputLogEvent{
+			Timestamp:     aws.Int64(ts),
+			IngestionTime: aws.Int64(ts),
+			Message:       aws.String(msg),
+		})
+	}
+	return out, nil
+}
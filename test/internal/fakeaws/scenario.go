@@ -0,0 +1,273 @@
+package fakeaws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+// AccountID is the account of every ARN the fake returns.
+const AccountID = "123456789012"
+
+// Scenario describes the deployment the fake serves: the ECS service and
+// its tasks, the ALB and target health, the network and the container
+// logs. Progress over time is driven by call counts, so a scenario can
+// start PENDING and become RUNNING on the third DescribeServices call.
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Region      string `json:"region"`
+
+	Cluster      string `json:"cluster"`
+	Service      string `json:"service"`
+	DesiredCount int64  `json:"desired_count"`
+	// TaskStatus is the last status the tasks reach (RUNNING, or PENDING
+	// for tasks that never start).
+	TaskStatus string `json:"task_status"`
+	// StartsAfter is the number of DescribeServices calls during which
+	// the tasks are still PENDING.
+	StartsAfter int `json:"starts_after"`
+	// Events are the service events, newest first.
+	Events         []string       `json:"events"`
+	StoppedTasks   []StoppedTask  `json:"stopped_tasks"`
+	TaskDefinition TaskDefinition `json:"task_definition"`
+
+	LoadBalancer LoadBalancer `json:"load_balancer"`
+	TargetGroup  TargetGroup  `json:"target_group"`
+	// Targets is the health every running task reaches.
+	Targets TargetHealth `json:"targets"`
+
+	VPCID   string `json:"vpc_id"`
+	VPCCIDR string `json:"vpc_cidr"`
+	// PrivateSubnets lists the subnet IDs the tasks run in.
+	PrivateSubnets []string        `json:"private_subnets"`
+	Subnets        []Subnet        `json:"subnets"`
+	RouteTables    []RouteTable    `json:"route_tables"`
+	NatGateways    []NatGateway    `json:"nat_gateways"`
+	SecurityGroups []SecurityGroup `json:"security_groups"`
+	VPCEndpoints   []VPCEndpoint   `json:"vpc_endpoints"`
+	// ALBSecurityGroup and BridgeSecurityGroup are IDs in SecurityGroups.
+	ALBSecurityGroup    string `json:"alb_security_group"`
+	BridgeSecurityGroup string `json:"bridge_security_group"`
+
+	LogGroup string `json:"log_group"`
+	// Logs are the messages of the bridge container of every task.
+	Logs []string `json:"logs"`
+}
+
+// StoppedTask is a task the service has already stopped.
+type StoppedTask struct {
+	StoppedReason   string `json:"stopped_reason"`
+	ContainerReason string `json:"container_reason"`
+	ExitCode        *int64 `json:"exit_code"`
+}
+
+// TaskDefinition is the task definition of the service.
+type TaskDefinition struct {
+	Family string `json:"family"`
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+	Image  string `json:"image"`
+}
+
+// LoadBalancer is the ALB in front of the service.
+type LoadBalancer struct {
+	Name    string `json:"name"`
+	DNSName string `json:"dns_name"`
+}
+
+// TargetGroup is the target group of the service.
+type TargetGroup struct {
+	Name            string `json:"name"`
+	Port            int64  `json:"port"`
+	HealthCheckPath string `json:"health_check_path"`
+}
+
+// TargetHealth is the health of the registered tasks.
+type TargetHealth struct {
+	State       string `json:"state"`
+	Reason      string `json:"reason"`
+	Description string `json:"description"`
+	// HealthyAfter is the number of DescribeTargetHealth calls during
+	// which the targets are still "initial".
+	HealthyAfter int `json:"healthy_after"`
+}
+
+// Subnet is a subnet of the VPC.
+type Subnet struct {
+	ID               string `json:"id"`
+	AvailabilityZone string `json:"availability_zone"`
+	CIDR             string `json:"cidr"`
+	AvailableIPs     int64  `json:"available_ips"`
+	RouteTable       string `json:"route_table"`
+}
+
+// RouteTable is a route table and its routes.
+type RouteTable struct {
+	ID     string  `json:"id"`
+	Routes []Route `json:"routes"`
+}
+
+// Route is one route. Exactly one target is set.
+type Route struct {
+	Destination  string `json:"destination"`
+	GatewayID    string `json:"gateway_id,omitempty"`
+	NatGatewayID string `json:"nat_gateway_id,omitempty"`
+}
+
+// NatGateway is a NAT gateway.
+type NatGateway struct {
+	ID       string `json:"id"`
+	SubnetID string `json:"subnet_id"`
+	State    string `json:"state"`
+	PublicIP string `json:"public_ip"`
+}
+
+// SecurityGroup is a security group with its rules.
+type SecurityGroup struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Ingress []Rule `json:"ingress"`
+	// EgressAll allows all outbound traffic; otherwise there is no egress.
+	EgressAll bool `json:"egress_all"`
+}
+
+// Rule is an ingress rule from a CIDR or a security group.
+type Rule struct {
+	Protocol    string `json:"protocol"`
+	FromPort    int64  `json:"from_port"`
+	ToPort      int64  `json:"to_port"`
+	CIDR        string `json:"cidr,omitempty"`
+	SourceGroup string `json:"source_group,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// VPCEndpoint is a VPC endpoint. Interface endpoints have subnets and
+// security groups, gateway endpoints route tables.
+type VPCEndpoint struct {
+	ID             string   `json:"id"`
+	Service        string   `json:"service"`
+	Type           string   `json:"type"`
+	SubnetIDs      []string `json:"subnet_ids,omitempty"`
+	SecurityGroups []string `json:"security_groups,omitempty"`
+	PrivateDNS     bool     `json:"private_dns,omitempty"`
+	RouteTableIDs  []string `json:"route_table_ids,omitempty"`
+	// State defaults to "available".
+	State string `json:"state,omitempty"`
+}
+
+// LoadScenario reads a scenario from a JSON file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	if sc.Cluster == "" || sc.Service == "" {
+		return fmt.Errorf("cluster and service are required")
+	}
+	if sc.DesiredCount < 1 {
+		return fmt.Errorf("desired_count must be at least 1")
+	}
+	if len(sc.PrivateSubnets) == 0 {
+		return fmt.Errorf("private_subnets is required")
+	}
+	for _, id := range sc.PrivateSubnets {
+		s, ok := sc.subnet(id)
+		if !ok {
+			return fmt.Errorf("private subnet %s is not in subnets", id)
+		}
+		if _, _, err := net.ParseCIDR(s.CIDR); err != nil {
+			return fmt.Errorf("subnet %s: %w", id, err)
+		}
+	}
+	for _, s := range sc.Subnets {
+		if s.RouteTable == "" {
+			continue
+		}
+		if _, ok := sc.routeTable(s.RouteTable); !ok {
+			return fmt.Errorf("subnet %s: route table %s is not in route_tables", s.ID, s.RouteTable)
+		}
+	}
+	for _, e := range sc.VPCEndpoints {
+		for _, id := range e.SubnetIDs {
+			if _, ok := sc.subnet(id); !ok {
+				return fmt.Errorf("VPC endpoint %s: subnet %s is not in subnets", e.ID, id)
+			}
+		}
+		for _, id := range e.SecurityGroups {
+			if _, ok := sc.securityGroup(id); !ok {
+				return fmt.Errorf("VPC endpoint %s: security group %s is not in security_groups", e.ID, id)
+			}
+		}
+		for _, id := range e.RouteTableIDs {
+			if _, ok := sc.routeTable(id); !ok {
+				return fmt.Errorf("VPC endpoint %s: route table %s is not in route_tables", e.ID, id)
+			}
+		}
+	}
+	for _, id := range []string{sc.ALBSecurityGroup, sc.BridgeSecurityGroup} {
+		if _, ok := sc.securityGroup(id); !ok {
+			return fmt.Errorf("security group %q is not in security_groups", id)
+		}
+	}
+	return nil
+}
+
+func (sc *Scenario) subnet(id string) (Subnet, bool) {
+	for _, s := range sc.Subnets {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Subnet{}, false
+}
+
+func (sc *Scenario) routeTable(id string) (RouteTable, bool) {
+	for _, rt := range sc.RouteTables {
+		if rt.ID == id {
+			return rt, true
+		}
+	}
+	return RouteTable{}, false
+}
+
+func (sc *Scenario) securityGroup(id string) (SecurityGroup, bool) {
+	for _, sg := range sc.SecurityGroups {
+		if sg.ID == id {
+			return sg, true
+		}
+	}
+	return SecurityGroup{}, false
+}
+
+func (sc *Scenario) arn(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, sc.Region, AccountID, resource)
+}
+
+// ClusterArn, LoadBalancerArn, TargetGroupArn and TaskDefinitionArn are
+// the ARNs the fake reports for the scenario's resources.
+func (sc *Scenario) ClusterArn() string { return sc.arn("ecs", "cluster/"+sc.Cluster) }
+
+func (sc *Scenario) LoadBalancerArn() string {
+	return sc.arn("elasticloadbalancing", "loadbalancer/app/"+sc.LoadBalancer.Name+"/0123456789abcdef")
+}
+
+func (sc *Scenario) TargetGroupArn() string {
+	return sc.arn("elasticloadbalancing", "targetgroup/"+sc.TargetGroup.Name+"/0123456789abcdef")
+}
+
+func (sc *Scenario) TaskDefinitionArn() string {
+	return sc.arn("ecs", "task-definition/"+sc.TaskDefinition.Family+":1")
+}
@@ -0,0 +1,198 @@
+package fakeaws
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// The SDK reads responses into its public ecs, elbv2, ec2 and
+// cloudwatchlogs shapes by their struct tags: a member is named by its
+// locationName tag (or its field name), and a Query protocol list wraps its
+// items in locationNameList elements ("member" by default) unless it is
+// flattened. The fake writes its responses from the same shapes, following
+// those tags, with encoding/json and encoding/xml.
+
+// eachMember calls fn with the wire name, field and value of every exported
+// member of the shape v.
+func eachMember(v reflect.Value, fn func(name string, f reflect.StructField, m reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get("locationName")
+		if name == "" {
+			name = f.Name
+		}
+		if err := fn(name, f, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalJSON encodes a shape in the JSON protocol: members are named by
+// locationName, nil members are left out and timestamps are epoch seconds.
+func marshalJSON(shape any) ([]byte, error) {
+	v, _ := jsonValue(reflect.ValueOf(shape))
+	return json.Marshal(v)
+}
+
+// jsonValue converts v into a value encoding/json marshals as the JSON
+// protocol does. ok is false for nil values, which are left out.
+func jsonValue(v reflect.Value) (value any, ok bool) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, false
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return jsonValue(v.Elem())
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return float64(t.UnixNano()) / 1e9, true
+		}
+		out := map[string]any{}
+		eachMember(v, func(name string, _ reflect.StructField, m reflect.Value) error {
+			if value, ok := jsonValue(m); ok {
+				out[name] = value
+			}
+			return nil
+		})
+		return out, true
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), true
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i], _ = jsonValue(v.Index(i))
+		}
+		return out, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		out := map[string]any{}
+		for it := v.MapRange(); it.Next(); {
+			if value, ok := jsonValue(it.Value()); ok {
+				out[it.Key().String()] = value
+			}
+		}
+		return out, true
+	default:
+		return v.Interface(), true
+	}
+}
+
+// encodeXMLMembers writes the members of the shape v (a struct or a pointer
+// to one) as Query protocol elements.
+func encodeXMLMembers(enc *xml.Encoder, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return eachMember(v, func(name string, f reflect.StructField, m reflect.Value) error {
+		return encodeXML(enc, name, f.Tag, m)
+	})
+}
+
+// encodeXML writes v as the element name. tag holds the list tags of the
+// member v belongs to. Nil values are left out.
+func encodeXML(enc *xml.Encoder, name string, tag reflect.StructTag, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return encodeXML(enc, name, tag, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		item := tag.Get("locationNameList")
+		if tag.Get("flattened") != "" {
+			if item == "" {
+				item = name
+			}
+			for i := 0; i < v.Len(); i++ {
+				if err := encodeXML(enc, item, "", v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if item == "" {
+			item = "member"
+		}
+		return element(enc, name, func() error {
+			for i := 0; i < v.Len(); i++ {
+				if err := encodeXML(enc, item, "", v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		return element(enc, name, func() error {
+			for it := v.MapRange(); it.Next(); {
+				err := element(enc, "entry", func() error {
+					if err := encodeXML(enc, "key", "", it.Key()); err != nil {
+						return err
+					}
+					return encodeXML(enc, "value", "", it.Value())
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return text(enc, name, t.UTC().Format("2006-01-02T15:04:05.000Z"))
+		}
+		return element(enc, name, func() error { return encodeXMLMembers(enc, v) })
+	case reflect.String:
+		return text(enc, name, v.String())
+	case reflect.Bool:
+		return text(enc, name, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return text(enc, name, strconv.FormatInt(v.Int(), 10))
+	case reflect.Float32, reflect.Float64:
+		return text(enc, name, strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	default:
+		return fmt.Errorf("fakeaws: cannot encode %s as XML", v.Type())
+	}
+}
+
+// element writes <name>, whatever body writes and </name>.
+func element(enc *xml.Encoder, name string, body func() error) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := body(); err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// text writes <name>s</name>.
+func text(enc *xml.Encoder, name, s string) error {
+	return element(enc, name, func() error { return enc.EncodeToken(xml.CharData(s)) })
+}
@@ -0,0 +1,129 @@
+// Package fakerun is an in-process fake of the Cloud Run Admin API v2
+// Services and Revisions gRPC services, read-only. It serves a Scenario on
+// a local port; the generated clients are pointed at it with the options
+// from ClientOptions:
+//
+//	srv, err := fakerun.Start(scenario)
+//	defer srv.Close()
+//	client, err := run.NewServicesClient(ctx, srv.ClientOptions()...)
+package fakerun
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server serves a Scenario. It is safe for concurrent use.
+type Server struct {
+	sc   *Scenario
+	lis  net.Listener
+	grpc *grpc.Server
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// Start serves sc on a free local port.
+func Start(sc *Scenario) (*Server, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{sc: sc, lis: lis, calls: map[string]int{}}
+	s.grpc = grpc.NewServer(grpc.UnaryInterceptor(s.count))
+	runpb.RegisterServicesServer(s.grpc, &services{s: s})
+	runpb.RegisterRevisionsServer(s.grpc, &revisions{s: s})
+	go s.grpc.Serve(lis)
+	return s, nil
+}
+
+// Addr is the host:port of the server.
+func (s *Server) Addr() string { return s.lis.Addr().String() }
+
+// Close stops the server.
+func (s *Server) Close() { s.grpc.Stop() }
+
+// ClientOptions point a Cloud Run client at the server over plaintext
+// without credentials.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// Calls returns how many times a method was called, such as "GetService"
+// or "ListRevisions".
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Server) count(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	s.mu.Lock()
+	s.calls[method]++
+	s.mu.Unlock()
+	return handler(ctx, req)
+}
+
+type services struct {
+	runpb.UnimplementedServicesServer
+	s *Server
+}
+
+func (x *services) GetService(_ context.Context, req *runpb.GetServiceRequest) (*runpb.Service, error) {
+	svc := x.s.sc.Service
+	if req.GetName() != svc.GetName() {
+		return nil, status.Errorf(codes.NotFound, "Resource '%s' was not found", req.GetName())
+	}
+	return proto.Clone(svc).(*runpb.Service), nil
+}
+
+func (x *services) ListServices(_ context.Context, req *runpb.ListServicesRequest) (*runpb.ListServicesResponse, error) {
+	svc := x.s.sc.Service
+	out := &runpb.ListServicesResponse{}
+	if strings.HasPrefix(svc.GetName(), req.GetParent()+"/services/") {
+		out.Services = append(out.Services, proto.Clone(svc).(*runpb.Service))
+	}
+	return out, nil
+}
+
+type revisions struct {
+	runpb.UnimplementedRevisionsServer
+	s *Server
+}
+
+func (x *revisions) GetRevision(_ context.Context, req *runpb.GetRevisionRequest) (*runpb.Revision, error) {
+	for _, rev := range x.s.sc.Revisions {
+		if rev.GetName() == req.GetName() {
+			return proto.Clone(rev).(*runpb.Revision), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "Resource '%s' was not found", req.GetName())
+}
+
+// ListRevisions returns every revision of the service in one page. The
+// "-" wildcard is not supported.
+func (x *revisions) ListRevisions(_ context.Context, req *runpb.ListRevisionsRequest) (*runpb.ListRevisionsResponse, error) {
+	if req.GetParent() != x.s.sc.Service.GetName() {
+		return nil, status.Errorf(codes.NotFound, "Resource '%s' was not found", req.GetParent())
+	}
+	out := &runpb.ListRevisionsResponse{}
+	for _, rev := range x.s.sc.Revisions {
+		out.Revisions = append(out.Revisions, proto.Clone(rev).(*runpb.Revision))
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package fakerun
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const fixture = `{
+  "name": "test",
+  "service": {
+    "name": "projects/p/locations/asia-northeast1/services/bridge",
+    "ingress": "INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER",
+    "template": {"containers": [{"image": "bridge", "ports": [{"containerPort": 8080}]}]},
+    "terminalCondition": {"type": "Ready", "state": "CONDITION_SUCCEEDED"}
+  },
+  "revisions": [
+    {"name": "projects/p/locations/asia-northeast1/services/bridge/revisions/bridge-00002-b"},
+    {"name": "projects/p/locations/asia-northeast1/services/bridge/revisions/bridge-00001-a"}
+  ]
+}`
+
+const serviceName = "projects/p/locations/asia-northeast1/services/bridge"
+
+func load(t *testing.T, content string) (*Scenario, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return LoadScenario(path)
+}
+
+func start(t *testing.T) *Server {
+	t.Helper()
+	sc, err := load(t, fixture)
+	require.NoError(t, err)
+	srv, err := Start(sc)
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServices(t *testing.T) {
+	ctx := context.Background()
+	srv := start(t)
+	client, err := run.NewServicesClient(ctx, srv.ClientOptions()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	svc, err := client.GetService(ctx, &runpb.GetServiceRequest{Name: serviceName})
+	require.NoError(t, err)
+	assert.Equal(t, runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER, svc.GetIngress())
+	assert.Equal(t, int32(8080), svc.GetTemplate().GetContainers()[0].GetPorts()[0].GetContainerPort())
+	assert.Equal(t, runpb.Condition_CONDITION_SUCCEEDED, svc.GetTerminalCondition().GetState())
+
+	_, err = client.GetService(ctx, &runpb.GetServiceRequest{Name: serviceName + "-missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	it := client.ListServices(ctx, &runpb.ListServicesRequest{Parent: "projects/p/locations/asia-northeast1"})
+	got, err := it.Next()
+	require.NoError(t, err)
+	assert.Equal(t, serviceName, got.GetName())
+	_, err = it.Next()
+	assert.True(t, errors.Is(err, iterator.Done))
+
+	assert.Equal(t, 2, srv.Calls("GetService"))
+	assert.Equal(t, 1, srv.Calls("ListServices"))
+}
+
+func TestRevisions(t *testing.T) {
+	ctx := context.Background()
+	srv := start(t)
+	client, err := run.NewRevisionsClient(ctx, srv.ClientOptions()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	var names []string
+	it := client.ListRevisions(ctx, &runpb.ListRevisionsRequest{Parent: serviceName})
+	for {
+		rev, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, rev.GetName())
+	}
+	assert.Equal(t, []string{serviceName + "/revisions/bridge-00002-b", serviceName + "/revisions/bridge-00001-a"}, names)
+
+	rev, err := client.GetRevision(ctx, &runpb.GetRevisionRequest{Name: serviceName + "/revisions/bridge-00001-a"})
+	require.NoError(t, err)
+	assert.Equal(t, serviceName+"/revisions/bridge-00001-a", rev.GetName())
+
+	_, err = client.GetRevision(ctx, &runpb.GetRevisionRequest{Name: serviceName + "/revisions/missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUnimplemented(t *testing.T) {
+	ctx := context.Background()
+	srv := start(t)
+	client, err := run.NewServicesClient(ctx, srv.ClientOptions()...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: serviceName})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestLoadScenario(t *testing.T) {
+	_, err := load(t, `{"service": {"name": "bridge"}}`)
+	assert.ErrorContains(t, err, `service name "bridge" is not projects/*/locations/*/services/*`)
+
+	_, err = load(t, `{"service": {"name": "`+serviceName+`", "ingress": "PUBLIC"}}`)
+	assert.ErrorContains(t, err, "service:")
+
+	_, err = load(t, `{"service": {"name": "`+serviceName+`"}, "revisions": [{"name": "projects/p/locations/asia-northeast1/services/other/revisions/r"}]}`)
+	assert.ErrorContains(t, err, "is not a revision of "+serviceName)
+
+	matches, err := filepath.Glob("../../gcp/testdata/scenarios/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	for _, path := range matches {
+		_, err := LoadScenario(path)
+		assert.NoError(t, err)
+	}
+}
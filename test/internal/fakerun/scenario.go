@@ -0,0 +1,70 @@
+package fakerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Scenario is the Cloud Run state the fake serves: one service and its
+// revisions, in the API's JSON representation.
+type Scenario struct {
+	Name        string
+	Description string
+	Service     *runpb.Service
+	Revisions   []*runpb.Revision
+}
+
+type scenarioFile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Service     json.RawMessage   `json:"service"`
+	Revisions   []json.RawMessage `json:"revisions"`
+}
+
+// LoadScenario reads a scenario from a JSON file. service and revisions
+// use the field names of the Cloud Run Admin API v2 (camelCase), so they
+// can be copied from `gcloud run services describe --format=json` after
+// removing what the test does not need.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f scenarioFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sc := &Scenario{Name: f.Name, Description: f.Description, Service: &runpb.Service{}}
+	if err := protojson.Unmarshal(f.Service, sc.Service); err != nil {
+		return nil, fmt.Errorf("%s: service: %w", path, err)
+	}
+	for i, raw := range f.Revisions {
+		rev := &runpb.Revision{}
+		if err := protojson.Unmarshal(raw, rev); err != nil {
+			return nil, fmt.Errorf("%s: revisions[%d]: %w", path, i, err)
+		}
+		sc.Revisions = append(sc.Revisions, rev)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	name := sc.Service.GetName()
+	if len(strings.Split(name, "/")) != 6 || !strings.HasPrefix(name, "projects/") {
+		return fmt.Errorf("service name %q is not projects/*/locations/*/services/*", name)
+	}
+	for _, rev := range sc.Revisions {
+		if !strings.HasPrefix(rev.GetName(), name+"/revisions/") {
+			return fmt.Errorf("revision %q is not a revision of %s", rev.GetName(), name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package gcpcleanup discovers and force-deletes the GCP resources left behind
+// by examples/gcp-cloud-run when terraform destroy fails.
+//
+// Resources are matched by service-name prefix and deleted in dependency
+// order.
+package gcpcleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Kind identifies a type of GCP resource handled by the cleaner.
+type Kind string
+
+const (
+	KindCloudRunService      Kind = "cloud-run-service"
+	KindSQLInstance          Kind = "sql-instance"
+	KindVPCPeering           Kind = "vpc-peering"
+	KindForwardingRule       Kind = "forwarding-rule"
+	KindTargetHTTPSProxy     Kind = "target-https-proxy"
+	KindTargetHTTPProxy      Kind = "target-http-proxy"
+	KindURLMap               Kind = "url-map"
+	KindSSLCertificate       Kind = "ssl-certificate"
+	KindBackendService       Kind = "backend-service"
+	KindSecurityPolicy       Kind = "security-policy"
+	KindNetworkEndpointGroup Kind = "network-endpoint-group"
+	KindGlobalAddress        Kind = "global-address"
+	KindServerlessIPv4       Kind = "serverless-ipv4"
+	KindRoute                Kind = "route"
+	KindSubnetwork           Kind = "subnetwork"
+	KindNetwork              Kind = "network"
+)
+
+const (
+	serverlessIPv4Substring      = "serverless-ipv4"
+	serviceNetworkingSubstring   = "servicenetworking"
+	defaultInternetGatewaySuffix = "default-internet-gateway"
+)
+
+// regional reports whether resources of the kind live in a region.
+func (k Kind) regional() bool {
+	switch k {
+	case KindCloudRunService, KindNetworkEndpointGroup, KindServerlessIPv4, KindSubnetwork:
+		return true
+	}
+	return false
+}
+
+// FullOrder is the deletion order used by a full cleanup.
+// Cloud Run goes first so that its serverless-ipv4 addresses start being
+// released while the slower Cloud SQL deletion runs.
+var FullOrder = []Kind{
+	KindCloudRunService,
+	KindSQLInstance,
+	KindVPCPeering,
+	KindForwardingRule,
+	KindTargetHTTPSProxy,
+	KindTargetHTTPProxy,
+	KindURLMap,
+	KindSSLCertificate,
+	KindBackendService,
+	KindSecurityPolicy,
+	KindNetworkEndpointGroup,
+	KindGlobalAddress,
+	KindServerlessIPv4,
+	KindRoute,
+	KindSubnetwork,
+	KindNetwork,
+}
+
+// QuickOrder only removes the VPC and what blocks its deletion.
+var QuickOrder = []Kind{
+	KindVPCPeering,
+	KindGlobalAddress,
+	KindServerlessIPv4,
+	KindSubnetwork,
+	KindNetwork,
+}
+
+// Resource is a discovered GCP resource.
+type Resource struct {
+	Kind   Kind   `json:"kind"`
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	// Network is the short name of the VPC network the resource belongs to
+	// (peerings, routes, subnetworks, addresses).
+	Network string `json:"network,omitempty"`
+	// Subnetwork is the short name of the subnetwork (serverless-ipv4 addresses).
+	Subnetwork string `json:"subnetwork,omitempty"`
+	// Target is the peer network of a peering or the next hop of a route.
+	Target string `json:"target,omitempty"`
+}
+
+func (r Resource) String() string {
+	if r.Region != "" {
+		return fmt.Sprintf("%s %s (region: %s)", r.Kind, r.Name, r.Region)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// Client lists and deletes GCP resources. Implementations must wait for
+// long running delete operations to finish before returning.
+type Client interface {
+	// List returns all resources of kind in the project. region is empty
+	// for global kinds.
+	List(ctx context.Context, kind Kind, region string) ([]Resource, error)
+	Delete(ctx context.Context, r Resource) error
+}
+
+// Options configures a cleanup run.
+type Options struct {
+	Project string
+	// Prefix is the service name prefix used to match resources.
+	Prefix  string
+	Regions []string
+	// Order is the list of kinds to process. Defaults to FullOrder.
+	Order  []Kind
+	DryRun bool
+
+	// ServerlessIPv4Timeout bounds the wait for Cloud Run to release its
+	// serverless-ipv4 addresses before subnetworks are deleted.
+	ServerlessIPv4Timeout  time.Duration
+	ServerlessIPv4Interval time.Duration
+
+	// Sleep and Now are injectable for tests.
+	Sleep func(time.Duration)
+	Now   func() time.Time
+	// Logf receives progress messages. Defaults to a no-op.
+	Logf func(format string, args ...any)
+}
+
+// DefaultRegions are the regions searched by default.
+var DefaultRegions = []string{"asia-northeast1", "us-central1", "europe-west1"}
+
+func (o *Options) setDefaults() {
+	if len(o.Regions) == 0 {
+		o.Regions = DefaultRegions
+	}
+	if len(o.Order) == 0 {
+		o.Order = FullOrder
+	}
+	if o.ServerlessIPv4Timeout == 0 {
+		o.ServerlessIPv4Timeout = 15 * time.Minute
+	}
+	if o.ServerlessIPv4Interval == 0 {
+		o.ServerlessIPv4Interval = 30 * time.Second
+	}
+	if o.Sleep == nil {
+		o.Sleep = time.Sleep
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	if o.Logf == nil {
+		o.Logf = func(string, ...any) {}
+	}
+}
+
+// Action is what happened to a resource.
+type Action string
+
+const (
+	ActionDeleted     Action = "deleted"
+	ActionWouldDelete Action = "would-delete"
+	ActionFailed      Action = "failed"
+	// ActionReleased and ActionNotReleased are used for serverless-ipv4
+	// addresses, which cannot be deleted and are released by GCP instead.
+	ActionReleased    Action = "released"
+	ActionNotReleased Action = "not-released"
+)
+
+// Entry is one line of the cleanup report.
+type Entry struct {
+	Resource Resource `json:"resource"`
+	Action   Action   `json:"action"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Report is the result of a cleanup run.
+type Report struct {
+	Project string  `json:"project"`
+	Prefix  string  `json:"prefix"`
+	DryRun  bool    `json:"dry_run"`
+	Entries []Entry `json:"entries"`
+	// ListErrors are discovery failures; the affected kinds were skipped.
+	ListErrors []string `json:"list_errors,omitempty"`
+}
+
+// OK reports whether every matched resource was deleted (or would be).
+func (r Report) OK() bool {
+	if len(r.ListErrors) > 0 {
+		return false
+	}
+	for _, e := range r.Entries {
+		if e.Action == ActionFailed || e.Action == ActionNotReleased {
+			return false
+		}
+	}
+	return true
+}
+
+// Run discovers the resources matching opts.Prefix and deletes them in order.
+// Deletion failures are recorded and do not stop the run, like the
+// `|| true` in the bash scripts.
+func Run(ctx context.Context, c Client, opts Options) Report {
+	opts.setDefaults()
+	report := Report{Project: opts.Project, Prefix: opts.Prefix, DryRun: opts.DryRun}
+
+	for _, kind := range opts.Order {
+		if err := ctx.Err(); err != nil {
+			report.ListErrors = append(report.ListErrors, err.Error())
+			return report
+		}
+
+		matched, errs := discover(ctx, c, kind, opts)
+		report.ListErrors = append(report.ListErrors, errs...)
+		if len(matched) == 0 {
+			opts.Logf("[%s] nothing to delete", kind)
+			continue
+		}
+
+		if kind == KindServerlessIPv4 {
+			report.Entries = append(report.Entries, waitServerlessIPv4(ctx, c, matched, opts)...)
+			continue
+		}
+
+		for _, r := range matched {
+			if opts.DryRun {
+				opts.Logf("[%s] would delete %s", kind, r)
+				report.Entries = append(report.Entries, Entry{Resource: r, Action: ActionWouldDelete})
+				continue
+			}
+			opts.Logf("[%s] deleting %s", kind, r)
+			if err := c.Delete(ctx, r); err != nil {
+				opts.Logf("[%s] failed to delete %s: %v", kind, r, err)
+				report.Entries = append(report.Entries, Entry{Resource: r, Action: ActionFailed, Error: err.Error()})
+				continue
+			}
+			report.Entries = append(report.Entries, Entry{Resource: r, Action: ActionDeleted})
+		}
+	}
+	return report
+}
+
+// discover lists kind in every relevant scope and keeps the resources that
+// belong to the prefix.
+func discover(ctx context.Context, c Client, kind Kind, opts Options) ([]Resource, []string) {
+	scopes := []string{""}
+	if kind.regional() {
+		scopes = opts.Regions
+	}
+
+	var matched []Resource
+	var errs []string
+	for _, region := range scopes {
+		all, err := c.List(ctx, kind, region)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("list %s in %q: %v", kind, region, err))
+			continue
+		}
+		for _, r := range all {
+			if Matches(r, opts.Prefix) {
+				matched = append(matched, r)
+			}
+		}
+	}
+	return matched, errs
+}
+
+// Matches reports whether r belongs to the deployment with the given prefix.
+func Matches(r Resource, prefix string) bool {
+	switch r.Kind {
+	case KindServerlessIPv4:
+		return strings.Contains(r.Name, serverlessIPv4Substring) && strings.HasPrefix(r.Subnetwork, prefix)
+	case KindVPCPeering:
+		return strings.HasPrefix(r.Network, prefix) && strings.Contains(r.Target, serviceNetworkingSubstring)
+	case KindRoute:
+		// Only routes to the internet gateway can be deleted; local and
+		// peering routes are removed with the network.
+		return strings.HasPrefix(r.Network, prefix) && strings.HasSuffix(r.Target, defaultInternetGatewaySuffix)
+	default:
+		return strings.HasPrefix(r.Name, prefix)
+	}
+}
+
+// waitServerlessIPv4 polls until Cloud Run has released the addresses or
+// the timeout expires. The addresses cannot be deleted directly. A failed
+// list leaves the addresses pending: only a successful list that no longer
+// contains an address counts as its release.
+func waitServerlessIPv4(ctx context.Context, c Client, addrs []Resource, opts Options) []Entry {
+	if opts.DryRun {
+		entries := make([]Entry, 0, len(addrs))
+		for _, r := range addrs {
+			opts.Logf("[%s] would wait for release of %s", KindServerlessIPv4, r)
+			entries = append(entries, Entry{Resource: r, Action: ActionWouldDelete})
+		}
+		return entries
+	}
+
+	deadline := opts.Now().Add(opts.ServerlessIPv4Timeout)
+	remaining := addrs
+	var listErrs []string
+	for {
+		opts.Logf("[%s] waiting for GCP to release %d address(es)...", KindServerlessIPv4, len(remaining))
+		if !opts.Now().Before(deadline) || ctx.Err() != nil {
+			break
+		}
+		opts.Sleep(opts.ServerlessIPv4Interval)
+
+		current, errs := discover(ctx, c, KindServerlessIPv4, opts)
+		listErrs = errs
+		if len(errs) > 0 {
+			opts.Logf("[%s] failed to list addresses: %s", KindServerlessIPv4, strings.Join(errs, "; "))
+			continue
+		}
+		remaining = intersect(remaining, current)
+		if len(remaining) == 0 {
+			break
+		}
+	}
+
+	reason := fmt.Sprintf("still present after %v", opts.ServerlessIPv4Timeout)
+	if len(listErrs) > 0 {
+		reason = fmt.Sprintf("release not confirmed after %v: %s", opts.ServerlessIPv4Timeout, strings.Join(listErrs, "; "))
+	}
+	entries := make([]Entry, 0, len(addrs))
+	for _, r := range addrs {
+		if contains(remaining, r) {
+			entries = append(entries, Entry{
+				Resource: r,
+				Action:   ActionNotReleased,
+				Error:    reason,
+			})
+			continue
+		}
+		entries = append(entries, Entry{Resource: r, Action: ActionReleased})
+	}
+	return entries
+}
+
+func intersect(a, b []Resource) []Resource {
+	var out []Resource
+	for _, r := range a {
+		if contains(b, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func contains(list []Resource, r Resource) bool {
+	for _, x := range list {
+		if x.Kind == r.Kind && x.Name == r.Name && x.Region == r.Region {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report as a human readable table.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Project:\t%s\n", r.Project)
+	fmt.Fprintf(tw, "Prefix:\t%s\n", r.Prefix)
+	fmt.Fprintf(tw, "Dry run:\t%t\n\n", r.DryRun)
+	fmt.Fprintln(tw, "KIND\tNAME\tREGION\tACTION\tERROR")
+	for _, e := range r.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Resource.Kind, e.Resource.Name, e.Resource.Region, e.Action, e.Error)
+	}
+	if len(r.Entries) == 0 {
+		fmt.Fprintln(tw, "(no matching resources)")
+	}
+	for _, le := range r.ListErrors {
+		fmt.Fprintf(tw, "list error: %s\n", le)
+	}
+	return tw.Flush()
+}
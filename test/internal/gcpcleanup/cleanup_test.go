@@ -0,0 +1,266 @@
+package gcpcleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient is an in-memory Client. Serverless-ipv4 addresses disappear
+// after releaseAfter list calls, like GCP releasing them asynchronously.
+type fakeClient struct {
+	resources    map[Kind][]Resource
+	deleted      []Resource
+	failDelete   map[string]error
+	releaseAfter int
+	ipv4Lists    int
+	// failIPv4ListAfter makes every serverless-ipv4 list after the first
+	// failIPv4ListAfter calls fail, like a revoked permission.
+	failIPv4ListAfter int
+}
+
+func (f *fakeClient) List(_ context.Context, kind Kind, region string) ([]Resource, error) {
+	if kind == KindServerlessIPv4 {
+		f.ipv4Lists++
+		if f.failIPv4ListAfter > 0 && f.ipv4Lists > f.failIPv4ListAfter {
+			return nil, errors.New("googleapi: Error 403: Required 'compute.addresses.list' permission")
+		}
+		if f.releaseAfter > 0 && f.ipv4Lists > f.releaseAfter {
+			return nil, nil
+		}
+	}
+	var out []Resource
+	for _, r := range f.resources[kind] {
+		if r.Region == region {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeClient) Delete(_ context.Context, r Resource) error {
+	if err := f.failDelete[r.Name]; err != nil {
+		return err
+	}
+	f.deleted = append(f.deleted, r)
+	kept := f.resources[r.Kind][:0]
+	for _, x := range f.resources[r.Kind] {
+		if x.Name != r.Name || x.Region != r.Region || x.Network != r.Network {
+			kept = append(kept, x)
+		}
+	}
+	f.resources[r.Kind] = kept
+	return nil
+}
+
+// newFakeDeployment returns the resources created by one run of
+// examples/gcp-cloud-run with service name "bridge-test-abc", plus
+// unrelated resources that must never be touched.
+func newFakeDeployment() *fakeClient {
+	const region = "asia-northeast1"
+	return &fakeClient{
+		resources: map[Kind][]Resource{
+			KindCloudRunService: {
+				{Kind: KindCloudRunService, Name: "bridge-test-abc", Region: region},
+				{Kind: KindCloudRunService, Name: "production-bridge", Region: region},
+			},
+			KindSQLInstance: {
+				{Kind: KindSQLInstance, Name: "bridge-test-abc-db-1a2b3c4d"},
+			},
+			KindVPCPeering: {
+				{Kind: KindVPCPeering, Name: "servicenetworking-googleapis-com", Network: "bridge-test-abc-vpc", Target: "https://www.googleapis.com/compute/v1/projects/x/global/networks/servicenetworking"},
+				{Kind: KindVPCPeering, Name: "servicenetworking-googleapis-com", Network: "production-vpc", Target: "https://www.googleapis.com/compute/v1/projects/x/global/networks/servicenetworking"},
+			},
+			KindForwardingRule:       {{Kind: KindForwardingRule, Name: "bridge-test-abc-https-rule"}},
+			KindTargetHTTPSProxy:     {{Kind: KindTargetHTTPSProxy, Name: "bridge-test-abc-https-proxy"}},
+			KindURLMap:               {{Kind: KindURLMap, Name: "bridge-test-abc-url-map"}},
+			KindSSLCertificate:       {{Kind: KindSSLCertificate, Name: "bridge-test-abc-cert"}},
+			KindBackendService:       {{Kind: KindBackendService, Name: "bridge-test-abc-backend"}},
+			KindSecurityPolicy:       {{Kind: KindSecurityPolicy, Name: "bridge-test-abc-policy"}},
+			KindNetworkEndpointGroup: {{Kind: KindNetworkEndpointGroup, Name: "bridge-test-abc-neg", Region: region}},
+			KindGlobalAddress: {
+				{Kind: KindGlobalAddress, Name: "bridge-test-abc-lb-ip"},
+				{Kind: KindGlobalAddress, Name: "bridge-test-abc-private-ip", Network: "bridge-test-abc-vpc"},
+			},
+			KindServerlessIPv4: {
+				{Kind: KindServerlessIPv4, Name: "serverless-ipv4-1712345678", Region: region, Subnetwork: "bridge-test-abc-subnet"},
+				{Kind: KindServerlessIPv4, Name: "serverless-ipv4-9999999999", Region: region, Subnetwork: "production-subnet"},
+			},
+			KindRoute: {
+				{Kind: KindRoute, Name: "default-route-1234", Network: "bridge-test-abc-vpc", Target: "https://www.googleapis.com/compute/v1/projects/x/global/gateways/default-internet-gateway"},
+				{Kind: KindRoute, Name: "default-route-local", Network: "bridge-test-abc-vpc"},
+			},
+			KindSubnetwork: {{Kind: KindSubnetwork, Name: "bridge-test-abc-subnet", Region: region, Network: "bridge-test-abc-vpc"}},
+			KindNetwork: {
+				{Kind: KindNetwork, Name: "bridge-test-abc-vpc"},
+				{Kind: KindNetwork, Name: "production-vpc"},
+			},
+		},
+		failDelete: map[string]error{},
+	}
+}
+
+// fakeClock advances when Sleep is called.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time        { return c.now }
+func (c *fakeClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
+func newFakeClock() *fakeClock             { return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)} }
+func names(entries []Entry, a Action) []string {
+	var out []string
+	for _, e := range entries {
+		if e.Action == a {
+			out = append(out, e.Resource.Name)
+		}
+	}
+	return out
+}
+
+func testOptions(clock *fakeClock) Options {
+	return Options{
+		Project: "my-project",
+		Prefix:  "bridge-test-abc",
+		Regions: []string{"asia-northeast1", "us-central1"},
+		Sleep:   clock.Sleep,
+		Now:     clock.Now,
+	}
+}
+
+func TestRunDeletesInDependencyOrder(t *testing.T) {
+	client := newFakeDeployment()
+	client.releaseAfter = 2
+	clock := newFakeClock()
+
+	report := Run(context.Background(), client, testOptions(clock))
+
+	require.True(t, report.OK(), "report: %+v", report)
+	var deleted []string
+	for _, r := range client.deleted {
+		deleted = append(deleted, r.Name)
+	}
+	assert.Equal(t, []string{
+		"bridge-test-abc",
+		"bridge-test-abc-db-1a2b3c4d",
+		"servicenetworking-googleapis-com",
+		"bridge-test-abc-https-rule",
+		"bridge-test-abc-https-proxy",
+		"bridge-test-abc-url-map",
+		"bridge-test-abc-cert",
+		"bridge-test-abc-backend",
+		"bridge-test-abc-policy",
+		"bridge-test-abc-neg",
+		"bridge-test-abc-lb-ip",
+		"bridge-test-abc-private-ip",
+		"default-route-1234",
+		"bridge-test-abc-subnet",
+		"bridge-test-abc-vpc",
+	}, deleted)
+	assert.Equal(t, []string{"serverless-ipv4-1712345678"}, names(report.Entries, ActionReleased))
+
+	// Unrelated resources are kept.
+	assert.Len(t, client.resources[KindCloudRunService], 1)
+	assert.Equal(t, "production-vpc", client.resources[KindNetwork][0].Name)
+	assert.Equal(t, "production-vpc", client.resources[KindVPCPeering][0].Network)
+}
+
+func TestRunDryRunDeletesNothing(t *testing.T) {
+	client := newFakeDeployment()
+	report := Run(context.Background(), client, func() Options {
+		o := testOptions(newFakeClock())
+		o.DryRun = true
+		return o
+	}())
+
+	assert.Empty(t, client.deleted)
+	assert.True(t, report.OK())
+	assert.Len(t, names(report.Entries, ActionWouldDelete), 16)
+	assert.Equal(t, 2, client.ipv4Lists, "dry run should list serverless-ipv4 addresses once per region and not poll")
+}
+
+func TestRunQuickOnlyTouchesNetwork(t *testing.T) {
+	client := newFakeDeployment()
+	client.releaseAfter = 1
+	opts := testOptions(newFakeClock())
+	opts.Order = QuickOrder
+
+	report := Run(context.Background(), client, opts)
+
+	require.True(t, report.OK())
+	assert.Equal(t, []string{
+		"servicenetworking-googleapis-com",
+		"bridge-test-abc-lb-ip",
+		"bridge-test-abc-private-ip",
+		"bridge-test-abc-subnet",
+		"bridge-test-abc-vpc",
+	}, names(report.Entries, ActionDeleted))
+	assert.Len(t, client.resources[KindCloudRunService], 2)
+}
+
+func TestRunServerlessIPv4Timeout(t *testing.T) {
+	client := newFakeDeployment()
+	client.failDelete["bridge-test-abc-subnet"] = errors.New("The subnetwork resource is already being used by serverless-ipv4-1712345678")
+	clock := newFakeClock()
+	opts := testOptions(clock)
+	opts.ServerlessIPv4Timeout = 2 * time.Minute
+	opts.ServerlessIPv4Interval = 30 * time.Second
+	start := clock.Now()
+
+	report := Run(context.Background(), client, opts)
+
+	assert.False(t, report.OK())
+	assert.Equal(t, []string{"serverless-ipv4-1712345678"}, names(report.Entries, ActionNotReleased))
+	assert.Equal(t, []string{"bridge-test-abc-subnet"}, names(report.Entries, ActionFailed))
+	assert.Equal(t, 2*time.Minute, clock.Now().Sub(start))
+}
+
+func TestRunServerlessIPv4ListError(t *testing.T) {
+	client := newFakeDeployment()
+	client.failIPv4ListAfter = 1
+	clock := newFakeClock()
+	opts := testOptions(clock)
+	opts.ServerlessIPv4Timeout = 2 * time.Minute
+	opts.ServerlessIPv4Interval = 30 * time.Second
+
+	report := Run(context.Background(), client, opts)
+
+	assert.False(t, report.OK())
+	assert.Empty(t, names(report.Entries, ActionReleased))
+	require.Equal(t, []string{"serverless-ipv4-1712345678"}, names(report.Entries, ActionNotReleased))
+	for _, e := range report.Entries {
+		if e.Action == ActionNotReleased {
+			assert.Contains(t, e.Error, "compute.addresses.list")
+		}
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	client := newFakeDeployment()
+	client.releaseAfter = 1
+	report := Run(context.Background(), client, testOptions(newFakeClock()))
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report, decoded)
+
+	buf.Reset()
+	require.NoError(t, report.WriteText(&buf))
+	assert.Contains(t, buf.String(), "bridge-test-abc-vpc")
+	assert.Contains(t, buf.String(), string(ActionDeleted))
+}
+
+func TestMatches(t *testing.T) {
+	assert.True(t, Matches(Resource{Kind: KindNetwork, Name: "bridge-test-abc-vpc"}, "bridge-test-abc"))
+	assert.False(t, Matches(Resource{Kind: KindNetwork, Name: "other-vpc"}, "bridge-test-abc"))
+	assert.False(t, Matches(Resource{Kind: KindServerlessIPv4, Name: "bridge-test-abc-ip", Subnetwork: "bridge-test-abc-subnet"}, "bridge-test-abc"),
+		"only serverless-ipv4 addresses are waited for")
+	assert.False(t, Matches(Resource{Kind: KindVPCPeering, Name: "custom-peering", Network: "bridge-test-abc-vpc", Target: "projects/x/global/networks/other"}, "bridge-test-abc"),
+		"only Service Networking peerings are removed")
+}
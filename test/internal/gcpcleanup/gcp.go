@@ -0,0 +1,294 @@
+package gcpcleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	sqladmin "google.golang.org/api/sqladmin/v1"
+)
+
+// GCPClient implements Client with the GCP client libraries.
+type GCPClient struct {
+	project string
+	compute *compute.Service
+	sql     *sqladmin.Service
+	run     *run.ServicesClient
+
+	// sqlPollInterval is the interval between Cloud SQL operation checks.
+	sqlPollInterval time.Duration
+}
+
+// NewGCPClient creates a client for project using Application Default Credentials
+// unless other options are given.
+func NewGCPClient(ctx context.Context, project string, opts ...option.ClientOption) (*GCPClient, error) {
+	computeService, err := compute.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create compute client: %w", err)
+	}
+	sqlService, err := sqladmin.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create sqladmin client: %w", err)
+	}
+	runClient, err := run.NewServicesClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create cloud run client: %w", err)
+	}
+	return &GCPClient{
+		project:         project,
+		compute:         computeService,
+		sql:             sqlService,
+		run:             runClient,
+		sqlPollInterval: 10 * time.Second,
+	}, nil
+}
+
+// Close releases the underlying connections.
+func (c *GCPClient) Close() error {
+	return c.run.Close()
+}
+
+// List implements Client.
+func (c *GCPClient) List(ctx context.Context, kind Kind, region string) ([]Resource, error) {
+	var out []Resource
+	add := func(name string) {
+		out = append(out, Resource{Kind: kind, Name: name, Region: region})
+	}
+
+	var err error
+	switch kind {
+	case KindCloudRunService:
+		it := c.run.ListServices(ctx, &runpb.ListServicesRequest{
+			Parent: fmt.Sprintf("projects/%s/locations/%s", c.project, region),
+		})
+		for {
+			svc, iterErr := it.Next()
+			if errors.Is(iterErr, iterator.Done) {
+				break
+			}
+			if iterErr != nil {
+				return nil, iterErr
+			}
+			add(path.Base(svc.GetName()))
+		}
+	case KindSQLInstance:
+		var resp *sqladmin.InstancesListResponse
+		resp, err = c.sql.Instances.List(c.project).Context(ctx).Do()
+		if err == nil {
+			for _, inst := range resp.Items {
+				add(inst.Name)
+			}
+		}
+	case KindVPCPeering:
+		err = c.compute.Networks.List(c.project).Pages(ctx, func(l *compute.NetworkList) error {
+			for _, n := range l.Items {
+				for _, p := range n.Peerings {
+					out = append(out, Resource{Kind: kind, Name: p.Name, Network: n.Name, Target: p.Network})
+				}
+			}
+			return nil
+		})
+	case KindForwardingRule:
+		err = c.compute.GlobalForwardingRules.List(c.project).Pages(ctx, func(l *compute.ForwardingRuleList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindTargetHTTPSProxy:
+		err = c.compute.TargetHttpsProxies.List(c.project).Pages(ctx, func(l *compute.TargetHttpsProxyList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindTargetHTTPProxy:
+		err = c.compute.TargetHttpProxies.List(c.project).Pages(ctx, func(l *compute.TargetHttpProxyList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindURLMap:
+		err = c.compute.UrlMaps.List(c.project).Pages(ctx, func(l *compute.UrlMapList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindSSLCertificate:
+		err = c.compute.SslCertificates.List(c.project).Pages(ctx, func(l *compute.SslCertificateList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindBackendService:
+		err = c.compute.BackendServices.List(c.project).Pages(ctx, func(l *compute.BackendServiceList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindSecurityPolicy:
+		err = c.compute.SecurityPolicies.List(c.project).Pages(ctx, func(l *compute.SecurityPolicyList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindNetworkEndpointGroup:
+		err = c.compute.RegionNetworkEndpointGroups.List(c.project, region).Pages(ctx, func(l *compute.NetworkEndpointGroupList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	case KindGlobalAddress:
+		err = c.compute.GlobalAddresses.List(c.project).Pages(ctx, func(l *compute.AddressList) error {
+			for _, r := range l.Items {
+				out = append(out, Resource{Kind: kind, Name: r.Name, Network: path.Base(r.Network)})
+			}
+			return nil
+		})
+	case KindServerlessIPv4:
+		err = c.compute.Addresses.List(c.project, region).Pages(ctx, func(l *compute.AddressList) error {
+			for _, r := range l.Items {
+				out = append(out, Resource{Kind: kind, Name: r.Name, Region: region, Subnetwork: path.Base(r.Subnetwork)})
+			}
+			return nil
+		})
+	case KindRoute:
+		err = c.compute.Routes.List(c.project).Pages(ctx, func(l *compute.RouteList) error {
+			for _, r := range l.Items {
+				out = append(out, Resource{Kind: kind, Name: r.Name, Network: path.Base(r.Network), Target: r.NextHopGateway})
+			}
+			return nil
+		})
+	case KindSubnetwork:
+		err = c.compute.Subnetworks.List(c.project, region).Pages(ctx, func(l *compute.SubnetworkList) error {
+			for _, r := range l.Items {
+				out = append(out, Resource{Kind: kind, Name: r.Name, Region: region, Network: path.Base(r.Network)})
+			}
+			return nil
+		})
+	case KindNetwork:
+		err = c.compute.Networks.List(c.project).Pages(ctx, func(l *compute.NetworkList) error {
+			for _, r := range l.Items {
+				add(r.Name)
+			}
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete implements Client. It waits for the operation to finish.
+func (c *GCPClient) Delete(ctx context.Context, r Resource) error {
+	var op *compute.Operation
+	var err error
+
+	switch r.Kind {
+	case KindCloudRunService:
+		lro, delErr := c.run.DeleteService(ctx, &runpb.DeleteServiceRequest{
+			Name: fmt.Sprintf("projects/%s/locations/%s/services/%s", c.project, r.Region, r.Name),
+		})
+		if delErr != nil {
+			return delErr
+		}
+		_, err = lro.Wait(ctx)
+		return err
+	case KindSQLInstance:
+		return c.deleteSQLInstance(ctx, r.Name)
+	case KindVPCPeering:
+		op, err = c.compute.Networks.RemovePeering(c.project, r.Network, &compute.NetworksRemovePeeringRequest{Name: r.Name}).Context(ctx).Do()
+	case KindForwardingRule:
+		op, err = c.compute.GlobalForwardingRules.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindTargetHTTPSProxy:
+		op, err = c.compute.TargetHttpsProxies.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindTargetHTTPProxy:
+		op, err = c.compute.TargetHttpProxies.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindURLMap:
+		op, err = c.compute.UrlMaps.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindSSLCertificate:
+		op, err = c.compute.SslCertificates.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindBackendService:
+		op, err = c.compute.BackendServices.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindSecurityPolicy:
+		op, err = c.compute.SecurityPolicies.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindNetworkEndpointGroup:
+		op, err = c.compute.RegionNetworkEndpointGroups.Delete(c.project, r.Region, r.Name).Context(ctx).Do()
+	case KindGlobalAddress:
+		op, err = c.compute.GlobalAddresses.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindRoute:
+		op, err = c.compute.Routes.Delete(c.project, r.Name).Context(ctx).Do()
+	case KindSubnetwork:
+		op, err = c.compute.Subnetworks.Delete(c.project, r.Region, r.Name).Context(ctx).Do()
+	case KindNetwork:
+		op, err = c.compute.Networks.Delete(c.project, r.Name).Context(ctx).Do()
+	default:
+		return fmt.Errorf("cannot delete %s", r.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	return c.waitComputeOperation(ctx, op, r.Region)
+}
+
+// waitComputeOperation blocks until a compute operation is DONE and returns
+// its error, if any.
+func (c *GCPClient) waitComputeOperation(ctx context.Context, op *compute.Operation, region string) error {
+	var err error
+	for op.Status != "DONE" {
+		if region != "" {
+			op, err = c.compute.RegionOperations.Wait(c.project, region, op.Name).Context(ctx).Do()
+		} else {
+			op, err = c.compute.GlobalOperations.Wait(c.project, op.Name).Context(ctx).Do()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		e := op.Error.Errors[0]
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+// deleteSQLInstance deletes a Cloud SQL instance and polls the operation,
+// which usually takes 5-10 minutes.
+func (c *GCPClient) deleteSQLInstance(ctx context.Context, name string) error {
+	op, err := c.sql.Instances.Delete(c.project, name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	for op.Status != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.sqlPollInterval):
+		}
+		op, err = c.sql.Operations.Get(c.project, op.Name).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		e := op.Error.Errors[0]
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package localstack supports applying the AWS example to LocalStack
+// instead of AWS: it prepares a copy of the example whose aws provider
+// talks to the LocalStack endpoint, SDK configuration for the same
+// endpoint, and the network and hosted zone the example deploys into.
+package localstack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/upgrade"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// EndpointEnv is the environment variable that enables LocalStack mode,
+// e.g. "http://localhost:4566".
+const EndpointEnv = "TEST_LOCALSTACK_ENDPOINT"
+
+// OverrideFile is the name of the Terraform override file Prepare writes.
+const OverrideFile = "localstack_override.tf"
+
+// AccessKey and SecretKey are the credentials LocalStack accepts.
+const (
+	AccessKey = "test"
+	SecretKey = "test"
+)
+
+// Services are the aws provider endpoints the example and the module use.
+var Services = []string{
+	"acm",
+	"ec2",
+	"ecr",
+	"ecs",
+	"elbv2",
+	"iam",
+	"logs",
+	"rds",
+	"route53",
+	"s3",
+	"secretsmanager",
+	"sts",
+}
+
+// Endpoint returns the LocalStack endpoint, or "" when LocalStack mode is
+// off.
+func Endpoint() string {
+	return os.Getenv(EndpointEnv)
+}
+
+// Config returns an SDK configuration for LocalStack.
+func Config(endpoint, region string) *aws.Config {
+	return &aws.Config{
+		Endpoint:         aws.String(endpoint),
+		Region:           aws.String(region),
+		Credentials:      credentials.NewStaticCredentials(AccessKey, SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+}
+
+// EnvVars returns the environment for Terraform runs against LocalStack.
+func EnvVars(region string) map[string]string {
+	return map[string]string{
+		"AWS_ACCESS_KEY_ID":        AccessKey,
+		"AWS_SECRET_ACCESS_KEY":    SecretKey,
+		"AWS_DEFAULT_REGION":       region,
+		"AWS_DISABLE_EC2_METADATA": "true",
+	}
+}
+
+// Override renders a Terraform override file that merges into the
+// example's provider "aws" block and sends every service in Services to
+// endpoint.
+func Override(endpoint string) []byte {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	body.AppendUnstructuredTokens(hclwrite.Tokens{{
+		Type:  hclsyntax.TokenComment,
+		Bytes: []byte("# Generated for LocalStack mode; see test/internal/localstack.\n"),
+	}})
+	provider := body.AppendNewBlock("provider", []string{"aws"}).Body()
+	provider.SetAttributeValue("access_key", cty.StringVal(AccessKey))
+	provider.SetAttributeValue("secret_key", cty.StringVal(SecretKey))
+	provider.SetAttributeValue("skip_credentials_validation", cty.True)
+	provider.SetAttributeValue("skip_metadata_api_check", cty.True)
+	provider.SetAttributeValue("skip_requesting_account_id", cty.True)
+	provider.SetAttributeValue("s3_use_path_style", cty.True)
+	endpoints := provider.AppendNewBlock("endpoints", nil).Body()
+	for _, service := range Services {
+		endpoints.SetAttributeValue(service, cty.StringVal(endpoint))
+	}
+	return f.Bytes()
+}
+
+// Prepare copies example and modules (paths relative to repoDir) into dir,
+// keeping their layout so that local module sources resolve, and writes
+// OverrideFile into the copied example. It returns the copied example's
+// directory.
+func Prepare(repoDir, dir, endpoint, example string, modules ...string) (string, error) {
+	if err := upgrade.Copy(repoDir, dir, append([]string{example}, modules...)...); err != nil {
+		return "", err
+	}
+	exampleDir := filepath.Join(dir, example)
+	if err := os.WriteFile(filepath.Join(exampleDir, OverrideFile), Override(endpoint), 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", OverrideFile, err)
+	}
+	return exampleDir, nil
+}
@@ -0,0 +1,94 @@
+package localstack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverride(t *testing.T) {
+	src := Override("http://localhost:4566")
+	file, diags := hclparse.NewParser().ParseHCL(src, OverrideFile)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	blocks := file.Body.(*hclsyntax.Body).Blocks
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "provider", blocks[0].Type)
+	assert.Equal(t, []string{"aws"}, blocks[0].Labels)
+
+	provider := blocks[0].Body
+	for _, name := range []string{"access_key", "secret_key", "skip_credentials_validation", "skip_requesting_account_id", "s3_use_path_style"} {
+		assert.Contains(t, provider.Attributes, name)
+	}
+	require.Len(t, provider.Blocks, 1)
+	endpoints := provider.Blocks[0].Body.Attributes
+	assert.Len(t, endpoints, len(Services))
+	v, diags := endpoints["ecs"].Expr.Value(nil)
+	require.False(t, diags.HasErrors())
+	assert.Equal(t, "http://localhost:4566", v.AsString())
+}
+
+func TestPrepare(t *testing.T) {
+	repo := t.TempDir()
+	for path, content := range map[string]string{
+		"examples/aws/main.tf":                `module "m" { source = "../../modules/aws" }`,
+		"examples/aws/scripts/diagnose.sh":    "#!/bin/sh",
+		"examples/aws/.terraform/providers/x": "cache",
+		"examples/aws/terraform.tfstate":      "{}",
+		"modules/aws/main.tf":                 `resource "aws_ecs_cluster" "main" {}`,
+		"modules/gcp/main.tf":                 `resource "google_cloud_run_v2_service" "main" {}`,
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, filepath.Dir(path)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, path), []byte(content), 0o644))
+	}
+
+	dest := t.TempDir()
+	dir, err := Prepare(repo, dest, "http://localhost:4566", "examples/aws", "modules/aws")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "examples/aws"), dir)
+
+	for _, path := range []string{"examples/aws/main.tf", "examples/aws/" + OverrideFile, "examples/aws/scripts/diagnose.sh", "modules/aws/main.tf"} {
+		assert.FileExists(t, filepath.Join(dest, path))
+	}
+	for _, path := range []string{"examples/aws/.terraform", "examples/aws/terraform.tfstate", "modules/gcp"} {
+		assert.NoFileExists(t, filepath.Join(dest, path))
+		assert.NoDirExists(t, filepath.Join(dest, path))
+	}
+}
+
+// TestCreateNetwork runs only against a LocalStack endpoint.
+func TestCreateNetwork(t *testing.T) {
+	endpoint := Endpoint()
+	if endpoint == "" {
+		t.Skipf("%s is not set", EndpointEnv)
+	}
+	sess := session.Must(session.NewSession(Config(endpoint, "ap-northeast-1")))
+
+	n, err := CreateNetwork(sess, "network-test")
+	require.NoError(t, err)
+	assert.Len(t, n.PublicSubnetIDs, 2)
+	assert.Len(t, n.PrivateSubnetIDs, 2)
+	assert.Equal(t, "bridge.network-test.localstack.test", n.DomainName)
+
+	out, err := ec2.New(sess).DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: []*ec2.Filter{{
+		Name:   aws.String("association.subnet-id"),
+		Values: aws.StringSlice(n.PublicSubnetIDs[:1]),
+	}}})
+	require.NoError(t, err)
+	require.Len(t, out.RouteTables, 1)
+	var igw string
+	for _, r := range out.RouteTables[0].Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" {
+			igw = aws.StringValue(r.GatewayId)
+		}
+	}
+	assert.Regexp(t, "^igw-", igw)
+}
@@ -0,0 +1,114 @@
+package localstack
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Network is what the example needs to exist before apply: a VPC with
+// public and private subnets in two availability zones, and a hosted zone
+// for the Bridge domain.
+type Network struct {
+	VPCID            string
+	PublicSubnetIDs  []string
+	PrivateSubnetIDs []string
+	ZoneID           string
+	DomainName       string
+}
+
+// CreateNetwork creates a Network named name in LocalStack. The public
+// subnets route to an internet gateway; the private subnets get their NAT
+// route from the module. Everything lives until LocalStack is reset.
+func CreateNetwork(sess client.ConfigProvider, name string) (*Network, error) {
+	ec2Client := ec2.New(sess)
+	region := aws.StringValue(ec2Client.Config.Region)
+	n := &Network{}
+
+	vpc, err := ec2Client.CreateVpc(&ec2.CreateVpcInput{
+		CidrBlock:         aws.String("10.0.0.0/16"),
+		TagSpecifications: tagSpec(ec2.ResourceTypeVpc, name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create VPC: %w", err)
+	}
+	n.VPCID = aws.StringValue(vpc.Vpc.VpcId)
+
+	igw, err := ec2Client.CreateInternetGateway(&ec2.CreateInternetGatewayInput{
+		TagSpecifications: tagSpec(ec2.ResourceTypeInternetGateway, name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create internet gateway: %w", err)
+	}
+	igwID := igw.InternetGateway.InternetGatewayId
+	if _, err := ec2Client.AttachInternetGateway(&ec2.AttachInternetGatewayInput{InternetGatewayId: igwID, VpcId: vpc.Vpc.VpcId}); err != nil {
+		return nil, fmt.Errorf("attach internet gateway: %w", err)
+	}
+
+	publicRT, err := ec2Client.CreateRouteTable(&ec2.CreateRouteTableInput{
+		VpcId:             vpc.Vpc.VpcId,
+		TagSpecifications: tagSpec(ec2.ResourceTypeRouteTable, name+"-public"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create route table: %w", err)
+	}
+	if _, err := ec2Client.CreateRoute(&ec2.CreateRouteInput{
+		RouteTableId:         publicRT.RouteTable.RouteTableId,
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            igwID,
+	}); err != nil {
+		return nil, fmt.Errorf("create internet route: %w", err)
+	}
+
+	for i, az := range []string{region + "a", region + "c"} {
+		public, err := createSubnet(ec2Client, vpc.Vpc.VpcId, az, fmt.Sprintf("10.0.%d.0/24", i), fmt.Sprintf("%s-public-%d", name, i))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ec2Client.AssociateRouteTable(&ec2.AssociateRouteTableInput{RouteTableId: publicRT.RouteTable.RouteTableId, SubnetId: public}); err != nil {
+			return nil, fmt.Errorf("associate route table: %w", err)
+		}
+		n.PublicSubnetIDs = append(n.PublicSubnetIDs, aws.StringValue(public))
+
+		private, err := createSubnet(ec2Client, vpc.Vpc.VpcId, az, fmt.Sprintf("10.0.%d.0/24", 10+i), fmt.Sprintf("%s-private-%d", name, i))
+		if err != nil {
+			return nil, err
+		}
+		n.PrivateSubnetIDs = append(n.PrivateSubnetIDs, aws.StringValue(private))
+	}
+
+	zoneName := name + ".localstack.test"
+	zone, err := route53.New(sess).CreateHostedZone(&route53.CreateHostedZoneInput{
+		Name:            aws.String(zoneName),
+		CallerReference: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create hosted zone: %w", err)
+	}
+	n.ZoneID = aws.StringValue(zone.HostedZone.Id)
+	n.DomainName = "bridge." + zoneName
+	return n, nil
+}
+
+func createSubnet(client *ec2.EC2, vpcID *string, az, cidr, name string) (*string, error) {
+	out, err := client.CreateSubnet(&ec2.CreateSubnetInput{
+		VpcId:             vpcID,
+		AvailabilityZone:  aws.String(az),
+		CidrBlock:         aws.String(cidr),
+		TagSpecifications: tagSpec(ec2.ResourceTypeSubnet, name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create subnet %s: %w", cidr, err)
+	}
+	return out.Subnet.SubnetId, nil
+}
+
+func tagSpec(resourceType, name string) []*ec2.TagSpecification {
+	return []*ec2.TagSpecification{{
+		ResourceType: aws.String(resourceType),
+		Tags:         []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+	}}
+}
@@ -0,0 +1,287 @@
+// Package managedcert follows a Google-managed SSL certificate from
+// PROVISIONING to ACTIVE and explains why a domain is not provisioned yet.
+//
+// Provisioning takes anywhere from a few minutes to an hour, so a fixed
+// timeout is either too short or wastes time on certificates that cannot
+// become ACTIVE. Poller keeps waiting while progress is possible and gives
+// up as soon as it is not: provisioning failed permanently, a CAA record
+// forbids Google's CAs, the CA rate limits the domain, or the domain has
+// not pointed at the load balancer for longer than a grace period.
+package managedcert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/dnscheck"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Certificate statuses (managed.status).
+const (
+	StatusProvisioning                  = "PROVISIONING"
+	StatusActive                        = "ACTIVE"
+	StatusProvisioningFailed            = "PROVISIONING_FAILED"
+	StatusProvisioningFailedPermanently = "PROVISIONING_FAILED_PERMANENTLY"
+	StatusRenewalFailed                 = "RENEWAL_FAILED"
+)
+
+// Domain statuses (managed.domainStatus).
+const (
+	DomainProvisioning       = "PROVISIONING"
+	DomainActive             = "ACTIVE"
+	DomainFailedNotVisible   = "FAILED_NOT_VISIBLE"
+	DomainFailedCAAChecking  = "FAILED_CAA_CHECKING"
+	DomainFailedCAAForbidden = "FAILED_CAA_FORBIDDEN"
+	DomainFailedRateLimited  = "FAILED_RATE_LIMITED"
+)
+
+// GoogleCAADomains are the CAA issuer domains of the CAs that issue
+// Google-managed certificates
+// (https://cloud.google.com/load-balancing/docs/ssl-certificates/google-managed-certs#caa).
+var GoogleCAADomains = []string{"pki.goog", "letsencrypt.org"}
+
+// Client reads a global SSL certificate.
+type Client interface {
+	SslCertificate(ctx context.Context, project, name string) (*compute.SslCertificate, error)
+}
+
+// ComputeClient implements Client with the Compute Engine API.
+type ComputeClient struct {
+	Service *compute.Service
+}
+
+func (c ComputeClient) SslCertificate(ctx context.Context, project, name string) (*compute.SslCertificate, error) {
+	return c.Service.SslCertificates.Get(project, name).Context(ctx).Do()
+}
+
+// Poller follows the managed certificate Name in Project, whose domains
+// must resolve to LoadBalancerIP.
+type Poller struct {
+	Client         Client
+	Project        string
+	Name           string
+	LoadBalancerIP string
+
+	// NotVisibleGrace is how long a domain may resolve elsewhere than
+	// LoadBalancerIP before Wait gives up (0 means 5 minutes). DNS changes
+	// made by the same apply usually need less than that to reach every
+	// nameserver.
+	NotVisibleGrace time.Duration
+
+	// CheckDNS returns nil when name resolves to want only (nil means a
+	// dnscheck.Checker, which asks the zone's authoritative nameservers).
+	CheckDNS func(ctx context.Context, name string, want []string) error
+	// LookupCAA returns the CAA records that apply to a name and where
+	// they were found (nil means a dnscheck.Checker).
+	LookupCAA func(ctx context.Context, name string) (string, []dnscheck.CAA, error)
+
+	// misdirectedSince is when each domain was first seen not resolving
+	// to LoadBalancerIP, for NotVisibleGrace
+	misdirectedSince map[string]time.Time
+}
+
+// Status is the certificate's state at one poll.
+type Status struct {
+	// Status is the certificate status, e.g. PROVISIONING or ACTIVE.
+	Status string
+	// Domains maps each domain to its status.
+	Domains map[string]string
+	// Reason explains, per domain, why the certificate is not ACTIVE;
+	// empty once it is.
+	Reason string
+	// Hopeless is set when the certificate cannot become ACTIVE without
+	// a change, so waiting longer does not help.
+	Hopeless bool
+}
+
+// Active reports whether the certificate is ACTIVE.
+func (s *Status) Active() bool {
+	return s.Status == StatusActive
+}
+
+func (s *Status) String() string {
+	var domains []string
+	for d, st := range s.Domains {
+		domains = append(domains, d+"="+st)
+	}
+	sort.Strings(domains)
+	out := fmt.Sprintf("%s (%s)", s.Status, strings.Join(domains, ", "))
+	if s.Reason != "" {
+		out += ": " + s.Reason
+	}
+	return out
+}
+
+// Wait polls the certificate until it is ACTIVE. It stops early, with the
+// Status's Reason in the error, once the certificate cannot become ACTIVE.
+// The last Status is returned with the error, if there was one.
+func (p *Poller) Wait(ctx context.Context, opts poll.Options) (*Status, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = poll.RealClock{}
+	}
+	var last *Status
+	err := poll.Until(ctx, opts, func(ctx context.Context) error {
+		s, err := p.Diagnose(ctx, clock.Now())
+		if err != nil {
+			return err
+		}
+		last = s
+		switch {
+		case s.Active():
+			return nil
+		case s.Hopeless:
+			return poll.Permanent(errors.New(s.String()))
+		default:
+			return errors.New(s.String())
+		}
+	})
+	return last, err
+}
+
+// Diagnose reads the certificate and, unless it is ACTIVE, explains each
+// domain that is not. now is used for NotVisibleGrace.
+func (p *Poller) Diagnose(ctx context.Context, now time.Time) (*Status, error) {
+	cert, err := p.Client.SslCertificate(ctx, p.Project, p.Name)
+	if err != nil {
+		return nil, fmt.Errorf("get SSL certificate %s: %w", p.Name, err)
+	}
+	if cert.Managed == nil {
+		return nil, fmt.Errorf("SSL certificate %s is not Google-managed", p.Name)
+	}
+	s := &Status{Status: cert.Managed.Status, Domains: cert.Managed.DomainStatus}
+	if s.Active() {
+		return s, nil
+	}
+
+	var reasons []string
+	if s.Status == StatusProvisioningFailedPermanently {
+		s.Hopeless = true
+		reasons = append(reasons, "provisioning failed permanently; the certificate must be recreated")
+	}
+	domains := cert.Managed.Domains
+	sort.Strings(domains)
+	for _, domain := range domains {
+		reason, hopeless := p.explain(ctx, domain, s.Domains[domain], now)
+		if reason != "" {
+			reasons = append(reasons, domain+": "+reason)
+		}
+		s.Hopeless = s.Hopeless || hopeless
+	}
+	s.Reason = strings.Join(reasons, "; ")
+	return s, nil
+}
+
+// explain returns why domain, whose status is status, is not ACTIVE, and
+// whether it can still become ACTIVE.
+func (p *Poller) explain(ctx context.Context, domain, status string, now time.Time) (string, bool) {
+	if status == "" {
+		// Domains have no status until provisioning starts
+		status = DomainProvisioning
+	}
+	switch status {
+	case DomainActive:
+		return "", false
+	case DomainFailedCAAForbidden:
+		owner, records, err := p.lookupCAA(ctx, domain)
+		if err != nil {
+			return fmt.Sprintf("a CAA record forbids Google's CAs (could not look it up: %v)", err), true
+		}
+		if AllowsGoogle(records) {
+			return "a CAA record forbade Google's CAs, but they are allowed now; waiting for Google to retry", false
+		}
+		return fmt.Sprintf("CAA records at %s (%s) do not allow %s; add an issue record for one of them",
+			owner, joinCAA(records), strings.Join(GoogleCAADomains, " or ")), true
+	case DomainFailedRateLimited:
+		return "the CA rate limited issuance for this domain; retry later", true
+	case DomainFailedCAAChecking:
+		return "Google could not check the CAA records and will retry", false
+	}
+
+	// PROVISIONING and FAILED_NOT_VISIBLE both depend on the domain
+	// pointing at the load balancer
+	err := p.checkDNS(ctx, domain, []string{p.LoadBalancerIP})
+	if err == nil {
+		delete(p.misdirectedSince, domain)
+		if status == DomainFailedNotVisible {
+			return fmt.Sprintf("not visible to Google yet although it resolves to the load balancer IP %s; Google will retry", p.LoadBalancerIP), false
+		}
+		return fmt.Sprintf("%s; it resolves to the load balancer IP %s", strings.ToLower(status), p.LoadBalancerIP), false
+	}
+
+	if p.misdirectedSince == nil {
+		p.misdirectedSince = map[string]time.Time{}
+	}
+	since, ok := p.misdirectedSince[domain]
+	if !ok {
+		since = now
+		p.misdirectedSince[domain] = now
+	}
+	grace := p.NotVisibleGrace
+	if grace == 0 {
+		grace = 5 * time.Minute
+	}
+	reason := fmt.Sprintf("%s; DNS does not point at the load balancer IP %s: %v", strings.ToLower(status), p.LoadBalancerIP, err)
+	if waited := now.Sub(since); waited >= grace {
+		return fmt.Sprintf("%s (for %v)", reason, waited.Round(time.Second)), true
+	}
+	return reason, false
+}
+
+func (p *Poller) checkDNS(ctx context.Context, name string, want []string) error {
+	if p.CheckDNS != nil {
+		return p.CheckDNS(ctx, name, want)
+	}
+	var c dnscheck.Checker
+	_, err := c.Check(ctx, name, want)
+	return err
+}
+
+func (p *Poller) lookupCAA(ctx context.Context, name string) (string, []dnscheck.CAA, error) {
+	if p.LookupCAA != nil {
+		return p.LookupCAA(ctx, name)
+	}
+	var c dnscheck.Checker
+	return c.CAA(ctx, name)
+}
+
+// AllowsGoogle reports whether the CAA records that apply to a domain let
+// one of GoogleCAADomains issue for it (RFC 8659 section 4).
+func AllowsGoogle(records []dnscheck.CAA) bool {
+	hasIssue, google := false, false
+	for _, r := range records {
+		switch r.Tag {
+		case "issue":
+			hasIssue = true
+			// The issuer domain is followed by optional "; key=value"
+			// parameters; an empty one forbids every CA
+			issuer := strings.TrimSpace(strings.SplitN(r.Value, ";", 2)[0])
+			for _, d := range GoogleCAADomains {
+				if strings.EqualFold(issuer, d) {
+					google = true
+				}
+			}
+		case "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone":
+		default:
+			if r.Critical {
+				return false
+			}
+		}
+	}
+	return !hasIssue || google
+}
+
+func joinCAA(records []dnscheck.CAA) string {
+	s := make([]string, len(records))
+	for i, r := range records {
+		s[i] = r.String()
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,270 @@
+package managedcert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/dnscheck"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const (
+	project = "test-project"
+	name    = "bridge-test-abc123-cert"
+	domain  = "bridge.example.com"
+	lbIP    = "203.0.113.10"
+)
+
+var start = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeClient returns one managed status per call, holding the last.
+type fakeClient struct {
+	statuses []*compute.SslCertificateManagedSslCertificate
+	calls    int
+}
+
+func (f *fakeClient) SslCertificate(_ context.Context, p, n string) (*compute.SslCertificate, error) {
+	if p != project || n != name {
+		return nil, errors.New("not found")
+	}
+	managed := f.statuses[len(f.statuses)-1]
+	if f.calls < len(f.statuses) {
+		managed = f.statuses[f.calls]
+	}
+	f.calls++
+	return &compute.SslCertificate{Name: n, Type: "MANAGED", Managed: managed}, nil
+}
+
+func managed(status, domainStatus string) *compute.SslCertificateManagedSslCertificate {
+	return &compute.SslCertificateManagedSslCertificate{
+		Status:       status,
+		Domains:      []string{domain},
+		DomainStatus: map[string]string{domain: domainStatus},
+	}
+}
+
+func resolvesTo(addr string) func(context.Context, string, []string) error {
+	return func(_ context.Context, _ string, want []string) error {
+		if addr != want[0] {
+			return errors.New("ns-1.example.net: " + addr)
+		}
+		return nil
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	noCAA := func(context.Context, string) (string, []dnscheck.CAA, error) { return "", nil, nil }
+	tests := []struct {
+		name      string
+		managed   *compute.SslCertificateManagedSslCertificate
+		dnsAddr   string
+		lookupCAA func(context.Context, string) (string, []dnscheck.CAA, error)
+		elapsed   time.Duration
+		hopeless  bool
+		reason    string
+	}{
+		{
+			name:    "active",
+			managed: managed(StatusActive, DomainActive),
+			dnsAddr: lbIP,
+		},
+		{
+			name:    "provisioning",
+			managed: managed(StatusProvisioning, DomainProvisioning),
+			dnsAddr: lbIP,
+			reason:  "bridge.example.com: provisioning; it resolves to the load balancer IP 203.0.113.10",
+		},
+		{
+			name:    "no domain status yet",
+			managed: &compute.SslCertificateManagedSslCertificate{Status: StatusProvisioning, Domains: []string{domain}},
+			dnsAddr: lbIP,
+			reason:  "bridge.example.com: provisioning; it resolves to the load balancer IP 203.0.113.10",
+		},
+		{
+			name:    "not visible but DNS is right",
+			managed: managed(StatusProvisioning, DomainFailedNotVisible),
+			dnsAddr: lbIP,
+			reason:  "not visible to Google yet although it resolves to the load balancer IP 203.0.113.10; Google will retry",
+		},
+		{
+			name:    "not visible within grace",
+			managed: managed(StatusProvisioning, DomainFailedNotVisible),
+			dnsAddr: "198.51.100.1",
+			elapsed: 4 * time.Minute,
+			reason:  "failed_not_visible; DNS does not point at the load balancer IP 203.0.113.10: ns-1.example.net: 198.51.100.1",
+		},
+		{
+			name:     "not visible past grace",
+			managed:  managed(StatusProvisioning, DomainFailedNotVisible),
+			dnsAddr:  "198.51.100.1",
+			elapsed:  5 * time.Minute,
+			hopeless: true,
+			reason:   "DNS does not point at the load balancer IP 203.0.113.10: ns-1.example.net: 198.51.100.1 (for 5m0s)",
+		},
+		{
+			name:    "CAA forbidden",
+			managed: managed(StatusProvisioning, DomainFailedCAAForbidden),
+			lookupCAA: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "example.com.", []dnscheck.CAA{{Tag: "issue", Value: "amazon.com"}}, nil
+			},
+			hopeless: true,
+			reason:   `CAA records at example.com. (0 issue "amazon.com") do not allow pki.goog or letsencrypt.org`,
+		},
+		{
+			name:    "CAA fixed since",
+			managed: managed(StatusProvisioning, DomainFailedCAAForbidden),
+			lookupCAA: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "example.com.", []dnscheck.CAA{{Tag: "issue", Value: "pki.goog"}}, nil
+			},
+			reason: "they are allowed now; waiting for Google to retry",
+		},
+		{
+			name:    "CAA lookup fails",
+			managed: managed(StatusProvisioning, DomainFailedCAAForbidden),
+			lookupCAA: func(context.Context, string) (string, []dnscheck.CAA, error) {
+				return "", nil, errors.New("timeout")
+			},
+			hopeless: true,
+			reason:   "could not look it up: timeout",
+		},
+		{
+			name:    "CAA checking",
+			managed: managed(StatusProvisioning, DomainFailedCAAChecking),
+			reason:  "Google could not check the CAA records and will retry",
+		},
+		{
+			name:     "rate limited",
+			managed:  managed(StatusProvisioning, DomainFailedRateLimited),
+			hopeless: true,
+			reason:   "rate limited",
+		},
+		{
+			name:     "failed permanently",
+			managed:  managed(StatusProvisioningFailedPermanently, DomainFailedNotVisible),
+			dnsAddr:  lbIP,
+			hopeless: true,
+			reason:   "provisioning failed permanently; the certificate must be recreated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupCAA := tt.lookupCAA
+			if lookupCAA == nil {
+				lookupCAA = noCAA
+			}
+			p := &Poller{
+				Client:         &fakeClient{statuses: []*compute.SslCertificateManagedSslCertificate{tt.managed}},
+				Project:        project,
+				Name:           name,
+				LoadBalancerIP: lbIP,
+				CheckDNS:       resolvesTo(tt.dnsAddr),
+				LookupCAA:      lookupCAA,
+			}
+			_, err := p.Diagnose(context.Background(), start)
+			require.NoError(t, err)
+			s, err := p.Diagnose(context.Background(), start.Add(tt.elapsed))
+			require.NoError(t, err)
+			assert.Equal(t, tt.managed.Status == StatusActive, s.Active())
+			assert.Equal(t, tt.hopeless, s.Hopeless)
+			if tt.reason == "" {
+				assert.Empty(t, s.Reason)
+			} else {
+				assert.Contains(t, s.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDiagnoseNotManaged(t *testing.T) {
+	p := &Poller{Client: &fakeClient{statuses: []*compute.SslCertificateManagedSslCertificate{nil}}, Project: project, Name: name}
+	_, err := p.Diagnose(context.Background(), start)
+	assert.ErrorContains(t, err, "not Google-managed")
+}
+
+func TestWait(t *testing.T) {
+	newPoller := func(dnsAddr string, statuses ...*compute.SslCertificateManagedSslCertificate) *Poller {
+		return &Poller{
+			Client:         &fakeClient{statuses: statuses},
+			Project:        project,
+			Name:           name,
+			LoadBalancerIP: lbIP,
+			CheckDNS:       resolvesTo(dnsAddr),
+		}
+	}
+	opts := func() poll.Options {
+		return poll.Options{Timeout: 30 * time.Minute, Backoff: poll.Backoff{Initial: time.Minute}, Clock: poll.NewFakeClock(start)}
+	}
+
+	t.Run("active", func(t *testing.T) {
+		p := newPoller(lbIP,
+			managed(StatusProvisioning, DomainProvisioning),
+			managed(StatusProvisioning, DomainFailedNotVisible),
+			managed(StatusActive, DomainActive))
+		s, err := p.Wait(context.Background(), opts())
+		require.NoError(t, err)
+		assert.True(t, s.Active())
+		assert.Equal(t, 3, p.Client.(*fakeClient).calls)
+	})
+
+	t.Run("DNS never points at the load balancer", func(t *testing.T) {
+		p := newPoller("198.51.100.1", managed(StatusProvisioning, DomainFailedNotVisible))
+		s, err := p.Wait(context.Background(), opts())
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, poll.ErrTimeout)
+		assert.True(t, s.Hopeless)
+		// gives up after the 5 minute grace, not the 30 minute timeout
+		assert.Equal(t, 6, p.Client.(*fakeClient).calls)
+		assert.Contains(t, err.Error(), "DNS does not point at the load balancer IP 203.0.113.10")
+	})
+
+	t.Run("DNS fixed within grace", func(t *testing.T) {
+		p := newPoller("198.51.100.1",
+			managed(StatusProvisioning, DomainFailedNotVisible),
+			managed(StatusProvisioning, DomainFailedNotVisible),
+			managed(StatusProvisioning, DomainFailedNotVisible),
+			managed(StatusActive, DomainActive))
+		calls := 0
+		p.CheckDNS = func(ctx context.Context, name string, want []string) error {
+			calls++
+			if calls < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		}
+		s, err := p.Wait(context.Background(), opts())
+		require.NoError(t, err)
+		assert.True(t, s.Active())
+	})
+
+	t.Run("still provisioning", func(t *testing.T) {
+		p := newPoller(lbIP, managed(StatusProvisioning, DomainProvisioning))
+		s, err := p.Wait(context.Background(), opts())
+		require.ErrorIs(t, err, poll.ErrTimeout)
+		assert.False(t, s.Hopeless)
+	})
+}
+
+func TestAllowsGoogle(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []dnscheck.CAA
+		want    bool
+	}{
+		{"no records", nil, true},
+		{"pki.goog", []dnscheck.CAA{{Tag: "issue", Value: "pki.goog; cansignhttpexchanges=yes"}}, true},
+		{"letsencrypt", []dnscheck.CAA{{Tag: "issue", Value: "amazon.com"}, {Tag: "issue", Value: "letsencrypt.org"}}, true},
+		{"other CA only", []dnscheck.CAA{{Tag: "issue", Value: "amazon.com"}}, false},
+		{"issuewild only", []dnscheck.CAA{{Tag: "issuewild", Value: "amazon.com"}}, true},
+		{"unknown critical tag", []dnscheck.CAA{{Critical: true, Tag: "future", Value: "x"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AllowsGoogle(tt.records))
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package modcompat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Bump is a semantic versioning increment.
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	}
+	return "none"
+}
+
+// MarshalText makes Bump render as its name in JSON.
+func (b Bump) MarshalText() ([]byte, error) { return []byte(b.String()), nil }
+
+// Kinds of interface changes.
+const (
+	VariableRemoved     = "variable-removed"
+	VariableRenamed     = "variable-renamed"
+	VariableAdded       = "variable-added"
+	VariableRequired    = "variable-required"
+	VariableOptional    = "variable-optional"
+	VariableType        = "variable-type"
+	VariableDefault     = "variable-default"
+	VariableSensitive   = "variable-sensitive"
+	VariableNullable    = "variable-nullable"
+	VariableValidation  = "variable-validation"
+	VariableDescription = "variable-description"
+	OutputRemoved       = "output-removed"
+	OutputAdded         = "output-added"
+	OutputSensitive     = "output-sensitive"
+	OutputDescription   = "output-description"
+)
+
+// Change is one difference between two versions of a module interface.
+type Change struct {
+	Kind string `json:"kind"`
+	// Subject is "variable.<name>" or "output.<name>" of the old interface
+	// (of the new one for additions).
+	Subject string `json:"subject"`
+	Detail  string `json:"detail"`
+	Bump    Bump   `json:"bump"`
+	// Pos is the file:line of the declaration in the new interface, or in
+	// the old one for removals.
+	Pos string `json:"pos"`
+}
+
+// Breaking reports whether the change requires a major version.
+func (c Change) Breaking() bool { return c.Bump == BumpMajor }
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", c.Pos, c.Subject, c.Detail, c.Bump)
+}
+
+// Compare returns the changes from old to new, sorted by subject and kind.
+//
+// Breaking (major): removed or renamed variables, new required variables,
+// variables losing their default, type and default changes, variables
+// becoming non-nullable, added or changed validation rules, removed outputs
+// and outputs becoming sensitive. Minor: new optional variables and
+// outputs, variables gaining a default, removed validation rules and other
+// sensitivity and nullability changes. Patch: description changes.
+func Compare(old, new *Interface) []Change {
+	var changes []Change
+	add := func(kind, subject, pos string, bump Bump, format string, args ...any) {
+		changes = append(changes, Change{Kind: kind, Subject: subject, Pos: pos, Bump: bump, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	renamed := renames(old, new)
+	renamedTo := map[string]bool{}
+	for _, to := range renamed {
+		renamedTo[to] = true
+	}
+
+	for _, name := range sortedKeys(old.Variables) {
+		o := old.Variables[name]
+		subject := "variable." + name
+		n, ok := new.Variables[name]
+		if !ok {
+			if to, ok := renamed[name]; ok {
+				add(VariableRenamed, subject, new.Variables[to].Pos, BumpMajor, "renamed to %s", to)
+			} else {
+				add(VariableRemoved, subject, o.Pos, BumpMajor, "removed")
+			}
+			continue
+		}
+		switch {
+		case !o.Required() && n.Required():
+			add(VariableRequired, subject, n.Pos, BumpMajor, "default %s removed, the variable is now required", o.Default)
+		case o.Required() && !n.Required():
+			add(VariableOptional, subject, n.Pos, BumpMinor, "default %s added, the variable is now optional", n.Default)
+		case !equalValues(o.Default, n.Default):
+			add(VariableDefault, subject, n.Pos, BumpMajor, "default changed from %s to %s", o.Default, n.Default)
+		}
+		if !equalTypes(o.Type, n.Type) {
+			add(VariableType, subject, n.Pos, BumpMajor, "type changed from %s to %s", typeString(o.Type), typeString(n.Type))
+		}
+		if o.Sensitive != n.Sensitive {
+			add(VariableSensitive, subject, n.Pos, BumpMinor, "sensitive changed from %t to %t", o.Sensitive, n.Sensitive)
+		}
+		if o.Nullable != n.Nullable {
+			bump := BumpMinor
+			if !n.Nullable {
+				bump = BumpMajor
+			}
+			add(VariableNullable, subject, n.Pos, bump, "nullable changed from %t to %t", o.Nullable, n.Nullable)
+		}
+		if !reflect.DeepEqual(o.Validations, n.Validations) {
+			// A new or changed rule can reject inputs that passed before;
+			// only dropping rules is safe.
+			if subset(n.Validations, o.Validations) {
+				add(VariableValidation, subject, n.Pos, BumpMinor, "validation rules removed")
+			} else {
+				add(VariableValidation, subject, n.Pos, BumpMajor, "validation rules added or changed; existing inputs may be rejected")
+			}
+		}
+		if o.Description != n.Description {
+			add(VariableDescription, subject, n.Pos, BumpPatch, "description changed")
+		}
+	}
+	for _, name := range sortedKeys(new.Variables) {
+		if _, ok := old.Variables[name]; ok || renamedTo[name] {
+			continue
+		}
+		n := new.Variables[name]
+		if n.Required() {
+			add(VariableAdded, "variable."+name, n.Pos, BumpMajor, "new required variable")
+		} else {
+			add(VariableAdded, "variable."+name, n.Pos, BumpMinor, "new optional variable (default %s)", n.Default)
+		}
+	}
+
+	for _, name := range sortedKeys(old.Outputs) {
+		o := old.Outputs[name]
+		subject := "output." + name
+		n, ok := new.Outputs[name]
+		if !ok {
+			add(OutputRemoved, subject, o.Pos, BumpMajor, "removed")
+			continue
+		}
+		if o.Sensitive != n.Sensitive {
+			// Callers using a newly sensitive output in a non-sensitive
+			// context (e.g. their own outputs) fail to plan.
+			bump := BumpMinor
+			if n.Sensitive {
+				bump = BumpMajor
+			}
+			add(OutputSensitive, subject, n.Pos, bump, "sensitive changed from %t to %t", o.Sensitive, n.Sensitive)
+		}
+		if o.Description != n.Description {
+			add(OutputDescription, subject, n.Pos, BumpPatch, "description changed")
+		}
+	}
+	for _, name := range sortedKeys(new.Outputs) {
+		if _, ok := old.Outputs[name]; !ok {
+			add(OutputAdded, "output."+name, new.Outputs[name].Pos, BumpMinor, "new output")
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Subject != changes[j].Subject {
+			return changes[i].Subject < changes[j].Subject
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
+
+// subset reports whether every element of a is in b.
+func subset(a, b []string) bool {
+	in := map[string]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	for _, s := range a {
+		if !in[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// renames pairs removed variables with added ones that have the same
+// non-empty description and type; only unambiguous pairs are returned.
+func renames(old, new *Interface) map[string]string {
+	out := map[string]string{}
+	for _, name := range sortedKeys(old.Variables) {
+		if _, ok := new.Variables[name]; ok {
+			continue
+		}
+		o := old.Variables[name]
+		if o.Description == "" {
+			continue
+		}
+		var candidates []string
+		for _, added := range sortedKeys(new.Variables) {
+			n := new.Variables[added]
+			if _, ok := old.Variables[added]; ok {
+				continue
+			}
+			if n.Description == o.Description && equalTypes(o.Type, n.Type) {
+				candidates = append(candidates, added)
+			}
+		}
+		if len(candidates) == 1 {
+			out[name] = candidates[0]
+		}
+	}
+	return out
+}
+
+func typeString(e *Expr) string {
+	if e == nil {
+		return "any"
+	}
+	return e.Source
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Recommend returns the version increment the changes require.
+func Recommend(changes []Change) Bump {
+	bump := BumpNone
+	for _, c := range changes {
+		bump = max(bump, c.Bump)
+	}
+	return bump
+}
+
+// Declarations lists breaking changes that a release intends to make.
+// Each non-empty line of the declarations file is
+//
+//	<module path> <subject>
+//
+// for example "modules/aws/ecs-fargate variable.certificate_arn". A subject
+// of "*" declares every breaking change of the module. Text after "#" is a
+// comment.
+type Declarations map[string]map[string]bool
+
+// ParseDeclarations reads a declarations file.
+func ParseDeclarations(r io.Reader) (Declarations, error) {
+	d := Declarations{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"<module path> <subject>\", got %q", line, strings.TrimSpace(text))
+		}
+		module := strings.TrimSuffix(fields[0], "/")
+		if d[module] == nil {
+			d[module] = map[string]bool{}
+		}
+		d[module][fields[1]] = true
+	}
+	return d, scanner.Err()
+}
+
+// Declared reports whether change of module is declared.
+func (d Declarations) Declared(module string, c Change) bool {
+	subjects := d[module]
+	return subjects["*"] || subjects[c.Subject]
+}
+
+// Undeclared returns the breaking changes of module that are not declared.
+func (d Declarations) Undeclared(module string, changes []Change) []Change {
+	var out []Change
+	for _, c := range changes {
+		if c.Breaking() && !d.Declared(module, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,278 @@
+// Package modcompat compares the public interface of a Terraform module
+// (the variables in variables.tf and the outputs in outputs.tf) between two
+// git refs and classifies the changes by their semantic versioning impact.
+package modcompat
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Files are the module files that declare its interface.
+var Files = []string{"variables.tf", "outputs.tf"}
+
+// Interface is the set of variables and outputs a module exposes.
+type Interface struct {
+	Variables map[string]*Variable
+	Outputs   map[string]*Output
+}
+
+// Expr is an attribute expression: its whitespace-normalized source and,
+// when it can be evaluated without context, its value.
+type Expr struct {
+	Source string
+	value  *cty.Value
+	typ    *cty.Type
+}
+
+func (e *Expr) String() string {
+	if e == nil {
+		return "(none)"
+	}
+	return e.Source
+}
+
+// equalValues compares two expressions by value where both evaluate, and
+// by source otherwise (e.g. defaults referring to functions).
+func equalValues(a, b *Expr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.value != nil && b.value != nil {
+		return a.value.RawEquals(*b.value)
+	}
+	return a.Source == b.Source
+}
+
+// equalTypes compares two type constraints, treating "list(string)" and
+// "list( string )" as equal. Constraints the HCL type parser does not know
+// (such as optional object attributes) are compared by source.
+func equalTypes(a, b *Expr) bool {
+	if isAny(a) || isAny(b) {
+		return isAny(a) && isAny(b)
+	}
+	if a.typ != nil && b.typ != nil {
+		return a.typ.Equals(*b.typ)
+	}
+	return a.Source == b.Source
+}
+
+// isAny reports whether e accepts any type; a missing type is "any".
+func isAny(e *Expr) bool { return e == nil || e.Source == "any" }
+
+// Variable is an input variable block.
+type Variable struct {
+	Name        string
+	Pos         string
+	Description string
+	Type        *Expr
+	// Default is nil for required variables.
+	Default   *Expr
+	Sensitive bool
+	Nullable  bool
+	// Validations holds the normalized source of each validation block.
+	Validations []string
+}
+
+// Required reports whether callers have to set the variable.
+func (v *Variable) Required() bool { return v.Default == nil }
+
+// Output is an output block.
+type Output struct {
+	Name        string
+	Pos         string
+	Description string
+	Sensitive   bool
+}
+
+var (
+	moduleSchema = &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+	}}
+	variableSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "description"}, {Name: "type"}, {Name: "default"},
+			{Name: "sensitive"}, {Name: "nullable"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{{Type: "validation"}},
+	}
+	outputSchema = &hcl.BodySchema{Attributes: []hcl.AttributeSchema{
+		{Name: "description"}, {Name: "sensitive"},
+	}}
+)
+
+// Parse adds the variables and outputs declared in src to m. filename is
+// used for positions.
+func (m *Interface) Parse(filename string, src []byte) error {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("parse %s: %s", filename, diags.Error())
+	}
+	content, _, diags := file.Body.PartialContent(moduleSchema)
+	if diags.HasErrors() {
+		return fmt.Errorf("parse %s: %s", filename, diags.Error())
+	}
+	for _, block := range content.Blocks {
+		name := block.Labels[0]
+		pos := fmt.Sprintf("%s:%d", filename, block.DefRange.Start.Line)
+		switch block.Type {
+		case "variable":
+			v, err := parseVariable(block, src)
+			if err != nil {
+				return fmt.Errorf("%s: variable %q: %w", pos, name, err)
+			}
+			v.Name, v.Pos = name, pos
+			m.Variables[name] = v
+		case "output":
+			o, err := parseOutput(block, src)
+			if err != nil {
+				return fmt.Errorf("%s: output %q: %w", pos, name, err)
+			}
+			o.Name, o.Pos = name, pos
+			m.Outputs[name] = o
+		}
+	}
+	return nil
+}
+
+func parseVariable(block *hcl.Block, src []byte) (*Variable, error) {
+	content, _, diags := block.Body.PartialContent(variableSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	v := &Variable{Nullable: true}
+	attrs := content.Attributes
+	var err error
+	if v.Description, err = stringAttr(attrs["description"]); err != nil {
+		return nil, err
+	}
+	if v.Sensitive, err = boolAttr(attrs["sensitive"], false); err != nil {
+		return nil, err
+	}
+	if v.Nullable, err = boolAttr(attrs["nullable"], true); err != nil {
+		return nil, err
+	}
+	if a := attrs["type"]; a != nil {
+		v.Type = newExpr(a.Expr, src)
+		if ty, diags := typeexpr.TypeConstraint(a.Expr); !diags.HasErrors() {
+			v.Type.typ = &ty
+		}
+	}
+	if a := attrs["default"]; a != nil {
+		v.Default = newExpr(a.Expr, src)
+		if val, diags := a.Expr.Value(nil); !diags.HasErrors() {
+			v.Default.value = &val
+		}
+	}
+	for _, b := range content.Blocks {
+		r := b.Body.(*hclsyntax.Body).Range()
+		v.Validations = append(v.Validations, normalize(r.SliceBytes(src)))
+	}
+	return v, nil
+}
+
+func parseOutput(block *hcl.Block, src []byte) (*Output, error) {
+	content, _, diags := block.Body.PartialContent(outputSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	o := &Output{}
+	var err error
+	if o.Description, err = stringAttr(content.Attributes["description"]); err != nil {
+		return nil, err
+	}
+	if o.Sensitive, err = boolAttr(content.Attributes["sensitive"], false); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func newExpr(expr hcl.Expression, src []byte) *Expr {
+	return &Expr{Source: normalize(expr.Range().SliceBytes(src))}
+}
+
+func normalize(src []byte) string {
+	return strings.Join(strings.Fields(string(src)), " ")
+}
+
+func stringAttr(a *hcl.Attribute) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	val, diags := a.Expr.Value(nil)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	if val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", fmt.Errorf("%s must be a string", a.Name)
+	}
+	return val.AsString(), nil
+}
+
+func boolAttr(a *hcl.Attribute, def bool) (bool, error) {
+	if a == nil {
+		return def, nil
+	}
+	val, diags := a.Expr.Value(nil)
+	if diags.HasErrors() {
+		return false, diags
+	}
+	if val.IsNull() || !val.Type().Equals(cty.Bool) {
+		return false, fmt.Errorf("%s must be true or false", a.Name)
+	}
+	return val.True(), nil
+}
+
+// Load reads the interface of the module at modulePath (relative to the
+// repository root) as of ref. An empty ref reads the working tree. Missing
+// files contribute nothing, so a module without outputs.tf has no outputs.
+func Load(repoDir, ref, modulePath string) (*Interface, error) {
+	m := &Interface{Variables: map[string]*Variable{}, Outputs: map[string]*Output{}}
+	for _, name := range Files {
+		file := path.Join(modulePath, name)
+		src, err := readFile(repoDir, ref, file)
+		if err != nil {
+			return nil, err
+		}
+		if src == nil {
+			continue
+		}
+		if err := m.Parse(file, src); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// readFile returns the content of file at ref, or nil if it does not exist.
+func readFile(repoDir, ref, file string) ([]byte, error) {
+	if ref == "" {
+		src, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return src, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", repoDir, "show", ref+":"+file)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := stderr.String()
+		if strings.Contains(msg, "does not exist in") || strings.Contains(msg, "exists on disk, but not in") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git show %s:%s: %w: %s", ref, file, err, strings.TrimSpace(msg))
+	}
+	return stdout.Bytes(), nil
+}
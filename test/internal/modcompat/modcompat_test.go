@@ -0,0 +1,298 @@
+package modcompat
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oldVariables = `
+variable "vpc_id" {
+  description = "VPC ID"
+  type        = string
+}
+
+variable "subnet_ids" {
+  description = "Subnets for the tasks"
+  type        = list(string)
+}
+
+variable "legacy" {
+  type    = bool
+  default = false
+}
+
+variable "fetch_interval" {
+  description = "Interval"
+  type        = string
+  default     = "1h"
+}
+
+variable "cpu" {
+  type    = number
+  default = 256
+}
+
+variable "port" {
+  type    = number
+  default = 8080
+  validation {
+    condition     = var.port != 4321
+    error_message = "Port 4321 is not allowed"
+  }
+}
+
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+
+variable "tenant_id" {
+  type = string
+}
+
+variable "image_tag" {
+  type    = string
+  default = "latest"
+}
+`
+
+const newVariables = `
+variable "vpc_id" {
+  description = "ID of the VPC"
+  type        = string
+}
+
+variable "private_subnet_ids" {
+  description = "Subnets for the tasks"
+  type        = list( string )
+}
+
+variable "fetch_interval" {
+  description = "Interval"
+  type        = string
+  default     = "30m"
+}
+
+variable "cpu" {
+  type = string
+  default = 256
+}
+
+variable "port" {
+  type    = number
+  default = 8080
+  validation {
+    condition     = var.port != 4321 && var.port > 0
+    error_message = "Port must be positive and not 4321"
+  }
+}
+
+variable "tags" {
+  type     = map(string)
+  default  = {}
+  nullable = false
+}
+
+variable "tenant_id" {
+  type      = string
+  sensitive = true
+  default   = "demo"
+}
+
+variable "image_tag" {
+  type = string
+}
+
+variable "certificate_arn" {
+  type = string
+}
+
+variable "log_retention" {
+  type    = number
+  default = 7
+}
+`
+
+const oldOutputs = `
+output "alb_arn" {
+  description = "ALB ARN"
+  value       = aws_lb.main.arn
+}
+
+output "url" {
+  value = "https://example.com"
+}
+
+output "password" {
+  value     = random_password.db.result
+  sensitive = true
+}
+`
+
+const newOutputs = `
+output "alb_arn" {
+  description = "ARN of the ALB"
+  value       = aws_lb.main.arn
+  sensitive   = true
+}
+
+output "password" {
+  value = nonsensitive(random_password.db.result)
+}
+
+output "service_url" {
+  value = "https://example.com"
+}
+`
+
+func parse(t *testing.T, variables, outputs string) *Interface {
+	t.Helper()
+	m := &Interface{Variables: map[string]*Variable{}, Outputs: map[string]*Output{}}
+	require.NoError(t, m.Parse("variables.tf", []byte(variables)))
+	require.NoError(t, m.Parse("outputs.tf", []byte(outputs)))
+	return m
+}
+
+func TestParse(t *testing.T) {
+	m := parse(t, newVariables, newOutputs)
+
+	v := m.Variables["tags"]
+	require.NotNil(t, v)
+	assert.Equal(t, "variables.tf:32", v.Pos)
+	assert.Equal(t, "map(string)", v.Type.Source)
+	assert.Equal(t, "{}", v.Default.Source)
+	assert.False(t, v.Nullable)
+	assert.False(t, v.Required())
+
+	assert.True(t, m.Variables["certificate_arn"].Required())
+	assert.True(t, m.Variables["tenant_id"].Sensitive)
+	assert.Len(t, m.Variables["port"].Validations, 1)
+
+	assert.True(t, m.Outputs["alb_arn"].Sensitive)
+	assert.Equal(t, "outputs.tf:2", m.Outputs["alb_arn"].Pos)
+}
+
+func TestParseInvalid(t *testing.T) {
+	m := &Interface{Variables: map[string]*Variable{}, Outputs: map[string]*Output{}}
+	assert.ErrorContains(t, m.Parse("variables.tf", []byte(`variable "x" {`)), "parse variables.tf")
+	assert.ErrorContains(t, m.Parse("variables.tf", []byte(`variable "x" { sensitive = "yes" }`)), `variables.tf:1: variable "x": sensitive must be true or false`)
+}
+
+func TestCompare(t *testing.T) {
+	changes := Compare(parse(t, oldVariables, oldOutputs), parse(t, newVariables, newOutputs))
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	assert.Equal(t, []string{
+		"outputs.tf:2: output.alb_arn: description changed (patch)",
+		"outputs.tf:2: output.alb_arn: sensitive changed from false to true (major)",
+		"outputs.tf:8: output.password: sensitive changed from true to false (minor)",
+		"outputs.tf:12: output.service_url: new output (minor)",
+		"outputs.tf:7: output.url: removed (major)",
+		"variables.tf:48: variable.certificate_arn: new required variable (major)",
+		"variables.tf:18: variable.cpu: type changed from number to string (major)",
+		"variables.tf:12: variable.fetch_interval: default changed from \"1h\" to \"30m\" (major)",
+		"variables.tf:44: variable.image_tag: default \"latest\" removed, the variable is now required (major)",
+		"variables.tf:12: variable.legacy: removed (major)",
+		"variables.tf:52: variable.log_retention: new optional variable (default 7) (minor)",
+		"variables.tf:23: variable.port: validation rules added or changed; existing inputs may be rejected (major)",
+		"variables.tf:7: variable.subnet_ids: renamed to private_subnet_ids (major)",
+		"variables.tf:32: variable.tags: nullable changed from true to false (major)",
+		"variables.tf:38: variable.tenant_id: default \"demo\" added, the variable is now optional (minor)",
+		"variables.tf:38: variable.tenant_id: sensitive changed from false to true (minor)",
+		"variables.tf:2: variable.vpc_id: description changed (patch)",
+	}, got)
+	assert.Equal(t, BumpMajor, Recommend(changes))
+}
+
+func TestCompareUnchanged(t *testing.T) {
+	m := parse(t, oldVariables, oldOutputs)
+	assert.Empty(t, Compare(m, parse(t, oldVariables, oldOutputs)))
+	assert.Equal(t, BumpNone, Recommend(nil))
+}
+
+func TestRecommend(t *testing.T) {
+	old := parse(t, oldVariables, oldOutputs)
+	added := parse(t, oldVariables+`
+variable "extra" {
+  type    = string
+  default = ""
+}`, oldOutputs)
+	assert.Equal(t, BumpMinor, Recommend(Compare(old, added)))
+
+	described := parse(t, strings.Replace(oldVariables, `"VPC ID"`, `"The VPC"`, 1), oldOutputs)
+	assert.Equal(t, BumpPatch, Recommend(Compare(old, described)))
+
+	// Dropping a validation rule only accepts more inputs
+	unvalidated := parse(t, strings.Replace(oldVariables, `  validation {
+    condition     = var.port != 4321
+    error_message = "Port 4321 is not allowed"
+  }
+`, "", 1), oldOutputs)
+	assert.Equal(t, BumpMinor, Recommend(Compare(old, unvalidated)))
+	assert.Equal(t, BumpMajor, Recommend(Compare(unvalidated, old)))
+}
+
+func TestDeclarations(t *testing.T) {
+	d, err := ParseDeclarations(strings.NewReader(`
+# Breaking changes of the next release
+modules/aws/ecs-fargate/ variable.legacy  # unused since v1
+modules/gcp/cloud-run    *
+`))
+	require.NoError(t, err)
+
+	changes := Compare(parse(t, oldVariables, oldOutputs), parse(t, newVariables, newOutputs))
+	var subjects []string
+	for _, c := range d.Undeclared("modules/aws/ecs-fargate", changes) {
+		subjects = append(subjects, c.Subject)
+	}
+	assert.NotContains(t, subjects, "variable.legacy")
+	assert.Contains(t, subjects, "output.url")
+	assert.NotContains(t, subjects, "variable.vpc_id", "non-breaking changes need no declaration")
+	assert.Empty(t, d.Undeclared("modules/gcp/cloud-run", changes))
+
+	_, err = ParseDeclarations(strings.NewReader("variable.legacy\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestLoad(t *testing.T) {
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, out)
+	}
+	dir := filepath.Join(repo, "modules", "demo")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(oldVariables), 0o644))
+	run("init", "-q")
+	run("add", "-A")
+	run("commit", "-q", "-m", "v1")
+	run("tag", "v1.0.0")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(newOutputs), 0o644))
+
+	old, err := Load(repo, "v1.0.0", "modules/demo")
+	require.NoError(t, err)
+	assert.Contains(t, old.Variables, "legacy")
+	assert.Equal(t, "modules/demo/variables.tf:2", old.Variables["vpc_id"].Pos)
+	assert.Empty(t, old.Outputs, "outputs.tf does not exist at v1.0.0")
+
+	cur, err := Load(repo, "", "modules/demo")
+	require.NoError(t, err)
+	assert.Contains(t, cur.Outputs, "alb_arn")
+
+	_, err = Load(repo, "v9.9.9", "modules/demo")
+	assert.ErrorContains(t, err, "git show v9.9.9:modules/demo/variables.tf")
+}
@@ -0,0 +1,405 @@
+// Package naming evaluates the resource names a module derives from its
+// variables, such as "${var.name_prefix}basemachina-bridge", and checks them
+// against the length and character rules of the cloud APIs. Names are
+// evaluated from the module source with the variable values, so invalid
+// names are found without a plan or an apply, which is where the APIs
+// reject them.
+package naming
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Rule is the naming rule of one resource attribute.
+type Rule struct {
+	Min, Max int
+	// Pattern is the allowed form of the whole value.
+	Pattern *regexp.Regexp
+	// Reject matches values that Pattern allows but the API refuses, such
+	// as ALB names starting with "internal-".
+	Reject *regexp.Regexp
+	// Generated is set for name_prefix attributes: Terraform appends a
+	// unique suffix, so the value is never shared between deployments.
+	// Max already accounts for the suffix.
+	Generated bool
+}
+
+var (
+	// elbName: letters, digits and hyphens, no hyphen at either end.
+	elbName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+	ecsName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// rfc1035 is the form of Compute Engine and Cloud Run names.
+	rfc1035 = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// Rules maps "<resource type>.<attribute>" to its rule. Only attributes
+// listed here are evaluated.
+var Rules = map[string]Rule{
+	"aws_lb.name":                       {Min: 1, Max: 32, Pattern: elbName, Reject: regexp.MustCompile(`^internal-`)},
+	"aws_lb_target_group.name":          {Min: 1, Max: 32, Pattern: elbName},
+	"aws_ecs_cluster.name":              {Min: 1, Max: 255, Pattern: ecsName},
+	"aws_ecs_service.name":              {Min: 1, Max: 255, Pattern: ecsName},
+	"aws_ecs_task_definition.family":    {Min: 1, Max: 255, Pattern: ecsName},
+	"aws_cloudwatch_log_group.name":     {Min: 1, Max: 512, Pattern: regexp.MustCompile(`^[.\-_/#A-Za-z0-9]+$`)},
+	"aws_iam_role.name_prefix":          {Min: 1, Max: 38, Pattern: regexp.MustCompile(`^[\w+=,.@-]+$`), Generated: true},
+	"aws_security_group.name_prefix":    {Min: 1, Max: 229, Pattern: regexp.MustCompile(`^[\x20-\x7e]+$`), Reject: regexp.MustCompile(`^sg-`), Generated: true},
+	"google_service_account.account_id": {Min: 6, Max: 30, Pattern: rfc1035},
+	"google_cloud_run_v2_service.name":  {Min: 1, Max: 49, Pattern: rfc1035},
+
+	"google_compute_backend_service.name":               {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_global_address.name":                {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_global_forwarding_rule.name":        {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_managed_ssl_certificate.name":       {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_region_network_endpoint_group.name": {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_security_policy.name":               {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_target_http_proxy.name":             {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_target_https_proxy.name":            {Min: 1, Max: 63, Pattern: rfc1035},
+	"google_compute_url_map.name":                       {Min: 1, Max: 63, Pattern: rfc1035},
+}
+
+type variable struct {
+	typ         cty.Type
+	def         cty.Value
+	validations []validation
+}
+
+type validation struct {
+	condition    hcl.Expression
+	errorMessage string
+}
+
+type nameExpr struct {
+	resourceType, resourceName, attribute string
+	expr                                  hcl.Expression
+	rng                                   hcl.Range
+}
+
+// Module holds the variables and the name expressions of a module.
+type Module struct {
+	variables map[string]*variable
+	names     []nameExpr
+}
+
+// Load parses the .tf files of the module in dir.
+func Load(dir string) (*Module, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	m := &Module{variables: map[string]*variable{}}
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Parse(f, src); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Parse adds the variables and name expressions of one file.
+func (m *Module) Parse(filename string, src []byte) error {
+	if m.variables == nil {
+		m.variables = map[string]*variable{}
+	}
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("parse %s: %s", filename, diags.Error())
+	}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		switch block.Type {
+		case "variable":
+			v, err := parseVariable(block)
+			if err != nil {
+				return err
+			}
+			m.variables[block.Labels[0]] = v
+		case "resource":
+			for attr, a := range block.Body.Attributes {
+				if _, ok := Rules[block.Labels[0]+"."+attr]; !ok {
+					continue
+				}
+				m.names = append(m.names, nameExpr{
+					resourceType: block.Labels[0],
+					resourceName: block.Labels[1],
+					attribute:    attr,
+					expr:         a.Expr,
+					rng:          a.SrcRange,
+				})
+			}
+		}
+	}
+	sort.Slice(m.names, func(i, j int) bool {
+		a, b := m.names[i].rng, m.names[j].rng
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Line < b.Start.Line
+	})
+	return nil
+}
+
+func parseVariable(block *hclsyntax.Block) (*variable, error) {
+	v := &variable{typ: cty.DynamicPseudoType, def: cty.NilVal}
+	if a, ok := block.Body.Attributes["type"]; ok {
+		typ, diags := typeexpr.TypeConstraint(a.Expr)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("variable %q: %s", block.Labels[0], diags.Error())
+		}
+		v.typ = typ
+	}
+	if a, ok := block.Body.Attributes["default"]; ok {
+		def, diags := a.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("variable %q: %s", block.Labels[0], diags.Error())
+		}
+		v.def = def
+	}
+	for _, b := range block.Body.Blocks {
+		if b.Type != "validation" {
+			continue
+		}
+		val := validation{}
+		if a, ok := b.Body.Attributes["condition"]; ok {
+			val.condition = a.Expr
+		}
+		if a, ok := b.Body.Attributes["error_message"]; ok {
+			if msg, diags := a.Expr.Value(nil); !diags.HasErrors() && msg.Type() == cty.String {
+				val.errorMessage = msg.AsString()
+			}
+		}
+		if val.condition != nil {
+			v.validations = append(v.validations, val)
+		}
+	}
+	return v, nil
+}
+
+// Validate evaluates the validation blocks of the variables in vars, as
+// Terraform does before planning, and returns the first failure.
+func (m *Module) Validate(vars map[string]cty.Value) error {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, ok := m.variables[name]
+		if !ok {
+			return fmt.Errorf("var.%s is not declared", name)
+		}
+		value, err := convert.Convert(vars[name], v.typ)
+		if err != nil {
+			return fmt.Errorf("var.%s: %w", name, err)
+		}
+		ctx := evalContext(map[string]cty.Value{name: value})
+		for _, val := range v.validations {
+			ok, diags := val.condition.Value(ctx)
+			if diags.HasErrors() {
+				return fmt.Errorf("var.%s: evaluate validation: %s", name, diags.Error())
+			}
+			if ok.Type() != cty.Bool || !ok.IsKnown() || ok.IsNull() {
+				return fmt.Errorf("var.%s: validation condition is not a known bool", name)
+			}
+			if ok.False() {
+				return fmt.Errorf("var.%s: %s", name, val.errorMessage)
+			}
+		}
+	}
+	return nil
+}
+
+// Name is an evaluated resource name.
+type Name struct {
+	// Resource is "<type>.<name>".
+	Resource  string
+	Attribute string
+	Value     string
+	// Pos is "<file>:<line>" of the attribute.
+	Pos string
+}
+
+// Rule returns the rule of the name.
+func (n Name) Rule() Rule {
+	typ, _, _ := strings.Cut(n.Resource, ".")
+	return Rules[typ+"."+n.Attribute]
+}
+
+func (n Name) String() string {
+	return fmt.Sprintf("%s.%s = %q", n.Resource, n.Attribute, n.Value)
+}
+
+// Names evaluates every name attribute of the module with vars, falling
+// back to the variable defaults. Names that depend on anything but
+// variables (resources, data sources, count) are skipped.
+func (m *Module) Names(vars map[string]cty.Value) ([]Name, error) {
+	values := map[string]cty.Value{}
+	for name, v := range m.variables {
+		value := v.def
+		if given, ok := vars[name]; ok {
+			value = given
+		}
+		if value == cty.NilVal {
+			value = cty.UnknownVal(v.typ)
+		}
+		converted, err := convert.Convert(value, v.typ)
+		if err != nil {
+			return nil, fmt.Errorf("var.%s: %w", name, err)
+		}
+		values[name] = converted
+	}
+	ctx := evalContext(values)
+
+	var out []Name
+	for _, n := range m.names {
+		pos := fmt.Sprintf("%s:%d", n.rng.Filename, n.rng.Start.Line)
+		if !onlyVariables(n.expr) {
+			continue
+		}
+		value, diags := n.expr.Value(ctx)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%s: %s", pos, diags.Error())
+		}
+		if !value.IsKnown() || value.IsNull() {
+			continue
+		}
+		value, err := convert.Convert(value, cty.String)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pos, err)
+		}
+		out = append(out, Name{
+			Resource:  n.resourceType + "." + n.resourceName,
+			Attribute: n.attribute,
+			Value:     value.AsString(),
+			Pos:       pos,
+		})
+	}
+	return out, nil
+}
+
+func onlyVariables(expr hcl.Expression) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "var" {
+			return false
+		}
+	}
+	return true
+}
+
+func evalContext(vars map[string]cty.Value) *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": cty.ObjectVal(vars)},
+		Functions: functions,
+	}
+}
+
+// functions are the Terraform functions the name expressions and
+// validation conditions of the modules use.
+var functions = map[string]function.Function{
+	"can":        tryfunc.CanFunc,
+	"contains":   stdlib.ContainsFunc,
+	"format":     stdlib.FormatFunc,
+	"join":       stdlib.JoinFunc,
+	"length":     lengthFunc,
+	"lower":      stdlib.LowerFunc,
+	"regex":      stdlib.RegexFunc,
+	"replace":    stdlib.ReplaceFunc,
+	"substr":     stdlib.SubstrFunc,
+	"trimprefix": stdlib.TrimPrefixFunc,
+	"trimsuffix": stdlib.TrimSuffixFunc,
+	"try":        tryfunc.TryFunc,
+	"upper":      stdlib.UpperFunc,
+}
+
+// lengthFunc is Terraform's length, which also accepts strings.
+var lengthFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{
+		Name:             "value",
+		Type:             cty.DynamicPseudoType,
+		AllowDynamicType: true,
+		AllowUnknown:     true,
+	}},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		if !args[0].IsKnown() {
+			return cty.UnknownVal(cty.Number), nil
+		}
+		if args[0].Type() == cty.String {
+			return stdlib.Strlen(args[0])
+		}
+		return stdlib.Length(args[0])
+	},
+})
+
+// Violation is a name that breaks its rule.
+type Violation struct {
+	Name    Name
+	Problem string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Name.Pos, v.Name, v.Problem)
+}
+
+// Check returns the names that break their rule.
+func Check(names []Name) []Violation {
+	var out []Violation
+	for _, n := range names {
+		rule := n.Rule()
+		length := len(n.Value)
+		switch {
+		case length < rule.Min:
+			out = append(out, Violation{Name: n, Problem: fmt.Sprintf("%d characters, at least %d required", length, rule.Min)})
+		case length > rule.Max:
+			out = append(out, Violation{Name: n, Problem: fmt.Sprintf("%d characters, at most %d allowed", length, rule.Max)})
+		}
+		if rule.Pattern != nil && !rule.Pattern.MatchString(n.Value) {
+			out = append(out, Violation{Name: n, Problem: fmt.Sprintf("does not match %s", rule.Pattern)})
+		}
+		if rule.Reject != nil && rule.Reject.MatchString(n.Value) {
+			out = append(out, Violation{Name: n, Problem: fmt.Sprintf("must not match %s", rule.Reject)})
+		}
+	}
+	return out
+}
+
+// Collisions returns the names that two resources of the same type would
+// share, within one deployment or across the deployments given. Generated
+// names (name_prefix) are unique by construction and are ignored.
+func Collisions(deployments ...[]Name) []string {
+	type key struct{ typ, attribute, value string }
+	seen := map[key]string{}
+	var out []string
+	for i, names := range deployments {
+		for _, n := range names {
+			if n.Rule().Generated {
+				continue
+			}
+			typ, _, _ := strings.Cut(n.Resource, ".")
+			k := key{typ, n.Attribute, n.Value}
+			owner := fmt.Sprintf("deployment %d %s", i, n.Resource)
+			if prev, ok := seen[k]; ok {
+				out = append(out, fmt.Sprintf("%s.%s %q is used by %s and %s", typ, n.Attribute, n.Value, prev, owner))
+				continue
+			}
+			seen[k] = owner
+		}
+	}
+	return out
+}
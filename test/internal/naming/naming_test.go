@@ -0,0 +1,131 @@
+package naming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const fixture = `
+variable "name_prefix" {
+  type    = string
+  default = ""
+
+  validation {
+    condition     = length(var.name_prefix) <= 14
+    error_message = "Too long"
+  }
+
+  validation {
+    condition     = can(regex("^[a-z0-9-]*$", var.name_prefix))
+    error_message = "Invalid characters"
+  }
+}
+
+variable "subnet_ids" {
+  type = list(string)
+}
+
+resource "aws_lb" "main" {
+  name = "${var.name_prefix}basemachina-bridge"
+}
+
+resource "aws_lb_target_group" "bridge" {
+  name = "${var.name_prefix}bridge-tg"
+}
+
+resource "aws_iam_role" "task" {
+  name_prefix = "${var.name_prefix}-bridge-task-"
+}
+
+resource "aws_ecs_service" "bridge" {
+  name    = "${var.name_prefix}bridge"
+  cluster = aws_ecs_cluster.main.id
+}
+
+resource "aws_ecs_cluster" "main" {
+  name = "${aws_lb.main.name}-cluster"
+}
+
+resource "aws_route53_record" "bridge" {
+  name = var.name_prefix
+}
+`
+
+func load(t *testing.T) *Module {
+	t.Helper()
+	m := &Module{}
+	require.NoError(t, m.Parse("main.tf", []byte(fixture)))
+	return m
+}
+
+func TestNames(t *testing.T) {
+	names, err := load(t).Names(map[string]cty.Value{"name_prefix": cty.StringVal("prod-")})
+	require.NoError(t, err)
+
+	var got []string
+	for _, n := range names {
+		got = append(got, n.Pos+" "+n.String())
+	}
+	assert.Equal(t, []string{
+		`main.tf:22 aws_lb.main.name = "prod-basemachina-bridge"`,
+		`main.tf:26 aws_lb_target_group.bridge.name = "prod-bridge-tg"`,
+		`main.tf:30 aws_iam_role.task.name_prefix = "prod--bridge-task-"`,
+		`main.tf:34 aws_ecs_service.bridge.name = "prod-bridge"`,
+	}, got)
+}
+
+func TestNamesDefaults(t *testing.T) {
+	names, err := load(t).Names(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+	assert.Equal(t, "basemachina-bridge", names[0].Value)
+}
+
+func TestValidate(t *testing.T) {
+	m := load(t)
+	assert.NoError(t, m.Validate(map[string]cty.Value{"name_prefix": cty.StringVal("prod-")}))
+	assert.EqualError(t, m.Validate(map[string]cty.Value{"name_prefix": cty.StringVal("a-very-long-prefix")}), "var.name_prefix: Too long")
+	assert.EqualError(t, m.Validate(map[string]cty.Value{"name_prefix": cty.StringVal("Prod")}), "var.name_prefix: Invalid characters")
+	assert.EqualError(t, m.Validate(map[string]cty.Value{"missing": cty.StringVal("x")}), "var.missing is not declared")
+}
+
+func TestCheck(t *testing.T) {
+	violations := Check([]Name{
+		{Resource: "aws_lb.main", Attribute: "name", Value: "a-very-long-prefix-basemachina-bridge", Pos: "alb.tf:11"},
+		{Resource: "aws_lb.main", Attribute: "name", Value: "internal-bridge", Pos: "alb.tf:11"},
+		{Resource: "aws_lb_target_group.bridge", Attribute: "name", Value: "-bridge-tg", Pos: "alb.tf:36"},
+		{Resource: "google_service_account.bridge", Attribute: "account_id", Value: "ab-sa", Pos: "cloud_run.tf:8"},
+		{Resource: "google_cloud_run_v2_service.bridge", Attribute: "name", Value: "basemachina-bridge", Pos: "cloud_run.tf:47"},
+	})
+
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.String())
+	}
+	assert.Equal(t, []string{
+		`alb.tf:11: aws_lb.main.name = "a-very-long-prefix-basemachina-bridge": 37 characters, at most 32 allowed`,
+		`alb.tf:11: aws_lb.main.name = "internal-bridge": must not match ^internal-`,
+		`alb.tf:36: aws_lb_target_group.bridge.name = "-bridge-tg": does not match ^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`,
+		`cloud_run.tf:8: google_service_account.bridge.account_id = "ab-sa": 5 characters, at least 6 required`,
+	}, got)
+}
+
+func TestCollisions(t *testing.T) {
+	a := []Name{
+		{Resource: "google_compute_global_forwarding_rule.https", Attribute: "name", Value: "a-https-rule"},
+		{Resource: "google_compute_global_forwarding_rule.http", Attribute: "name", Value: "a-http-rule"},
+		{Resource: "aws_iam_role.task", Attribute: "name_prefix", Value: "-bridge-"},
+	}
+	b := []Name{
+		{Resource: "google_compute_global_forwarding_rule.http", Attribute: "name", Value: "a-https-rule"},
+		{Resource: "google_compute_url_map.default", Attribute: "name", Value: "a-http-rule"},
+		{Resource: "aws_iam_role.task", Attribute: "name_prefix", Value: "-bridge-"},
+	}
+	assert.Empty(t, Collisions(a))
+	assert.Equal(t, []string{
+		`google_compute_global_forwarding_rule.name "a-https-rule" is used by deployment 0 google_compute_global_forwarding_rule.https and deployment 1 google_compute_global_forwarding_rule.http`,
+	}, Collisions(a, b))
+}
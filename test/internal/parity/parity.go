@@ -0,0 +1,366 @@
+// Package parity extracts the Bridge container configuration from plans of
+// the AWS (ECS Fargate) and GCP (Cloud Run) modules and reports where the
+// two deployments of the same Bridge diverge.
+package parity
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+)
+
+// Variables are the module variables that shape the Bridge container.
+var Variables = []string{"bridge_image_tag", "fetch_interval", "fetch_timeout", "port", "tenant_id"}
+
+// Image is a container image reference.
+type Image struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+func (i Image) String() string {
+	return i.Registry + "/" + i.Repository + ":" + i.Tag
+}
+
+// ecrPullThrough matches images pulled through an ECR pull-through cache
+// rule for ECR Public with the "ecr-public" prefix.
+var ecrPullThrough = regexp.MustCompile(`^\d{12}\.dkr\.ecr\.[a-z0-9-]+\.amazonaws\.com/ecr-public/(.+)$`)
+
+// ParseImage splits ref into registry, repository and tag. Images pulled
+// through the ECR pull-through cache are reported with their upstream
+// registry, public.ecr.aws.
+func ParseImage(ref string) Image {
+	var img Image
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, img.Tag = ref[:i], ref[i+1:]
+	}
+	if m := ecrPullThrough.FindStringSubmatch(ref); m != nil {
+		ref = "public.ecr.aws/" + m[1]
+	}
+	img.Registry, img.Repository, _ = strings.Cut(ref, "/")
+	return img
+}
+
+// Variable is the declaration of a module variable.
+type Variable struct {
+	Declared  bool `json:"declared"`
+	Required  bool `json:"required"`
+	Default   any  `json:"default"`
+	Sensitive bool `json:"sensitive"`
+}
+
+// Bridge is the Bridge-facing configuration of one deployment.
+type Bridge struct {
+	Cloud string `json:"cloud"`
+	// Address is the resource the container was read from.
+	Address string `json:"address"`
+	Image   Image  `json:"image"`
+	// Env holds the container environment. Values only known after apply
+	// are "(known after apply)", values from secrets "(secret)".
+	Env map[string]string `json:"env"`
+	// PlatformEnv lists Env entries set by the platform rather than the
+	// module, such as PORT on Cloud Run.
+	PlatformEnv []string `json:"platform_env,omitempty"`
+	Port        int      `json:"port"`
+	// Variables holds the declarations of Variables in the module.
+	Variables map[string]Variable `json:"variables"`
+}
+
+const unknown = "(known after apply)"
+
+// FromECS extracts the "bridge" container of the ECS task definition in
+// plan.
+func FromECS(plan *tfplan.Plan) (*Bridge, error) {
+	res, ok := findResource(plan, "aws_ecs_task_definition")
+	if !ok {
+		return nil, fmt.Errorf("plan has no aws_ecs_task_definition")
+	}
+	raw, ok := res.Values["container_definitions"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: container_definitions is %s", res.Address, unknown)
+	}
+	var containers []struct {
+		Name         string `json:"name"`
+		Image        string `json:"image"`
+		PortMappings []struct {
+			ContainerPort int `json:"containerPort"`
+		} `json:"portMappings"`
+		Environment []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"environment"`
+		Secrets []struct {
+			Name string `json:"name"`
+		} `json:"secrets"`
+	}
+	if err := json.Unmarshal([]byte(raw), &containers); err != nil {
+		return nil, fmt.Errorf("%s: parse container_definitions: %w", res.Address, err)
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%s: no containers", res.Address)
+	}
+	c := containers[0]
+	for _, candidate := range containers {
+		if candidate.Name == "bridge" {
+			c = candidate
+		}
+	}
+
+	b := &Bridge{Cloud: "aws", Address: res.Address, Image: ParseImage(c.Image), Env: map[string]string{}}
+	for _, e := range c.Environment {
+		b.Env[e.Name] = e.Value
+	}
+	for _, s := range c.Secrets {
+		b.Env[s.Name] = "(secret)"
+	}
+	if len(c.PortMappings) > 0 {
+		b.Port = c.PortMappings[0].ContainerPort
+	}
+	b.Variables = variables(plan, res.Address)
+	return b, nil
+}
+
+// FromCloudRun extracts the first container of the Cloud Run v2 service in
+// plan. Cloud Run sets PORT to the container port, so PORT is added to Env
+// as platform-provided unless the module sets it.
+func FromCloudRun(plan *tfplan.Plan) (*Bridge, error) {
+	res, ok := findResource(plan, "google_cloud_run_v2_service")
+	if !ok {
+		return nil, fmt.Errorf("plan has no google_cloud_run_v2_service")
+	}
+	template, _ := first(res.Values["template"])
+	container, ok := first(template["containers"])
+	if !ok {
+		return nil, fmt.Errorf("%s: template has no containers", res.Address)
+	}
+
+	b := &Bridge{Cloud: "gcp", Address: res.Address, Env: map[string]string{}}
+	if image, ok := container["image"].(string); ok {
+		b.Image = ParseImage(image)
+	}
+	envs, _ := container["env"].([]any)
+	for _, e := range envs {
+		env, _ := e.(map[string]any)
+		name, _ := env["name"].(string)
+		switch value := env["value"].(type) {
+		case string:
+			b.Env[name] = value
+		default:
+			if src, ok := env["value_source"].([]any); ok && len(src) > 0 {
+				b.Env[name] = "(secret)"
+			} else {
+				b.Env[name] = unknown
+			}
+		}
+	}
+	if port, ok := first(container["ports"]); ok {
+		if n, ok := port["container_port"].(float64); ok {
+			b.Port = int(n)
+		}
+	}
+	if _, ok := b.Env["PORT"]; !ok && b.Port != 0 {
+		b.Env["PORT"] = strconv.Itoa(b.Port)
+		b.PlatformEnv = append(b.PlatformEnv, "PORT")
+	}
+	b.Variables = variables(plan, res.Address)
+	return b, nil
+}
+
+func findResource(plan *tfplan.Plan, typ string) (tfplan.Resource, bool) {
+	for _, r := range plan.Resources() {
+		if r.Mode == "managed" && r.Type == typ {
+			return r, true
+		}
+	}
+	return tfplan.Resource{}, false
+}
+
+// first returns the first element of a nested block list.
+func first(v any) (map[string]any, bool) {
+	l, ok := v.([]any)
+	if !ok || len(l) == 0 {
+		return nil, false
+	}
+	m, ok := l[0].(map[string]any)
+	return m, ok
+}
+
+func variables(plan *tfplan.Plan, resourceAddress string) map[string]Variable {
+	out := map[string]Variable{}
+	module, ok := plan.Module(tfplan.ModuleAddress(resourceAddress))
+	if !ok {
+		return out
+	}
+	for _, name := range Variables {
+		v, ok := module.Variables[name]
+		if !ok {
+			out[name] = Variable{}
+			continue
+		}
+		out[name] = Variable{Declared: true, Required: v.Required(), Default: v.DefaultValue(), Sensitive: v.Sensitive}
+	}
+	return out
+}
+
+// Gap is one difference between the deployments.
+type Gap struct {
+	// Key identifies the compared setting, e.g. "image.registry",
+	// "env.PORT" or "variable.tenant_id.sensitive".
+	Key string `json:"key"`
+	AWS string `json:"aws"`
+	GCP string `json:"gcp"`
+}
+
+func (g Gap) String() string {
+	return fmt.Sprintf("%s: aws=%s gcp=%s", g.Key, g.AWS, g.GCP)
+}
+
+const unset = "(unset)"
+
+// Compare returns the differences between the two deployments, sorted by
+// key. Values of environment variables backed by a sensitive variable are
+// compared but not reported.
+func Compare(aws, gcp *Bridge) []Gap {
+	var gaps []Gap
+	add := func(key, a, g string) {
+		if a != g {
+			gaps = append(gaps, Gap{Key: key, AWS: a, GCP: g})
+		}
+	}
+
+	add("image.registry", aws.Image.Registry, gcp.Image.Registry)
+	add("image.repository", aws.Image.Repository, gcp.Image.Repository)
+	add("image.tag", aws.Image.Tag, gcp.Image.Tag)
+	add("port", strconv.Itoa(aws.Port), strconv.Itoa(gcp.Port))
+
+	names := map[string]bool{}
+	for name := range aws.Env {
+		names[name] = true
+	}
+	for name := range gcp.Env {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		a, g := envValue(aws, name), envValue(gcp, name)
+		if a == g {
+			continue
+		}
+		if sensitiveEnv(name, aws, gcp) {
+			a, g = mask(a), mask(g)
+			if a == g {
+				a, g = "(sensitive)", "(sensitive, different value)"
+			}
+		}
+		add("env."+name, describeEnv(aws, name, a), describeEnv(gcp, name, g))
+	}
+
+	for _, name := range Variables {
+		a, g := aws.Variables[name], gcp.Variables[name]
+		if !a.Declared || !g.Declared {
+			add("variable."+name, declared(a), declared(g))
+			continue
+		}
+		add("variable."+name+".default", defaultString(a), defaultString(g))
+		add("variable."+name+".sensitive", strconv.FormatBool(a.Sensitive), strconv.FormatBool(g.Sensitive))
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].Key < gaps[j].Key })
+	return gaps
+}
+
+func envValue(b *Bridge, name string) string {
+	if v, ok := b.Env[name]; ok {
+		return v
+	}
+	return unset
+}
+
+// describeEnv marks values set by the platform.
+func describeEnv(b *Bridge, name, value string) string {
+	for _, p := range b.PlatformEnv {
+		if p == name {
+			return value + " (platform)"
+		}
+	}
+	return value
+}
+
+// sensitiveEnv reports whether the environment variable is set from a
+// variable ("TENANT_ID" from tenant_id) that is sensitive in either module.
+func sensitiveEnv(name string, bridges ...*Bridge) bool {
+	for _, b := range bridges {
+		if b.Variables[strings.ToLower(name)].Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of b with the values of environment variables
+// backed by a sensitive variable in b or any of others masked.
+func Redacted(b *Bridge, others ...*Bridge) *Bridge {
+	out := *b
+	out.Env = map[string]string{}
+	for name, v := range b.Env {
+		if sensitiveEnv(name, append([]*Bridge{b}, others...)...) {
+			v = mask(v)
+		}
+		out.Env[name] = v
+	}
+	return &out
+}
+
+func mask(v string) string {
+	if v == unset || v == unknown || v == "(secret)" {
+		return v
+	}
+	return "(sensitive)"
+}
+
+func declared(v Variable) string {
+	if v.Declared {
+		return "declared"
+	}
+	return "not declared"
+}
+
+func defaultString(v Variable) string {
+	if v.Required {
+		return "(required)"
+	}
+	data, err := json.Marshal(v.Default)
+	if err != nil {
+		return fmt.Sprint(v.Default)
+	}
+	return string(data)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Unaccepted returns the gaps whose key is not in accepted.
+func Unaccepted(gaps []Gap, accepted []string) []Gap {
+	ok := map[string]bool{}
+	for _, k := range accepted {
+		ok[k] = true
+	}
+	var out []Gap
+	for _, g := range gaps {
+		if !ok[g.Key] {
+			out = append(out, g)
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package parity
+
+import (
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ecsPlan and cloudRunPlan are trimmed `terraform show -json` outputs of
+// the examples.
+const ecsPlan = `{
+  "planned_values": {"root_module": {"child_modules": [{
+    "address": "module.basemachina_bridge",
+    "resources": [
+      {"address": "module.basemachina_bridge.aws_ecs_cluster.main", "mode": "managed", "type": "aws_ecs_cluster", "name": "main", "values": {}},
+      {"address": "module.basemachina_bridge.aws_ecs_task_definition.bridge", "mode": "managed", "type": "aws_ecs_task_definition", "name": "bridge", "values": {
+        "container_definitions": "[{\"name\":\"bridge\",\"image\":\"123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/ecr-public/basemachina/bridge:latest\",\"portMappings\":[{\"containerPort\":8080,\"protocol\":\"tcp\"}],\"environment\":[{\"name\":\"FETCH_INTERVAL\",\"value\":\"1h\"},{\"name\":\"FETCH_TIMEOUT\",\"value\":\"10s\"},{\"name\":\"PORT\",\"value\":\"8080\"},{\"name\":\"TENANT_ID\",\"value\":\"tenant-aws\"}]}]"
+      }}
+    ]
+  }]}},
+  "configuration": {"root_module": {"module_calls": {"basemachina_bridge": {
+    "source": "../../modules/aws/ecs-fargate",
+    "module": {"variables": {
+      "bridge_image_tag": {"default": "latest"},
+      "fetch_interval": {"default": "1h"},
+      "fetch_timeout": {"default": "10s"},
+      "port": {"default": 8080},
+      "tenant_id": {"sensitive": true}
+    }}
+  }}}}
+}`
+
+const cloudRunPlan = `{
+  "planned_values": {"root_module": {"child_modules": [{
+    "address": "module.basemachina_bridge",
+    "resources": [
+      {"address": "module.basemachina_bridge.google_cloud_run_v2_service.bridge", "mode": "managed", "type": "google_cloud_run_v2_service", "name": "bridge", "values": {
+        "template": [{"containers": [{
+          "image": "gcr.io/basemachina/bridge:latest",
+          "env": [
+            {"name": "FETCH_INTERVAL", "value": "1h", "value_source": []},
+            {"name": "FETCH_TIMEOUT", "value": "30s", "value_source": []},
+            {"name": "TENANT_ID", "value": "tenant-gcp", "value_source": []}
+          ],
+          "ports": [{"container_port": 8080, "name": "http1"}]
+        }]}]
+      }}
+    ]
+  }]}},
+  "configuration": {"root_module": {"module_calls": {"basemachina_bridge": {
+    "source": "../../modules/gcp/cloud-run",
+    "module": {"variables": {
+      "bridge_image_tag": {"default": "latest"},
+      "fetch_interval": {"default": "1h"},
+      "fetch_timeout": {"default": "30s"},
+      "port": {"default": 8080},
+      "tenant_id": {"sensitive": false}
+    }}
+  }}}}
+}`
+
+func parse(t *testing.T, data string) *tfplan.Plan {
+	t.Helper()
+	plan, err := tfplan.Parse([]byte(data))
+	require.NoError(t, err)
+	return plan
+}
+
+func TestParseImage(t *testing.T) {
+	assert.Equal(t, Image{Registry: "public.ecr.aws", Repository: "basemachina/bridge", Tag: "v1.2.3"},
+		ParseImage("123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/ecr-public/basemachina/bridge:v1.2.3"))
+	assert.Equal(t, Image{Registry: "gcr.io", Repository: "basemachina/bridge", Tag: "latest"},
+		ParseImage("gcr.io/basemachina/bridge:latest"))
+	assert.Equal(t, Image{Registry: "localhost:5000", Repository: "bridge"}, ParseImage("localhost:5000/bridge"))
+}
+
+func TestFromECS(t *testing.T) {
+	b, err := FromECS(parse(t, ecsPlan))
+	require.NoError(t, err)
+
+	assert.Equal(t, "module.basemachina_bridge.aws_ecs_task_definition.bridge", b.Address)
+	assert.Equal(t, "public.ecr.aws/basemachina/bridge:latest", b.Image.String())
+	assert.Equal(t, 8080, b.Port)
+	assert.Equal(t, map[string]string{"FETCH_INTERVAL": "1h", "FETCH_TIMEOUT": "10s", "PORT": "8080", "TENANT_ID": "tenant-aws"}, b.Env)
+	assert.Empty(t, b.PlatformEnv)
+	assert.Equal(t, Variable{Declared: true, Required: true, Sensitive: true}, b.Variables["tenant_id"])
+	assert.Equal(t, Variable{Declared: true, Default: float64(8080)}, b.Variables["port"])
+}
+
+func TestFromCloudRun(t *testing.T) {
+	b, err := FromCloudRun(parse(t, cloudRunPlan))
+	require.NoError(t, err)
+
+	assert.Equal(t, "gcr.io/basemachina/bridge:latest", b.Image.String())
+	assert.Equal(t, "8080", b.Env["PORT"], "Cloud Run sets PORT to the container port")
+	assert.Equal(t, []string{"PORT"}, b.PlatformEnv)
+}
+
+func TestFromPlanWithoutResource(t *testing.T) {
+	_, err := FromECS(parse(t, cloudRunPlan))
+	assert.ErrorContains(t, err, "plan has no aws_ecs_task_definition")
+	_, err = FromCloudRun(parse(t, ecsPlan))
+	assert.ErrorContains(t, err, "plan has no google_cloud_run_v2_service")
+}
+
+func TestCompare(t *testing.T) {
+	aws, err := FromECS(parse(t, ecsPlan))
+	require.NoError(t, err)
+	gcp, err := FromCloudRun(parse(t, cloudRunPlan))
+	require.NoError(t, err)
+
+	gaps := Compare(aws, gcp)
+	var got []string
+	for _, g := range gaps {
+		got = append(got, g.String())
+	}
+	assert.Equal(t, []string{
+		"env.FETCH_TIMEOUT: aws=10s gcp=30s",
+		"env.TENANT_ID: aws=(sensitive) gcp=(sensitive, different value)",
+		"image.registry: aws=public.ecr.aws gcp=gcr.io",
+		`variable.fetch_timeout.default: aws="10s" gcp="30s"`,
+		"variable.tenant_id.sensitive: aws=true gcp=false",
+	}, got)
+	for _, g := range gaps {
+		assert.NotContains(t, g.AWS+g.GCP, "tenant-", "sensitive values must not be reported")
+	}
+
+	assert.Equal(t, []string{"env.FETCH_TIMEOUT", "env.TENANT_ID", `variable.fetch_timeout.default`, "variable.tenant_id.sensitive"},
+		keys(Unaccepted(gaps, []string{"image.registry"})))
+}
+
+func TestRedacted(t *testing.T) {
+	aws, err := FromECS(parse(t, ecsPlan))
+	require.NoError(t, err)
+	gcp, err := FromCloudRun(parse(t, cloudRunPlan))
+	require.NoError(t, err)
+
+	r := Redacted(gcp, aws)
+	assert.Equal(t, "(sensitive)", r.Env["TENANT_ID"], "tenant_id is sensitive in the AWS module")
+	assert.Equal(t, "1h", r.Env["FETCH_INTERVAL"])
+	assert.Equal(t, "tenant-gcp", gcp.Env["TENANT_ID"], "the original is not modified")
+}
+
+func TestComparePlatformPort(t *testing.T) {
+	aws, err := FromECS(parse(t, ecsPlan))
+	require.NoError(t, err)
+	gcp, err := FromCloudRun(parse(t, cloudRunPlan))
+	require.NoError(t, err)
+	gcp.Port = 9090
+	gcp.Env["PORT"] = "9090"
+
+	gaps := Compare(aws, gcp)
+	assert.Contains(t, gaps, Gap{Key: "env.PORT", AWS: "8080", GCP: "9090 (platform)"})
+	assert.Contains(t, gaps, Gap{Key: "port", AWS: "8080", GCP: "9090"})
+}
+
+func keys(gaps []Gap) []string {
+	var out []string
+	for _, g := range gaps {
+		out = append(out, g.Key)
+	}
+	return out
+}
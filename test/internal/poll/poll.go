@@ -0,0 +1,209 @@
+// Package poll retries a condition with exponential backoff and jitter until
+// it succeeds, the context is cancelled, a timeout expires, or the condition
+// returns a permanent error.
+//
+// On failure the returned *Error wraps the last error returned by the
+// condition together with the number of attempts, so test logs show why
+// polling gave up instead of a bare "timed out".
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backoff describes the wait between attempts.
+type Backoff struct {
+	// Initial is the wait after the first failed attempt.
+	Initial time.Duration
+	// Max caps a single wait (0 means no cap).
+	Max time.Duration
+	// Multiplier grows the wait after each failed attempt. Values below 1
+	// are treated as 1 (constant interval).
+	Multiplier float64
+	// Jitter randomizes each wait by up to ±Jitter of its value, e.g. 0.2
+	// for ±20%. 0 disables jitter.
+	Jitter float64
+}
+
+// Delay returns the wait after the given failed attempt (1-based) without
+// jitter.
+func (b Backoff) Delay(attempt int) time.Duration {
+	mult := b.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		d *= mult
+		if b.Max > 0 && d >= float64(b.Max) {
+			return b.Max
+		}
+	}
+	delay := time.Duration(d)
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// Options configures Until.
+type Options struct {
+	// Timeout bounds the total polling time (0 means only ctx bounds it).
+	Timeout time.Duration
+	// MaxAttempts bounds the number of attempts (0 means unlimited).
+	MaxAttempts int
+	Backoff     Backoff
+
+	// Clock defaults to the real clock.
+	Clock Clock
+	// Rand returns a number in [0, 1) used for jitter. Defaults to math/rand.
+	Rand func() float64
+	// Logf receives a message after every failed attempt. Defaults to a no-op.
+	Logf func(format string, args ...any)
+}
+
+// Error is returned by Until when polling gives up.
+type Error struct {
+	// Attempts is the number of times the condition was called.
+	Attempts int
+	// Elapsed is the time spent polling.
+	Elapsed time.Duration
+	// Last is the last error returned by the condition. It may be nil if
+	// the context was cancelled before the first attempt.
+	Last error
+	// Reason is why polling stopped: the context error, ErrTimeout,
+	// ErrMaxAttempts, or nil when Last is permanent.
+	Reason error
+}
+
+var (
+	// ErrTimeout is the Reason when Options.Timeout expires.
+	ErrTimeout = errors.New("timed out")
+	// ErrMaxAttempts is the Reason when Options.MaxAttempts is reached.
+	ErrMaxAttempts = errors.New("max attempts reached")
+)
+
+func (e *Error) Error() string {
+	reason := "permanent error"
+	if e.Reason != nil {
+		reason = e.Reason.Error()
+	}
+	if e.Last == nil {
+		return fmt.Sprintf("poll: %s after %d attempt(s) in %v", reason, e.Attempts, e.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("poll: %s after %d attempt(s) in %v: last error: %v", reason, e.Attempts, e.Elapsed.Round(time.Millisecond), e.Last)
+}
+
+// Unwrap exposes both the last condition error and the stop reason, so
+// errors.Is works for either (e.g. context.DeadlineExceeded).
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Last != nil {
+		errs = append(errs, e.Last)
+	}
+	if e.Reason != nil {
+		errs = append(errs, e.Reason)
+	}
+	return errs
+}
+
+type permanentError struct{ err error }
+
+func (p *permanentError) Error() string { return p.err.Error() }
+func (p *permanentError) Unwrap() error { return p.err }
+
+// Permanent marks err so that Until stops retrying immediately.
+// Permanent(nil) returns nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Until calls cond until it returns nil. It returns nil on success and an
+// *Error otherwise.
+func Until(ctx context.Context, opts Options, cond func(ctx context.Context) error) error {
+	clock := opts.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	random := opts.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+
+	start := clock.Now()
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = start.Add(opts.Timeout)
+	}
+	fail := func(attempts int, last, reason error) error {
+		var p *permanentError
+		if errors.As(last, &p) {
+			last = p.err
+		}
+		return &Error{Attempts: attempts, Elapsed: clock.Now().Sub(start), Last: last, Reason: reason}
+	}
+
+	var last error
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fail(attempt-1, last, err)
+		}
+
+		last = cond(ctx)
+		if last == nil {
+			return nil
+		}
+		if IsPermanent(last) {
+			return fail(attempt, last, nil)
+		}
+		if opts.MaxAttempts > 0 && attempt >= opts.MaxAttempts {
+			return fail(attempt, last, ErrMaxAttempts)
+		}
+
+		wait := jitter(opts.Backoff.Delay(attempt), opts.Backoff.Jitter, random)
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(clock.Now())
+			if remaining <= 0 {
+				return fail(attempt, last, ErrTimeout)
+			}
+			if wait > remaining {
+				wait = remaining
+			}
+		}
+		logf("Attempt %d failed: %v. Retrying in %v...", attempt, last, wait.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return fail(attempt, last, ctx.Err())
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// jitter spreads d uniformly over [d*(1-f), d*(1+f)).
+func jitter(d time.Duration, f float64, random func() float64) time.Duration {
+	if f <= 0 || d <= 0 {
+		return d
+	}
+	if f > 1 {
+		f = 1
+	}
+	return time.Duration(float64(d) * (1 - f + 2*f*random()))
+}
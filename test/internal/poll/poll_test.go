@@ -0,0 +1,184 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// failN returns a condition that fails n times and then succeeds.
+func failN(n int, calls *int) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= n {
+			return fmt.Errorf("not ready (call %d)", *calls)
+		}
+		return nil
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, b.Delay(1))
+	assert.Equal(t, 2*time.Second, b.Delay(2))
+	assert.Equal(t, 8*time.Second, b.Delay(4))
+	assert.Equal(t, 10*time.Second, b.Delay(5))
+	assert.Equal(t, 10*time.Second, b.Delay(100))
+
+	constant := Backoff{Initial: 5 * time.Second}
+	assert.Equal(t, 5*time.Second, constant.Delay(7))
+}
+
+func TestUntilSucceedsWithExponentialBackoff(t *testing.T) {
+	clock := NewFakeClock(start)
+	calls := 0
+
+	err := Until(context.Background(), Options{
+		Timeout: time.Minute,
+		Backoff: Backoff{Initial: time.Second, Multiplier: 2},
+		Clock:   clock,
+	}, failN(3, &calls))
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.Waits())
+}
+
+func TestUntilAppliesJitter(t *testing.T) {
+	clock := NewFakeClock(start)
+	calls := 0
+	randoms := []float64{0, 0.5, 0.999}
+
+	err := Until(context.Background(), Options{
+		Backoff: Backoff{Initial: 10 * time.Second, Jitter: 0.2},
+		Clock:   clock,
+		Rand: func() float64 {
+			r := randoms[0]
+			randoms = randoms[1:]
+			return r
+		},
+	}, failN(3, &calls))
+
+	require.NoError(t, err)
+	waits := clock.Waits()
+	require.Len(t, waits, 3)
+	assert.Equal(t, 8*time.Second, waits[0])
+	assert.Equal(t, 10*time.Second, waits[1])
+	assert.InDelta(t, float64(12*time.Second), float64(waits[2]), float64(10*time.Millisecond))
+}
+
+func TestUntilTimeoutWrapsLastError(t *testing.T) {
+	clock := NewFakeClock(start)
+	sentinel := errors.New("connection refused")
+	calls := 0
+
+	err := Until(context.Background(), Options{
+		Timeout: 25 * time.Second,
+		Backoff: Backoff{Initial: 10 * time.Second},
+		Clock:   clock,
+	}, func(context.Context) error {
+		calls++
+		return fmt.Errorf("GET /ok: %w", sentinel)
+	})
+
+	var pollErr *Error
+	require.ErrorAs(t, err, &pollErr)
+	assert.ErrorIs(t, err, sentinel)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Equal(t, 4, pollErr.Attempts)
+	assert.Equal(t, 25*time.Second, pollErr.Elapsed)
+	// The last wait is clamped to the remaining time.
+	assert.Equal(t, []time.Duration{10 * time.Second, 10 * time.Second, 5 * time.Second}, clock.Waits())
+	assert.Equal(t, "poll: timed out after 4 attempt(s) in 25s: last error: GET /ok: connection refused", err.Error())
+}
+
+func TestUntilMaxAttempts(t *testing.T) {
+	calls := 0
+	err := Until(context.Background(), Options{
+		MaxAttempts: 2,
+		Backoff:     Backoff{Initial: time.Second},
+		Clock:       NewFakeClock(start),
+	}, failN(5, &calls))
+
+	assert.ErrorIs(t, err, ErrMaxAttempts)
+	assert.Equal(t, 2, calls)
+}
+
+func TestUntilPermanentStopsImmediately(t *testing.T) {
+	clock := NewFakeClock(start)
+	denied := errors.New("403 forbidden")
+	calls := 0
+
+	err := Until(context.Background(), Options{
+		Timeout: time.Hour,
+		Backoff: Backoff{Initial: time.Second},
+		Clock:   clock,
+	}, func(context.Context) error {
+		calls++
+		return Permanent(denied)
+	})
+
+	var pollErr *Error
+	require.ErrorAs(t, err, &pollErr)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.Waits())
+	assert.Equal(t, denied, pollErr.Last, "Last should be unwrapped from Permanent")
+	assert.Nil(t, pollErr.Reason)
+	assert.ErrorIs(t, err, denied)
+	assert.Nil(t, Permanent(nil))
+}
+
+func TestUntilContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := Until(ctx, Options{
+		Backoff: Backoff{Initial: time.Second},
+		Clock:   NewFakeClock(start),
+	}, func(context.Context) error {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return errors.New("not ready")
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 2, calls)
+	assert.Contains(t, err.Error(), "last error: not ready")
+}
+
+func TestUntilContextAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Until(ctx, Options{Backoff: Backoff{Initial: time.Hour}}, func(context.Context) error {
+		return errors.New("never called")
+	})
+
+	var pollErr *Error
+	require.ErrorAs(t, err, &pollErr)
+	assert.Equal(t, 0, pollErr.Attempts)
+	assert.Nil(t, pollErr.Last)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUntilRealClockWaitRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	err := Until(ctx, Options{Backoff: Backoff{Initial: time.Hour}}, func(context.Context) error {
+		return errors.New("not ready")
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(began), time.Second)
+}
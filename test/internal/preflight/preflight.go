@@ -0,0 +1,425 @@
+// Package preflight checks the existing network the AWS ECS Fargate module
+// is about to be applied to, so that a subnet in the wrong VPC or a
+// resource the module conflicts with fails in seconds instead of late in
+// apply.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/reach"
+)
+
+// PullThroughCachePrefix is the repository prefix of the module's
+// aws_ecr_pull_through_cache_rule. There is one rule per prefix in a
+// registry, so an existing rule makes apply fail.
+const PullThroughCachePrefix = "ecr-public"
+
+// NATGatewaysPerAZQuota is the Service Quotas code of "NAT gateways per
+// Availability Zone".
+const NATGatewaysPerAZQuota = "L-FE5A380F"
+
+// vpcMaxElasticIPs is the account attribute with the VPC Elastic IP quota.
+const vpcMaxElasticIPs = "vpc-max-elastic-ips"
+
+// Check names, in the order they are run.
+const (
+	CheckVPC                  = "vpc_id"
+	CheckPrivateSubnets       = "private_subnet_ids"
+	CheckPublicSubnets        = "public_subnet_ids"
+	CheckPublicRoutes         = "public_subnet_routes"
+	CheckS3Endpoint           = "s3_gateway_endpoint"
+	CheckPullThroughCacheRule = "pull_through_cache_rule"
+	CheckNATGateway           = "nat_gateway_id"
+	CheckElasticIPQuota       = "elastic_ip_quota"
+	CheckNATGatewayQuota      = "nat_gateway_quota"
+)
+
+// Input is the network given to the module: its vpc_id,
+// private_subnet_ids, public_subnet_ids and nat_gateway_id variables.
+type Input struct {
+	VPCID            string
+	PrivateSubnetIDs []string
+	PublicSubnetIDs  []string
+	// NATGatewayID is empty when the module creates the NAT gateway and
+	// its Elastic IP.
+	NATGatewayID string
+}
+
+// Checker reads the account and region the module is applied to.
+type Checker struct {
+	EC2           ec2iface.EC2API
+	ECR           ecriface.ECRAPI
+	ServiceQuotas servicequotasiface.ServiceQuotasAPI
+}
+
+// Check is the outcome of one check.
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+func (c Check) String() string {
+	mark := "✓"
+	if !c.OK {
+		mark = "✗"
+	}
+	return fmt.Sprintf("%s %s: %s", mark, c.Name, c.Detail)
+}
+
+// Result lists the checks made. Checks that depend on a failed one, such
+// as the subnet checks on vpc_id, are left out.
+type Result struct {
+	Checks []Check
+}
+
+// Err joins the failed checks, or is nil when all passed.
+func (r *Result) Err() error {
+	var errs []error
+	for _, c := range r.Checks {
+		if !c.OK {
+			errs = append(errs, fmt.Errorf("%s: %s", c.Name, c.Detail))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Failed reports whether the check with the given name was made and failed.
+func (r *Result) Failed(name string) bool {
+	for _, c := range r.Checks {
+		if c.Name == name && !c.OK {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Result) pass(name, format string, args ...any) {
+	r.Checks = append(r.Checks, Check{Name: name, OK: true, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *Result) fail(name, format string, args ...any) {
+	r.Checks = append(r.Checks, Check{Name: name, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Run makes the checks. The error is for API calls that failed; problems
+// with the network are failed checks in the result.
+func (c *Checker) Run(ctx context.Context, in Input) (*Result, error) {
+	r := &Result{}
+	n, err := reach.FromEC2(ctx, c.EC2, in.VPCID)
+	if code(err) == "InvalidVpcID.NotFound" || err == nil && len(n.CIDRs) == 0 {
+		r.fail(CheckVPC, "VPC %s does not exist in this account and region", in.VPCID)
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.pass(CheckVPC, "VPC %s (%s)", in.VPCID, joinPrefixes(n))
+
+	privateOK, err := c.subnets(ctx, r, n, CheckPrivateSubnets, in.PrivateSubnetIDs)
+	if err != nil {
+		return nil, err
+	}
+	publicOK, err := c.subnets(ctx, r, n, CheckPublicSubnets, in.PublicSubnetIDs)
+	if err != nil {
+		return nil, err
+	}
+	if publicOK {
+		publicRoutes(r, n, in.PublicSubnetIDs)
+	}
+	if privateOK {
+		s3Endpoints(r, n, in.PrivateSubnetIDs)
+	}
+	if err := c.pullThroughCacheRule(ctx, r); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case in.NATGatewayID != "":
+		natGateway(r, n, in.NATGatewayID)
+	case publicOK:
+		if err := c.elasticIPQuota(ctx, r); err != nil {
+			return nil, err
+		}
+		// The module puts the NAT gateway in the first public subnet
+		if err := c.natGatewayQuota(ctx, r, n.Subnets[in.PublicSubnetIDs[0]].AvailabilityZone); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// subnets checks that the subnets are in the VPC and span two
+// availability zones, as the ALB and the ECS service need.
+func (c *Checker) subnets(ctx context.Context, r *Result, n *reach.Network, name string, ids []string) (bool, error) {
+	if len(ids) == 0 {
+		r.fail(name, "no subnets given")
+		return false, nil
+	}
+	var outside []string
+	for _, id := range ids {
+		if _, ok := n.Subnets[id]; !ok {
+			outside = append(outside, id)
+		}
+	}
+	if len(outside) > 0 {
+		var problems []string
+		for _, id := range outside {
+			out, err := c.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(id)}})
+			switch {
+			case code(err) == "InvalidSubnetID.NotFound" || err == nil && len(out.Subnets) == 0:
+				problems = append(problems, fmt.Sprintf("%s does not exist", id))
+			case err != nil:
+				return false, fmt.Errorf("describe subnet %s: %w", id, err)
+			default:
+				problems = append(problems, fmt.Sprintf("%s is in %s, not %s", id, aws.StringValue(out.Subnets[0].VpcId), n.VPCID))
+			}
+		}
+		r.fail(name, "%s", strings.Join(problems, "; "))
+		return false, nil
+	}
+
+	zones := map[string]bool{}
+	for _, id := range ids {
+		zones[n.Subnets[id].AvailabilityZone] = true
+	}
+	names := sortedKeys(zones)
+	if len(names) < 2 {
+		r.fail(name, "all subnets are in %s; at least two availability zones are needed", names[0])
+		return false, nil
+	}
+	r.pass(name, "%d subnets in %s across %s", len(ids), n.VPCID, strings.Join(names, ", "))
+	return true, nil
+}
+
+// publicRoutes checks that the public subnets, where the ALB and the NAT
+// gateway are placed, route to an internet gateway.
+func publicRoutes(r *Result, n *reach.Network, ids []string) {
+	var problems []string
+	for _, id := range ids {
+		// Subnets without an association use the main route table
+		rtID := n.Subnets[id].RouteTableID
+		if rtID == "" {
+			rtID = n.MainRouteTableID
+		}
+		rt, ok := n.RouteTables[rtID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no route table", id))
+			continue
+		}
+		var def *reach.Route
+		for i, route := range rt.Routes {
+			if route.Destination.String() == "0.0.0.0/0" {
+				def = &rt.Routes[i]
+			}
+		}
+		switch {
+		case def == nil:
+			problems = append(problems, fmt.Sprintf("%s (%s) has no default route (0.0.0.0/0)", id, rt.ID))
+		case !strings.HasPrefix(def.Target, "igw-"):
+			problems = append(problems, fmt.Sprintf("%s (%s) routes 0.0.0.0/0 to %s, not an internet gateway", id, rt.ID, def.Target))
+		case def.Blackhole:
+			problems = append(problems, fmt.Sprintf("%s (%s) routes 0.0.0.0/0 to %s, which no longer exists", id, rt.ID, def.Target))
+		}
+	}
+	if len(problems) > 0 {
+		r.fail(CheckPublicRoutes, "%s", strings.Join(problems, "; "))
+		return
+	}
+	r.pass(CheckPublicRoutes, "every public subnet routes 0.0.0.0/0 to an internet gateway")
+}
+
+// s3Endpoints checks that no S3 gateway endpoint is attached to the
+// private subnets' route tables: a route table takes one route per prefix
+// list, so the module's endpoint could not be attached.
+func s3Endpoints(r *Result, n *reach.Network, privateSubnetIDs []string) {
+	routeTables := map[string]bool{}
+	for _, id := range privateSubnetIDs {
+		rtID := n.Subnets[id].RouteTableID
+		if rtID == "" {
+			rtID = n.MainRouteTableID
+		}
+		routeTables[rtID] = true
+	}
+	var conflicts []string
+	for _, ep := range n.Endpoints {
+		if ep.Type != reach.EndpointGateway || !ep.Service("s3") || ep.Gone() {
+			continue
+		}
+		var attached []string
+		for _, id := range ep.RouteTableIDs {
+			if routeTables[id] {
+				attached = append(attached, id)
+			}
+		}
+		if len(attached) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s is attached to %s", ep.ID, strings.Join(attached, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		r.fail(CheckS3Endpoint, "%s; detach or delete it, the module creates its own", strings.Join(conflicts, "; "))
+		return
+	}
+	r.pass(CheckS3Endpoint, "no S3 gateway endpoint on the private subnets' route tables")
+}
+
+func (c *Checker) pullThroughCacheRule(ctx context.Context, r *Result) error {
+	out, err := c.ECR.DescribePullThroughCacheRulesWithContext(ctx, &ecr.DescribePullThroughCacheRulesInput{
+		EcrRepositoryPrefixes: []*string{aws.String(PullThroughCachePrefix)},
+	})
+	if code(err) == ecr.ErrCodePullThroughCacheRuleNotFoundException || err == nil && len(out.PullThroughCacheRules) == 0 {
+		r.pass(CheckPullThroughCacheRule, "no pull-through cache rule for %s", PullThroughCachePrefix)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("describe pull-through cache rules: %w", err)
+	}
+	rule := out.PullThroughCacheRules[0]
+	r.fail(CheckPullThroughCacheRule, "a pull-through cache rule for %s already exists (upstream %s, created %s); delete it or import it into the module",
+		PullThroughCachePrefix, aws.StringValue(rule.UpstreamRegistryUrl), aws.TimeValue(rule.CreatedAt).Format("2006-01-02"))
+	return nil
+}
+
+// natGateway checks the existing NAT gateway given to the module.
+func natGateway(r *Result, n *reach.Network, id string) {
+	nat, ok := n.NATGateways[id]
+	switch {
+	case !ok:
+		r.fail(CheckNATGateway, "NAT gateway %s is not in %s", id, n.VPCID)
+	case nat.State != ec2.NatGatewayStateAvailable:
+		r.fail(CheckNATGateway, "NAT gateway %s is %s, not available", id, nat.State)
+	default:
+		r.pass(CheckNATGateway, "NAT gateway %s is available in %s", id, nat.SubnetID)
+	}
+}
+
+// elasticIPQuota checks that one more VPC Elastic IP can be allocated for
+// the NAT gateway.
+func (c *Checker) elasticIPQuota(ctx context.Context, r *Result) error {
+	attrs, err := c.EC2.DescribeAccountAttributesWithContext(ctx, &ec2.DescribeAccountAttributesInput{
+		AttributeNames: []*string{aws.String(vpcMaxElasticIPs)},
+	})
+	if err != nil {
+		return fmt.Errorf("describe account attributes: %w", err)
+	}
+	limit := -1
+	for _, a := range attrs.AccountAttributes {
+		for _, v := range a.AttributeValues {
+			if l, err := strconv.Atoi(aws.StringValue(v.AttributeValue)); err == nil {
+				limit = l
+			}
+		}
+	}
+	if limit < 0 {
+		return fmt.Errorf("account attribute %s not returned", vpcMaxElasticIPs)
+	}
+	addrs, err := c.EC2.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("domain"), Values: []*string{aws.String(ec2.DomainTypeVpc)}}},
+	})
+	if err != nil {
+		return fmt.Errorf("describe addresses: %w", err)
+	}
+	if used := len(addrs.Addresses); used >= limit {
+		r.fail(CheckElasticIPQuota, "%d of %d Elastic IPs are allocated; release one or request a quota increase", used, limit)
+	} else {
+		r.pass(CheckElasticIPQuota, "%d of %d Elastic IPs are allocated", used, limit)
+	}
+	return nil
+}
+
+// natGatewayQuota checks that one more NAT gateway can be created in the
+// availability zone.
+func (c *Checker) natGatewayQuota(ctx context.Context, r *Result, zone string) error {
+	in := &servicequotas.GetServiceQuotaInput{ServiceCode: aws.String("vpc"), QuotaCode: aws.String(NATGatewaysPerAZQuota)}
+	var limit float64
+	out, err := c.ServiceQuotas.GetServiceQuotaWithContext(ctx, in)
+	switch {
+	case code(err) == servicequotas.ErrCodeNoSuchResourceException:
+		// Quotas that were never changed only have the default value
+		def, err := c.ServiceQuotas.GetAWSDefaultServiceQuotaWithContext(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+			ServiceCode: in.ServiceCode, QuotaCode: in.QuotaCode,
+		})
+		if err != nil {
+			return fmt.Errorf("get default quota %s: %w", NATGatewaysPerAZQuota, err)
+		}
+		limit = aws.Float64Value(def.Quota.Value)
+	case err != nil:
+		return fmt.Errorf("get quota %s: %w", NATGatewaysPerAZQuota, err)
+	default:
+		limit = aws.Float64Value(out.Quota.Value)
+	}
+
+	// The quota counts the NAT gateways of every VPC in the zone
+	inZone := map[string]bool{}
+	err = c.EC2.DescribeSubnetsPagesWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("availability-zone"), Values: []*string{aws.String(zone)}}},
+	}, func(out *ec2.DescribeSubnetsOutput, _ bool) bool {
+		for _, s := range out.Subnets {
+			inZone[aws.StringValue(s.SubnetId)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("describe subnets in %s: %w", zone, err)
+	}
+	used := 0
+	err = c.EC2.DescribeNatGatewaysPagesWithContext(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable})}},
+	}, func(out *ec2.DescribeNatGatewaysOutput, _ bool) bool {
+		for _, g := range out.NatGateways {
+			if inZone[aws.StringValue(g.SubnetId)] {
+				used++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("describe NAT gateways: %w", err)
+	}
+	if float64(used) >= limit {
+		r.fail(CheckNATGatewayQuota, "%d of %v NAT gateways exist in %s; delete one or request a quota increase", used, limit, zone)
+	} else {
+		r.pass(CheckNATGatewayQuota, "%d of %v NAT gateways exist in %s", used, limit, zone)
+	}
+	return nil
+}
+
+// code is the AWS error code of err, or "".
+func code(err error) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func joinPrefixes(n *reach.Network) string {
+	var cidrs []string
+	for _, p := range n.CIDRs {
+		cidrs = append(cidrs, p.String())
+	}
+	return strings.Join(cidrs, ", ")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
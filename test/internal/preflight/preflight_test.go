@@ -0,0 +1,400 @@
+package preflight
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEC2 serves an account with the test VPC and another VPC, filtering
+// by the filters preflight and reach.FromEC2 use.
+type fakeEC2 struct {
+	ec2iface.EC2API
+	vpcs        map[string]string
+	subnets     []*ec2.Subnet
+	routeTables []*ec2.RouteTable
+	nats        []*ec2.NatGateway
+	endpoints   []*ec2.VpcEndpoint
+	addresses   int
+	maxEIPs     string
+}
+
+func newFakeEC2() *fakeEC2 {
+	subnet := func(id, vpc, zone, cidr string) *ec2.Subnet {
+		return &ec2.Subnet{SubnetId: aws.String(id), VpcId: aws.String(vpc), AvailabilityZone: aws.String(zone), CidrBlock: aws.String(cidr)}
+	}
+	return &fakeEC2{
+		vpcs: map[string]string{"vpc-1": "10.0.0.0/16", "vpc-other": "10.1.0.0/16"},
+		subnets: []*ec2.Subnet{
+			subnet("subnet-private-a", "vpc-1", "ap-northeast-1a", "10.0.10.0/24"),
+			subnet("subnet-private-c", "vpc-1", "ap-northeast-1c", "10.0.11.0/24"),
+			subnet("subnet-public-a", "vpc-1", "ap-northeast-1a", "10.0.0.0/24"),
+			subnet("subnet-public-c", "vpc-1", "ap-northeast-1c", "10.0.1.0/24"),
+			subnet("subnet-other-a", "vpc-other", "ap-northeast-1a", "10.1.0.0/24"),
+		},
+		routeTables: []*ec2.RouteTable{
+			{
+				RouteTableId: aws.String("rtb-private"),
+				VpcId:        aws.String("vpc-1"),
+				Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-private-a")}, {SubnetId: aws.String("subnet-private-c")}},
+				Routes:       []*ec2.Route{{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: aws.String("active")}},
+			},
+			{
+				RouteTableId: aws.String("rtb-public"),
+				VpcId:        aws.String("vpc-1"),
+				Associations: []*ec2.RouteTableAssociation{{Main: aws.Bool(true)}},
+				Routes: []*ec2.Route{
+					{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: aws.String("active")},
+					{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1"), State: aws.String("active")},
+				},
+			},
+		},
+		nats: []*ec2.NatGateway{
+			{NatGatewayId: aws.String("nat-other"), VpcId: aws.String("vpc-other"), SubnetId: aws.String("subnet-other-a"), State: aws.String("available")},
+		},
+		addresses: 2,
+		maxEIPs:   "5",
+	}
+}
+
+// matches reports whether value passes the filter called name, if any.
+func matches(filters []*ec2.Filter, name, value string) bool {
+	for _, f := range filters {
+		if aws.StringValue(f.Name) != name {
+			continue
+		}
+		for _, v := range f.Values {
+			if aws.StringValue(v) == value {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (f *fakeEC2) DescribeVpcsWithContext(_ aws.Context, in *ec2.DescribeVpcsInput, _ ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	id := aws.StringValue(in.VpcIds[0])
+	cidr, ok := f.vpcs[id]
+	if !ok {
+		return nil, awserr.New("InvalidVpcID.NotFound", "The vpc ID '"+id+"' does not exist", nil)
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{VpcId: aws.String(id), CidrBlock: aws.String(cidr)}}}, nil
+}
+
+func (f *fakeEC2) DescribeSubnetsWithContext(_ aws.Context, in *ec2.DescribeSubnetsInput, _ ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	id := aws.StringValue(in.SubnetIds[0])
+	for _, s := range f.subnets {
+		if aws.StringValue(s.SubnetId) == id {
+			return &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{s}}, nil
+		}
+	}
+	return nil, awserr.New("InvalidSubnetID.NotFound", "The subnet ID '"+id+"' does not exist", nil)
+}
+
+func (f *fakeEC2) DescribeSubnetsPagesWithContext(_ aws.Context, in *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool, _ ...request.Option) error {
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, s := range f.subnets {
+		if matches(in.Filters, "vpc-id", aws.StringValue(s.VpcId)) && matches(in.Filters, "availability-zone", aws.StringValue(s.AvailabilityZone)) {
+			out.Subnets = append(out.Subnets, s)
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeRouteTablesPagesWithContext(_ aws.Context, in *ec2.DescribeRouteTablesInput, fn func(*ec2.DescribeRouteTablesOutput, bool) bool, _ ...request.Option) error {
+	out := &ec2.DescribeRouteTablesOutput{}
+	for _, rt := range f.routeTables {
+		if matches(in.Filters, "vpc-id", aws.StringValue(rt.VpcId)) {
+			out.RouteTables = append(out.RouteTables, rt)
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeSecurityGroupsPagesWithContext(_ aws.Context, _ *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeSecurityGroupsOutput{}, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeNetworkAclsPagesWithContext(_ aws.Context, _ *ec2.DescribeNetworkAclsInput, fn func(*ec2.DescribeNetworkAclsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeNetworkAclsOutput{}, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeNatGatewaysPagesWithContext(_ aws.Context, in *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, _ ...request.Option) error {
+	out := &ec2.DescribeNatGatewaysOutput{}
+	for _, g := range f.nats {
+		if matches(in.Filter, "vpc-id", aws.StringValue(g.VpcId)) && matches(in.Filter, "state", aws.StringValue(g.State)) {
+			out.NatGateways = append(out.NatGateways, g)
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeVpcEndpointsPagesWithContext(_ aws.Context, in *ec2.DescribeVpcEndpointsInput, fn func(*ec2.DescribeVpcEndpointsOutput, bool) bool, _ ...request.Option) error {
+	out := &ec2.DescribeVpcEndpointsOutput{}
+	for _, e := range f.endpoints {
+		if matches(in.Filters, "vpc-id", aws.StringValue(e.VpcId)) {
+			out.VpcEndpoints = append(out.VpcEndpoints, e)
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeAccountAttributesWithContext(aws.Context, *ec2.DescribeAccountAttributesInput, ...request.Option) (*ec2.DescribeAccountAttributesOutput, error) {
+	return &ec2.DescribeAccountAttributesOutput{AccountAttributes: []*ec2.AccountAttribute{{
+		AttributeName:   aws.String(vpcMaxElasticIPs),
+		AttributeValues: []*ec2.AccountAttributeValue{{AttributeValue: aws.String(f.maxEIPs)}},
+	}}}, nil
+}
+
+func (f *fakeEC2) DescribeAddressesWithContext(aws.Context, *ec2.DescribeAddressesInput, ...request.Option) (*ec2.DescribeAddressesOutput, error) {
+	out := &ec2.DescribeAddressesOutput{}
+	for i := 0; i < f.addresses; i++ {
+		out.Addresses = append(out.Addresses, &ec2.Address{Domain: aws.String(ec2.DomainTypeVpc)})
+	}
+	return out, nil
+}
+
+type fakeECR struct {
+	ecriface.ECRAPI
+	rules []*ecr.PullThroughCacheRule
+}
+
+func (f *fakeECR) DescribePullThroughCacheRulesWithContext(aws.Context, *ecr.DescribePullThroughCacheRulesInput, ...request.Option) (*ecr.DescribePullThroughCacheRulesOutput, error) {
+	if len(f.rules) == 0 {
+		return nil, awserr.New(ecr.ErrCodePullThroughCacheRuleNotFoundException, "not found", nil)
+	}
+	return &ecr.DescribePullThroughCacheRulesOutput{PullThroughCacheRules: f.rules}, nil
+}
+
+// fakeQuotas has the default NAT gateway quota unless applied is set.
+type fakeQuotas struct {
+	servicequotasiface.ServiceQuotasAPI
+	applied *float64
+}
+
+func (f *fakeQuotas) GetServiceQuotaWithContext(aws.Context, *servicequotas.GetServiceQuotaInput, ...request.Option) (*servicequotas.GetServiceQuotaOutput, error) {
+	if f.applied == nil {
+		return nil, awserr.New(servicequotas.ErrCodeNoSuchResourceException, "no applied quota", nil)
+	}
+	return &servicequotas.GetServiceQuotaOutput{Quota: &servicequotas.ServiceQuota{Value: f.applied}}, nil
+}
+
+func (f *fakeQuotas) GetAWSDefaultServiceQuotaWithContext(aws.Context, *servicequotas.GetAWSDefaultServiceQuotaInput, ...request.Option) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+	return &servicequotas.GetAWSDefaultServiceQuotaOutput{Quota: &servicequotas.ServiceQuota{Value: aws.Float64(5)}}, nil
+}
+
+func TestRun(t *testing.T) {
+	input := Input{
+		VPCID:            "vpc-1",
+		PrivateSubnetIDs: []string{"subnet-private-a", "subnet-private-c"},
+		PublicSubnetIDs:  []string{"subnet-public-a", "subnet-public-c"},
+	}
+	allChecks := []string{CheckVPC, CheckPrivateSubnets, CheckPublicSubnets, CheckPublicRoutes, CheckS3Endpoint, CheckPullThroughCacheRule, CheckElasticIPQuota, CheckNATGatewayQuota}
+
+	tests := []struct {
+		name   string
+		modify func(*Input, *fakeEC2, *fakeECR, *fakeQuotas)
+		// checks are the checks made, when not allChecks
+		checks []string
+		// failed are the failed checks and a part of their detail
+		failed map[string]string
+	}{
+		{
+			name:   "ready",
+			modify: func(*Input, *fakeEC2, *fakeECR, *fakeQuotas) {},
+		},
+		{
+			name: "unknown VPC",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				in.VPCID = "vpc-missing"
+			},
+			checks: []string{CheckVPC},
+			failed: map[string]string{CheckVPC: "VPC vpc-missing does not exist"},
+		},
+		{
+			name: "subnets outside the VPC",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				in.PrivateSubnetIDs = []string{"subnet-private-a", "subnet-other-a"}
+				in.PublicSubnetIDs = []string{"subnet-public-a", "subnet-typo"}
+			},
+			checks: []string{CheckVPC, CheckPrivateSubnets, CheckPublicSubnets, CheckPullThroughCacheRule},
+			failed: map[string]string{
+				CheckPrivateSubnets: "subnet-other-a is in vpc-other, not vpc-1",
+				CheckPublicSubnets:  "subnet-typo does not exist",
+			},
+		},
+		{
+			name: "one availability zone",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				in.PrivateSubnetIDs = []string{"subnet-private-a"}
+			},
+			checks: []string{CheckVPC, CheckPrivateSubnets, CheckPublicSubnets, CheckPublicRoutes, CheckPullThroughCacheRule, CheckElasticIPQuota, CheckNATGatewayQuota},
+			failed: map[string]string{CheckPrivateSubnets: "all subnets are in ap-northeast-1a; at least two availability zones are needed"},
+		},
+		{
+			name: "public subnet behind a NAT gateway",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				in.PublicSubnetIDs = []string{"subnet-public-a", "subnet-private-c"}
+			},
+			failed: map[string]string{CheckPublicRoutes: "subnet-private-c (rtb-private) has no default route (0.0.0.0/0)"},
+		},
+		{
+			name: "internet gateway deleted",
+			modify: func(_ *Input, f *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				f.routeTables[1].Routes[1].State = aws.String(ec2.RouteStateBlackhole)
+			},
+			failed: map[string]string{CheckPublicRoutes: "subnet-public-a (rtb-public) routes 0.0.0.0/0 to igw-1, which no longer exists"},
+		},
+		{
+			name: "S3 endpoint on the private route table",
+			modify: func(_ *Input, f *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				f.endpoints = []*ec2.VpcEndpoint{
+					{
+						VpcEndpointId: aws.String("vpce-s3-old"), VpcId: aws.String("vpc-1"), ServiceName: aws.String("com.amazonaws.ap-northeast-1.s3"),
+						VpcEndpointType: aws.String("Gateway"), State: aws.String("Available"), RouteTableIds: aws.StringSlice([]string{"rtb-private", "rtb-public"}),
+					},
+					{
+						VpcEndpointId: aws.String("vpce-s3-deleted"), VpcId: aws.String("vpc-1"), ServiceName: aws.String("com.amazonaws.ap-northeast-1.s3"),
+						VpcEndpointType: aws.String("Gateway"), State: aws.String("Deleted"), RouteTableIds: aws.StringSlice([]string{"rtb-private"}),
+					},
+				}
+			},
+			failed: map[string]string{CheckS3Endpoint: "vpce-s3-old is attached to rtb-private; detach or delete it"},
+		},
+		{
+			name: "S3 endpoint on the public route table only",
+			modify: func(_ *Input, f *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				f.endpoints = []*ec2.VpcEndpoint{{
+					VpcEndpointId: aws.String("vpce-s3-public"), VpcId: aws.String("vpc-1"), ServiceName: aws.String("com.amazonaws.ap-northeast-1.s3"),
+					VpcEndpointType: aws.String("Gateway"), State: aws.String("Available"), RouteTableIds: aws.StringSlice([]string{"rtb-public"}),
+				}}
+			},
+		},
+		{
+			name: "pull-through cache rule exists",
+			modify: func(_ *Input, _ *fakeEC2, f *fakeECR, _ *fakeQuotas) {
+				f.rules = []*ecr.PullThroughCacheRule{{
+					EcrRepositoryPrefix: aws.String(PullThroughCachePrefix),
+					UpstreamRegistryUrl: aws.String("public.ecr.aws"),
+					CreatedAt:           aws.Time(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+				}}
+			},
+			failed: map[string]string{CheckPullThroughCacheRule: "for ecr-public already exists (upstream public.ecr.aws, created 2026-10-01)"},
+		},
+		{
+			name: "Elastic IPs exhausted",
+			modify: func(_ *Input, f *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				f.addresses = 5
+			},
+			failed: map[string]string{CheckElasticIPQuota: "5 of 5 Elastic IPs are allocated"},
+		},
+		{
+			name: "NAT gateways exhausted in the zone",
+			modify: func(_ *Input, _ *fakeEC2, _ *fakeECR, f *fakeQuotas) {
+				f.applied = aws.Float64(1)
+			},
+			failed: map[string]string{CheckNATGatewayQuota: "1 of 1 NAT gateways exist in ap-northeast-1a"},
+		},
+		{
+			name: "NAT gateways in other zones",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, f *fakeQuotas) {
+				in.PublicSubnetIDs = []string{"subnet-public-c", "subnet-public-a"}
+				f.applied = aws.Float64(1)
+			},
+		},
+		{
+			name: "existing NAT gateway",
+			modify: func(in *Input, f *fakeEC2, _ *fakeECR, q *fakeQuotas) {
+				in.NATGatewayID = "nat-1"
+				f.nats = append(f.nats, &ec2.NatGateway{NatGatewayId: aws.String("nat-1"), VpcId: aws.String("vpc-1"), SubnetId: aws.String("subnet-public-a"), State: aws.String("available")})
+				// Neither quota matters
+				f.addresses = 5
+				q.applied = aws.Float64(0)
+			},
+			checks: []string{CheckVPC, CheckPrivateSubnets, CheckPublicSubnets, CheckPublicRoutes, CheckS3Endpoint, CheckPullThroughCacheRule, CheckNATGateway},
+		},
+		{
+			name: "NAT gateway in another VPC",
+			modify: func(in *Input, _ *fakeEC2, _ *fakeECR, _ *fakeQuotas) {
+				in.NATGatewayID = "nat-other"
+			},
+			checks: []string{CheckVPC, CheckPrivateSubnets, CheckPublicSubnets, CheckPublicRoutes, CheckS3Endpoint, CheckPullThroughCacheRule, CheckNATGateway},
+			failed: map[string]string{CheckNATGateway: "NAT gateway nat-other is not in vpc-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			in := input
+			ec2Client, ecrClient, quotas := newFakeEC2(), &fakeECR{}, &fakeQuotas{}
+			tt.modify(&in, ec2Client, ecrClient, quotas)
+
+			r, err := (&Checker{EC2: ec2Client, ECR: ecrClient, ServiceQuotas: quotas}).Run(context.Background(), in)
+			require.NoError(t, err)
+
+			var names []string
+			failed := map[string]string{}
+			for _, c := range r.Checks {
+				names = append(names, c.Name)
+				if !c.OK {
+					failed[c.Name] = c.Detail
+				}
+			}
+			want := tt.checks
+			if want == nil {
+				want = allChecks
+			}
+			assert.Equal(t, want, names, "checks made")
+			assert.Len(t, failed, len(tt.failed), "failed checks: %v", failed)
+			for name, detail := range tt.failed {
+				assert.Contains(t, failed[name], detail, name)
+			}
+			if len(tt.failed) == 0 {
+				assert.NoError(t, r.Err())
+			} else {
+				assert.Error(t, r.Err())
+			}
+		})
+	}
+}
+
+func TestResultErr(t *testing.T) {
+	r := &Result{}
+	r.pass(CheckVPC, "VPC vpc-1 (10.0.0.0/16)")
+	r.fail(CheckS3Endpoint, "vpce-1 is attached to rtb-1")
+	r.fail(CheckElasticIPQuota, "5 of 5 Elastic IPs are allocated")
+
+	err := r.Err()
+	require.Error(t, err)
+	assert.Equal(t, "s3_gateway_endpoint: vpce-1 is attached to rtb-1\nelastic_ip_quota: 5 of 5 Elastic IPs are allocated", err.Error())
+	assert.True(t, r.Failed(CheckS3Endpoint))
+	assert.False(t, r.Failed(CheckVPC))
+	assert.False(t, r.Failed(CheckNATGatewayQuota))
+
+	var lines []string
+	for _, c := range r.Checks {
+		lines = append(lines, c.String())
+	}
+	assert.Equal(t, "✓ vpc_id: VPC vpc-1 (10.0.0.0/16)\n✗ s3_gateway_endpoint: vpce-1 is attached to rtb-1\n✗ elastic_ip_quota: 5 of 5 Elastic IPs are allocated", strings.Join(lines, "\n"))
+}
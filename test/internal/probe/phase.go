@@ -0,0 +1,49 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+)
+
+// BridgeReadyBody is contained in the Bridge's /ok response once it
+// serves requests.
+const BridgeReadyBody = "bridge is ready"
+
+// StartPhase starts a prober that requests https://<domainName>/ok every
+// second and expects BridgeReadyBody, so that an error page served with
+// 200 counts as a failure. The returned function stops it, records the
+// probe counts and latencies as metrics of phase and returns the result.
+func StartPhase(ctx context.Context, phase *report.Phase, domainName string, logf func(format string, args ...any)) func() Result {
+	return startPhase(ctx, phase, New(fmt.Sprintf("https://%s/ok", domainName), Options{
+		Interval:   time.Second,
+		Timeout:    10 * time.Second,
+		ExpectBody: BridgeReadyBody,
+		Logf:       logf,
+	}), logf)
+}
+
+func startPhase(ctx context.Context, phase *report.Phase, prober *Prober, logf func(format string, args ...any)) func() Result {
+	prober.Start(ctx)
+	return func() Result {
+		res := prober.Stop()
+		phase.SetMetric("probes", float64(res.Total))
+		phase.SetMetric("failed_probes", float64(res.Failed()))
+		phase.SetMetric("max_latency_seconds", res.MaxLatency.Seconds())
+		phase.SetMetric("longest_outage_seconds", res.LongestOutage.Seconds())
+		logf("Prober: %s", res)
+		return res
+	}
+}
+
+// FinishPhase finishes phase as failed when any probe failed. what names
+// the operation probed, e.g. "rolling update".
+func FinishPhase(phase *report.Phase, res Result, what string) {
+	if res.Failed() > 0 {
+		phase.Finish(fmt.Errorf("%d of %d probes failed during the %s", res.Failed(), res.Total, what))
+		return
+	}
+	phase.Finish(nil)
+}
@@ -0,0 +1,200 @@
+// Package probe continuously requests an HTTP endpoint in the background
+// and records every failure, so that tests can assert that an update,
+// scaling operation or task kill did not make the Bridge unavailable.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a Prober.
+type Options struct {
+	// Interval between the start of two probes (default 1s).
+	Interval time.Duration
+	// Timeout of a single probe (default 5s).
+	Timeout time.Duration
+	// ExpectBody, if set, must be contained in the response body
+	// (case-insensitive). The status code must always be 200.
+	ExpectBody string
+	// Client defaults to an http.Client with Timeout.
+	Client *http.Client
+	// Logf receives a message for every failed probe. Defaults to a no-op.
+	Logf func(format string, args ...any)
+}
+
+// Failure is one failed probe.
+type Failure struct {
+	Time   time.Time `json:"time"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error"`
+}
+
+// Result summarizes a probing session.
+type Result struct {
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Total      int           `json:"total"`
+	Failures   []Failure     `json:"failures,omitempty"`
+	MaxLatency time.Duration `json:"max_latency"`
+	// LongestOutage is the longest interval between the first and last
+	// failure of a run of consecutive failures.
+	LongestOutage time.Duration `json:"longest_outage"`
+}
+
+// Failed returns the number of failed probes.
+func (r Result) Failed() int { return len(r.Failures) }
+
+// SuccessRate returns the fraction of successful probes (1 when no probe ran).
+func (r Result) SuccessRate() float64 {
+	if r.Total == 0 {
+		return 1
+	}
+	return float64(r.Total-len(r.Failures)) / float64(r.Total)
+}
+
+// String returns a one-line summary.
+func (r Result) String() string {
+	return fmt.Sprintf("%d probe(s), %d failed (%.2f%% success), max latency %v, longest outage %v",
+		r.Total, len(r.Failures), r.SuccessRate()*100, r.MaxLatency.Round(time.Millisecond), r.LongestOutage.Round(time.Millisecond))
+}
+
+// Prober probes one URL until stopped.
+type Prober struct {
+	url  string
+	opts Options
+
+	mu     sync.Mutex
+	result Result
+	outage time.Time // start of the current run of failures
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Prober for url. Call Start to begin probing.
+func New(url string, opts Options) *Prober {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &Prober{url: url, opts: opts}
+}
+
+// Start begins probing in the background until ctx is done or Stop is called.
+func (p *Prober) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	p.result.Start = time.Now()
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+		for {
+			p.probe(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends probing and returns the result.
+func (p *Prober) Stop() Result {
+	p.cancel()
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.End = time.Now()
+	return p.result
+}
+
+// Snapshot returns the result so far without stopping.
+func (p *Prober) Snapshot() Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.result
+	r.Failures = append([]Failure(nil), p.result.Failures...)
+	r.End = time.Now()
+	return r
+}
+
+func (p *Prober) probe(ctx context.Context) {
+	reqCtx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, err := p.do(reqCtx)
+	latency := time.Since(start)
+
+	// Probes interrupted by Stop are not counted.
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.Total++
+	if latency > p.result.MaxLatency {
+		p.result.MaxLatency = latency
+	}
+	if err == nil {
+		p.outage = time.Time{}
+		return
+	}
+
+	p.result.Failures = append(p.result.Failures, Failure{Time: start, Status: status, Error: err.Error()})
+	if p.outage.IsZero() {
+		p.outage = start
+	}
+	if d := start.Sub(p.outage); d > p.result.LongestOutage {
+		p.result.LongestOutage = d
+	}
+	p.opts.Logf("probe %s failed: %v", p.url, err)
+}
+
+func (p *Prober) do(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("read body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if p.opts.ExpectBody != "" && !strings.Contains(strings.ToLower(string(body)), strings.ToLower(p.opts.ExpectBody)) {
+		return resp.StatusCode, fmt.Errorf("unexpected body %q", truncate(string(body), 100))
+	}
+	return resp.StatusCode, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,151 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bridgeServer mimics the Bridge /ok endpoint. While down is set it
+// returns 502 like an ALB without healthy targets.
+func bridgeServer(t *testing.T, down *atomic.Bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("Bridge is ready"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func waitForProbes(t *testing.T, p *Prober, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return p.Snapshot().Total >= n }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestProberHealthyEndpoint(t *testing.T) {
+	var down atomic.Bool
+	srv := bridgeServer(t, &down)
+
+	p := New(srv.URL+"/ok", Options{Interval: 5 * time.Millisecond, ExpectBody: "bridge is ready"})
+	p.Start(context.Background())
+	waitForProbes(t, p, 5)
+	res := p.Stop()
+
+	assert.GreaterOrEqual(t, res.Total, 5)
+	assert.Zero(t, res.Failed())
+	assert.Equal(t, 1.0, res.SuccessRate())
+	assert.Zero(t, res.LongestOutage)
+	assert.False(t, res.End.Before(res.Start))
+}
+
+func TestProberRecordsOutage(t *testing.T) {
+	var down atomic.Bool
+	srv := bridgeServer(t, &down)
+
+	var logged atomic.Int32
+	p := New(srv.URL+"/ok", Options{
+		Interval: 5 * time.Millisecond,
+		Logf:     func(string, ...any) { logged.Add(1) },
+	})
+	p.Start(context.Background())
+	waitForProbes(t, p, 2)
+
+	down.Store(true)
+	require.Eventually(t, func() bool { return p.Snapshot().Failed() >= 3 }, 5*time.Second, 5*time.Millisecond)
+	down.Store(false)
+	before := p.Snapshot().Total
+	waitForProbes(t, p, before+2)
+	res := p.Stop()
+
+	require.GreaterOrEqual(t, res.Failed(), 3)
+	assert.Equal(t, http.StatusBadGateway, res.Failures[0].Status)
+	assert.Equal(t, "status 502", res.Failures[0].Error)
+	assert.Positive(t, res.LongestOutage)
+	assert.Less(t, res.SuccessRate(), 1.0)
+	assert.EqualValues(t, res.Failed(), logged.Load())
+	assert.Contains(t, res.String(), "failed")
+}
+
+func TestProberUnexpectedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>maintenance</html>"))
+	}))
+	defer srv.Close()
+
+	p := New(srv.URL, Options{Interval: 5 * time.Millisecond, ExpectBody: "bridge is ready"})
+	p.Start(context.Background())
+	waitForProbes(t, p, 1)
+	res := p.Stop()
+
+	require.NotEmpty(t, res.Failures)
+	assert.Contains(t, res.Failures[0].Error, "unexpected body")
+}
+
+func TestProberConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	p := New(url, Options{Interval: 5 * time.Millisecond, Timeout: time.Second})
+	p.Start(context.Background())
+	waitForProbes(t, p, 1)
+	res := p.Stop()
+
+	require.NotEmpty(t, res.Failures)
+	assert.Zero(t, res.Failures[0].Status)
+}
+
+func TestProberStopsWithContext(t *testing.T) {
+	var down atomic.Bool
+	srv := bridgeServer(t, &down)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(srv.URL, Options{Interval: 5 * time.Millisecond})
+	p.Start(ctx)
+	waitForProbes(t, p, 1)
+	cancel()
+
+	res := p.Stop()
+	total := res.Total
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, total, p.Snapshot().Total, "no probes after the context is cancelled")
+}
+
+func TestPhase(t *testing.T) {
+	var down atomic.Bool
+	srv := bridgeServer(t, &down)
+	rep := report.New("probe", "test")
+
+	phase := rep.Begin("rolling_update")
+	p := New(srv.URL+"/ok", Options{Interval: 5 * time.Millisecond, ExpectBody: BridgeReadyBody})
+	stop := startPhase(context.Background(), phase, p, t.Logf)
+	waitForProbes(t, p, 3)
+	res := stop()
+	FinishPhase(phase, res, "rolling update")
+
+	assert.Equal(t, report.OutcomePassed, phase.Outcome)
+	assert.Equal(t, float64(res.Total), phase.Metrics["probes"])
+	assert.Zero(t, phase.Metrics["failed_probes"])
+
+	phase = rep.Begin("task_kill_recovery")
+	down.Store(true)
+	p = New(srv.URL+"/ok", Options{Interval: 5 * time.Millisecond, ExpectBody: BridgeReadyBody})
+	stop = startPhase(context.Background(), phase, p, t.Logf)
+	waitForProbes(t, p, 2)
+	res = stop()
+	FinishPhase(phase, res, "task kill")
+
+	assert.Equal(t, report.OutcomeFailed, phase.Outcome)
+	assert.Equal(t, float64(res.Failed()), phase.Metrics["failed_probes"])
+	assert.Contains(t, phase.Error, "probes failed during the task kill")
+}
@@ -0,0 +1,210 @@
+package reach
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// FromEC2 describes the VPC and everything in it that the model uses.
+func FromEC2(ctx context.Context, api ec2iface.EC2API, vpcID string) (*Network, error) {
+	n := &Network{
+		VPCID:          vpcID,
+		Subnets:        map[string]*Subnet{},
+		RouteTables:    map[string]*RouteTable{},
+		SecurityGroups: map[string]*SecurityGroup{},
+		NetworkACLs:    map[string]*NetworkACL{},
+		NATGateways:    map[string]*NATGateway{},
+	}
+	inVPC := []*ec2.Filter{{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}}}
+
+	vpcs, err := api.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{aws.String(vpcID)}})
+	if err != nil {
+		return nil, fmt.Errorf("describe VPC %s: %w", vpcID, err)
+	}
+	for _, vpc := range vpcs.Vpcs {
+		for _, a := range vpc.CidrBlockAssociationSet {
+			if p, err := netip.ParsePrefix(aws.StringValue(a.CidrBlock)); err == nil {
+				n.CIDRs = append(n.CIDRs, p)
+			}
+		}
+		if len(vpc.CidrBlockAssociationSet) == 0 {
+			if p, err := netip.ParsePrefix(aws.StringValue(vpc.CidrBlock)); err == nil {
+				n.CIDRs = append(n.CIDRs, p)
+			}
+		}
+	}
+
+	err = api.DescribeSubnetsPagesWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: inVPC}, func(out *ec2.DescribeSubnetsOutput, _ bool) bool {
+		for _, s := range out.Subnets {
+			cidr, _ := netip.ParsePrefix(aws.StringValue(s.CidrBlock))
+			id := aws.StringValue(s.SubnetId)
+			n.Subnets[id] = &Subnet{ID: id, AvailabilityZone: aws.StringValue(s.AvailabilityZone), CIDR: cidr}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe subnets of %s: %w", vpcID, err)
+	}
+
+	err = api.DescribeRouteTablesPagesWithContext(ctx, &ec2.DescribeRouteTablesInput{Filters: inVPC}, func(out *ec2.DescribeRouteTablesOutput, _ bool) bool {
+		for _, t := range out.RouteTables {
+			rt := &RouteTable{ID: aws.StringValue(t.RouteTableId)}
+			for _, r := range t.Routes {
+				rt.Routes = append(rt.Routes, route(r))
+			}
+			n.RouteTables[rt.ID] = rt
+			for _, a := range t.Associations {
+				if aws.BoolValue(a.Main) {
+					n.MainRouteTableID = rt.ID
+				}
+				if s, ok := n.Subnets[aws.StringValue(a.SubnetId)]; ok {
+					s.RouteTableID = rt.ID
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe route tables of %s: %w", vpcID, err)
+	}
+
+	err = api.DescribeSecurityGroupsPagesWithContext(ctx, &ec2.DescribeSecurityGroupsInput{Filters: inVPC}, func(out *ec2.DescribeSecurityGroupsOutput, _ bool) bool {
+		for _, g := range out.SecurityGroups {
+			sg := &SecurityGroup{ID: aws.StringValue(g.GroupId), Name: aws.StringValue(g.GroupName)}
+			for _, p := range g.IpPermissions {
+				sg.Ingress = append(sg.Ingress, permission(p))
+			}
+			for _, p := range g.IpPermissionsEgress {
+				sg.Egress = append(sg.Egress, permission(p))
+			}
+			n.SecurityGroups[sg.ID] = sg
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe security groups of %s: %w", vpcID, err)
+	}
+
+	err = api.DescribeNetworkAclsPagesWithContext(ctx, &ec2.DescribeNetworkAclsInput{Filters: inVPC}, func(out *ec2.DescribeNetworkAclsOutput, _ bool) bool {
+		for _, a := range out.NetworkAcls {
+			acl := &NetworkACL{ID: aws.StringValue(a.NetworkAclId)}
+			for _, e := range a.Entries {
+				entry := ACLEntry{
+					RuleNumber: int(aws.Int64Value(e.RuleNumber)),
+					Egress:     aws.BoolValue(e.Egress),
+					Allow:      aws.StringValue(e.RuleAction) == ec2.RuleActionAllow,
+					Protocol:   aws.StringValue(e.Protocol),
+				}
+				entry.CIDR, _ = netip.ParsePrefix(aws.StringValue(e.CidrBlock))
+				if e.PortRange != nil {
+					entry.FromPort = int(aws.Int64Value(e.PortRange.From))
+					entry.ToPort = int(aws.Int64Value(e.PortRange.To))
+				}
+				acl.Entries = append(acl.Entries, entry)
+			}
+			n.NetworkACLs[acl.ID] = acl
+			for _, assoc := range a.Associations {
+				if s, ok := n.Subnets[aws.StringValue(assoc.SubnetId)]; ok {
+					s.NetworkACLID = acl.ID
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe network ACLs of %s: %w", vpcID, err)
+	}
+
+	err = api.DescribeNatGatewaysPagesWithContext(ctx, &ec2.DescribeNatGatewaysInput{Filter: inVPC}, func(out *ec2.DescribeNatGatewaysOutput, _ bool) bool {
+		for _, g := range out.NatGateways {
+			id := aws.StringValue(g.NatGatewayId)
+			n.NATGateways[id] = &NATGateway{ID: id, SubnetID: aws.StringValue(g.SubnetId), State: aws.StringValue(g.State)}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe NAT gateways of %s: %w", vpcID, err)
+	}
+
+	err = api.DescribeVpcEndpointsPagesWithContext(ctx, &ec2.DescribeVpcEndpointsInput{Filters: inVPC}, func(out *ec2.DescribeVpcEndpointsOutput, _ bool) bool {
+		for _, e := range out.VpcEndpoints {
+			ep := &Endpoint{
+				ID:            aws.StringValue(e.VpcEndpointId),
+				ServiceName:   aws.StringValue(e.ServiceName),
+				Type:          aws.StringValue(e.VpcEndpointType),
+				State:         strings.ToLower(aws.StringValue(e.State)),
+				SubnetIDs:     aws.StringValueSlice(e.SubnetIds),
+				PrivateDNS:    aws.BoolValue(e.PrivateDnsEnabled),
+				RouteTableIDs: aws.StringValueSlice(e.RouteTableIds),
+			}
+			for _, g := range e.Groups {
+				ep.SecurityGroupIDs = append(ep.SecurityGroupIDs, aws.StringValue(g.GroupId))
+			}
+			n.Endpoints = append(n.Endpoints, ep)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe VPC endpoints of %s: %w", vpcID, err)
+	}
+	// Gateway endpoint routes name the service's prefix list, not the
+	// endpoint
+	for _, ep := range n.Endpoints {
+		if ep.Type != EndpointGateway {
+			continue
+		}
+		for _, id := range ep.RouteTableIDs {
+			rt, ok := n.RouteTables[id]
+			if !ok {
+				continue
+			}
+			for _, r := range rt.Routes {
+				if r.PrefixListID != "" && r.Target == ep.ID {
+					ep.PrefixListID = r.PrefixListID
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+func route(r *ec2.Route) Route {
+	out := Route{
+		PrefixListID: aws.StringValue(r.DestinationPrefixListId),
+		Blackhole:    aws.StringValue(r.State) == ec2.RouteStateBlackhole,
+	}
+	out.Destination, _ = netip.ParsePrefix(aws.StringValue(r.DestinationCidrBlock))
+	for _, target := range []*string{r.GatewayId, r.NatGatewayId, r.TransitGatewayId, r.VpcPeeringConnectionId, r.NetworkInterfaceId, r.LocalGatewayId, r.CarrierGatewayId, r.InstanceId} {
+		if id := aws.StringValue(target); id != "" {
+			out.Target = id
+			break
+		}
+	}
+	return out
+}
+
+func permission(p *ec2.IpPermission) Permission {
+	out := Permission{
+		Protocol: aws.StringValue(p.IpProtocol),
+		FromPort: int(aws.Int64Value(p.FromPort)),
+		ToPort:   int(aws.Int64Value(p.ToPort)),
+	}
+	for _, r := range p.IpRanges {
+		if cidr, err := netip.ParsePrefix(aws.StringValue(r.CidrIp)); err == nil {
+			out.CIDRs = append(out.CIDRs, cidr)
+		}
+	}
+	for _, pl := range p.PrefixListIds {
+		out.PrefixListIDs = append(out.PrefixListIDs, aws.StringValue(pl.PrefixListId))
+	}
+	for _, g := range p.UserIdGroupPairs {
+		out.GroupIDs = append(out.GroupIDs, aws.StringValue(g.GroupId))
+	}
+	return out
+}
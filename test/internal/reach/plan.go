@@ -0,0 +1,293 @@
+package reach
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+)
+
+// AddPlan overlays the security groups and rules, VPC endpoints, NAT
+// gateways and routes of a plan onto the network, typically one built by
+// FromEC2 for the VPC the plan deploys into.
+//
+// Resources the plan creates have no IDs yet; they are identified by their
+// address instead ("module.bridge.aws_security_group.bridge"), and
+// arguments that refer to them are resolved through the plan's
+// configuration. Planned resources are assumed to become available.
+func (n *Network) AddPlan(p *tfplan.Plan) error {
+	if n.Subnets == nil {
+		n.Subnets = map[string]*Subnet{}
+	}
+	if n.RouteTables == nil {
+		n.RouteTables = map[string]*RouteTable{}
+	}
+	if n.SecurityGroups == nil {
+		n.SecurityGroups = map[string]*SecurityGroup{}
+	}
+	if n.NATGateways == nil {
+		n.NATGateways = map[string]*NATGateway{}
+	}
+
+	resources := p.Resources()
+	// Security groups first, so that rules find them
+	for _, r := range resources {
+		if r.Mode != "managed" || r.Type != "aws_security_group" {
+			continue
+		}
+		pr := planned{p, r}
+		sg := &SecurityGroup{ID: pr.id(), Name: pr.str("name")}
+		sg.Ingress = pr.inlineRules("ingress")
+		sg.Egress = pr.inlineRules("egress")
+		n.SecurityGroups[sg.ID] = sg
+	}
+	var nats []string
+	for _, r := range resources {
+		if r.Mode == "managed" && r.Type == "aws_nat_gateway" {
+			pr := planned{p, r}
+			id := pr.id()
+			n.NATGateways[id] = &NATGateway{ID: id, SubnetID: pr.ref("subnet_id"), State: "available"}
+			nats = append(nats, id)
+		}
+	}
+
+	for _, r := range resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		pr := planned{p, r}
+		switch r.Type {
+		case "aws_security_group_rule":
+			sg, err := n.plannedGroup(pr, "security_group_id")
+			if err != nil {
+				return err
+			}
+			perm := Permission{
+				Protocol: pr.str("protocol"),
+				FromPort: pr.int("from_port"),
+				ToPort:   pr.int("to_port"),
+				CIDRs:    prefixes(pr.strs("cidr_blocks")),
+			}
+			perm.PrefixListIDs = pr.strs("prefix_list_ids")
+			if id := pr.ref("source_security_group_id"); id != "" {
+				perm.GroupIDs = append(perm.GroupIDs, id)
+			}
+			if pr.bool("self") {
+				perm.GroupIDs = append(perm.GroupIDs, sg.ID)
+			}
+			if pr.str("type") == "egress" {
+				sg.Egress = append(sg.Egress, perm)
+			} else {
+				sg.Ingress = append(sg.Ingress, perm)
+			}
+		case "aws_vpc_security_group_ingress_rule", "aws_vpc_security_group_egress_rule":
+			sg, err := n.plannedGroup(pr, "security_group_id")
+			if err != nil {
+				return err
+			}
+			perm := Permission{
+				Protocol: pr.str("ip_protocol"),
+				FromPort: pr.int("from_port"),
+				ToPort:   pr.int("to_port"),
+				CIDRs:    prefixes([]string{pr.str("cidr_ipv4")}),
+			}
+			if id := pr.str("prefix_list_id"); id != "" {
+				perm.PrefixListIDs = []string{id}
+			}
+			if id := pr.ref("referenced_security_group_id"); id != "" {
+				perm.GroupIDs = []string{id}
+			}
+			if r.Type == "aws_vpc_security_group_egress_rule" {
+				sg.Egress = append(sg.Egress, perm)
+			} else {
+				sg.Ingress = append(sg.Ingress, perm)
+			}
+		case "aws_vpc_endpoint":
+			n.Endpoints = append(n.Endpoints, &Endpoint{
+				ID:               pr.id(),
+				ServiceName:      pr.str("service_name"),
+				Type:             pr.str("vpc_endpoint_type"),
+				SubnetIDs:        pr.strs("subnet_ids"),
+				SecurityGroupIDs: pr.refs("security_group_ids"),
+				PrivateDNS:       pr.bool("private_dns_enabled"),
+				RouteTableIDs:    pr.strs("route_table_ids"),
+			})
+		case "aws_route":
+			rtID := pr.str("route_table_id")
+			rt, ok := n.RouteTables[rtID]
+			if !ok {
+				return fmt.Errorf("%s: route table %q is not in %s", r.Address, rtID, n.VPCID)
+			}
+			route := Route{PrefixListID: pr.str("destination_prefix_list_id")}
+			route.Destination, _ = netip.ParsePrefix(pr.str("destination_cidr_block"))
+			for _, arg := range []string{"gateway_id", "nat_gateway_id", "transit_gateway_id", "vpc_peering_connection_id", "network_interface_id", "vpc_endpoint_id"} {
+				target := pr.ref(arg)
+				if _, known := r.Values[arg]; !known && target == "" && arg == "nat_gateway_id" && len(nats) == 1 {
+					// Usually a local choosing between the planned NAT
+					// gateway and an existing one
+					target = nats[0]
+				}
+				if target != "" {
+					route.Target = target
+					break
+				}
+			}
+			rt.replace(route)
+		}
+	}
+	return nil
+}
+
+// replace adds the route, replacing one for the same destination.
+func (rt *RouteTable) replace(route Route) {
+	for i, r := range rt.Routes {
+		if r.Destination == route.Destination && r.PrefixListID == route.PrefixListID {
+			rt.Routes[i] = route
+			return
+		}
+	}
+	rt.Routes = append(rt.Routes, route)
+}
+
+func (n *Network) plannedGroup(pr planned, arg string) (*SecurityGroup, error) {
+	id := pr.ref(arg)
+	sg, ok := n.SecurityGroups[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: security group %q is neither planned nor in %s", pr.r.Address, id, n.VPCID)
+	}
+	return sg, nil
+}
+
+// planned reads the values of one planned resource.
+type planned struct {
+	p *tfplan.Plan
+	r tfplan.Resource
+}
+
+// id is the resource ID, or its address while the ID is unknown.
+func (pr planned) id() string {
+	if id := pr.str("id"); id != "" {
+		return id
+	}
+	return pr.r.Address
+}
+
+func (pr planned) str(arg string) string {
+	s, _ := pr.r.Values[arg].(string)
+	return s
+}
+
+func (pr planned) int(arg string) int {
+	f, _ := pr.r.Values[arg].(float64)
+	return int(f)
+}
+
+func (pr planned) bool(arg string) bool {
+	b, _ := pr.r.Values[arg].(bool)
+	return b
+}
+
+func (pr planned) strs(arg string) []string {
+	return stringList(pr.r.Values[arg])
+}
+
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	var out []string
+	for _, item := range list {
+		if s, ok := item.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ref is the argument's value or, while unknown, the address of the
+// resource it refers to.
+func (pr planned) ref(arg string) string {
+	if s := pr.str(arg); s != "" {
+		return s
+	}
+	if refs := pr.addresses(arg); len(refs) > 0 {
+		return refs[0]
+	}
+	return ""
+}
+
+// refs is ref for list arguments.
+func (pr planned) refs(arg string) []string {
+	if s := pr.strs(arg); len(s) > 0 {
+		return s
+	}
+	return pr.addresses(arg)
+}
+
+// addresses are the resources the argument refers to, as full addresses
+// in the resource's module.
+func (pr planned) addresses(arg string) []string {
+	module := tfplan.ModuleAddress(pr.r.Address)
+	var out []string
+	for _, ref := range pr.references(arg) {
+		// "aws_security_group.bridge.id" is listed along with
+		// "aws_security_group.bridge"; the attribute form names the
+		// instance of counted resources
+		target, ok := strings.CutSuffix(ref, ".id")
+		if !ok || strings.HasPrefix(target, "var.") || strings.HasPrefix(target, "local.") || strings.HasPrefix(target, "data.") {
+			continue
+		}
+		if module != "" {
+			target = module + "." + target
+		}
+		out = append(out, target)
+	}
+	return out
+}
+
+func (pr planned) references(arg string) []string {
+	module, ok := pr.p.Module(tfplan.ModuleAddress(pr.r.Address))
+	if !ok {
+		return nil
+	}
+	res, ok := module.Resource(pr.r.Type + "." + pr.r.Name)
+	if !ok {
+		return nil
+	}
+	return res.References(arg)
+}
+
+// inlineRules reads the ingress or egress blocks of aws_security_group.
+func (pr planned) inlineRules(arg string) []Permission {
+	blocks, _ := pr.r.Values[arg].([]any)
+	var out []Permission
+	for _, b := range blocks {
+		m, ok := b.(map[string]any)
+		if !ok {
+			continue
+		}
+		block := planned{pr.p, tfplan.Resource{Values: m}}
+		perm := Permission{
+			Protocol:      block.str("protocol"),
+			FromPort:      block.int("from_port"),
+			ToPort:        block.int("to_port"),
+			CIDRs:         prefixes(block.strs("cidr_blocks")),
+			PrefixListIDs: block.strs("prefix_list_ids"),
+			GroupIDs:      block.strs("security_groups"),
+		}
+		if block.bool("self") {
+			perm.GroupIDs = append(perm.GroupIDs, pr.id())
+		}
+		out = append(out, perm)
+	}
+	return out
+}
+
+func prefixes(cidrs []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, c := range cidrs {
+		if p, err := netip.ParsePrefix(c); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
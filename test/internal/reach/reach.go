@@ -0,0 +1,692 @@
+// Package reach answers whether a Bridge task can reach a destination
+// (the internet, an AWS service or a host such as RDS) from a subnet with
+// a set of security groups, and explains each hop it checked.
+//
+// The model is a snapshot of a VPC: route tables, security groups,
+// network ACLs, VPC endpoints and NAT gateways. FromEC2 builds it from
+// describe calls and AddPlan overlays the resources a Terraform plan is
+// about to create, so the same question can be asked of a live VPC, of a
+// plan, or of a synthetic fixture in a test.
+//
+// The model only follows IPv4 TCP traffic and stops at the VPC boundary:
+// an available NAT gateway with an internet route, or an internet gateway
+// for a task with a public IP, counts as reaching the internet.
+package reach
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Network is a snapshot of one VPC.
+type Network struct {
+	VPCID string
+	CIDRs []netip.Prefix
+
+	Subnets        map[string]*Subnet
+	RouteTables    map[string]*RouteTable
+	SecurityGroups map[string]*SecurityGroup
+	NetworkACLs    map[string]*NetworkACL
+	NATGateways    map[string]*NATGateway
+	Endpoints      []*Endpoint
+
+	// MainRouteTableID is used by subnets without an explicit association.
+	MainRouteTableID string
+}
+
+// Subnet is a subnet of the VPC.
+type Subnet struct {
+	ID               string
+	AvailabilityZone string
+	CIDR             netip.Prefix
+	// RouteTableID is the explicitly associated route table ("" for the
+	// main route table).
+	RouteTableID string
+	NetworkACLID string
+}
+
+// RouteTable is a route table and its routes.
+type RouteTable struct {
+	ID     string
+	Routes []Route
+}
+
+// Route is one route. Either Destination or PrefixListID is set.
+type Route struct {
+	Destination  netip.Prefix
+	PrefixListID string
+	// Target is "local" or the ID of the gateway, NAT gateway, endpoint,
+	// transit gateway, peering connection or network interface.
+	Target    string
+	Blackhole bool
+}
+
+// SecurityGroup is a security group with its rules.
+type SecurityGroup struct {
+	ID      string
+	Name    string
+	Ingress []Permission
+	Egress  []Permission
+}
+
+// Permission is a security group rule. It allows traffic to or from any
+// of its CIDRs, prefix lists or security groups.
+type Permission struct {
+	// Protocol is "-1" for all protocols, or a name or number.
+	Protocol      string
+	FromPort      int
+	ToPort        int
+	CIDRs         []netip.Prefix
+	PrefixListIDs []string
+	GroupIDs      []string
+}
+
+// NetworkACL is a network ACL and its entries.
+type NetworkACL struct {
+	ID      string
+	Entries []ACLEntry
+}
+
+// ACLEntry is one numbered network ACL rule.
+type ACLEntry struct {
+	RuleNumber int
+	Egress     bool
+	Allow      bool
+	// Protocol is "-1" for all protocols, or a number.
+	Protocol string
+	FromPort int
+	ToPort   int
+	CIDR     netip.Prefix
+}
+
+// NATGateway is a NAT gateway.
+type NATGateway struct {
+	ID       string
+	SubnetID string
+	// State is the NAT gateway state, e.g. "available".
+	State string
+}
+
+// Endpoint types.
+const (
+	EndpointInterface = "Interface"
+	EndpointGateway   = "Gateway"
+)
+
+// Endpoint is a VPC endpoint.
+type Endpoint struct {
+	ID string
+	// ServiceName is the full service name, e.g.
+	// "com.amazonaws.ap-northeast-1.ecr.api".
+	ServiceName string
+	Type        string
+	State       string
+
+	// SubnetIDs, SecurityGroupIDs and PrivateDNS apply to interface
+	// endpoints.
+	SubnetIDs        []string
+	SecurityGroupIDs []string
+	PrivateDNS       bool
+
+	// RouteTableIDs and PrefixListID apply to gateway endpoints.
+	RouteTableIDs []string
+	PrefixListID  string
+}
+
+// Service reports whether the endpoint is for the AWS service name, e.g.
+// "ecr.api", in any region.
+func (e *Endpoint) Service(name string) bool {
+	return strings.HasSuffix(e.ServiceName, "."+name)
+}
+
+// Source is where the traffic starts: a task in SubnetID with
+// SecurityGroupIDs.
+type Source struct {
+	SubnetID         string
+	SecurityGroupIDs []string
+	// PublicIP is set when the task gets a public IP (assign_public_ip).
+	PublicIP bool
+}
+
+// Destination is what the traffic is for. Exactly one of Service or Addr
+// is set, or neither for the internet.
+type Destination struct {
+	// Name is used in explanations.
+	Name string
+	// Service is an AWS service name such as "ecr.api" or "s3"; it is
+	// reached through a VPC endpoint when there is one, otherwise through
+	// the internet.
+	Service string
+	// Addr is a host address. Inside the VPC, SubnetID and
+	// SecurityGroupIDs describe the host's network interface.
+	Addr             netip.Addr
+	SubnetID         string
+	SecurityGroupIDs []string
+	Port             int
+}
+
+// Destinations the Bridge task needs.
+var (
+	Internet   = Destination{Name: "internet", Port: 443}
+	ECRAPI     = Destination{Name: "ECR API", Service: "ecr.api", Port: 443}
+	ECRDocker  = Destination{Name: "ECR Docker registry", Service: "ecr.dkr", Port: 443}
+	S3         = Destination{Name: "S3", Service: "s3", Port: 443}
+	CloudWatch = Destination{Name: "CloudWatch Logs", Service: "logs", Port: 443}
+)
+
+// Host returns the destination of a host inside the VPC, such as an RDS
+// instance.
+func Host(name string, addr netip.Addr, subnetID string, securityGroupIDs []string, port int) Destination {
+	return Destination{Name: name, Addr: addr, SubnetID: subnetID, SecurityGroupIDs: securityGroupIDs, Port: port}
+}
+
+// Result is the answer for one source and destination.
+type Result struct {
+	Destination Destination
+	Reachable   bool
+	// Via is the hop that leaves the subnet, e.g. a NAT gateway or VPC
+	// endpoint ID, or "local".
+	Via string
+	// Steps are the checks made, in order; the last one failed when the
+	// destination is not reachable.
+	Steps []Step
+}
+
+// Step is one check on the path.
+type Step struct {
+	OK     bool
+	Detail string
+}
+
+func (s Step) String() string {
+	if s.OK {
+		return "✓ " + s.Detail
+	}
+	return "✗ " + s.Detail
+}
+
+// Reason is the detail of the failed step ("" when reachable).
+func (r *Result) Reason() string {
+	for _, s := range r.Steps {
+		if !s.OK {
+			return s.Detail
+		}
+	}
+	return ""
+}
+
+func (r *Result) String() string {
+	port := fmt.Sprintf("%s:%d", r.Destination.Name, r.Destination.Port)
+	if r.Reachable {
+		return fmt.Sprintf("%s reachable via %s", port, r.Via)
+	}
+	return fmt.Sprintf("%s NOT reachable: %s", port, r.Reason())
+}
+
+// Explain lists every step, one per line.
+func (r *Result) Explain() string {
+	lines := []string{r.String()}
+	for _, s := range r.Steps {
+		lines = append(lines, "  "+s.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check answers whether src can open a TCP connection to dst.
+func (n *Network) Check(src Source, dst Destination) *Result {
+	c := &check{n: n, r: &Result{Destination: dst}}
+	c.run(src, dst)
+	c.r.Reachable = c.ok
+	return c.r
+}
+
+// check accumulates the steps of one Check; ok turns false at the first
+// failed step and every later step is skipped.
+type check struct {
+	n  *Network
+	r  *Result
+	ok bool
+}
+
+func (c *check) pass(format string, args ...any) {
+	c.r.Steps = append(c.r.Steps, Step{OK: true, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (c *check) fail(format string, args ...any) {
+	c.r.Steps = append(c.r.Steps, Step{Detail: fmt.Sprintf(format, args...)})
+	c.ok = false
+}
+
+// peer is the other end of a connection as security groups and network
+// ACLs see it.
+type peer struct {
+	desc string
+	// prefix holds the peer's address; rules must cover all of it
+	prefix       netip.Prefix
+	prefixListID string
+	groupIDs     []string
+}
+
+// internetPeer stands for any public address.
+var internetPeer = peer{desc: "the internet (0.0.0.0/0)", prefix: netip.MustParsePrefix("0.0.0.0/0")}
+
+func (c *check) run(src Source, dst Destination) {
+	c.ok = true
+	subnet, ok := c.n.Subnets[src.SubnetID]
+	if !ok {
+		c.fail("subnet %s is not in %s", src.SubnetID, c.n.VPCID)
+		return
+	}
+	rt := c.n.routeTable(subnet)
+	if rt == nil {
+		c.fail("subnet %s has no route table", subnet.ID)
+		return
+	}
+	for _, id := range src.SecurityGroupIDs {
+		if _, ok := c.n.SecurityGroups[id]; !ok {
+			c.fail("security group %s is not in %s", id, c.n.VPCID)
+			return
+		}
+	}
+
+	switch {
+	case dst.Service != "":
+		if ep := c.n.endpoint(dst.Service, EndpointInterface, rt); ep != nil {
+			if ep.PrivateDNS {
+				c.viaInterfaceEndpoint(src, subnet, dst, ep)
+				return
+			}
+			c.pass("interface endpoint %s for %s has private DNS disabled, so the service name resolves to public addresses", ep.ID, dst.Service)
+		}
+		if ep := c.n.endpoint(dst.Service, EndpointGateway, rt); ep != nil {
+			if ep.attached(rt) {
+				c.viaGatewayEndpoint(src, subnet, rt, dst, ep)
+				return
+			}
+			c.pass("gateway endpoint %s for %s is not attached to %s", ep.ID, dst.Service, rt.ID)
+		}
+		c.viaRoute(src, subnet, rt, dst, internetPeer)
+	case dst.Addr.IsValid():
+		c.viaRoute(src, subnet, rt, dst, peer{
+			desc:     dst.Addr.String(),
+			prefix:   netip.PrefixFrom(dst.Addr, dst.Addr.BitLen()),
+			groupIDs: dst.SecurityGroupIDs,
+		})
+	default:
+		c.viaRoute(src, subnet, rt, dst, internetPeer)
+	}
+}
+
+// viaRoute follows the route table towards to.
+func (c *check) viaRoute(src Source, subnet *Subnet, rt *RouteTable, dst Destination, to peer) {
+	route := rt.lookup(to.prefix)
+	if route == nil {
+		c.fail("%s has no route to %s", rt.ID, to.desc)
+		return
+	}
+	if route.Blackhole {
+		c.fail("%s routes %s to %s, which no longer exists (blackhole)", rt.ID, route.Destination, route.Target)
+		return
+	}
+	c.pass("%s routes %s to %s", rt.ID, route.Destination, route.Target)
+
+	switch {
+	case route.Target == "local":
+		c.r.Via = "local"
+		c.egress(src, subnet, to, dst.Port)
+		if !c.ok {
+			return
+		}
+		if dst.SubnetID == "" {
+			c.pass("%s is not described further; its own security groups and network ACL are not checked", to.desc)
+			return
+		}
+		c.ingress(src, subnet, dst.SubnetID, dst.SecurityGroupIDs, to.desc, dst.Port)
+	case c.n.NATGateways[route.Target] != nil || strings.HasPrefix(route.Target, "nat-"):
+		c.r.Via = route.Target
+		c.natGateway(route.Target)
+		if c.ok {
+			c.egress(src, subnet, to, dst.Port)
+		}
+	case strings.HasPrefix(route.Target, "igw-"):
+		c.r.Via = route.Target
+		if !src.PublicIP {
+			c.fail("the task has no public IP, so %s cannot carry its traffic (use a NAT gateway or assign_public_ip)", route.Target)
+			return
+		}
+		c.pass("the task has a public IP")
+		c.egress(src, subnet, to, dst.Port)
+	default:
+		c.fail("traffic leaves through %s, which this model does not follow", route.Target)
+	}
+}
+
+// natGateway checks that the NAT gateway is available and can itself
+// reach the internet.
+func (c *check) natGateway(id string) {
+	nat, ok := c.n.NATGateways[id]
+	if !ok {
+		c.fail("NAT gateway %s is not in %s", id, c.n.VPCID)
+		return
+	}
+	if nat.State != "available" {
+		c.fail("NAT gateway %s is %s, not available", id, nat.State)
+		return
+	}
+	subnet, ok := c.n.Subnets[nat.SubnetID]
+	if !ok {
+		c.fail("NAT gateway %s is in subnet %s, which is not in %s", id, nat.SubnetID, c.n.VPCID)
+		return
+	}
+	rt := c.n.routeTable(subnet)
+	var route *Route
+	if rt != nil {
+		route = rt.lookup(internetPeer.prefix)
+	}
+	if route == nil || route.Blackhole || !strings.HasPrefix(route.Target, "igw-") {
+		c.fail("NAT gateway %s is in %s, whose route table has no internet gateway route", id, subnet.ID)
+		return
+	}
+	c.pass("NAT gateway %s is available and %s routes 0.0.0.0/0 to %s", id, subnet.ID, route.Target)
+}
+
+func (c *check) viaInterfaceEndpoint(src Source, subnet *Subnet, dst Destination, ep *Endpoint) {
+	c.r.Via = ep.ID
+	if ep.State != "" && ep.State != "available" {
+		c.fail("interface endpoint %s for %s is %s, not available", ep.ID, dst.Service, ep.State)
+		return
+	}
+	// Private DNS answers with the endpoint's network interface in the
+	// task's availability zone when there is one
+	var eniSubnet *Subnet
+	for _, id := range ep.SubnetIDs {
+		s, ok := c.n.Subnets[id]
+		if !ok {
+			continue
+		}
+		if eniSubnet == nil || s.AvailabilityZone == subnet.AvailabilityZone && eniSubnet.AvailabilityZone != subnet.AvailabilityZone {
+			eniSubnet = s
+		}
+	}
+	if eniSubnet == nil {
+		c.fail("interface endpoint %s for %s has no network interface in %s", ep.ID, dst.Service, c.n.VPCID)
+		return
+	}
+	c.pass("%s resolves to interface endpoint %s in %s", dst.Service, ep.ID, eniSubnet.ID)
+	to := peer{desc: fmt.Sprintf("endpoint %s (%s)", ep.ID, eniSubnet.CIDR), prefix: eniSubnet.CIDR, groupIDs: ep.SecurityGroupIDs}
+	if rt := c.n.routeTable(subnet); rt != nil {
+		if route := rt.lookup(eniSubnet.CIDR); route == nil || route.Target != "local" {
+			c.fail("%s does not route %s locally", rt.ID, eniSubnet.CIDR)
+			return
+		}
+	}
+	c.egress(src, subnet, to, dst.Port)
+	if c.ok {
+		c.ingress(src, subnet, eniSubnet.ID, ep.SecurityGroupIDs, to.desc, dst.Port)
+	}
+}
+
+func (c *check) viaGatewayEndpoint(src Source, subnet *Subnet, rt *RouteTable, dst Destination, ep *Endpoint) {
+	c.r.Via = ep.ID
+	if ep.State != "" && ep.State != "available" {
+		c.fail("gateway endpoint %s for %s is %s, not available", ep.ID, dst.Service, ep.State)
+		return
+	}
+	c.pass("%s routes %s to gateway endpoint %s", rt.ID, dst.Service, ep.ID)
+	// The service's addresses are public, so CIDR rules must cover all
+	// of them unless the prefix list is referenced
+	to := internetPeer
+	to.desc = dst.Service + "'s public addresses"
+	if ep.PrefixListID != "" {
+		to.desc = fmt.Sprintf("%s (%s)", dst.Service, ep.PrefixListID)
+	}
+	to.prefixListID = ep.PrefixListID
+	c.egress(src, subnet, to, dst.Port)
+}
+
+// egress checks the source security groups and the source subnet's
+// network ACL for the connection to to, and the ACL for the replies.
+func (c *check) egress(src Source, subnet *Subnet, to peer, port int) {
+	if len(src.SecurityGroupIDs) == 0 {
+		c.fail("the task has no security groups")
+		return
+	}
+	if id, ok := c.n.allows(src.SecurityGroupIDs, true, to, port); ok {
+		c.pass("security group %s allows outbound TCP %d to %s", id, port, to.desc)
+	} else {
+		c.fail("no security group of the task (%s) allows outbound TCP %d to %s", strings.Join(src.SecurityGroupIDs, ", "), port, to.desc)
+		return
+	}
+	if covers(subnet.CIDR, to.prefix) {
+		// Network ACLs only filter traffic crossing the subnet boundary
+		return
+	}
+	c.acl(subnet, true, to, port, port, "outbound")
+	if c.ok {
+		c.acl(subnet, false, to, ephemeralFrom, ephemeralTo, "inbound replies")
+	}
+}
+
+// ingress checks the destination subnet's network ACL and the
+// destination security groups for a connection from src.
+func (c *check) ingress(src Source, srcSubnet *Subnet, subnetID string, groupIDs []string, desc string, port int) {
+	subnet, ok := c.n.Subnets[subnetID]
+	if !ok {
+		c.fail("subnet %s of %s is not in %s", subnetID, desc, c.n.VPCID)
+		return
+	}
+	from := peer{desc: fmt.Sprintf("%s (%s)", srcSubnet.ID, srcSubnet.CIDR), prefix: srcSubnet.CIDR, groupIDs: src.SecurityGroupIDs}
+	if subnet.ID != srcSubnet.ID {
+		c.acl(subnet, false, from, port, port, "inbound")
+		if c.ok {
+			c.acl(subnet, true, from, ephemeralFrom, ephemeralTo, "outbound replies")
+		}
+		if !c.ok {
+			return
+		}
+	}
+	if len(groupIDs) == 0 {
+		c.fail("%s has no security groups", desc)
+		return
+	}
+	for _, id := range groupIDs {
+		if _, ok := c.n.SecurityGroups[id]; !ok {
+			c.fail("security group %s of %s is not in %s", id, desc, c.n.VPCID)
+			return
+		}
+	}
+	if id, ok := c.n.allows(groupIDs, false, from, port); ok {
+		c.pass("security group %s of %s allows inbound TCP %d from %s", id, desc, port, from.desc)
+	} else {
+		c.fail("no security group of %s (%s) allows inbound TCP %d from the task's security groups or %s", desc, strings.Join(groupIDs, ", "), port, srcSubnet.CIDR)
+	}
+}
+
+// Fargate tasks use the Linux ephemeral port range for replies.
+const (
+	ephemeralFrom = 32768
+	ephemeralTo   = 60999
+)
+
+// acl evaluates the subnet's network ACL for ports from and to (both ends
+// of a range) towards or from p.
+func (c *check) acl(subnet *Subnet, egress bool, p peer, from, to int, what string) {
+	ports := fmt.Sprint(from)
+	if from != to {
+		ports = fmt.Sprintf("%d-%d", from, to)
+	}
+	acl, ok := c.n.NetworkACLs[subnet.NetworkACLID]
+	if !ok {
+		c.pass("no network ACL is known for %s; assuming the default ACL, which allows all traffic", subnet.ID)
+		return
+	}
+	for _, port := range []int{from, to} {
+		e := acl.evaluate(egress, p.prefix, port)
+		if e == nil {
+			c.fail("network ACL %s of %s denies %s TCP %s for %s (no rule matches)", acl.ID, subnet.ID, what, ports, p.desc)
+			return
+		}
+		if !e.Allow {
+			c.fail("network ACL %s of %s denies %s TCP %s for %s (rule %d)", acl.ID, subnet.ID, what, ports, p.desc, e.RuleNumber)
+			return
+		}
+	}
+	c.pass("network ACL %s of %s allows %s TCP %s for %s", acl.ID, subnet.ID, what, ports, p.desc)
+}
+
+// evaluate returns the first entry, by rule number, that matches TCP
+// traffic on port to or from an address in prefix; nil means the implicit
+// deny. An entry matches only if its CIDR covers all of prefix.
+func (a *NetworkACL) evaluate(egress bool, prefix netip.Prefix, port int) *ACLEntry {
+	entries := make([]ACLEntry, 0, len(a.Entries))
+	for _, e := range a.Entries {
+		if e.Egress == egress {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RuleNumber < entries[j].RuleNumber })
+	for i, e := range entries {
+		if !covers(e.CIDR, prefix) || !tcp(e.Protocol) {
+			continue
+		}
+		if e.Protocol != "-1" && (port < e.FromPort || port > e.ToPort) {
+			continue
+		}
+		return &entries[i]
+	}
+	return nil
+}
+
+// allows returns the first of groupIDs with a rule for TCP port to or from
+// p.
+func (n *Network) allows(groupIDs []string, egress bool, p peer, port int) (string, bool) {
+	for _, id := range groupIDs {
+		sg, ok := n.SecurityGroups[id]
+		if !ok {
+			continue
+		}
+		perms := sg.Ingress
+		if egress {
+			perms = sg.Egress
+		}
+		for _, perm := range perms {
+			if perm.allows(p, port) {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p Permission) allows(to peer, port int) bool {
+	if !tcp(p.Protocol) {
+		return false
+	}
+	if p.Protocol != "-1" && (port < p.FromPort || port > p.ToPort) {
+		return false
+	}
+	for _, cidr := range p.CIDRs {
+		if covers(cidr, to.prefix) {
+			return true
+		}
+	}
+	for _, id := range p.PrefixListIDs {
+		if id != "" && id == to.prefixListID {
+			return true
+		}
+	}
+	for _, id := range p.GroupIDs {
+		for _, g := range to.groupIDs {
+			if id == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func tcp(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case "-1", "all", "tcp", "6":
+		return true
+	}
+	return false
+}
+
+// covers reports whether outer contains every address of inner.
+func covers(outer, inner netip.Prefix) bool {
+	if !outer.IsValid() || !inner.IsValid() || outer.Addr().Is4() != inner.Addr().Is4() {
+		return false
+	}
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func (n *Network) routeTable(s *Subnet) *RouteTable {
+	id := s.RouteTableID
+	if id == "" {
+		id = n.MainRouteTableID
+	}
+	return n.RouteTables[id]
+}
+
+// lookup returns the most specific CIDR route that covers all of prefix.
+// Prefix list routes are left to gateway endpoints.
+func (rt *RouteTable) lookup(prefix netip.Prefix) *Route {
+	var best *Route
+	for i, r := range rt.Routes {
+		if r.PrefixListID != "" || !covers(r.Destination, prefix) {
+			continue
+		}
+		if best == nil || r.Destination.Bits() > best.Destination.Bits() {
+			best = &rt.Routes[i]
+		}
+	}
+	return best
+}
+
+// endpoint returns the endpoint of the type for the service that traffic
+// from a subnet using rt takes. Endpoints that are gone are skipped, and
+// gateway endpoints attached to rt are preferred.
+func (n *Network) endpoint(service, typ string, rt *RouteTable) *Endpoint {
+	var found *Endpoint
+	for _, e := range n.Endpoints {
+		if e.Type != typ || !e.Service(service) || e.Gone() {
+			continue
+		}
+		if typ == EndpointGateway && e.attached(rt) {
+			return e
+		}
+		if found == nil {
+			found = e
+		}
+	}
+	return found
+}
+
+// Gone reports whether the endpoint is being or has been removed, or was
+// never created. DescribeVpcEndpoints keeps listing such endpoints for a
+// while.
+func (e *Endpoint) Gone() bool {
+	switch e.State {
+	case "deleting", "deleted", "failed", "rejected", "expired":
+		return true
+	}
+	return false
+}
+
+// attached reports whether the gateway endpoint serves the route table.
+func (e *Endpoint) attached(rt *RouteTable) bool {
+	for _, id := range e.RouteTableIDs {
+		if id == rt.ID {
+			return true
+		}
+	}
+	for _, r := range rt.Routes {
+		if r.Target == e.ID || e.PrefixListID != "" && r.PrefixListID == e.PrefixListID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,509 @@
+package reach
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	all      = netip.MustParsePrefix("0.0.0.0/0")
+	vpcCIDR  = netip.MustParsePrefix("10.0.0.0/16")
+	rdsAddr  = netip.MustParseAddr("10.0.20.15")
+	bridge   = Source{SubnetID: "subnet-private-a", SecurityGroupIDs: []string{"sg-bridge"}}
+	rds      = Host("RDS", rdsAddr, "subnet-db-a", []string{"sg-rds"}, 5432)
+	allowAll = []ACLEntry{
+		{RuleNumber: 100, Allow: true, Protocol: "-1", CIDR: all},
+		{RuleNumber: 100, Egress: true, Allow: true, Protocol: "-1", CIDR: all},
+	}
+)
+
+// fixture is the example deployment: private subnets with a NAT gateway in
+// a public subnet, interface endpoints for ECR API, an S3 gateway endpoint,
+// and an RDS subnet with its own network ACL.
+func fixture() *Network {
+	return &Network{
+		VPCID: "vpc-1",
+		CIDRs: []netip.Prefix{vpcCIDR},
+		Subnets: map[string]*Subnet{
+			"subnet-private-a": {ID: "subnet-private-a", AvailabilityZone: "ap-northeast-1a", CIDR: netip.MustParsePrefix("10.0.10.0/24"), RouteTableID: "rtb-private", NetworkACLID: "acl-default"},
+			"subnet-private-c": {ID: "subnet-private-c", AvailabilityZone: "ap-northeast-1c", CIDR: netip.MustParsePrefix("10.0.11.0/24"), RouteTableID: "rtb-private", NetworkACLID: "acl-default"},
+			"subnet-public-a":  {ID: "subnet-public-a", AvailabilityZone: "ap-northeast-1a", CIDR: netip.MustParsePrefix("10.0.0.0/24"), NetworkACLID: "acl-default"},
+			"subnet-db-a":      {ID: "subnet-db-a", AvailabilityZone: "ap-northeast-1a", CIDR: netip.MustParsePrefix("10.0.20.0/24"), RouteTableID: "rtb-private", NetworkACLID: "acl-db"},
+		},
+		MainRouteTableID: "rtb-public",
+		RouteTables: map[string]*RouteTable{
+			"rtb-private": {ID: "rtb-private", Routes: []Route{
+				{Destination: vpcCIDR, Target: "local"},
+				{Destination: all, Target: "nat-1"},
+				{PrefixListID: "pl-s3", Target: "vpce-s3"},
+			}},
+			"rtb-public": {ID: "rtb-public", Routes: []Route{
+				{Destination: vpcCIDR, Target: "local"},
+				{Destination: all, Target: "igw-1"},
+			}},
+		},
+		NATGateways: map[string]*NATGateway{
+			"nat-1": {ID: "nat-1", SubnetID: "subnet-public-a", State: "available"},
+		},
+		SecurityGroups: map[string]*SecurityGroup{
+			"sg-bridge": {ID: "sg-bridge", Egress: []Permission{{Protocol: "-1", CIDRs: []netip.Prefix{all}}}},
+			"sg-endpoints": {ID: "sg-endpoints", Ingress: []Permission{
+				{Protocol: "tcp", FromPort: 443, ToPort: 443, GroupIDs: []string{"sg-bridge"}},
+			}},
+			"sg-rds": {ID: "sg-rds", Ingress: []Permission{
+				{Protocol: "tcp", FromPort: 5432, ToPort: 5432, GroupIDs: []string{"sg-bridge"}},
+			}},
+		},
+		NetworkACLs: map[string]*NetworkACL{
+			"acl-default": {ID: "acl-default", Entries: allowAll},
+			"acl-db": {ID: "acl-db", Entries: []ACLEntry{
+				{RuleNumber: 100, Allow: true, Protocol: "6", FromPort: 5432, ToPort: 5432, CIDR: vpcCIDR},
+				{RuleNumber: 100, Egress: true, Allow: true, Protocol: "6", FromPort: 1024, ToPort: 65535, CIDR: vpcCIDR},
+			}},
+		},
+		Endpoints: []*Endpoint{
+			{ID: "vpce-ecr-api", ServiceName: "com.amazonaws.ap-northeast-1.ecr.api", Type: EndpointInterface, State: "available",
+				SubnetIDs: []string{"subnet-private-a", "subnet-private-c"}, SecurityGroupIDs: []string{"sg-endpoints"}, PrivateDNS: true},
+			{ID: "vpce-s3", ServiceName: "com.amazonaws.ap-northeast-1.s3", Type: EndpointGateway, State: "available",
+				RouteTableIDs: []string{"rtb-private"}, PrefixListID: "pl-s3"},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(n *Network)
+		src    Source
+		dst    Destination
+		via    string
+		reason string
+	}{
+		{name: "internet through NAT", dst: Internet, via: "nat-1"},
+		{name: "ECR API through interface endpoint", dst: ECRAPI, via: "vpce-ecr-api"},
+		{name: "ECR Docker without endpoint uses NAT", dst: ECRDocker, via: "nat-1"},
+		{name: "S3 through gateway endpoint", dst: S3, via: "vpce-s3"},
+		{name: "RDS in the VPC", dst: rds, via: "local"},
+		{
+			name: "task in public subnet with public IP",
+			src:  Source{SubnetID: "subnet-public-a", SecurityGroupIDs: []string{"sg-bridge"}, PublicIP: true},
+			dst:  Internet, via: "igw-1",
+		},
+		{
+			name:   "task in public subnet without public IP",
+			src:    Source{SubnetID: "subnet-public-a", SecurityGroupIDs: []string{"sg-bridge"}},
+			dst:    Internet,
+			reason: "the task has no public IP, so igw-1 cannot carry its traffic",
+		},
+		{
+			name: "no default route",
+			change: func(n *Network) {
+				n.RouteTables["rtb-private"].Routes = n.RouteTables["rtb-private"].Routes[:1]
+			},
+			dst:    Internet,
+			reason: "rtb-private has no route to the internet (0.0.0.0/0)",
+		},
+		{
+			name: "blackhole route",
+			change: func(n *Network) {
+				n.RouteTables["rtb-private"].Routes[1].Blackhole = true
+			},
+			dst:    Internet,
+			reason: "rtb-private routes 0.0.0.0/0 to nat-1, which no longer exists (blackhole)",
+		},
+		{
+			name:   "NAT gateway pending",
+			change: func(n *Network) { n.NATGateways["nat-1"].State = "pending" },
+			dst:    ECRDocker,
+			reason: "NAT gateway nat-1 is pending, not available",
+		},
+		{
+			name:   "NAT gateway in a private subnet",
+			change: func(n *Network) { n.NATGateways["nat-1"].SubnetID = "subnet-private-c" },
+			dst:    Internet,
+			reason: "NAT gateway nat-1 is in subnet-private-c, whose route table has no internet gateway route",
+		},
+		{
+			name: "transit gateway",
+			change: func(n *Network) {
+				n.RouteTables["rtb-private"].Routes[1].Target = "tgw-1"
+			},
+			dst:    Internet,
+			reason: "traffic leaves through tgw-1, which this model does not follow",
+		},
+		{
+			name: "endpoint security group does not accept the task",
+			change: func(n *Network) {
+				n.SecurityGroups["sg-endpoints"].Ingress[0].GroupIDs = []string{"sg-other"}
+			},
+			dst:    ECRAPI,
+			reason: "no security group of endpoint vpce-ecr-api (10.0.10.0/24) (sg-endpoints) allows inbound TCP 443",
+		},
+		{
+			name: "endpoint security group accepts the VPC CIDR",
+			change: func(n *Network) {
+				n.SecurityGroups["sg-endpoints"].Ingress[0] = Permission{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDRs: []netip.Prefix{vpcCIDR}}
+			},
+			dst: ECRAPI, via: "vpce-ecr-api",
+		},
+		{
+			name:   "endpoint without private DNS",
+			change: func(n *Network) { n.Endpoints[0].PrivateDNS = false },
+			dst:    ECRAPI, via: "nat-1",
+		},
+		{
+			name:   "endpoint not available",
+			change: func(n *Network) { n.Endpoints[0].State = "pending" },
+			dst:    ECRAPI,
+			reason: "interface endpoint vpce-ecr-api for ecr.api is pending, not available",
+		},
+		{
+			name: "gateway endpoint not attached",
+			change: func(n *Network) {
+				n.Endpoints[1].RouteTableIDs = nil
+				n.RouteTables["rtb-private"].Routes = n.RouteTables["rtb-private"].Routes[:2]
+			},
+			dst: S3, via: "nat-1",
+		},
+		{
+			name: "deleted S3 endpoint listed first",
+			change: func(n *Network) {
+				deleted := &Endpoint{ID: "vpce-s3-old", ServiceName: "com.amazonaws.ap-northeast-1.s3", Type: EndpointGateway, State: "deleted",
+					RouteTableIDs: []string{"rtb-private"}}
+				n.Endpoints = append([]*Endpoint{deleted}, n.Endpoints...)
+			},
+			dst: S3, via: "vpce-s3",
+		},
+		{
+			name: "S3 endpoint of the public route table listed first",
+			change: func(n *Network) {
+				public := &Endpoint{ID: "vpce-s3-public", ServiceName: "com.amazonaws.ap-northeast-1.s3", Type: EndpointGateway, State: "available",
+					RouteTableIDs: []string{"rtb-public"}}
+				n.Endpoints = append([]*Endpoint{public}, n.Endpoints...)
+			},
+			dst: S3, via: "vpce-s3",
+		},
+		{
+			name: "deleting interface endpoint listed first",
+			change: func(n *Network) {
+				deleting := *n.Endpoints[0]
+				deleting.ID, deleting.State = "vpce-ecr-api-old", "deleting"
+				n.Endpoints = append([]*Endpoint{&deleting}, n.Endpoints...)
+			},
+			dst: ECRAPI, via: "vpce-ecr-api",
+		},
+		{
+			name:   "only a deleted interface endpoint",
+			change: func(n *Network) { n.Endpoints[0].State = "deleted" },
+			dst:    ECRAPI, via: "nat-1",
+		},
+		{
+			name: "egress only to the VPC",
+			change: func(n *Network) {
+				n.SecurityGroups["sg-bridge"].Egress = []Permission{{Protocol: "tcp", FromPort: 0, ToPort: 65535, CIDRs: []netip.Prefix{vpcCIDR}}}
+			},
+			dst:    Internet,
+			reason: "no security group of the task (sg-bridge) allows outbound TCP 443 to the internet (0.0.0.0/0)",
+		},
+		{
+			name: "egress to the S3 prefix list",
+			change: func(n *Network) {
+				n.SecurityGroups["sg-bridge"].Egress = []Permission{{Protocol: "tcp", FromPort: 443, ToPort: 443, PrefixListIDs: []string{"pl-s3"}}}
+			},
+			dst: S3, via: "vpce-s3",
+		},
+		{
+			name: "network ACL denies replies",
+			change: func(n *Network) {
+				acl := n.NetworkACLs["acl-default"]
+				acl.Entries = append(acl.Entries, ACLEntry{RuleNumber: 90, Protocol: "6", FromPort: 1024, ToPort: 65535, CIDR: all})
+			},
+			dst:    Internet,
+			reason: "network ACL acl-default of subnet-private-a denies inbound replies TCP 32768-60999 for the internet (0.0.0.0/0) (rule 90)",
+		},
+		{
+			name: "network ACL without matching rule",
+			change: func(n *Network) {
+				n.NetworkACLs["acl-default"].Entries = nil
+			},
+			dst:    Internet,
+			reason: "network ACL acl-default of subnet-private-a denies outbound TCP 443 for the internet (0.0.0.0/0) (no rule matches)",
+		},
+		{
+			name:   "unknown network ACL",
+			change: func(n *Network) { delete(n.NetworkACLs, "acl-default") },
+			dst:    Internet, via: "nat-1",
+		},
+		{
+			name:   "RDS port closed",
+			dst:    Host("RDS", rdsAddr, "subnet-db-a", []string{"sg-rds"}, 3306),
+			reason: "network ACL acl-db of subnet-db-a denies inbound TCP 3306 for subnet-private-a (10.0.10.0/24)",
+		},
+		{
+			name: "RDS security group",
+			change: func(n *Network) {
+				n.NetworkACLs["acl-db"] = &NetworkACL{ID: "acl-db", Entries: allowAll}
+				n.SecurityGroups["sg-rds"].Ingress = nil
+			},
+			dst:    rds,
+			reason: "no security group of 10.0.20.15 (sg-rds) allows inbound TCP 5432 from the task's security groups or 10.0.10.0/24",
+		},
+		{
+			name: "RDS replies blocked",
+			change: func(n *Network) {
+				n.NetworkACLs["acl-db"].Entries = n.NetworkACLs["acl-db"].Entries[:1]
+			},
+			dst:    rds,
+			reason: "network ACL acl-db of subnet-db-a denies outbound replies TCP 32768-60999",
+		},
+		{
+			name:   "unknown subnet",
+			src:    Source{SubnetID: "subnet-missing", SecurityGroupIDs: []string{"sg-bridge"}},
+			dst:    Internet,
+			reason: "subnet subnet-missing is not in vpc-1",
+		},
+		{
+			name:   "unknown security group",
+			src:    Source{SubnetID: "subnet-private-a", SecurityGroupIDs: []string{"sg-missing"}},
+			dst:    Internet,
+			reason: "security group sg-missing is not in vpc-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := fixture()
+			if tt.change != nil {
+				tt.change(n)
+			}
+			src := tt.src
+			if src.SubnetID == "" {
+				src = bridge
+			}
+			r := n.Check(src, tt.dst)
+			if tt.reason == "" {
+				assert.True(t, r.Reachable, r.Explain())
+				assert.Equal(t, tt.via, r.Via)
+				assert.Empty(t, r.Reason())
+				return
+			}
+			assert.False(t, r.Reachable, r.Explain())
+			assert.Contains(t, r.Reason(), tt.reason)
+			assert.Contains(t, r.String(), "NOT reachable")
+			assert.False(t, r.Steps[len(r.Steps)-1].OK, "the failed step is the last")
+		})
+	}
+}
+
+func TestCheckPicksEndpointInSameZone(t *testing.T) {
+	n := fixture()
+	r := n.Check(Source{SubnetID: "subnet-private-c", SecurityGroupIDs: []string{"sg-bridge"}}, ECRAPI)
+	require.True(t, r.Reachable, r.Explain())
+	assert.Contains(t, r.Explain(), "ecr.api resolves to interface endpoint vpce-ecr-api in subnet-private-c")
+}
+
+// fakeEC2 serves the fixture's VPC in the shape of the EC2 API.
+type fakeEC2 struct {
+	ec2iface.EC2API
+}
+
+func (fakeEC2) DescribeVpcsWithContext(aws.Context, *ec2.DescribeVpcsInput, ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	return &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{
+		VpcId:                   aws.String("vpc-1"),
+		CidrBlock:               aws.String("10.0.0.0/16"),
+		CidrBlockAssociationSet: []*ec2.VpcCidrBlockAssociation{{CidrBlock: aws.String("10.0.0.0/16")}},
+	}}}, nil
+}
+
+func (fakeEC2) DescribeSubnetsPagesWithContext(_ aws.Context, _ *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+		{SubnetId: aws.String("subnet-private-a"), AvailabilityZone: aws.String("ap-northeast-1a"), CidrBlock: aws.String("10.0.10.0/24")},
+		{SubnetId: aws.String("subnet-public-a"), AvailabilityZone: aws.String("ap-northeast-1a"), CidrBlock: aws.String("10.0.0.0/24")},
+	}}, true)
+	return nil
+}
+
+func (fakeEC2) DescribeRouteTablesPagesWithContext(_ aws.Context, _ *ec2.DescribeRouteTablesInput, fn func(*ec2.DescribeRouteTablesOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{
+		{
+			RouteTableId: aws.String("rtb-private"),
+			Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-private-a")}},
+			Routes: []*ec2.Route{
+				{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: aws.String("active")},
+				{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1"), State: aws.String("active")},
+				{DestinationPrefixListId: aws.String("pl-s3"), GatewayId: aws.String("vpce-s3"), State: aws.String("active")},
+			},
+		},
+		{
+			RouteTableId: aws.String("rtb-public"),
+			Associations: []*ec2.RouteTableAssociation{{Main: aws.Bool(true)}},
+			Routes: []*ec2.Route{
+				{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), State: aws.String("active")},
+				{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1"), State: aws.String("active")},
+			},
+		},
+	}}, true)
+	return nil
+}
+
+func (fakeEC2) DescribeSecurityGroupsPagesWithContext(_ aws.Context, _ *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+		{
+			GroupId: aws.String("sg-bridge"),
+			IpPermissionsEgress: []*ec2.IpPermission{{
+				IpProtocol: aws.String("-1"),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			}},
+		},
+		{
+			GroupId: aws.String("sg-endpoints"),
+			IpPermissions: []*ec2.IpPermission{{
+				IpProtocol:       aws.String("tcp"),
+				FromPort:         aws.Int64(443),
+				ToPort:           aws.Int64(443),
+				UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-bridge")}},
+			}},
+		},
+	}}, true)
+	return nil
+}
+
+func (fakeEC2) DescribeNetworkAclsPagesWithContext(_ aws.Context, _ *ec2.DescribeNetworkAclsInput, fn func(*ec2.DescribeNetworkAclsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeNetworkAclsOutput{NetworkAcls: []*ec2.NetworkAcl{{
+		NetworkAclId: aws.String("acl-default"),
+		Associations: []*ec2.NetworkAclAssociation{{SubnetId: aws.String("subnet-private-a")}, {SubnetId: aws.String("subnet-public-a")}},
+		Entries: []*ec2.NetworkAclEntry{
+			{RuleNumber: aws.Int64(100), Egress: aws.Bool(false), RuleAction: aws.String("allow"), Protocol: aws.String("6"), CidrBlock: aws.String("0.0.0.0/0"), PortRange: &ec2.PortRange{From: aws.Int64(1024), To: aws.Int64(65535)}},
+			{RuleNumber: aws.Int64(100), Egress: aws.Bool(true), RuleAction: aws.String("allow"), Protocol: aws.String("6"), CidrBlock: aws.String("0.0.0.0/0"), PortRange: &ec2.PortRange{From: aws.Int64(443), To: aws.Int64(443)}},
+			{RuleNumber: aws.Int64(32767), Egress: aws.Bool(true), RuleAction: aws.String("deny"), Protocol: aws.String("-1"), CidrBlock: aws.String("0.0.0.0/0")},
+		},
+	}}}, true)
+	return nil
+}
+
+func (fakeEC2) DescribeNatGatewaysPagesWithContext(_ aws.Context, _ *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{
+		{NatGatewayId: aws.String("nat-1"), SubnetId: aws.String("subnet-public-a"), State: aws.String("available")},
+	}}, true)
+	return nil
+}
+
+func (fakeEC2) DescribeVpcEndpointsPagesWithContext(_ aws.Context, _ *ec2.DescribeVpcEndpointsInput, fn func(*ec2.DescribeVpcEndpointsOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeVpcEndpointsOutput{VpcEndpoints: []*ec2.VpcEndpoint{
+		{
+			VpcEndpointId: aws.String("vpce-ecr-api"), ServiceName: aws.String("com.amazonaws.ap-northeast-1.ecr.api"),
+			VpcEndpointType: aws.String("Interface"), State: aws.String("Available"), PrivateDnsEnabled: aws.Bool(true),
+			SubnetIds: aws.StringSlice([]string{"subnet-private-a"}), Groups: []*ec2.SecurityGroupIdentifier{{GroupId: aws.String("sg-endpoints")}},
+		},
+		{
+			VpcEndpointId: aws.String("vpce-s3"), ServiceName: aws.String("com.amazonaws.ap-northeast-1.s3"),
+			VpcEndpointType: aws.String("Gateway"), State: aws.String("available"),
+			RouteTableIds: aws.StringSlice([]string{"rtb-private"}),
+		},
+	}}, true)
+	return nil
+}
+
+func TestFromEC2(t *testing.T) {
+	n, err := FromEC2(context.Background(), fakeEC2{}, "vpc-1")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{vpcCIDR}, n.CIDRs)
+	assert.Equal(t, "rtb-public", n.MainRouteTableID)
+	assert.Equal(t, "rtb-private", n.Subnets["subnet-private-a"].RouteTableID)
+	assert.Equal(t, "", n.Subnets["subnet-public-a"].RouteTableID, "uses the main route table")
+	assert.Equal(t, "acl-default", n.Subnets["subnet-private-a"].NetworkACLID)
+	assert.Equal(t, "pl-s3", n.Endpoints[1].PrefixListID)
+	assert.Equal(t, "available", n.Endpoints[0].State)
+
+	for _, dst := range []Destination{Internet, ECRAPI, S3} {
+		r := n.Check(bridge, dst)
+		assert.True(t, r.Reachable, r.Explain())
+	}
+	// Only 443 leaves the subnet
+	r := n.Check(bridge, Destination{Name: "SMTP", Port: 25})
+	assert.False(t, r.Reachable)
+	assert.Contains(t, r.Reason(), "denies outbound TCP 25 for the internet (0.0.0.0/0) (rule 32767)")
+}
+
+// modulePlan is a trimmed plan of the module: new security groups whose
+// rules refer to each other, an interface endpoint, and a default route to
+// a new NAT gateway chosen through a local.
+const modulePlan = `{
+  "planned_values": {"root_module": {"child_modules": [{"address": "module.bridge", "resources": [
+    {"address": "module.bridge.aws_security_group.bridge", "mode": "managed", "type": "aws_security_group", "name": "bridge",
+     "values": {"name_prefix": "bridge-"}},
+    {"address": "module.bridge.aws_security_group.vpc_endpoints", "mode": "managed", "type": "aws_security_group", "name": "vpc_endpoints",
+     "values": {}},
+    {"address": "module.bridge.aws_security_group_rule.bridge_egress_all", "mode": "managed", "type": "aws_security_group_rule", "name": "bridge_egress_all",
+     "values": {"type": "egress", "protocol": "-1", "from_port": 0, "to_port": 0, "cidr_blocks": ["0.0.0.0/0"], "self": false}},
+    {"address": "module.bridge.aws_security_group_rule.vpc_endpoints_ingress_https", "mode": "managed", "type": "aws_security_group_rule", "name": "vpc_endpoints_ingress_https",
+     "values": {"type": "ingress", "protocol": "tcp", "from_port": 443, "to_port": 443, "cidr_blocks": null, "self": false}},
+    {"address": "module.bridge.aws_vpc_endpoint.ecr_dkr", "mode": "managed", "type": "aws_vpc_endpoint", "name": "ecr_dkr",
+     "values": {"service_name": "com.amazonaws.ap-northeast-1.ecr.dkr", "vpc_endpoint_type": "Interface", "private_dns_enabled": true,
+                "subnet_ids": ["subnet-private-a", "subnet-private-c"]}},
+    {"address": "module.bridge.aws_nat_gateway.bridge[0]", "mode": "managed", "type": "aws_nat_gateway", "name": "bridge", "index": 0,
+     "values": {"subnet_id": "subnet-public-a"}},
+    {"address": "module.bridge.aws_route.private_nat_gateway[\"subnet-private-a\"]", "mode": "managed", "type": "aws_route", "name": "private_nat_gateway",
+     "values": {"route_table_id": "rtb-private", "destination_cidr_block": "0.0.0.0/0", "gateway_id": null}}
+  ]}]}},
+  "configuration": {"root_module": {"module_calls": {"bridge": {"source": "../../modules/aws/ecs-fargate", "module": {"resources": [
+    {"address": "aws_security_group.bridge", "mode": "managed", "type": "aws_security_group", "name": "bridge"},
+    {"address": "aws_security_group.vpc_endpoints", "mode": "managed", "type": "aws_security_group", "name": "vpc_endpoints"},
+    {"address": "aws_security_group_rule.bridge_egress_all", "mode": "managed", "type": "aws_security_group_rule", "name": "bridge_egress_all",
+     "expressions": {"security_group_id": {"references": ["aws_security_group.bridge.id", "aws_security_group.bridge"]}}},
+    {"address": "aws_security_group_rule.vpc_endpoints_ingress_https", "mode": "managed", "type": "aws_security_group_rule", "name": "vpc_endpoints_ingress_https",
+     "expressions": {
+       "security_group_id": {"references": ["aws_security_group.vpc_endpoints.id", "aws_security_group.vpc_endpoints"]},
+       "source_security_group_id": {"references": ["aws_security_group.bridge.id", "aws_security_group.bridge"]}
+     }},
+    {"address": "aws_vpc_endpoint.ecr_dkr", "mode": "managed", "type": "aws_vpc_endpoint", "name": "ecr_dkr",
+     "expressions": {"security_group_ids": {"references": ["aws_security_group.vpc_endpoints.id", "aws_security_group.vpc_endpoints"]}}},
+    {"address": "aws_nat_gateway.bridge", "mode": "managed", "type": "aws_nat_gateway", "name": "bridge"},
+    {"address": "aws_route.private_nat_gateway", "mode": "managed", "type": "aws_route", "name": "private_nat_gateway",
+     "expressions": {"nat_gateway_id": {"references": ["local.nat_gateway_id"]}}}
+  ]}}}}}
+}`
+
+func TestAddPlan(t *testing.T) {
+	plan, err := tfplan.Parse([]byte(modulePlan))
+	require.NoError(t, err)
+
+	// The existing VPC has no way out yet
+	n := fixture()
+	n.RouteTables["rtb-private"].Routes = n.RouteTables["rtb-private"].Routes[:1]
+	delete(n.NATGateways, "nat-1")
+	require.NoError(t, n.AddPlan(plan))
+
+	src := Source{SubnetID: "subnet-private-a", SecurityGroupIDs: []string{"module.bridge.aws_security_group.bridge"}}
+	r := n.Check(src, ECRDocker)
+	assert.True(t, r.Reachable, r.Explain())
+	assert.Equal(t, "module.bridge.aws_vpc_endpoint.ecr_dkr", r.Via)
+
+	r = n.Check(src, Internet)
+	assert.True(t, r.Reachable, r.Explain())
+	assert.Equal(t, "module.bridge.aws_nat_gateway.bridge[0]", r.Via)
+
+	// Without the ingress rule the endpoint refuses the task
+	sg := n.SecurityGroups["module.bridge.aws_security_group.vpc_endpoints"]
+	sg.Ingress = nil
+	r = n.Check(src, ECRDocker)
+	assert.False(t, r.Reachable)
+	assert.Contains(t, r.Reason(), "allows inbound TCP 443")
+}
+
+func TestAddPlanUnknownRouteTable(t *testing.T) {
+	plan, err := tfplan.Parse([]byte(modulePlan))
+	require.NoError(t, err)
+	n := fixture()
+	delete(n.RouteTables, "rtb-private")
+	err = n.AddPlan(plan)
+	assert.ErrorContains(t, err, `route table "rtb-private" is not in vpc-1`)
+}
@@ -0,0 +1,346 @@
+// Package report records the phases of an integration test run (apply,
+// certificate issuance, first running task, destroy, ...) with their timing
+// and outcome, and writes them as JSON and JUnit XML so that provisioning
+// time can be compared across runs.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome is the result of a phase.
+type Outcome string
+
+const (
+	OutcomeRunning Outcome = "running"
+	OutcomePassed  Outcome = "passed"
+	OutcomeFailed  Outcome = "failed"
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// Phase is one timed step of a run.
+type Phase struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	// End is nil, and left out of the JSON, while the phase is running.
+	End     *time.Time `json:"end,omitempty"`
+	Outcome Outcome    `json:"outcome"`
+	// Seconds is End-Start, for consumers that do not parse timestamps.
+	Seconds float64 `json:"seconds"`
+	Error   string  `json:"error,omitempty"`
+	// Metrics holds additional numbers such as attempt counts.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	report *Report
+}
+
+// Duration returns End-Start, or zero while the phase is running.
+func (p *Phase) Duration() time.Duration {
+	if p.End == nil {
+		return 0
+	}
+	return p.End.Sub(p.Start)
+}
+
+// Finish ends the phase as passed when err is nil and failed otherwise.
+// Finishing a phase twice keeps the first outcome.
+func (p *Phase) Finish(err error) {
+	if err != nil {
+		p.finish(OutcomeFailed, err.Error())
+		return
+	}
+	p.finish(OutcomePassed, "")
+}
+
+// Skip ends the phase as skipped.
+func (p *Phase) Skip(reason string) {
+	p.finish(OutcomeSkipped, reason)
+}
+
+// SetMetric records a named number on the phase.
+func (p *Phase) SetMetric(name string, value float64) {
+	p.report.mu.Lock()
+	defer p.report.mu.Unlock()
+	if p.Metrics == nil {
+		p.Metrics = map[string]float64{}
+	}
+	p.Metrics[name] = value
+}
+
+func (p *Phase) finish(outcome Outcome, msg string) {
+	r := p.report
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Outcome != OutcomeRunning {
+		return
+	}
+	end := r.now()
+	p.End = &end
+	p.Seconds = end.Sub(p.Start).Seconds()
+	p.Outcome = outcome
+	p.Error = msg
+}
+
+// Report is the set of phases of one run. It is safe for concurrent use.
+type Report struct {
+	Suite string    `json:"suite"`
+	RunID string    `json:"run_id"`
+	Start time.Time `json:"start"`
+	// End is nil, and left out of the JSON, until Close.
+	End    *time.Time        `json:"end,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Phases []*Phase          `json:"phases"`
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// New returns a report for one run of suite.
+func New(suite, runID string) *Report {
+	return NewWithClock(suite, runID, time.Now)
+}
+
+// NewWithClock is New with an injectable clock, for tests.
+func NewWithClock(suite, runID string, now func() time.Time) *Report {
+	return &Report{Suite: suite, RunID: runID, Start: now(), now: now}
+}
+
+// SetLabel records a string attribute of the run, such as the region.
+func (r *Report) SetLabel(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Labels == nil {
+		r.Labels = map[string]string{}
+	}
+	r.Labels[key] = value
+}
+
+// Begin starts a phase now.
+func (r *Report) Begin(name string) *Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &Phase{Name: name, Start: r.now(), Outcome: OutcomeRunning, report: r}
+	r.Phases = append(r.Phases, p)
+	return p
+}
+
+// Add records a phase whose timing was observed elsewhere, e.g. an ACM
+// certificate's CreatedAt and IssuedAt.
+func (r *Report) Add(name string, start, end time.Time, err error) *Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &Phase{Name: name, Start: start, End: &end, Seconds: end.Sub(start).Seconds(), Outcome: OutcomePassed, report: r}
+	if err != nil {
+		p.Outcome = OutcomeFailed
+		p.Error = err.Error()
+	}
+	r.Phases = append(r.Phases, p)
+	return p
+}
+
+// Run times fn as a phase and returns its error.
+func (r *Report) Run(name string, fn func() error) error {
+	p := r.Begin(name)
+	err := fn()
+	p.Finish(err)
+	return err
+}
+
+// Phase returns the last phase with the given name, or nil.
+func (r *Report) Phase(name string) *Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.Phases) - 1; i >= 0; i-- {
+		if r.Phases[i].Name == name {
+			return r.Phases[i]
+		}
+	}
+	return nil
+}
+
+// Close ends the run. Phases still running are marked failed with reason,
+// which happens when a require assertion aborts the test mid-phase.
+func (r *Report) Close(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for _, p := range r.Phases {
+		if p.Outcome == OutcomeRunning {
+			p.End = &now
+			p.Seconds = now.Sub(p.Start).Seconds()
+			p.Outcome = OutcomeFailed
+			p.Error = reason
+		}
+	}
+	r.End = &now
+}
+
+// Failed reports whether any phase failed.
+func (r *Report) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.Phases {
+		if p.Outcome == OutcomeFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite with one test
+// case per phase, so CI systems can chart phase durations.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	suite := junitSuite{
+		Name:      r.Suite,
+		Tests:     len(r.Phases),
+		Timestamp: r.Start.UTC().Format(time.RFC3339),
+	}
+	if r.End != nil {
+		suite.Time = seconds(r.End.Sub(r.Start).Seconds())
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	suite.Properties = append(suite.Properties, junitProperty{Name: "run_id", Value: r.RunID})
+	for _, k := range keys {
+		suite.Properties = append(suite.Properties, junitProperty{Name: k, Value: r.Labels[k]})
+	}
+
+	for _, p := range r.Phases {
+		c := junitCase{Name: p.Name, Classname: r.Suite, Time: seconds(p.Seconds), SystemOut: formatMetrics(p.Metrics)}
+		switch p.Outcome {
+		case OutcomeFailed:
+			suite.Failures++
+			c.Failure = &junitMessage{Message: p.Error}
+		case OutcomeSkipped:
+			suite.Skipped++
+			c.Skipped = &junitMessage{Message: p.Error}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFiles writes <suite>-<run id>.json and <suite>-<run id>.junit.xml
+// into dir and returns their paths.
+func (r *Report) WriteFiles(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", r.Suite, r.RunID))
+	var paths []string
+	var errs []error
+	for _, f := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{base + ".json", r.WriteJSON},
+		{base + ".junit.xml", r.WriteJUnit},
+	} {
+		if err := writeFile(f.path, f.write); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		paths = append(paths, f.path)
+	}
+	return paths, errors.Join(errs...)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func formatMetrics(m map[string]float64) string {
+	if len(m) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := ""
+	for _, k := range keys {
+		out += fmt.Sprintf("%s=%g\n", k, m[k])
+	}
+	return out
+}
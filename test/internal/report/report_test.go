@@ -0,0 +1,182 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// steppingClock advances by step on every call.
+func steppingClock(step time.Duration) func() time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		t := now
+		now = now.Add(step)
+		return t
+	}
+}
+
+func newTestReport() *Report {
+	r := NewWithClock("aws-ecs-fargate", "abc123", steppingClock(time.Minute))
+	r.SetLabel("region", "ap-northeast-1")
+
+	apply := r.Begin("init_and_apply")
+	apply.Finish(nil)
+
+	issuedAt := time.Date(2024, 1, 1, 0, 4, 30, 0, time.UTC)
+	r.Add("acm_certificate_issued", issuedAt.Add(-90*time.Second), issuedAt, nil)
+
+	task := r.Begin("first_running_task")
+	task.SetMetric("attempts", 3)
+	task.Finish(errors.New("ECS Service should have 1 running tasks"))
+
+	r.Begin("healthy_target").Skip("no running task")
+	r.Begin("destroy")
+	r.Close("test failed during this phase")
+	return r
+}
+
+func TestPhaseTiming(t *testing.T) {
+	r := newTestReport()
+
+	apply := r.Phase("init_and_apply")
+	require.NotNil(t, apply)
+	assert.Equal(t, OutcomePassed, apply.Outcome)
+	assert.Equal(t, time.Minute, apply.Duration())
+	assert.Equal(t, 60.0, apply.Seconds)
+
+	cert := r.Phase("acm_certificate_issued")
+	assert.Equal(t, 90*time.Second, cert.Duration())
+
+	task := r.Phase("first_running_task")
+	assert.Equal(t, OutcomeFailed, task.Outcome)
+	assert.Equal(t, "ECS Service should have 1 running tasks", task.Error)
+	assert.Equal(t, map[string]float64{"attempts": 3}, task.Metrics)
+
+	assert.Equal(t, OutcomeSkipped, r.Phase("healthy_target").Outcome)
+
+	destroy := r.Phase("destroy")
+	assert.Equal(t, OutcomeFailed, destroy.Outcome, "running phases are failed on Close")
+	assert.Equal(t, "test failed during this phase", destroy.Error)
+
+	assert.True(t, r.Failed())
+	assert.Nil(t, r.Phase("missing"))
+}
+
+func TestFinishTwiceKeepsFirstOutcome(t *testing.T) {
+	r := NewWithClock("s", "1", steppingClock(time.Second))
+	p := r.Begin("apply")
+	p.Finish(nil)
+	p.Finish(errors.New("late"))
+	assert.Equal(t, OutcomePassed, p.Outcome)
+	assert.False(t, r.Failed())
+}
+
+func TestRun(t *testing.T) {
+	r := NewWithClock("s", "1", steppingClock(time.Second))
+	boom := errors.New("boom")
+	assert.ErrorIs(t, r.Run("destroy", func() error { return boom }), boom)
+	assert.Equal(t, OutcomeFailed, r.Phase("destroy").Outcome)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestReport().WriteJSON(&buf))
+
+	var decoded struct {
+		Suite  string
+		RunID  string `json:"run_id"`
+		Labels map[string]string
+		Phases []struct {
+			Name    string
+			Outcome string
+			Seconds float64
+			Metrics map[string]float64
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "aws-ecs-fargate", decoded.Suite)
+	assert.Equal(t, "abc123", decoded.RunID)
+	assert.Equal(t, "ap-northeast-1", decoded.Labels["region"])
+	require.Len(t, decoded.Phases, 5)
+	assert.Equal(t, "acm_certificate_issued", decoded.Phases[1].Name)
+	assert.Equal(t, 90.0, decoded.Phases[1].Seconds)
+	assert.Equal(t, 3.0, decoded.Phases[2].Metrics["attempts"])
+}
+
+func TestWriteJSONLeavesOutEndWhileRunning(t *testing.T) {
+	r := NewWithClock("s", "1", steppingClock(time.Minute))
+	r.Begin("init_and_apply")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteJSON(&buf))
+	assert.NotContains(t, buf.String(), `"end"`, "neither the running phase nor the open report has ended")
+	assert.Zero(t, r.Phase("init_and_apply").Duration())
+
+	r.Close("aborted")
+	buf.Reset()
+	require.NoError(t, r.WriteJSON(&buf))
+	var decoded struct {
+		End    *time.Time
+		Phases []struct{ End *time.Time }
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.NotNil(t, decoded.End)
+	require.NotNil(t, decoded.Phases[0].End)
+	assert.Equal(t, *decoded.End, *decoded.Phases[0].End)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newTestReport().WriteJUnit(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, `<testsuite name="aws-ecs-fargate" tests="5" failures="2" skipped="1"`)
+	assert.Contains(t, out, `<property name="run_id" value="abc123"></property>`)
+	assert.Contains(t, out, `<property name="region" value="ap-northeast-1"></property>`)
+	assert.Contains(t, out, `<testcase name="init_and_apply" classname="aws-ecs-fargate" time="60.000"></testcase>`)
+	assert.Contains(t, out, `<failure message="ECS Service should have 1 running tasks"></failure>`)
+	assert.Contains(t, out, `<skipped message="no running task"></skipped>`)
+	assert.Contains(t, out, "<system-out>attempts=3&#xA;</system-out>")
+}
+
+func TestForTestWritesFilesOnCleanup(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+
+	fake := &fakeTB{}
+	r := ForTest(fake, "gcp-cloud-run", "xyz")
+	r.Begin("init_and_apply").Finish(nil)
+	r.Begin("managed_certificate")
+	fake.failed = true
+	fake.runCleanup()
+
+	data, err := os.ReadFile(filepath.Join(dir, "gcp-cloud-run-xyz.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"test failed during this phase"`)
+	_, err = os.Stat(filepath.Join(dir, "gcp-cloud-run-xyz.junit.xml"))
+	assert.NoError(t, err)
+	assert.Len(t, fake.logs, 2)
+}
+
+type fakeTB struct {
+	cleanups []func()
+	failed   bool
+	logs     []string
+}
+
+func (f *fakeTB) Cleanup(fn func())            { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Failed() bool                 { return f.failed }
+func (f *fakeTB) Logf(format string, _ ...any) { f.logs = append(f.logs, format) }
+func (f *fakeTB) runCleanup() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DirEnv names the environment variable overriding the report directory.
+const DirEnv = "TEST_REPORT_DIR"
+
+// DefaultDir is used when DirEnv is unset. Tests run from test/aws and
+// test/gcp, so this resolves to test/reports.
+const DefaultDir = "../reports"
+
+// Dir returns the directory reports are written to.
+func Dir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// TB is the subset of testing.TB used by ForTest.
+type TB interface {
+	Cleanup(func())
+	Failed() bool
+	Logf(format string, args ...any)
+}
+
+// ForTest returns a report that is closed and written to Dir() when the
+// test finishes, after its deferred calls (including terraform destroy)
+// have run.
+func ForTest(t TB, suite, runID string) *Report {
+	r := New(suite, runID)
+	t.Cleanup(func() {
+		reason := "phase did not complete"
+		if t.Failed() {
+			reason = "test failed during this phase"
+		}
+		r.Close(reason)
+		paths, err := r.WriteFiles(Dir())
+		if err != nil {
+			t.Logf("failed to write test report: %v", err)
+		}
+		for _, p := range paths {
+			if abs, err := filepath.Abs(p); err == nil {
+				p = abs
+			}
+			t.Logf("Test report written: %s", p)
+		}
+	})
+	return r
+}
@@ -0,0 +1,114 @@
+// Package runstate reads the rollout state of a Cloud Run v2 service
+// (latest ready revision and traffic split) so that tests can assert that
+// a new revision took over all traffic.
+package runstate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/googleapis/gax-go/v2"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+// ServiceGetter is the part of run.ServicesClient used by this package.
+type ServiceGetter interface {
+	GetService(ctx context.Context, req *runpb.GetServiceRequest, opts ...gax.CallOption) (*runpb.Service, error)
+}
+
+// Service is a snapshot of a Cloud Run service's rollout state.
+type Service struct {
+	Name                  string
+	LatestReadyRevision   string
+	LatestCreatedRevision string
+	Reconciling           bool
+	Ready                 bool
+	// ReadyTime is the last transition time of the terminal condition,
+	// i.e. when the latest rollout finished.
+	ReadyTime time.Time
+	// Traffic maps a revision's short name to its percent of traffic.
+	Traffic map[string]int32
+}
+
+// FromProto converts a service returned by the Cloud Run API.
+func FromProto(svc *runpb.Service) *Service {
+	s := &Service{
+		Name:                  svc.GetName(),
+		LatestReadyRevision:   shortName(svc.GetLatestReadyRevision()),
+		LatestCreatedRevision: shortName(svc.GetLatestCreatedRevision()),
+		Reconciling:           svc.GetReconciling(),
+		Traffic:               map[string]int32{},
+	}
+	if cond := svc.GetTerminalCondition(); cond.GetState() == runpb.Condition_CONDITION_SUCCEEDED {
+		s.Ready = true
+		if cond.GetLastTransitionTime() != nil {
+			s.ReadyTime = cond.GetLastTransitionTime().AsTime()
+		}
+	}
+	for _, status := range svc.GetTrafficStatuses() {
+		revision := shortName(status.GetRevision())
+		// LATEST allocations may omit the resolved revision.
+		if revision == "" && status.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			revision = s.LatestReadyRevision
+		}
+		s.Traffic[revision] += status.GetPercent()
+	}
+	return s
+}
+
+// shortName strips projects/*/locations/*/services/*/revisions/ from a
+// revision name.
+func shortName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// ServesOnly reports whether the service is ready, not reconciling, and
+// sends 100% of its traffic to revision, which is also the latest ready
+// and latest created revision.
+func (s *Service) ServesOnly(revision string) bool {
+	return s.Ready && !s.Reconciling &&
+		s.LatestReadyRevision == revision &&
+		s.LatestCreatedRevision == revision &&
+		s.Traffic[revision] == 100
+}
+
+// String returns a one-line summary for logs.
+func (s *Service) String() string {
+	return fmt.Sprintf("latestReady=%s latestCreated=%s reconciling=%v ready=%v traffic=%v",
+		s.LatestReadyRevision, s.LatestCreatedRevision, s.Reconciling, s.Ready, s.Traffic)
+}
+
+// Describe returns the current rollout state of the service with the full
+// resource name (projects/*/locations/*/services/*).
+func Describe(ctx context.Context, client ServiceGetter, name string) (*Service, error) {
+	svc, err := client.GetService(ctx, &runpb.GetServiceRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("get service %s: %w", name, err)
+	}
+	return FromProto(svc), nil
+}
+
+// WaitRollout polls the service until a revision other than oldRevision
+// is ready and serves 100% of the traffic, and returns that state.
+func WaitRollout(ctx context.Context, client ServiceGetter, name, oldRevision string, opts poll.Options) (*Service, error) {
+	var state *Service
+	err := poll.Until(ctx, opts, func(ctx context.Context) error {
+		s, err := Describe(ctx, client, name)
+		if err != nil {
+			return err
+		}
+		state = s
+		if s.LatestCreatedRevision == oldRevision {
+			return fmt.Errorf("no new revision yet: %s", s)
+		}
+		if !s.ServesOnly(s.LatestCreatedRevision) {
+			return fmt.Errorf("revision %s not serving all traffic: %s", s.LatestCreatedRevision, s)
+		}
+		return nil
+	})
+	return state, err
+}
@@ -0,0 +1,127 @@
+package runstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+)
+
+const servicePath = "projects/p/locations/asia-northeast1/services/bridge"
+
+func revision(name string) string { return servicePath + "/revisions/" + name }
+
+func service(created, ready string, reconciling bool, traffic ...*runpb.TrafficTargetStatus) *runpb.Service {
+	return &runpb.Service{
+		Name:                  servicePath,
+		LatestCreatedRevision: revision(created),
+		LatestReadyRevision:   revision(ready),
+		Reconciling:           reconciling,
+		TerminalCondition: &runpb.Condition{
+			Type:               "Ready",
+			State:              runpb.Condition_CONDITION_SUCCEEDED,
+			LastTransitionTime: timestamppb.New(time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC)),
+		},
+		TrafficStatuses: traffic,
+	}
+}
+
+func latest(percent int32) *runpb.TrafficTargetStatus {
+	return &runpb.TrafficTargetStatus{
+		Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+		Percent: percent,
+	}
+}
+
+func pinned(name string, percent int32) *runpb.TrafficTargetStatus {
+	return &runpb.TrafficTargetStatus{
+		Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+		Revision: name,
+		Percent:  percent,
+	}
+}
+
+// fakeServices replays one service per GetService call, holding the last.
+type fakeServices struct {
+	services []*runpb.Service
+	calls    int
+}
+
+func (f *fakeServices) GetService(_ context.Context, req *runpb.GetServiceRequest, _ ...gax.CallOption) (*runpb.Service, error) {
+	i := f.calls
+	if i >= len(f.services) {
+		i = len(f.services) - 1
+	}
+	f.calls++
+	return f.services[i], nil
+}
+
+func TestFromProto(t *testing.T) {
+	s := FromProto(service("bridge-00002", "bridge-00002", false, latest(100)))
+
+	assert.Equal(t, "bridge-00002", s.LatestReadyRevision)
+	assert.Equal(t, "bridge-00002", s.LatestCreatedRevision)
+	assert.Equal(t, map[string]int32{"bridge-00002": 100}, s.Traffic, "LATEST resolves to the latest ready revision")
+	assert.True(t, s.Ready)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC), s.ReadyTime)
+	assert.True(t, s.ServesOnly("bridge-00002"))
+	assert.False(t, s.ServesOnly("bridge-00001"))
+}
+
+func TestServesOnly(t *testing.T) {
+	cases := map[string]*runpb.Service{
+		"split traffic":          service("bridge-00002", "bridge-00002", false, pinned("bridge-00001", 50), pinned("bridge-00002", 50)),
+		"still reconciling":      service("bridge-00002", "bridge-00002", true, latest(100)),
+		"new revision not ready": service("bridge-00002", "bridge-00001", false, latest(100)),
+	}
+	for name, svc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.False(t, FromProto(svc).ServesOnly("bridge-00002"))
+		})
+	}
+
+	failed := service("bridge-00002", "bridge-00002", false, latest(100))
+	failed.TerminalCondition.State = runpb.Condition_CONDITION_FAILED
+	assert.False(t, FromProto(failed).ServesOnly("bridge-00002"))
+}
+
+func TestWaitRollout(t *testing.T) {
+	client := &fakeServices{services: []*runpb.Service{
+		service("bridge-00001", "bridge-00001", false, latest(100)),
+		service("bridge-00002", "bridge-00001", true, latest(100)),
+		service("bridge-00002", "bridge-00002", false, latest(100)),
+	}}
+
+	clock := poll.NewFakeClock(time.Unix(0, 0))
+	s, err := WaitRollout(context.Background(), client, servicePath, "bridge-00001", poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second},
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bridge-00002", s.LatestReadyRevision)
+	assert.Equal(t, 3, client.calls)
+	assert.Len(t, clock.Waits(), 2)
+}
+
+func TestWaitRolloutTimeout(t *testing.T) {
+	client := &fakeServices{services: []*runpb.Service{
+		service("bridge-00001", "bridge-00001", false, latest(100)),
+	}}
+	s, err := WaitRollout(context.Background(), client, servicePath, "bridge-00001", poll.Options{
+		Timeout: time.Minute,
+		Backoff: poll.Backoff{Initial: 5 * time.Second},
+		Clock:   poll.NewFakeClock(time.Unix(0, 0)),
+	})
+	require.ErrorIs(t, err, poll.ErrTimeout)
+	assert.Contains(t, err.Error(), "no new revision yet")
+	require.NotNil(t, s)
+	assert.Equal(t, "bridge-00001", s.LatestCreatedRevision)
+}
@@ -0,0 +1,95 @@
+// Package teardown classifies errors returned by terraform destroy and retries
+// the destroy while the failure is one of the known transient cases.
+package teardown
+
+import (
+	"strings"
+)
+
+// Rule describes one known transient destroy error.
+// A rule matches when the error message contains any of its patterns
+// (case-insensitive).
+type Rule struct {
+	// Name is a short identifier used in logs and reports.
+	Name string
+	// Description explains why the error happens and why retrying helps.
+	Description string
+	// Patterns are substrings searched for in the error message.
+	Patterns []string
+}
+
+// Matches reports whether msg contains any of the rule's patterns.
+func (r Rule) Matches(msg string) bool {
+	lower := strings.ToLower(msg)
+	for _, p := range r.Patterns {
+		if p != "" && strings.Contains(lower, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Catalog is the list of known transient destroy errors for one provider.
+type Catalog struct {
+	// Provider is the Terraform provider name (e.g. "google", "aws").
+	Provider string
+	Rules    []Rule
+}
+
+// Classify returns the first rule matching err.
+// The second return value is false when err is nil or not a known transient error.
+func (c Catalog) Classify(err error) (Rule, bool) {
+	if err == nil {
+		return Rule{}, false
+	}
+	msg := err.Error()
+	for _, r := range c.Rules {
+		if r.Matches(msg) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// GCP lists the transient destroy errors seen with examples/gcp-cloud-run.
+// Most of them come from Cloud Run Direct VPC Egress, which keeps a
+// serverless-ipv4 address in the subnet for several minutes after the
+// service is deleted.
+var GCP = Catalog{
+	Provider: "google",
+	Rules: []Rule{
+		{
+			Name:        "serverless-ipv4-in-use",
+			Description: "Cloud Run Direct VPC Egress still holds a serverless-ipv4 address in the subnet (released 5-10 minutes after the service is deleted)",
+			Patterns:    []string{"serverless-ipv4"},
+		},
+		{
+			Name:        "resource-in-use",
+			Description: "The subnet or network is still referenced by another resource that is being deleted",
+			Patterns:    []string{"already being used", "resourceInUseByAnotherResource"},
+		},
+		{
+			Name:        "service-networking-peering",
+			Description: "The Service Networking peering used by Cloud SQL has not been released yet",
+			Patterns:    []string{"servicenetworking", "Producer services", "still using this connection"},
+		},
+	},
+}
+
+// AWS lists the transient destroy errors seen with examples/aws-ecs-fargate.
+// They are mostly ENIs that Fargate and VPC endpoints release asynchronously.
+var AWS = Catalog{
+	Provider: "aws",
+	Rules: []Rule{
+		{
+			Name:        "dependency-violation",
+			Description: "A security group or subnet is still referenced by an ENI that AWS has not released yet",
+			Patterns:    []string{"DependencyViolation", "has a dependent object"},
+		},
+		{
+			Name:        "resource-in-use",
+			Description: "The target group or load balancer is still in use by a listener being deleted",
+			Patterns:    []string{"ResourceInUse", "is currently in use"},
+		},
+	},
+}
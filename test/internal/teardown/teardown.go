@@ -0,0 +1,201 @@
+package teardown
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/poll"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Policy controls how a destroy is retried.
+// Only errors classified by Catalog are retried; any other error stops
+// immediately.
+type Policy struct {
+	Catalog Catalog
+
+	// MaxAttempts is the total number of destroy attempts (at least 1).
+	MaxAttempts int
+	// InitialDelay is waited once before the first attempt.
+	InitialDelay time.Duration
+	// BaseDelay is the wait after the first transient failure.
+	BaseDelay time.Duration
+	// Multiplier grows the wait after each further transient failure.
+	Multiplier float64
+	// MaxDelay caps a single wait (0 means no cap).
+	MaxDelay time.Duration
+
+	// Sleep is used for every wait. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+	// Logf receives progress messages. Defaults to a no-op.
+	Logf func(format string, args ...any)
+}
+
+// DefaultGCPPolicy waits 30s for the serverless-ipv4 release and then retries
+// up to 3 times, waiting 60s and 120s between attempts.
+func DefaultGCPPolicy() Policy {
+	return Policy{
+		Catalog:      GCP,
+		MaxAttempts:  3,
+		InitialDelay: 30 * time.Second,
+		BaseDelay:    60 * time.Second,
+		Multiplier:   2,
+		MaxDelay:     5 * time.Minute,
+	}
+}
+
+// DefaultAWSPolicy retries up to 3 times, waiting 30s and 60s between
+// attempts for Fargate and VPC endpoint ENIs to be released.
+func DefaultAWSPolicy() Policy {
+	return Policy{
+		Catalog:     AWS,
+		MaxAttempts: 3,
+		BaseDelay:   30 * time.Second,
+		Multiplier:  2,
+		MaxDelay:    5 * time.Minute,
+	}
+}
+
+// Delay returns the wait after the given failed attempt (1-based).
+func (p Policy) Delay(attempt int) time.Duration {
+	return poll.Backoff{Initial: p.BaseDelay, Max: p.MaxDelay, Multiplier: p.Multiplier}.Delay(attempt)
+}
+
+// Result is the outcome of a Policy run.
+type Result struct {
+	// Attempts is the number of destroy attempts made.
+	Attempts int
+	// Err is the last destroy error, or nil if the destroy succeeded.
+	Err error
+	// Rule is the catalog rule matching Err, if any.
+	Rule *Rule
+	// Remaining lists the managed resource addresses left in state after
+	// the last attempt. Empty when the destroy succeeded.
+	Remaining []string
+	// StateErr is set when the remaining addresses could not be listed.
+	StateErr error
+}
+
+// Known reports whether the run ended on a known transient error.
+func (r Result) Known() bool {
+	return r.Err != nil && r.Rule != nil
+}
+
+// Summary returns a human readable multi-line report of the result.
+func (r Result) Summary() string {
+	var b strings.Builder
+	if r.Err == nil {
+		fmt.Fprintf(&b, "destroy succeeded after %d attempt(s)\n", r.Attempts)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "destroy failed after %d attempt(s)\n", r.Attempts)
+	if r.Rule != nil {
+		fmt.Fprintf(&b, "known transient error: %s (%s)\n", r.Rule.Name, r.Rule.Description)
+	} else {
+		b.WriteString("unknown error (not in the transient error catalog)\n")
+	}
+	switch {
+	case r.StateErr != nil:
+		fmt.Fprintf(&b, "remaining resources: unknown (%v)\n", r.StateErr)
+	case len(r.Remaining) == 0:
+		b.WriteString("remaining resources: none\n")
+	default:
+		fmt.Fprintf(&b, "remaining resources (%d):\n", len(r.Remaining))
+		for _, addr := range r.Remaining {
+			fmt.Fprintf(&b, "  - %s\n", addr)
+		}
+	}
+	return b.String()
+}
+
+// Run calls destroy until it succeeds, fails with an error that is not in
+// the catalog, or MaxAttempts is reached. listState is called after a final
+// failure to find the addresses left in state; it may be nil.
+func (p Policy) Run(destroy func() error, listState func() ([]string, error)) Result {
+	sleep := p.Sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	logf := p.Logf
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	if p.InitialDelay > 0 {
+		logf("Waiting %v before the first destroy attempt...", p.InitialDelay)
+		sleep(p.InitialDelay)
+	}
+
+	var res Result
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		res.Attempts = attempt
+		logf("Destroy attempt %d/%d...", attempt, maxAttempts)
+
+		res.Err = destroy()
+		if res.Err == nil {
+			res.Rule = nil
+			return res
+		}
+
+		rule, ok := p.Catalog.Classify(res.Err)
+		if !ok {
+			res.Rule = nil
+			logf("Destroy failed with an unknown error, not retrying: %v", res.Err)
+			break
+		}
+		res.Rule = &rule
+
+		if attempt < maxAttempts {
+			wait := p.Delay(attempt)
+			logf("Destroy failed with known transient error %q. Waiting %v before retry...", rule.Name, wait)
+			sleep(wait)
+		}
+	}
+
+	if listState != nil {
+		res.Remaining, res.StateErr = listState()
+	}
+	return res
+}
+
+// Destroy runs terraform destroy for options under the policy and lists the
+// resources left in state if it does not succeed.
+func Destroy(t testing.TestingT, options *terraform.Options, p Policy) Result {
+	return p.Run(
+		func() error {
+			_, err := terraform.DestroyE(t, options)
+			return err
+		},
+		func() ([]string, error) {
+			out, err := terraform.RunTerraformCommandAndGetStdoutE(t, options, "state", "list")
+			if err != nil {
+				return nil, err
+			}
+			return ManagedAddresses(out), nil
+		},
+	)
+}
+
+// ManagedAddresses parses `terraform state list` output and drops data
+// sources, which are never destroyed.
+func ManagedAddresses(stateList string) []string {
+	var out []string
+	for _, line := range strings.Split(stateList, "\n") {
+		addr := strings.TrimSpace(line)
+		if addr == "" || isDataSource(addr) {
+			continue
+		}
+		out = append(out, addr)
+	}
+	return out
+}
+
+func isDataSource(addr string) bool {
+	return strings.HasPrefix(addr, "data.") || strings.Contains(addr, ".data.")
+}
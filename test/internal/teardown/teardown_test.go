@@ -0,0 +1,141 @@
+package teardown
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Error strings captured from failed destroys of the examples.
+const (
+	gcpSubnetServerlessIPv4 = `Error: Error waiting for Deleting Subnetwork: The subnetwork resource 'projects/p/regions/asia-northeast1/subnetworks/bridge-test-abc123-subnet' is already being used by 'projects/p/regions/asia-northeast1/addresses/serverless-ipv4-1712345678901234567'`
+	gcpNetworkInUse         = `Error: Error waiting for Deleting Network: The network resource 'projects/p/global/networks/bridge-test-abc123-vpc' is already being used by 'projects/p/global/routes/peering-route-0f1e2d3c4b5a6978'`
+	gcpServiceNetworking    = `Error: Unable to remove Service Networking Connection, err: Error waiting for Delete Service Networking Connection: Error code 9, message: Failed to delete connection; Producer services (e.g. CloudSQL, Cloud Memstore, etc.) are still using this connection.`
+	gcpPermissionDenied     = `Error: Error when reading or editing Subnetwork "bridge-test-abc123-subnet": googleapi: Error 403: Required 'compute.subnetworks.delete' permission for 'projects/p/regions/asia-northeast1/subnetworks/bridge-test-abc123-subnet', forbidden`
+	awsSecurityGroupInUse   = `Error: deleting Security Group (sg-0123456789abcdef0): DependencyViolation: resource sg-0123456789abcdef0 has a dependent object`
+	awsTargetGroupInUse     = `Error: deleting ELBv2 Target Group (arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/test-bridge-tg/0123456789abcdef): ResourceInUse: Target group 'arn:...' is currently in use by a listener or a rule`
+)
+
+func TestCatalogClassify(t *testing.T) {
+	cases := []struct {
+		name     string
+		catalog  Catalog
+		err      error
+		wantRule string
+	}{
+		{"gcp serverless-ipv4", GCP, errors.New(gcpSubnetServerlessIPv4), "serverless-ipv4-in-use"},
+		{"gcp network in use", GCP, errors.New(gcpNetworkInUse), "resource-in-use"},
+		{"gcp service networking", GCP, errors.New(gcpServiceNetworking), "service-networking-peering"},
+		{"gcp permission denied", GCP, errors.New(gcpPermissionDenied), ""},
+		{"gcp nil error", GCP, nil, ""},
+		{"aws dependency violation", AWS, errors.New(awsSecurityGroupInUse), "dependency-violation"},
+		{"aws target group in use", AWS, errors.New(awsTargetGroupInUse), "resource-in-use"},
+		{"aws does not match gcp errors", AWS, errors.New(gcpSubnetServerlessIPv4), ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, ok := tc.catalog.Classify(tc.err)
+			if tc.wantRule == "" {
+				assert.False(t, ok, "unexpected match: %s", rule.Name)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tc.wantRule, rule.Name)
+		})
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := DefaultGCPPolicy()
+	assert.Equal(t, 60*time.Second, p.Delay(1))
+	assert.Equal(t, 120*time.Second, p.Delay(2))
+	assert.Equal(t, 240*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Minute, p.Delay(4), "delay should be capped by MaxDelay")
+}
+
+func TestDefaultAWSPolicy(t *testing.T) {
+	var slept []time.Duration
+	p := DefaultAWSPolicy()
+	p.Sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	calls := 0
+	res := p.Run(func() error {
+		calls++
+		return errors.New(awsSecurityGroupInUse)
+	}, func() ([]string, error) {
+		return []string{"module.basemachina_bridge.aws_security_group.bridge"}, nil
+	})
+
+	assert.Equal(t, 3, calls)
+	require.True(t, res.Known())
+	assert.Equal(t, "dependency-violation", res.Rule.Name)
+	assert.Equal(t, []time.Duration{30 * time.Second, 60 * time.Second}, slept, "no initial wait")
+	assert.Equal(t, []string{"module.basemachina_bridge.aws_security_group.bridge"}, res.Remaining)
+}
+
+func TestPolicyRunRetriesKnownErrors(t *testing.T) {
+	var slept []time.Duration
+	p := DefaultGCPPolicy()
+	p.Sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	errs := []error{errors.New(gcpSubnetServerlessIPv4), errors.New(gcpNetworkInUse), nil}
+	calls := 0
+	res := p.Run(func() error {
+		err := errs[calls]
+		calls++
+		return err
+	}, func() ([]string, error) {
+		t.Fatal("state should not be listed after a successful destroy")
+		return nil, nil
+	})
+
+	require.NoError(t, res.Err)
+	assert.Equal(t, 3, res.Attempts)
+	assert.Nil(t, res.Rule)
+	assert.Equal(t, []time.Duration{30 * time.Second, 60 * time.Second, 120 * time.Second}, slept)
+}
+
+func TestPolicyRunStopsOnUnknownError(t *testing.T) {
+	p := DefaultGCPPolicy()
+	p.Sleep = func(time.Duration) {}
+
+	calls := 0
+	res := p.Run(func() error {
+		calls++
+		return errors.New(gcpPermissionDenied)
+	}, func() ([]string, error) {
+		return []string{"google_compute_subnetwork.main"}, nil
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.Error(t, res.Err)
+	assert.False(t, res.Known())
+	assert.Equal(t, []string{"google_compute_subnetwork.main"}, res.Remaining)
+	assert.Contains(t, res.Summary(), "unknown error")
+}
+
+func TestPolicyRunReportsRemainingAfterMaxAttempts(t *testing.T) {
+	p := DefaultGCPPolicy()
+	p.Sleep = func(time.Duration) {}
+
+	calls := 0
+	res := p.Run(func() error {
+		calls++
+		return errors.New(gcpSubnetServerlessIPv4)
+	}, func() ([]string, error) {
+		return ManagedAddresses("data.google_dns_managed_zone.main[0]\ngoogle_compute_network.main\ngoogle_compute_subnetwork.main\nmodule.basemachina_bridge.data.google_project.current\n"), nil
+	})
+
+	assert.Equal(t, 3, calls)
+	require.True(t, res.Known())
+	assert.Equal(t, "serverless-ipv4-in-use", res.Rule.Name)
+	assert.Equal(t, []string{"google_compute_network.main", "google_compute_subnetwork.main"}, res.Remaining)
+
+	summary := res.Summary()
+	assert.Contains(t, summary, "serverless-ipv4-in-use")
+	assert.Contains(t, summary, "google_compute_subnetwork.main")
+}
@@ -0,0 +1,84 @@
+// Package testutil holds test doubles shared by the AWS and GCP suites.
+package testutil
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// Recorder stands in for a *testing.T in validation code so that scenarios
+// which are expected to fail can be asserted on: failures are recorded
+// instead of failing the test, and FailNow (and Fatal, Fatalf) end the
+// goroutine like testing.T does. Logs go to T. Run the validation with Run.
+type Recorder struct {
+	T *testing.T
+
+	mu     sync.Mutex
+	errors []string
+}
+
+// NewRecorder returns a Recorder logging to t.
+func NewRecorder(t *testing.T) *Recorder {
+	return &Recorder{T: t}
+}
+
+// Run calls fn in a new goroutine and waits for it, so that FailNow ends
+// fn and not the test.
+func (r *Recorder) Run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
+
+// Errors returns the recorded failures in order.
+func (r *Recorder) Errors() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.errors...)
+}
+
+// Failed reports whether any failure was recorded.
+func (r *Recorder) Failed() bool { return len(r.Errors()) > 0 }
+
+func (r *Recorder) Error(args ...any) { r.record(fmt.Sprint(args...)) }
+
+func (r *Recorder) Errorf(format string, args ...any) { r.record(fmt.Sprintf(format, args...)) }
+
+func (r *Recorder) Fail() { r.record("Fail called") }
+
+func (r *Recorder) FailNow() { runtime.Goexit() }
+
+func (r *Recorder) Fatal(args ...any) {
+	r.Error(args...)
+	r.FailNow()
+}
+
+func (r *Recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	r.FailNow()
+}
+
+func (r *Recorder) Helper() { r.T.Helper() }
+
+func (r *Recorder) Log(args ...any) {
+	r.T.Helper()
+	r.T.Log(args...)
+}
+
+func (r *Recorder) Logf(format string, args ...any) {
+	r.T.Helper()
+	r.T.Logf(format, args...)
+}
+
+func (r *Recorder) Name() string { return r.T.Name() }
+
+func (r *Recorder) record(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, msg)
+}
@@ -0,0 +1,36 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(t)
+	reached := false
+	rec.Run(func() {
+		assert.Equal(rec, 1, 2)
+		rec.Logf("logged to %s", t.Name())
+		require.NoError(rec, assert.AnError)
+		reached = true
+	})
+
+	assert.False(t, reached, "FailNow ends the function")
+	assert.True(t, rec.Failed())
+	errs := rec.Errors()
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0], "Not equal")
+	assert.Contains(t, errs[1], assert.AnError.Error())
+	assert.False(t, t.Failed(), "the test itself does not fail")
+}
+
+func TestRecorderFatalf(t *testing.T) {
+	rec := NewRecorder(t)
+	rec.Run(func() {
+		rec.Fatalf("stop at %d", 1)
+		rec.Errorf("not reached")
+	})
+	assert.Equal(t, []string{"stop at 1"}, rec.Errors())
+}
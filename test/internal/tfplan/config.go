@@ -0,0 +1,115 @@
+package tfplan
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Configuration is the configuration section of the plan: the modules as
+// written, before evaluation.
+type Configuration struct {
+	RootModule ConfigModule `json:"root_module"`
+}
+
+// ConfigModule is a module of the configuration.
+type ConfigModule struct {
+	Resources   []ConfigResource          `json:"resources"`
+	ModuleCalls map[string]ModuleCall     `json:"module_calls"`
+	Variables   map[string]ConfigVariable `json:"variables"`
+}
+
+// ConfigResource is a resource block of a module.
+type ConfigResource struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	// Expressions are the resource's arguments as written, decoded by
+	// References.
+	Expressions map[string]json.RawMessage `json:"expressions"`
+}
+
+// References returns what the expression of the argument refers to, e.g.
+// ["aws_security_group.bridge.id", "aws_security_group.bridge"]; nil for
+// constants and unset arguments.
+func (r ConfigResource) References(argument string) []string {
+	var expr struct {
+		References []string `json:"references"`
+	}
+	_ = json.Unmarshal(r.Expressions[argument], &expr)
+	return expr.References
+}
+
+// Resource returns the resource block of the module with the address
+// relative to the module ("aws_lb.main").
+func (m ConfigModule) Resource(address string) (ConfigResource, bool) {
+	for _, r := range m.Resources {
+		if r.Address == address {
+			return r, true
+		}
+	}
+	return ConfigResource{}, false
+}
+
+// ModuleCall is a module block.
+type ModuleCall struct {
+	Source string       `json:"source"`
+	Module ConfigModule `json:"module"`
+}
+
+// ConfigVariable is a variable block.
+type ConfigVariable struct {
+	// Default is the raw JSON default; nil for required variables.
+	Default     json.RawMessage `json:"default"`
+	Description string          `json:"description"`
+	Sensitive   bool            `json:"sensitive"`
+}
+
+// Required reports whether the variable has no default.
+func (v ConfigVariable) Required() bool { return v.Default == nil }
+
+// DefaultValue decodes the default; it is nil for required variables.
+func (v ConfigVariable) DefaultValue() any {
+	var out any
+	if v.Default != nil {
+		_ = json.Unmarshal(v.Default, &out)
+	}
+	return out
+}
+
+// Module returns the configuration of the module at address
+// ("module.a.module.b"; "" for the root module).
+func (p *Plan) Module(address string) (ConfigModule, bool) {
+	if p.Configuration == nil {
+		return ConfigModule{}, false
+	}
+	m := p.Configuration.RootModule
+	if address == "" {
+		return m, true
+	}
+	parts := strings.Split(address, ".")
+	for i := 0; i < len(parts); i += 2 {
+		if parts[i] != "module" || i+1 >= len(parts) {
+			return ConfigModule{}, false
+		}
+		// Instances of counted modules share one configuration.
+		name, _, _ := strings.Cut(parts[i+1], "[")
+		call, ok := m.ModuleCalls[name]
+		if !ok {
+			return ConfigModule{}, false
+		}
+		m = call.Module
+	}
+	return m, true
+}
+
+// ModuleAddress returns the module part of a resource address:
+// "module.a" for "module.a.aws_lb.main", "" for root resources.
+func ModuleAddress(resourceAddress string) string {
+	parts := strings.Split(resourceAddress, ".")
+	end := 0
+	for i := 0; i+1 < len(parts) && parts[i] == "module"; i += 2 {
+		end = i + 2
+	}
+	return strings.Join(parts[:end], ".")
+}
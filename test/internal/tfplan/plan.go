@@ -0,0 +1,412 @@
+// Package tfplan parses the JSON representation of a Terraform plan
+// (`terraform show -json <planfile>`) and describes which resources and
+// attributes it would change.
+package tfplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Actions of a resource change as they appear in the plan.
+const (
+	ActionNoOp   = "no-op"
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionReplace is reported for ["delete","create"] and
+	// ["create","delete"].
+	ActionReplace = "replace"
+)
+
+// Plan is the part of the plan JSON format used by the tests.
+type Plan struct {
+	FormatVersion    string           `json:"format_version"`
+	TerraformVersion string           `json:"terraform_version"`
+	ResourceChanges  []ResourceChange `json:"resource_changes"`
+	ResourceDrift    []ResourceChange `json:"resource_drift"`
+	PlannedValues    *Values          `json:"planned_values"`
+	Configuration    *Configuration   `json:"configuration"`
+}
+
+// Values is the planned_values (or state values) tree.
+type Values struct {
+	RootModule Module `json:"root_module"`
+}
+
+// Module is a module in the values tree.
+type Module struct {
+	Address      string     `json:"address"`
+	Resources    []Resource `json:"resources"`
+	ChildModules []Module   `json:"child_modules"`
+}
+
+// Resource is a resource in the values tree.
+type Resource struct {
+	Address         string         `json:"address"`
+	Mode            string         `json:"mode"`
+	Type            string         `json:"type"`
+	Name            string         `json:"name"`
+	ProviderName    string         `json:"provider_name"`
+	Values          map[string]any `json:"values"`
+	SensitiveValues any            `json:"sensitive_values"`
+}
+
+// ResourceChange is one entry of resource_changes.
+type ResourceChange struct {
+	Address       string `json:"address"`
+	ModuleAddress string `json:"module_address"`
+	Mode          string `json:"mode"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	ProviderName  string `json:"provider_name"`
+	ActionReason  string `json:"action_reason"`
+	Change        Change `json:"change"`
+}
+
+// Change is the change block of a resource change.
+type Change struct {
+	Actions         []string `json:"actions"`
+	Before          any      `json:"before"`
+	After           any      `json:"after"`
+	AfterUnknown    any      `json:"after_unknown"`
+	BeforeSensitive any      `json:"before_sensitive"`
+	AfterSensitive  any      `json:"after_sensitive"`
+	ReplacePaths    [][]any  `json:"replace_paths"`
+}
+
+// Parse decodes the output of `terraform show -json <planfile>`.
+func Parse(data []byte) (*Plan, error) {
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse plan JSON: %w", err)
+	}
+	return &p, nil
+}
+
+// Changes returns the managed resource changes that are not no-ops,
+// sorted by address.
+func (p *Plan) Changes() []ResourceChange {
+	var changes []ResourceChange
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode == "data" {
+			continue
+		}
+		if a := rc.Action(); a == ActionNoOp || a == ActionRead {
+			continue
+		}
+		changes = append(changes, rc)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Address < changes[j].Address })
+	return changes
+}
+
+// Resources returns all resources of the planned values, depth first.
+func (p *Plan) Resources() []Resource {
+	if p.PlannedValues == nil {
+		return nil
+	}
+	var out []Resource
+	var walk func(m Module)
+	walk = func(m Module) {
+		out = append(out, m.Resources...)
+		for _, child := range m.ChildModules {
+			walk(child)
+		}
+	}
+	walk(p.PlannedValues.RootModule)
+	return out
+}
+
+// Action collapses the change's action list into a single action.
+func (rc ResourceChange) Action() string {
+	switch len(rc.Change.Actions) {
+	case 0:
+		return ActionNoOp
+	case 1:
+		return rc.Change.Actions[0]
+	default:
+		return ActionReplace
+	}
+}
+
+// Destroys reports whether the change deletes the existing object, either
+// outright or as part of a replacement.
+func (rc ResourceChange) Destroys() bool {
+	a := rc.Action()
+	return a == ActionDelete || a == ActionReplace
+}
+
+// AttributeChange is one changed attribute of a resource.
+type AttributeChange struct {
+	// Path is a dotted path such as "tags.Name" or "ingress[0].cidr_blocks".
+	// Attributes holding JSON documents (e.g. container_definitions) are
+	// compared structurally; their inner paths follow the attribute name.
+	Path   string
+	Before any
+	After  any
+	// Unknown is set when the new value is only known after apply.
+	Unknown bool
+	// Sensitive is set when either value is sensitive; Before and After
+	// are then nil.
+	Sensitive bool
+	// ForcesReplacement is set when Terraform reported the path in
+	// replace_paths.
+	ForcesReplacement bool
+}
+
+func (a AttributeChange) String() string {
+	var b strings.Builder
+	b.WriteString(a.Path)
+	b.WriteString(": ")
+	switch {
+	case a.Sensitive:
+		b.WriteString("(sensitive value)")
+	case a.Unknown:
+		fmt.Fprintf(&b, "%s -> (known after apply)", formatValue(a.Before))
+	default:
+		fmt.Fprintf(&b, "%s -> %s", formatValue(a.Before), formatValue(a.After))
+	}
+	if a.ForcesReplacement {
+		b.WriteString(" # forces replacement")
+	}
+	return b.String()
+}
+
+func formatValue(v any) string {
+	if v == nil {
+		return "null"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	const limit = 200
+	if len(data) > limit {
+		return string(data[:limit]) + "..."
+	}
+	return string(data)
+}
+
+// Attributes returns the attributes that differ between before and after,
+// sorted by path. Creates and deletes report every non-null attribute.
+func (rc ResourceChange) Attributes() []AttributeChange {
+	d := differ{replace: map[string]bool{}}
+	for _, p := range rc.Change.ReplacePaths {
+		d.replace[formatPath(p)] = true
+	}
+	d.diff("", rc.Change.Before, rc.Change.After, rc.Change.AfterUnknown,
+		rc.Change.BeforeSensitive, rc.Change.AfterSensitive)
+	sort.Slice(d.out, func(i, j int) bool { return d.out[i].Path < d.out[j].Path })
+	return d.out
+}
+
+type differ struct {
+	replace map[string]bool
+	out     []AttributeChange
+}
+
+func (d *differ) diff(path string, before, after, unknown, beforeSensitive, afterSensitive any) {
+	if unknown == true {
+		d.add(AttributeChange{Path: path, Before: before, Unknown: true}, beforeSensitive, afterSensitive)
+		return
+	}
+	if isTrue(beforeSensitive) || isTrue(afterSensitive) {
+		if !reflect.DeepEqual(before, after) {
+			d.out = append(d.out, AttributeChange{Path: path, Sensitive: true, ForcesReplacement: d.forces(path)})
+		}
+		return
+	}
+
+	switch b := before.(type) {
+	case map[string]any:
+		if a, ok := after.(map[string]any); ok || after == nil {
+			keys := map[string]bool{}
+			for k := range b {
+				keys[k] = true
+			}
+			for k := range a {
+				keys[k] = true
+			}
+			if um, ok := unknown.(map[string]any); ok {
+				for k := range um {
+					keys[k] = true
+				}
+			}
+			for k := range keys {
+				d.diff(join(path, k), b[k], a[k], child(unknown, k), child(beforeSensitive, k), child(afterSensitive, k))
+			}
+			return
+		}
+	case []any:
+		if a, ok := after.([]any); ok || after == nil {
+			n := max(len(b), len(a))
+			if ul, ok := unknown.([]any); ok {
+				n = max(n, len(ul))
+			}
+			for i := 0; i < n; i++ {
+				d.diff(fmt.Sprintf("%s[%d]", path, i), index(b, i), index(a, i), child(unknown, i), child(beforeSensitive, i), child(afterSensitive, i))
+			}
+			return
+		}
+	case string:
+		if a, ok := after.(string); ok && a != b {
+			if bj, aj, ok := jsonDocuments(b, a); ok {
+				d.diff(path, bj, aj, nil, nil, nil)
+				return
+			}
+		}
+	case nil:
+		// Creates: descend into the new value so that every attribute is
+		// reported individually. Empty collections are reported as a whole.
+		switch a := after.(type) {
+		case map[string]any:
+			if len(a) > 0 {
+				d.diff(path, map[string]any{}, a, unknown, beforeSensitive, afterSensitive)
+				return
+			}
+		case []any:
+			if len(a) > 0 {
+				d.diff(path, []any{}, a, unknown, beforeSensitive, afterSensitive)
+				return
+			}
+		}
+		if um, ok := unknown.(map[string]any); ok {
+			d.diff(path, map[string]any{}, nil, um, beforeSensitive, afterSensitive)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		d.out = append(d.out, AttributeChange{Path: path, Before: before, After: after, ForcesReplacement: d.forces(path)})
+	}
+}
+
+func (d *differ) add(c AttributeChange, beforeSensitive, afterSensitive any) {
+	if isTrue(beforeSensitive) || isTrue(afterSensitive) {
+		c.Before, c.Sensitive = nil, true
+	}
+	c.ForcesReplacement = d.forces(c.Path)
+	d.out = append(d.out, c)
+}
+
+// forces reports whether path or one of its parents is in replace_paths.
+func (d *differ) forces(path string) bool {
+	for p := range d.replace {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrue reports whether a sensitivity or unknown marker covers the whole
+// value.
+func isTrue(v any) bool { return v == true }
+
+func child(v any, key any) any {
+	switch c := v.(type) {
+	case map[string]any:
+		if k, ok := key.(string); ok {
+			return c[k]
+		}
+	case []any:
+		if i, ok := key.(int); ok {
+			return index(c, i)
+		}
+	case bool:
+		// A marker on a collection applies to all of its elements.
+		return c
+	}
+	return nil
+}
+
+func index(l []any, i int) any {
+	if i < len(l) {
+		return l[i]
+	}
+	return nil
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// formatPath converts a replace_paths entry (["ingress", 0, "cidr_blocks"])
+// into the dotted form used by AttributeChange.Path.
+func formatPath(p []any) string {
+	var path string
+	for _, step := range p {
+		switch s := step.(type) {
+		case string:
+			path = join(path, s)
+		case float64:
+			path = fmt.Sprintf("%s[%d]", path, int(s))
+		}
+	}
+	return path
+}
+
+// jsonDocuments decodes two strings that both hold JSON objects or arrays.
+func jsonDocuments(before, after string) (any, any, bool) {
+	decode := func(s string) (any, bool) {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+			return nil, false
+		}
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, false
+		}
+		return v, true
+	}
+	b, ok := decode(before)
+	if !ok {
+		return nil, nil, false
+	}
+	a, ok := decode(after)
+	if !ok {
+		return nil, nil, false
+	}
+	return b, a, true
+}
+
+// Describe renders changes as one block per resource listing its changed
+// attributes, suitable for test failure messages.
+func Describe(changes []ResourceChange) string {
+	var b strings.Builder
+	for _, rc := range changes {
+		fmt.Fprintf(&b, "%s will be %s", rc.Address, describeAction(rc.Action()))
+		if rc.ActionReason != "" {
+			fmt.Fprintf(&b, " (%s)", rc.ActionReason)
+		}
+		b.WriteString("\n")
+		if rc.Action() == ActionUpdate || rc.Action() == ActionReplace {
+			for _, a := range rc.Attributes() {
+				fmt.Fprintf(&b, "  ~ %s\n", a)
+			}
+		}
+	}
+	return b.String()
+}
+
+func describeAction(action string) string {
+	switch action {
+	case ActionCreate:
+		return "created"
+	case ActionUpdate:
+		return "updated in-place"
+	case ActionDelete:
+		return "destroyed"
+	case ActionReplace:
+		return "replaced"
+	}
+	return action
+}
@@ -0,0 +1,321 @@
+package tfplan
+
+import (
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// perpetualDiffPlan is a trimmed `terraform show -json` of a second plan
+// with the kinds of drift the idempotency phase is meant to catch.
+const perpetualDiffPlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.6.6",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "values": {"bucket": "logs"}}
+      ],
+      "child_modules": [
+        {
+          "address": "module.basemachina_bridge",
+          "resources": [
+            {"address": "module.basemachina_bridge.aws_ecs_task_definition.bridge", "mode": "managed", "type": "aws_ecs_task_definition", "name": "bridge", "values": {"family": "bridge"}}
+          ]
+        }
+      ]
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "data.aws_region.current",
+      "mode": "data",
+      "type": "aws_region",
+      "name": "current",
+      "change": {"actions": ["read"], "before": null, "after": {}}
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {"actions": ["no-op"], "before": {"bucket": "logs"}, "after": {"bucket": "logs"}}
+    },
+    {
+      "address": "module.basemachina_bridge.aws_ecs_task_definition.bridge",
+      "module_address": "module.basemachina_bridge",
+      "mode": "managed",
+      "type": "aws_ecs_task_definition",
+      "name": "bridge",
+      "change": {
+        "actions": ["delete", "create"],
+        "before": {
+          "family": "bridge",
+          "cpu": "256",
+          "container_definitions": "[{\"name\":\"bridge\",\"environment\":[{\"name\":\"FETCH_INTERVAL\",\"value\":\"1h\"}],\"essential\":true}]",
+          "arn": "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:1",
+          "tags": {"Name": "bridge"}
+        },
+        "after": {
+          "family": "bridge",
+          "cpu": "256",
+          "container_definitions": "[{\"name\":\"bridge\",\"environment\":[{\"name\":\"FETCH_INTERVAL\",\"value\":\"1h\"}],\"essential\":true,\"mountPoints\":[]}]",
+          "arn": null,
+          "tags": {"Name": "bridge"}
+        },
+        "after_unknown": {"arn": true, "tags": {}},
+        "before_sensitive": {"tags": {}},
+        "after_sensitive": {"tags": {}},
+        "replace_paths": [["container_definitions"]]
+      }
+    },
+    {
+      "address": "module.basemachina_bridge.google_compute_security_policy.bridge[0]",
+      "module_address": "module.basemachina_bridge",
+      "mode": "managed",
+      "type": "google_compute_security_policy",
+      "name": "bridge",
+      "change": {
+        "actions": ["update"],
+        "before": {"rule": [{"priority": 1000, "action": "allow"}, {"priority": 2147483647, "action": "deny(403)"}]},
+        "after": {"rule": [{"priority": 2147483647, "action": "deny(403)"}, {"priority": 1000, "action": "allow"}]},
+        "after_unknown": {"rule": [{}, {}]},
+        "before_sensitive": {"rule": [{}, {}]},
+        "after_sensitive": {"rule": [{}, {}]}
+      }
+    },
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "change": {
+        "actions": ["update"],
+        "before": {"password": "old-secret", "instance_class": "db.t3.micro"},
+        "after": {"password": "new-secret", "instance_class": "db.t3.micro"},
+        "after_unknown": {},
+        "before_sensitive": {"password": true},
+        "after_sensitive": {"password": true}
+      }
+    }
+  ]
+}`
+
+func parseFixture(t *testing.T) *Plan {
+	t.Helper()
+	plan, err := Parse([]byte(perpetualDiffPlan))
+	require.NoError(t, err)
+	return plan
+}
+
+func TestChangesSkipsNoOpsAndReads(t *testing.T) {
+	changes := parseFixture(t).Changes()
+
+	var addresses []string
+	for _, rc := range changes {
+		addresses = append(addresses, rc.Address)
+	}
+	assert.Equal(t, []string{
+		"aws_db_instance.main",
+		"module.basemachina_bridge.aws_ecs_task_definition.bridge",
+		"module.basemachina_bridge.google_compute_security_policy.bridge[0]",
+	}, addresses)
+
+	assert.Equal(t, ActionUpdate, changes[0].Action())
+	assert.False(t, changes[0].Destroys())
+	assert.Equal(t, ActionReplace, changes[1].Action())
+	assert.True(t, changes[1].Destroys())
+}
+
+func TestResources(t *testing.T) {
+	var addresses []string
+	for _, r := range parseFixture(t).Resources() {
+		addresses = append(addresses, r.Address)
+	}
+	assert.Equal(t, []string{"aws_s3_bucket.logs", "module.basemachina_bridge.aws_ecs_task_definition.bridge"}, addresses)
+	assert.Empty(t, (&Plan{}).Resources())
+}
+
+func TestAttributesInsideJSONDocuments(t *testing.T) {
+	taskDef := parseFixture(t).Changes()[1]
+
+	assert.Equal(t, []AttributeChange{
+		{Path: "arn", Before: "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:1", Unknown: true},
+		{Path: "container_definitions[0].mountPoints", Before: nil, After: []any{}, ForcesReplacement: true},
+	}, taskDef.Attributes())
+}
+
+func TestAttributesReorderedList(t *testing.T) {
+	policy := parseFixture(t).Changes()[2]
+
+	var paths []string
+	for _, a := range policy.Attributes() {
+		paths = append(paths, a.String())
+	}
+	assert.Equal(t, []string{
+		`rule[0].action: "allow" -> "deny(403)"`,
+		`rule[0].priority: 1000 -> 2147483647`,
+		`rule[1].action: "deny(403)" -> "allow"`,
+		`rule[1].priority: 2147483647 -> 1000`,
+	}, paths)
+}
+
+func TestAttributesSensitive(t *testing.T) {
+	db := parseFixture(t).Changes()[0]
+
+	attrs := db.Attributes()
+	require.Len(t, attrs, 1)
+	assert.Equal(t, AttributeChange{Path: "password", Sensitive: true}, attrs[0])
+	assert.Equal(t, "password: (sensitive value)", attrs[0].String())
+	assert.NotContains(t, Describe([]ResourceChange{db}), "secret")
+}
+
+func TestAttributesCreate(t *testing.T) {
+	rc := ResourceChange{Change: Change{
+		Actions:      []string{ActionCreate},
+		Before:       nil,
+		After:        map[string]any{"name": "bridge", "tags": map[string]any{"Env": "test"}, "id": nil},
+		AfterUnknown: map[string]any{"id": true},
+	}}
+	assert.Equal(t, []AttributeChange{
+		{Path: "id", Unknown: true},
+		{Path: "name", After: "bridge"},
+		{Path: "tags.Env", After: "test"},
+	}, rc.Attributes())
+}
+
+func TestDescribe(t *testing.T) {
+	out := Describe(parseFixture(t).Changes())
+
+	assert.Contains(t, out, "module.basemachina_bridge.aws_ecs_task_definition.bridge will be replaced\n")
+	assert.Contains(t, out, "  ~ container_definitions[0].mountPoints: null -> [] # forces replacement\n")
+	assert.Contains(t, out, "  ~ arn: \"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/bridge:1\" -> (known after apply)\n")
+	assert.Contains(t, out, "module.basemachina_bridge.google_compute_security_policy.bridge[0] will be updated in-place\n")
+}
+
+func TestNotIdempotentError(t *testing.T) {
+	err := &NotIdempotentError{Changes: parseFixture(t).Changes()}
+	assert.Contains(t, err.Error(), "plan after apply is not empty, 3 resource(s) would change:\n")
+	assert.Contains(t, err.Error(), "aws_db_instance.main will be updated in-place")
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("not json"))
+	assert.ErrorContains(t, err, "parse plan JSON")
+}
+
+func TestConfiguration(t *testing.T) {
+	plan, err := Parse([]byte(`{"configuration": {"root_module": {
+	  "variables": {"region": {"default": "ap-northeast-1"}},
+	  "module_calls": {"bridge": {"source": "../../modules/aws/ecs-fargate", "module": {
+	    "variables": {"tenant_id": {"sensitive": true}, "nat_gateway_id": {"default": null}},
+	    "module_calls": {"inner": {"source": "./inner", "module": {"variables": {"x": {"default": [1]}}}}}
+	  }}}
+	}}}`))
+	require.NoError(t, err)
+
+	root, ok := plan.Module("")
+	require.True(t, ok)
+	assert.Equal(t, "ap-northeast-1", root.Variables["region"].DefaultValue())
+
+	bridge, ok := plan.Module(ModuleAddress("module.bridge.aws_lb.main"))
+	require.True(t, ok)
+	assert.True(t, bridge.Variables["tenant_id"].Required())
+	assert.True(t, bridge.Variables["tenant_id"].Sensitive)
+	assert.False(t, bridge.Variables["nat_gateway_id"].Required(), "a null default is a default")
+	assert.Nil(t, bridge.Variables["nat_gateway_id"].DefaultValue())
+
+	inner, ok := plan.Module(`module.bridge.module.inner["a"]`)
+	require.True(t, ok)
+	assert.Equal(t, []any{float64(1)}, inner.Variables["x"].DefaultValue())
+
+	_, ok = plan.Module("module.missing")
+	assert.False(t, ok)
+	_, ok = (&Plan{}).Module("")
+	assert.False(t, ok)
+
+	assert.Equal(t, "", ModuleAddress("aws_lb.main"))
+	assert.Equal(t, "module.a.module.b[0]", ModuleAddress("module.a.module.b[0].aws_lb.main"))
+}
+
+func TestReferences(t *testing.T) {
+	plan, err := Parse([]byte(`{"configuration": {"root_module": {"module_calls": {"bridge": {"module": {"resources": [
+	  {"address": "aws_security_group_rule.ingress", "mode": "managed", "type": "aws_security_group_rule", "name": "ingress",
+	   "expressions": {
+	     "security_group_id": {"references": ["aws_security_group.bridge.id", "aws_security_group.bridge"]},
+	     "from_port": {"constant_value": 8080}
+	   }}
+	]}}}}}}`))
+	require.NoError(t, err)
+
+	bridge, ok := plan.Module("module.bridge")
+	require.True(t, ok)
+	rule, ok := bridge.Resource("aws_security_group_rule.ingress")
+	require.True(t, ok)
+	assert.Equal(t, []string{"aws_security_group.bridge.id", "aws_security_group.bridge"}, rule.References("security_group_id"))
+	assert.Nil(t, rule.References("from_port"))
+	assert.Nil(t, rule.References("missing"))
+
+	_, ok = bridge.Resource("aws_security_group_rule.missing")
+	assert.False(t, ok)
+}
+
+const tagsPlan = `{
+  "resource_changes": [
+    {"address": "aws_ecs_cluster.main", "mode": "managed", "type": "aws_ecs_cluster",
+     "change": {"actions": ["create"], "after": {"name": "c", "tags": {"Sentinel": "s1", "Name": "c"}}, "after_unknown": {"arn": true}}},
+    {"address": "aws_lb_listener.https", "mode": "managed", "type": "aws_lb_listener",
+     "change": {"actions": ["create"], "after": {"port": 443, "tags": null}, "after_unknown": {}}},
+    {"address": "aws_eip.nat", "mode": "managed", "type": "aws_eip",
+     "change": {"actions": ["create"], "after": {"tags": {"Sentinel": "other"}}, "after_unknown": {}}},
+    {"address": "aws_nat_gateway.main", "mode": "managed", "type": "aws_nat_gateway",
+     "change": {"actions": ["create"], "after": {}, "after_unknown": {"tags": true}}},
+    {"address": "aws_route.nat", "mode": "managed", "type": "aws_route",
+     "change": {"actions": ["create"], "after": {"route_table_id": "rtb"}, "after_unknown": {}}},
+    {"address": "aws_s3_bucket.old", "mode": "managed", "type": "aws_s3_bucket",
+     "change": {"actions": ["delete"], "before": {"tags": {}}, "after": null}},
+    {"address": "data.aws_vpc.main", "mode": "data", "type": "aws_vpc",
+     "change": {"actions": ["read"], "after": {"tags": {}}}}
+  ]
+}`
+
+func TestCheckTags(t *testing.T) {
+	plan, err := Parse([]byte(tagsPlan))
+	require.NoError(t, err)
+
+	got := plan.CheckTags("tags", map[string]string{"Sentinel": "s1"})
+	assert.Equal(t, []string{"aws_ecs_cluster.main", "aws_eip.nat", "aws_lb_listener.https", "aws_nat_gateway.main"}, got.Checked)
+
+	var misses []string
+	for _, m := range got.Misses {
+		misses = append(misses, m.String())
+	}
+	assert.Equal(t, []string{
+		"aws_eip.nat: missing Sentinel",
+		"aws_lb_listener.https: missing Sentinel",
+		"aws_nat_gateway.main: (known after apply)",
+	}, misses)
+}
+
+func TestCheckPropagation(t *testing.T) {
+	plan, err := Parse([]byte(tagsPlan))
+	require.NoError(t, err)
+	rep := report.New("tfplan", "test")
+	rec := testutil.NewRecorder(t)
+
+	rec.Run(func() { checkPropagation(rec, rep, plan, "tags", map[string]string{"Sentinel": "s1"}) })
+
+	assert.Equal(t, []string{
+		"var.tags does not reach aws_eip.nat: missing Sentinel",
+		"var.tags does not reach aws_lb_listener.https: missing Sentinel",
+		"var.tags does not reach aws_nat_gateway.main: (known after apply)",
+	}, rec.Errors())
+	phase := rep.Phase("tag_propagation")
+	require.NotNil(t, phase)
+	assert.Equal(t, report.OutcomeFailed, phase.Outcome)
+	assert.Equal(t, "3 of 4 taggable resource(s) miss the sentinel tags", phase.Error)
+	assert.Equal(t, map[string]float64{"taggable_resources": 4, "untagged_resources": 3}, phase.Metrics)
+}
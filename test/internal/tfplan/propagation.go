@@ -0,0 +1,80 @@
+package tfplan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/report"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// propagation names the phase and metrics of a CheckPropagation attribute.
+type propagation struct {
+	phase     string
+	supported string
+	missed    string
+	// adjective describes a resource that supports the attribute
+	adjective string
+}
+
+var propagations = map[string]propagation{
+	"tags": {
+		phase:     "tag_propagation",
+		supported: "taggable_resources",
+		missed:    "untagged_resources",
+		adjective: "taggable",
+	},
+	"labels": {
+		phase:     "label_propagation",
+		supported: "labelable_resources",
+		missed:    "unlabeled_resources",
+		adjective: "labelable",
+	},
+}
+
+// CheckPropagation plans options, whose var.<attribute> is set to
+// sentinel, and checks with CheckTags that every resource supporting
+// attribute ("tags" or "labels") receives it. Planning and checking are
+// recorded as the plan and <tag|label>_propagation phases of rep, and
+// every miss is a test error. Nothing is applied.
+func CheckPropagation(t testing.TestingT, rep *report.Report, options *terraform.Options, attribute string, sentinel map[string]string) {
+	planPhase := rep.Begin("plan")
+	_, err := terraform.InitE(t, options)
+	var result *Result
+	if err == nil {
+		result, err = Run(t, options)
+	}
+	planPhase.Finish(err)
+	if err != nil {
+		t.Fatalf("terraform plan failed: %v", err)
+		return
+	}
+	if result.Plan == nil {
+		t.Fatalf("the plan of a fresh example must create resources")
+		return
+	}
+	checkPropagation(t, rep, result.Plan, attribute, sentinel)
+}
+
+func checkPropagation(t testing.TestingT, rep *report.Report, plan *Plan, attribute string, sentinel map[string]string) {
+	p, ok := propagations[attribute]
+	if !ok {
+		t.Fatalf("no propagation check for attribute %q", attribute)
+		return
+	}
+	phase := rep.Begin(p.phase)
+	check := plan.CheckTags(attribute, sentinel)
+	phase.SetMetric(p.supported, float64(len(check.Checked)))
+	phase.SetMetric(p.missed, float64(len(check.Misses)))
+	logger.Default.Logf(t, "Checked %s of %d resource(s):\n  %s", attribute, len(check.Checked), strings.Join(check.Checked, "\n  "))
+	for _, miss := range check.Misses {
+		t.Errorf("var.%s does not reach %s", attribute, miss)
+	}
+	var err error
+	if len(check.Misses) > 0 {
+		err = fmt.Errorf("%d of %d %s resource(s) miss the sentinel %s", len(check.Misses), len(check.Checked), p.adjective, attribute)
+	}
+	phase.Finish(err)
+}
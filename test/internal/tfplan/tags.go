@@ -0,0 +1,69 @@
+package tfplan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagMiss is a resource that does not receive all expected tags.
+type TagMiss struct {
+	Address string
+	// Missing lists the expected keys that are absent or have another
+	// value.
+	Missing []string
+	// Unknown is set when the attribute is only known after apply, so the
+	// tags cannot be checked from the plan.
+	Unknown bool
+}
+
+func (m TagMiss) String() string {
+	if m.Unknown {
+		return m.Address + ": (known after apply)"
+	}
+	return fmt.Sprintf("%s: missing %s", m.Address, strings.Join(m.Missing, ", "))
+}
+
+// TagCheck is the result of CheckTags.
+type TagCheck struct {
+	// Checked lists the resources that support the attribute, sorted.
+	Checked []string
+	Misses  []TagMiss
+}
+
+// CheckTags checks that every managed resource created or updated by the
+// plan whose schema has attribute ("tags" on AWS, "labels" on Google)
+// carries all entries of want. A resource supports the attribute when the
+// planned object has it, even as null, so the provider schema decides and
+// no list of taggable types is needed.
+func (p *Plan) CheckTags(attribute string, want map[string]string) TagCheck {
+	var out TagCheck
+	for _, rc := range p.Changes() {
+		after, ok := rc.Change.After.(map[string]any)
+		if !ok {
+			continue
+		}
+		value, supported := after[attribute]
+		unknown := isTrue(child(rc.Change.AfterUnknown, attribute))
+		if !supported && !unknown {
+			continue
+		}
+		out.Checked = append(out.Checked, rc.Address)
+		if unknown {
+			out.Misses = append(out.Misses, TagMiss{Address: rc.Address, Unknown: true})
+			continue
+		}
+		got, _ := value.(map[string]any)
+		var missing []string
+		for k, v := range want {
+			if got[k] != v {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			out.Misses = append(out.Misses, TagMiss{Address: rc.Address, Missing: missing})
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package tfplan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Exit codes of `terraform plan -detailed-exitcode`.
+const (
+	ExitClean   = 0
+	ExitError   = 1
+	ExitChanges = 2
+)
+
+// Result is the outcome of Run.
+type Result struct {
+	ExitCode int
+	// Plan is nil when the plan is clean.
+	Plan *Plan
+}
+
+// Changes returns the resource changes of the plan (none when clean).
+func (r *Result) Changes() []ResourceChange {
+	if r.Plan == nil {
+		return nil
+	}
+	return r.Plan.Changes()
+}
+
+// Run runs `terraform plan -detailed-exitcode` for options and, when the
+// plan has changes, parses it with `terraform show -json`. The JSON is not
+// logged because it contains sensitive values, and the binary plan file
+// is removed.
+func Run(t testing.TestingT, options *terraform.Options) (*Result, error) {
+	tmp, err := os.MkdirTemp("", "tfplan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	planFile := filepath.Join(tmp, "tfplan")
+
+	planOptions := *options
+	planOptions.PlanFilePath = planFile
+	code, err := terraform.PlanExitCodeE(t, &planOptions)
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case ExitClean:
+		return &Result{ExitCode: code}, nil
+	case ExitChanges:
+	default:
+		return nil, fmt.Errorf("terraform plan failed with exit code %d", code)
+	}
+
+	quiet := *options
+	quiet.Logger = logger.Discard
+	raw, err := terraform.RunTerraformCommandAndGetStdoutE(t, &quiet, "show", "-json", planFile)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := Parse([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{ExitCode: code, Plan: plan}, nil
+}
+
+// NotIdempotentError is returned by CheckIdempotent when a plan right
+// after apply still has changes.
+type NotIdempotentError struct {
+	Changes []ResourceChange
+}
+
+func (e *NotIdempotentError) Error() string {
+	return fmt.Sprintf("plan after apply is not empty, %d resource(s) would change:\n%s", len(e.Changes), Describe(e.Changes))
+}
+
+// CheckIdempotent plans options against the applied state and returns a
+// *NotIdempotentError listing every changed attribute if the plan is not
+// empty. Perpetual diffs, such as re-encoded JSON documents or reordered
+// rules, show up here.
+func CheckIdempotent(t testing.TestingT, options *terraform.Options) error {
+	result, err := Run(t, options)
+	if err != nil {
+		return err
+	}
+	// -detailed-exitcode also reports output-only changes, which are not
+	// resource drift.
+	if changes := result.Changes(); len(changes) > 0 {
+		return &NotIdempotentError{Changes: changes}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+// Package upgrade supports the module upgrade tests: it finds the release
+// a module was last tagged at, lays out the example and its modules at that
+// release next to a copy of the working tree, and reports planned changes
+// that would destroy protected resources.
+package upgrade
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// TagPatterns returns the tag patterns that mark a release of the module at
+// modulePath (e.g. "modules/aws/ecs-fargate"): module-scoped tags such as
+// "modules/aws/ecs-fargate/v1.2.0" and repository-wide tags such as "v1.2.0".
+func TagPatterns(modulePath string) []string {
+	return []string{strings.TrimSuffix(modulePath, "/") + "/v*", "v*"}
+}
+
+// PreviousTag returns the nearest tag reachable from HEAD that matches one of
+// patterns. It returns "" without error when no such tag exists, e.g. before
+// the first release or in a shallow clone without tags.
+func PreviousTag(repoDir string, patterns ...string) (string, error) {
+	args := []string{"-C", repoDir, "describe", "--tags", "--abbrev=0"}
+	for _, p := range patterns {
+		args = append(args, "--match", p)
+	}
+	args = append(args, "HEAD")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := stderr.String()
+		if strings.Contains(msg, "No names found") || strings.Contains(msg, "No tags can describe") {
+			return "", nil
+		}
+		return "", fmt.Errorf("git describe: %w: %s", err, strings.TrimSpace(msg))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// FromRefEnv names the environment variable overriding the ref upgraded
+// from.
+const FromRefEnv = "TEST_UPGRADE_FROM_REF"
+
+// EnableEnv names the environment variable that must be "true" for the
+// upgrade tests to run. They apply the same example into the same VPC,
+// subnets and DNS zone as the main suites, and resources such as the ECR
+// pull-through cache rule, the S3 gateway endpoint and the Bridge DNS
+// record exist once per account or domain, so the two cannot run at once.
+const EnableEnv = "TEST_UPGRADE"
+
+// FromRef returns the ref to upgrade the module at modulePath from:
+// FromRefEnv if set, otherwise its previous release tag. It returns ""
+// without error when there is neither.
+func FromRef(repoDir, modulePath string) (string, error) {
+	if ref := os.Getenv(FromRefEnv); ref != "" {
+		return ref, nil
+	}
+	return PreviousTag(repoDir, TagPatterns(modulePath)...)
+}
+
+// TB is the subset of testing.TB used by FromRefForTest.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+	Skipf(format string, args ...any)
+}
+
+// FromRefForTest returns FromRef, failing the test when it cannot be
+// determined. It skips the test unless EnableEnv is "true" and when there
+// is nothing to upgrade from.
+func FromRefForTest(t TB, repoDir, modulePath string) string {
+	t.Helper()
+	if os.Getenv(EnableEnv) != "true" {
+		t.Skipf("%s=true is not set; the upgrade test cannot run alongside the main suite", EnableEnv)
+		return ""
+	}
+	ref, err := FromRef(repoDir, modulePath)
+	if err != nil {
+		t.Fatalf("find the ref to upgrade %s from: %v", modulePath, err)
+		return ""
+	}
+	if ref == "" {
+		t.Skipf("No release tag of %s found; nothing to upgrade from", modulePath)
+	}
+	return ref
+}
+
+// Export writes paths (relative to the repository root) as of ref into dest,
+// keeping their relative layout so that local module sources such as
+// "../../modules/aws/ecs-fargate" still resolve.
+func Export(repoDir, ref, dest string, paths ...string) error {
+	args := append([]string{"-C", repoDir, "archive", "--format=tar", ref, "--"}, paths...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git archive %s: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	return extract(&stdout, dest)
+}
+
+func extract(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q escapes the destination", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// skipCopy lists working-tree entries that belong to a local Terraform run
+// rather than to the configuration.
+func skipCopy(name string) bool {
+	return name == ".terraform" || strings.HasSuffix(name, ".tfstate") ||
+		strings.HasSuffix(name, ".tfstate.backup") || strings.HasSuffix(name, ".tfplan")
+}
+
+// Copy copies paths (relative to the repository root) from the working tree
+// into dest, skipping local Terraform state and provider caches.
+func Copy(repoDir, dest string, paths ...string) error {
+	for _, p := range paths {
+		src := filepath.Join(repoDir, p)
+		err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if skipCopy(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(repoDir, path)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dest, rel)
+			if d.IsDir() {
+				return os.MkdirAll(target, 0o755)
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, info.Mode().Perm())
+		})
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Workspace holds an example at a previous release and the same example
+// from the working tree, each next to its own copy of the modules.
+type Workspace struct {
+	// Ref is the release the previous tree was exported from.
+	Ref string
+	// Previous is the example directory as of Ref.
+	Previous string
+	// Current is the example directory copied from the working tree.
+	Current string
+}
+
+// NewWorkspace lays out example (e.g. "examples/aws-ecs-fargate") and
+// modules at ref under dir/previous and from the working tree under
+// dir/current.
+func NewWorkspace(repoDir, ref, dir, example string, modules ...string) (*Workspace, error) {
+	paths := append([]string{example}, modules...)
+	ws := &Workspace{
+		Ref:      ref,
+		Previous: filepath.Join(dir, "previous", example),
+		Current:  filepath.Join(dir, "current", example),
+	}
+	if err := Export(repoDir, ref, filepath.Join(dir, "previous"), paths...); err != nil {
+		return nil, err
+	}
+	if err := Copy(repoDir, filepath.Join(dir, "current"), paths...); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// MoveState moves the local state applied in Previous to Current, so that
+// the next plan in Current compares the working tree against the resources
+// created by the previous release.
+func (ws *Workspace) MoveState() error {
+	for _, name := range []string{"terraform.tfstate", "terraform.tfstate.backup"} {
+		src := filepath.Join(ws.Previous, name)
+		if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.Rename(src, filepath.Join(ws.Current, name)); err != nil {
+			return fmt.Errorf("move %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DeclaredVariables returns the names of the variables declared by the .tf
+// files in dir.
+func DeclaredVariables(dir string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	schema := &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}}}
+	parser := hclparse.NewParser()
+	names := map[string]bool{}
+	for _, f := range files {
+		file, diags := parser.ParseHCLFile(f)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parse %s: %s", f, diags.Error())
+		}
+		content, _, diags := file.Body.PartialContent(schema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parse %s: %s", f, diags.Error())
+		}
+		for _, b := range content.Blocks {
+			names[b.Labels[0]] = true
+		}
+	}
+	return names, nil
+}
+
+// FilterVars returns the entries of vars that are declared in dir and the
+// names of those that are not. Older releases reject variables added since,
+// so the previous release is applied with the declared subset.
+func FilterVars(vars map[string]interface{}, dir string) (map[string]interface{}, []string, error) {
+	declared, err := DeclaredVariables(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := map[string]interface{}{}
+	var dropped []string
+	for name, value := range vars {
+		if declared[name] {
+			out[name] = value
+		} else {
+			dropped = append(dropped, name)
+		}
+	}
+	return out, dropped, nil
+}
+
+// Protected returns the changes that would destroy (delete or replace) a
+// resource in protected. Entries match the address with or without an
+// instance key, so "module.x.aws_eip.nat" covers "module.x.aws_eip.nat[0]".
+func Protected(changes []tfplan.ResourceChange, protected []string) []tfplan.ResourceChange {
+	var out []tfplan.ResourceChange
+	for _, rc := range changes {
+		if !rc.Destroys() {
+			continue
+		}
+		for _, p := range protected {
+			if rc.Address == p || strings.HasPrefix(rc.Address, p+"[") {
+				out = append(out, rc)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ProtectedChangeError is returned when upgrading from Ref would destroy
+// protected resources.
+type ProtectedChangeError struct {
+	Ref     string
+	Changes []tfplan.ResourceChange
+}
+
+func (e *ProtectedChangeError) Error() string {
+	addresses := make([]string, len(e.Changes))
+	for i, rc := range e.Changes {
+		addresses[i] = rc.Address
+	}
+	return fmt.Sprintf("upgrading from %s would destroy protected resources: %s\n%s",
+		e.Ref, strings.Join(addresses, ", "), tfplan.Describe(e.Changes))
+}
+
+// Check returns a *ProtectedChangeError if changes destroy any resource in
+// protected.
+func Check(ref string, changes []tfplan.ResourceChange, protected []string) error {
+	if bad := Protected(changes, protected); len(bad) > 0 {
+		return &ProtectedChangeError{Ref: ref, Changes: bad}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/tfplan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, out)
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// newRepo creates a repository with an example and a module, committed
+// twice: v1.0.0 declares one variable, HEAD declares two.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	git(t, repo, "init", "-q")
+	write(t, filepath.Join(repo, "examples/demo/main.tf"), `module "m" { source = "../../modules/demo" }`)
+	write(t, filepath.Join(repo, "examples/demo/variables.tf"), `variable "name" { type = string }`)
+	write(t, filepath.Join(repo, "modules/demo/main.tf"), `resource "null_resource" "v1" {}`)
+	git(t, repo, "add", "-A")
+	git(t, repo, "commit", "-q", "-m", "v1")
+	git(t, repo, "tag", "v1.0.0")
+
+	write(t, filepath.Join(repo, "examples/demo/variables.tf"), `
+variable "name" { type = string }
+variable "image_tag" {
+  type    = string
+  default = "latest"
+}`)
+	write(t, filepath.Join(repo, "modules/demo/main.tf"), `resource "null_resource" "v2" {}`)
+	git(t, repo, "commit", "-q", "-am", "v2")
+	return repo
+}
+
+func TestPreviousTag(t *testing.T) {
+	repo := newRepo(t)
+
+	tag, err := PreviousTag(repo, TagPatterns("modules/demo")...)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", tag)
+
+	git(t, repo, "tag", "modules/demo/v1.1.0")
+	tag, err = PreviousTag(repo, TagPatterns("modules/demo")...)
+	require.NoError(t, err)
+	assert.Equal(t, "modules/demo/v1.1.0", tag)
+
+	tag, err = PreviousTag(repo, "release-*")
+	require.NoError(t, err)
+	assert.Empty(t, tag, "no matching tag is not an error")
+}
+
+func TestPreviousTagWithoutTags(t *testing.T) {
+	repo := t.TempDir()
+	git(t, repo, "init", "-q")
+	write(t, filepath.Join(repo, "README.md"), "demo")
+	git(t, repo, "add", "-A")
+	git(t, repo, "commit", "-q", "-m", "init")
+
+	tag, err := PreviousTag(repo, TagPatterns("modules/demo")...)
+	require.NoError(t, err)
+	assert.Empty(t, tag)
+}
+
+// skipRecorder is a TB that records skips instead of ending the test.
+type skipRecorder struct {
+	*testing.T
+	skipped string
+}
+
+func (r *skipRecorder) Skipf(format string, args ...any) { r.skipped = fmt.Sprintf(format, args...) }
+
+func TestFromRef(t *testing.T) {
+	repo := newRepo(t)
+
+	t.Setenv(FromRefEnv, "")
+	ref, err := FromRef(repo, "modules/demo")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", ref)
+
+	t.Setenv(FromRefEnv, "main")
+	ref, err = FromRef(repo, "modules/demo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", ref)
+
+	t.Setenv(FromRefEnv, "")
+	untagged := t.TempDir()
+	git(t, untagged, "init", "-q")
+	write(t, filepath.Join(untagged, "README.md"), "demo")
+	git(t, untagged, "add", "-A")
+	git(t, untagged, "commit", "-q", "-m", "init")
+	rec := &skipRecorder{T: t}
+	t.Setenv(EnableEnv, "")
+	assert.Empty(t, FromRefForTest(rec, repo, "modules/demo"))
+	assert.Equal(t, "TEST_UPGRADE=true is not set; the upgrade test cannot run alongside the main suite", rec.skipped)
+
+	t.Setenv(EnableEnv, "true")
+	assert.Equal(t, "v1.0.0", FromRefForTest(rec, repo, "modules/demo"))
+	assert.Empty(t, FromRefForTest(rec, untagged, "modules/demo"))
+	assert.Equal(t, "No release tag of modules/demo found; nothing to upgrade from", rec.skipped)
+}
+
+func TestWorkspace(t *testing.T) {
+	repo := newRepo(t)
+	// Local runs leave state and provider caches in the working tree.
+	write(t, filepath.Join(repo, "examples/demo/terraform.tfstate"), "{}")
+	write(t, filepath.Join(repo, "examples/demo/.terraform/modules/modules.json"), "{}")
+
+	ws, err := NewWorkspace(repo, "v1.0.0", t.TempDir(), "examples/demo", "modules/demo")
+	require.NoError(t, err)
+
+	old, err := os.ReadFile(filepath.Join(ws.Previous, "../../modules/demo/main.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(old), `"v1"`)
+	cur, err := os.ReadFile(filepath.Join(ws.Current, "../../modules/demo/main.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(cur), `"v2"`)
+
+	assert.NoFileExists(t, filepath.Join(ws.Current, "terraform.tfstate"))
+	assert.NoDirExists(t, filepath.Join(ws.Current, ".terraform"))
+
+	write(t, filepath.Join(ws.Previous, "terraform.tfstate"), `{"serial": 1}`)
+	require.NoError(t, ws.MoveState())
+	assert.NoFileExists(t, filepath.Join(ws.Previous, "terraform.tfstate"))
+	assert.FileExists(t, filepath.Join(ws.Current, "terraform.tfstate"))
+}
+
+func TestFilterVars(t *testing.T) {
+	repo := newRepo(t)
+	ws, err := NewWorkspace(repo, "v1.0.0", t.TempDir(), "examples/demo", "modules/demo")
+	require.NoError(t, err)
+
+	vars := map[string]interface{}{"name": "bridge", "image_tag": "v2"}
+
+	old, dropped, err := FilterVars(vars, ws.Previous)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "bridge"}, old)
+	assert.Equal(t, []string{"image_tag"}, dropped)
+
+	cur, dropped, err := FilterVars(vars, ws.Current)
+	require.NoError(t, err)
+	assert.Equal(t, vars, cur)
+	assert.Empty(t, dropped)
+}
+
+func TestCheck(t *testing.T) {
+	change := func(address string, actions ...string) tfplan.ResourceChange {
+		return tfplan.ResourceChange{Address: address, Change: tfplan.Change{Actions: actions}}
+	}
+	changes := []tfplan.ResourceChange{
+		change("aws_acm_certificate.bridge", tfplan.ActionCreate, tfplan.ActionDelete),
+		change("module.bridge.aws_ecs_task_definition.bridge", tfplan.ActionDelete, tfplan.ActionCreate),
+		change("module.bridge.aws_eip.nat[0]", tfplan.ActionDelete),
+		change("module.bridge.aws_eip.nat_extra", tfplan.ActionDelete),
+		change("module.bridge.aws_lb.main", tfplan.ActionUpdate),
+	}
+	protected := []string{"aws_acm_certificate.bridge", "module.bridge.aws_eip.nat", "module.bridge.aws_lb.main"}
+
+	var addresses []string
+	for _, rc := range Protected(changes, protected) {
+		addresses = append(addresses, rc.Address)
+	}
+	assert.Equal(t, []string{"aws_acm_certificate.bridge", "module.bridge.aws_eip.nat[0]"}, addresses)
+
+	err := Check("v1.0.0", changes, protected)
+	var pce *ProtectedChangeError
+	require.ErrorAs(t, err, &pce)
+	assert.Contains(t, err.Error(), "upgrading from v1.0.0 would destroy protected resources: aws_acm_certificate.bridge, module.bridge.aws_eip.nat[0]\n")
+	assert.Contains(t, err.Error(), "module.bridge.aws_eip.nat[0] will be destroyed\n")
+
+	assert.NoError(t, Check("v1.0.0", changes[1:2], protected))
+}
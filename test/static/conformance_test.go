@@ -0,0 +1,22 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/conformance"
+	"github.com/stretchr/testify/require"
+)
+
+// TestModuleConformance checks the .tf files of every module against the
+// house rules without running Terraform or touching a cloud account.
+func TestModuleConformance(t *testing.T) {
+	repoRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	violations, err := conformance.CheckDir(repoRoot, filepath.Join(repoRoot, "modules"))
+	require.NoError(t, err)
+	for _, v := range violations {
+		t.Error(v)
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/naming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// quickConfig runs each property on enough inputs to hit the length
+// boundaries and the rejected prefixes.
+var quickConfig = &quick.Config{MaxCount: 3000}
+
+// namePrefix generates name_prefix values: mostly valid characters, lengths
+// around the limits, and the prefixes the APIs refuse.
+type namePrefix string
+
+func (namePrefix) Generate(r *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(namePrefix(generate(r, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789---_./", []string{"internal-", "sg-", "sg", "-"})))
+}
+
+// serviceName generates service_name values the same way.
+type serviceName string
+
+func (serviceName) Generate(r *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(serviceName(generate(r, "abcdefghijklmnopqrstuvwxyz0123456789---A_", []string{"-", "1", "bridge-"})))
+}
+
+func generate(r *rand.Rand, alphabet string, starts []string) string {
+	var b strings.Builder
+	if r.Intn(8) == 0 {
+		b.WriteString(starts[r.Intn(len(starts))])
+	}
+	for n := r.Intn(40); b.Len() < n; {
+		b.WriteByte(alphabet[r.Intn(len(alphabet))])
+	}
+	return b.String()
+}
+
+// checkNames is the property shared by both modules: for any value of the
+// variable, either the module's validation rejects it before planning, or
+// every derived name is accepted by the API.
+func checkNames(t *testing.T, module *naming.Module, variable, value string) bool {
+	vars := map[string]cty.Value{variable: cty.StringVal(value)}
+	if module.Validate(vars) != nil {
+		return true
+	}
+	names, err := module.Names(vars)
+	require.NoError(t, err)
+	for _, v := range naming.Check(names) {
+		t.Errorf("%s = %q is accepted by the module but breaks a naming rule: %s", variable, value, v)
+	}
+	return !t.Failed()
+}
+
+// checkDistinct is the collision property: two deployments with different
+// accepted values never share the name of a resource of the same type.
+func checkDistinct(t *testing.T, module *naming.Module, variable, a, b string) bool {
+	if a == b {
+		return true
+	}
+	var deployments [][]naming.Name
+	for _, value := range []string{a, b} {
+		vars := map[string]cty.Value{variable: cty.StringVal(value)}
+		if module.Validate(vars) != nil {
+			return true
+		}
+		names, err := module.Names(vars)
+		require.NoError(t, err)
+		deployments = append(deployments, names)
+	}
+	for _, c := range naming.Collisions(deployments...) {
+		t.Errorf("%s = %q and %q collide: %s", variable, a, b, c)
+	}
+	return !t.Failed()
+}
+
+func loadModule(t *testing.T, dir string) *naming.Module {
+	module, err := naming.Load(dir)
+	require.NoError(t, err)
+	return module
+}
+
+func TestECSFargateResourceNames(t *testing.T) {
+	module := loadModule(t, "../../modules/aws/ecs-fargate")
+
+	// Values in use must stay accepted.
+	for _, prefix := range []string{"", "prod", "prod-", "test-abc123"} {
+		assert.NoError(t, module.Validate(map[string]cty.Value{"name_prefix": cty.StringVal(prefix)}), "name_prefix = %q", prefix)
+	}
+
+	err := quick.Check(func(p namePrefix) bool {
+		return checkNames(t, module, "name_prefix", string(p))
+	}, quickConfig)
+	assert.NoError(t, err)
+
+	err = quick.Check(func(a, b namePrefix) bool {
+		return checkDistinct(t, module, "name_prefix", string(a), string(b))
+	}, quickConfig)
+	assert.NoError(t, err)
+}
+
+func TestCloudRunResourceNames(t *testing.T) {
+	module := loadModule(t, "../../modules/gcp/cloud-run")
+
+	for _, name := range []string{"basemachina-bridge", "bridge-test-abc123"} {
+		assert.NoError(t, module.Validate(map[string]cty.Value{"service_name": cty.StringVal(name)}), "service_name = %q", name)
+	}
+
+	err := quick.Check(func(s serviceName) bool {
+		return checkNames(t, module, "service_name", string(s))
+	}, quickConfig)
+	assert.NoError(t, err)
+
+	err = quick.Check(func(a, b serviceName) bool {
+		return checkDistinct(t, module, "service_name", string(a), string(b))
+	}, quickConfig)
+	assert.NoError(t, err)
+}
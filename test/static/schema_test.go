@@ -0,0 +1,58 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/basemachina/terraform-basemachina-modules/test/internal/conformance"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTaggableTypesMatchProviderSchema checks conformance.TaggableAWS and
+// LabelableGCP against the schemas of the providers each module requires,
+// so that the aws-tags and gcp-labels rules cover every taggable and
+// labelable resource type the modules use. terraform init downloads the
+// providers, so the test is skipped when terraform is not installed.
+func TestTaggableTypesMatchProviderSchema(t *testing.T) {
+	if _, err := exec.LookPath("terraform"); err != nil {
+		t.Skip("terraform is not installed")
+	}
+	repoRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	for _, module := range []string{"modules/aws/ecs-fargate", "modules/gcp/cloud-run"} {
+		module := module
+		t.Run(module, func(t *testing.T) {
+			t.Parallel()
+			src := filepath.Join(repoRoot, module)
+			used, err := conformance.ResourceTypes(src)
+			require.NoError(t, err)
+
+			// Initialize a copy so that the module directory gets no
+			// .terraform or lock file.
+			dir := t.TempDir()
+			tfFiles, err := filepath.Glob(filepath.Join(src, "*.tf"))
+			require.NoError(t, err)
+			for _, f := range tfFiles {
+				data, err := os.ReadFile(f)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(f)), data, 0o644))
+			}
+			options := &terraform.Options{TerraformDir: dir, Logger: logger.Discard}
+			_, err = terraform.RunTerraformCommandE(t, options, "init", "-backend=false", "-input=false")
+			require.NoError(t, err)
+			raw, err := terraform.RunTerraformCommandAndGetStdoutE(t, options, "providers", "schema", "-json")
+			require.NoError(t, err)
+			schema, err := conformance.ParseSchema([]byte(raw))
+			require.NoError(t, err)
+
+			for _, problem := range conformance.CheckSchema(schema, used) {
+				t.Error(problem)
+			}
+		})
+	}
+}